@@ -28,12 +28,19 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/ai/advisor"
+	"github.com/iac-studio/engine/internal/ai/llm"
+	"github.com/iac-studio/engine/internal/ai/monitor"
 	"github.com/iac-studio/engine/internal/api"
 	"github.com/iac-studio/engine/internal/api/handlers"
+	"github.com/iac-studio/engine/internal/events"
 	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/internal/services"
 	"github.com/iac-studio/engine/pkg/config"
 	"github.com/iac-studio/engine/pkg/database"
 	"github.com/iac-studio/engine/pkg/logger"
+	"github.com/iac-studio/engine/pkg/utils"
 	"go.uber.org/zap"
 
 	_ "github.com/iac-studio/engine/docs"
@@ -63,10 +70,100 @@ func main() {
 	}
 	log.Info("Database connected successfully")
 
+	// Encryption keyring for state and credentials at rest
+	keyring, err := utils.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionKeysFile, cfg.EncryptionPrimaryKey)
+	if err != nil {
+		log.Fatal("Invalid encryption keys", zap.Error(err))
+	}
+	if keyring == nil {
+		log.Warn("ENCRYPTION_KEYS not set, Terraform state and credentials are stored unencrypted")
+	}
+
+	// Task queue client used to enqueue deployment jobs for the worker, and
+	// an inspector for the archived (dead-letter) tasks
+	redisOpt := asynq.RedisClientOpt{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+	}
+	asynqClient := asynq.NewClient(redisOpt)
+	defer asynqClient.Close()
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	projectRepo := repository.NewProjectRepository(db)
 	deploymentRepo := repository.NewDeploymentRepository(db)
+	stateRepo := repository.NewStateVersionRepository(db, keyring)
+	graphRepo := repository.NewGraphRepository(db)
+	driftRepo := repository.NewDriftRepository(db)
+	logRepo := repository.NewDeploymentLogRepository(db)
+	resourceRepo := repository.NewResourceRepository(db)
+	approvalRepo := repository.NewApprovalRepository(db)
+	policyRepo := repository.NewPolicyRepository(db)
+	recRepo := repository.NewRecommendationRepository(db)
+	anomalyRepo := repository.NewAnomalyRepository(db)
+	alertRepo := repository.NewAlertRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db, keyring)
+	cloudAccountRepo := repository.NewCloudAccountRepository(db, keyring)
+	budgetRepo := repository.NewBudgetRepository(db)
+
+	// Cost estimates are priced from an offline catalog
+	estimator, err := services.NewCostEstimator(cfg.PriceCatalogDir)
+	if err != nil {
+		log.Fatal("Failed to load price catalog", zap.Error(err))
+	}
+
+	// Events raised by API calls, such as budget overruns, go to the log,
+	// the optional webhook, the project alert rules and project webhooks
+	alertSvc := services.NewAlertService(projectRepo, alertRepo, asynqClient, monitor.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}, cfg.AlertRateLimit, cfg.AlertDedupeWindow)
+	webhookSvc := services.NewWebhookService(projectRepo, webhookRepo, asynqClient)
+	publisher := events.Multi(events.NewLogPublisher(), alertSvc, webhookSvc)
+	if cfg.EventWebhookURL != "" {
+		publisher = events.Multi(publisher, events.NewWebhookPublisher(cfg.EventWebhookURL, 10*time.Second))
+	}
+
+	// Initialize services
+	costSvc := services.NewCostService(projectRepo, graphRepo, budgetRepo, estimator)
+	stateSvc := services.NewStateService(projectRepo, deploymentRepo, stateRepo)
+	deploySvc := services.NewDeploymentService(db, projectRepo, deploymentRepo, stateRepo, logRepo, nil, asynqClient, inspector, costSvc, publisher)
+	projectSvc := services.NewProjectService(db, projectRepo, publisher)
+	driftSvc := services.NewDriftService(projectRepo, deploymentRepo, graphRepo, driftRepo, projectSvc, asynqClient)
+	taskSvc := services.NewTaskService(projectRepo, deploymentRepo, inspector)
+	inventorySvc := services.NewInventoryService(projectRepo, deploymentRepo, resourceRepo)
+	approvalSvc := services.NewApprovalService(projectRepo, deploymentRepo, approvalRepo, asynqClient)
+	policySvc := services.NewPolicyService(projectRepo, deploymentRepo, policyRepo)
+	securitySvc := services.NewSecurityService(projectRepo, graphRepo, recRepo)
+	recommendationSvc := services.NewRecommendationService(projectRepo, graphRepo, recRepo, projectSvc)
+	// anomalies are detected by the worker; the API only lists them
+	anomalySvc := services.NewAnomalyService(projectRepo, anomalyRepo, monitor.NewDetector(monitor.DefaultConfig()), publisher)
+
+	// AI features are off unless a model provider is configured
+	var adv *advisor.Advisor
+	if cfg.LLMProvider != "" {
+		client, err := llm.New(llm.Config{
+			Provider:   cfg.LLMProvider,
+			APIKey:     cfg.LLMAPIKey,
+			BaseURL:    cfg.LLMBaseURL,
+			Model:      cfg.LLMModel,
+			Timeout:    cfg.LLMTimeout,
+			MaxRetries: cfg.LLMMaxRetries,
+			OnUsage: func(provider, model string, u llm.Usage) {
+				log.Info("llm usage", zap.String("provider", provider), zap.String("model", model), zap.Int("input_tokens", u.InputTokens), zap.Int("output_tokens", u.OutputTokens))
+			},
+		})
+		if err != nil {
+			log.Fatal("Failed to configure LLM client", zap.Error(err))
+		}
+		adv = advisor.New(client, advisor.DefaultMaxRepairs)
+	}
+	aiSvc := services.NewAIService(projectRepo, deploymentRepo, graphRepo, logRepo, projectSvc, adv, cfg.LLMProvider, cfg.LLMModel)
 
 	// JWT Secret from environment
 	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
@@ -78,16 +175,44 @@ func main() {
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userRepo, jwtSecret)
 	projectsHandler := handlers.NewProjectsHandler(projectRepo, nil) // nil validator for now
-	deploymentsHandler := handlers.NewDeploymentsHandler(deploymentRepo)
+	deploymentsHandler := handlers.NewDeploymentsHandler(deploymentRepo, deploySvc)
 	graphsHandler := handlers.NewGraphsHandler()
+	stateHandler := handlers.NewStateHandler(stateSvc)
+	driftHandler := handlers.NewDriftHandler(driftSvc)
+	tasksHandler := handlers.NewTasksHandler(taskSvc)
+	inventoryHandler := handlers.NewInventoryHandler(inventorySvc)
+	approvalsHandler := handlers.NewApprovalsHandler(approvalSvc)
+	policiesHandler := handlers.NewPoliciesHandler(policySvc)
+	securityHandler := handlers.NewSecurityHandler(securitySvc)
+	costHandler := handlers.NewCostHandler(costSvc)
+	aiHandler := handlers.NewAIHandler(aiSvc)
+	recommendationsHandler := handlers.NewRecommendationsHandler(recommendationSvc)
+	anomaliesHandler := handlers.NewAnomaliesHandler(anomalySvc)
+	alertsHandler := handlers.NewAlertsHandler(alertSvc)
+	webhooksHandler := handlers.NewWebhooksHandler(webhookSvc)
+	cloudAccountsHandler := handlers.NewCloudAccountsHandler(cloudAccountRepo)
 
 	// Create router with dependencies
 	router := api.NewRouter(api.Dependencies{
-		HMACSecret:         jwtSecret,
-		AuthHandler:        authHandler,
-		ProjectsHandler:    projectsHandler,
-		DeploymentsHandler: deploymentsHandler,
-		GraphsHandler:      graphsHandler,
+		HMACSecret:             jwtSecret,
+		AuthHandler:            authHandler,
+		ProjectsHandler:        projectsHandler,
+		DeploymentsHandler:     deploymentsHandler,
+		GraphsHandler:          graphsHandler,
+		StateHandler:           stateHandler,
+		DriftHandler:           driftHandler,
+		TasksHandler:           tasksHandler,
+		InventoryHandler:       inventoryHandler,
+		ApprovalsHandler:       approvalsHandler,
+		PoliciesHandler:        policiesHandler,
+		SecurityHandler:        securityHandler,
+		CostHandler:            costHandler,
+		AIHandler:              aiHandler,
+		RecommendationsHandler: recommendationsHandler,
+		AnomaliesHandler:       anomaliesHandler,
+		AlertsHandler:          alertsHandler,
+		WebhooksHandler:        webhooksHandler,
+		CloudAccountsHandler:   cloudAccountsHandler,
 	})
 
 	// Create HTTP server
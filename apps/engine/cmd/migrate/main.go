@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/iac-studio/engine/pkg/config"
 	"github.com/iac-studio/engine/pkg/logger"
+	"github.com/iac-studio/engine/pkg/utils"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -58,5 +60,22 @@ func main() {
 	}
 
 	log.Info("migrations completed successfully")
+
+	// secrets stored before encryption was enabled are sealed now
+	keyring, err := utils.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionKeysFile, cfg.EncryptionPrimaryKey)
+	if err != nil {
+		log.Fatal("invalid encryption keys", zap.Error(err))
+	}
+	if keyring == nil {
+		log.Warn("ENCRYPTION_KEYS not set, stored secrets are left unencrypted")
+	} else {
+		for _, t := range sealTargets {
+			n, err := sealPlaintext(context.Background(), db, keyring, t.table, t.column, t.text)
+			if err != nil {
+				log.Fatal("encrypting stored secrets failed", zap.String("table", t.table), zap.Error(err))
+			}
+			log.Info("stored secrets encrypted", zap.String("table", t.table), zap.String("column", t.column), zap.Int("rows", n))
+		}
+	}
 	fmt.Fprintln(os.Stdout, "✓ All migrations completed")
 }
\ No newline at end of file
@@ -17,9 +17,23 @@ func registerModels() []interface{} {
 		&models.Project{},
 		&models.Resource{},
 		&models.Deployment{},
+		&models.StateVersion{},
+		&models.DriftReport{},
+		&models.DeploymentLog{},
+		&models.DeploymentStatusHistory{},
+		&models.DeploymentApproval{},
+		&models.PolicyRule{},
+		&models.PolicyFinding{},
+		&models.BudgetOverride{},
 		
 		// AI & Recommendations
-		// &models.Recommendation{}, // enable once Recommendation model is defined
+		&models.Recommendation{},
+		&models.Anomaly{},
+		&models.AlertChannel{},
+		&models.AlertRule{},
+		&models.AlertDelivery{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
 		
 		// Add other models as you create them
 		// &models.Workspace{},
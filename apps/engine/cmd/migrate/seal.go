@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// sealTargets are columns of secrets that rows written before encryption
+// was enabled hold in plaintext; text columns take the envelope as a string.
+var sealTargets = []struct {
+	table, column string
+	text          bool
+}{
+	{"cloud_accounts", "credentials", false},
+	{"webhooks", "secret", true},
+}
+
+// sealPlaintext encrypts the plaintext values of table.column with the
+// primary key and returns how many it sealed. Sealed values are left alone;
+// cmd/rotate-keys re-encrypts those after a key change.
+func sealPlaintext(ctx context.Context, db *gorm.DB, keyring *utils.Keyring, table, column string, text bool) (int, error) {
+	type row struct {
+		ID   uuid.UUID
+		Data []byte
+	}
+	count := 0
+	after := uuid.Nil
+	for {
+		var rows []row
+		err := db.WithContext(ctx).Table(table).
+			Select(fmt.Sprintf("id, %s AS data", column)).
+			Where(fmt.Sprintf("%s IS NOT NULL AND id > ?", column), after).
+			Order("id").Limit(100).
+			Scan(&rows).Error
+		if err != nil {
+			return count, fmt.Errorf("load %s: %w", table, err)
+		}
+		if len(rows) == 0 {
+			return count, nil
+		}
+		for _, r := range rows {
+			after = r.ID
+			if _, sealed := utils.ParseEnvelope(r.Data); sealed {
+				continue
+			}
+			ct, err := keyring.Seal(r.Data)
+			if err != nil {
+				return count, fmt.Errorf("encrypt %s %s: %w", table, r.ID, err)
+			}
+			var value any = ct
+			if text {
+				value = string(ct)
+			}
+			if err := db.WithContext(ctx).Table(table).Where("id = ?", r.ID).Update(column, value).Error; err != nil {
+				return count, fmt.Errorf("update %s %s: %w", table, r.ID, err)
+			}
+			count++
+		}
+	}
+}
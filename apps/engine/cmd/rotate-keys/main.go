@@ -0,0 +1,66 @@
+// Command rotate-keys re-encrypts Terraform state and cloud credentials with
+// the primary encryption key.
+//
+// To rotate a master key, add the new key to ENCRYPTION_KEYS, point
+// ENCRYPTION_PRIMARY_KEY at it and run this command. Once it reports no
+// remaining rows the old key can be removed from the configuration. Rows that
+// were written before encryption was enabled are sealed as well.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/iac-studio/engine/pkg/config"
+	"github.com/iac-studio/engine/pkg/database"
+	"github.com/iac-studio/engine/pkg/logger"
+	"github.com/iac-studio/engine/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "count rows that need re-encryption without writing")
+	batchSize := flag.Int("batch-size", 100, "rows loaded per query")
+	flag.Parse()
+
+	cfg := config.MustLoad()
+	log, err := logger.Init(cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		panic(err)
+	}
+	defer logger.Sync()
+
+	keyring, err := utils.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionKeysFile, cfg.EncryptionPrimaryKey)
+	if err != nil {
+		log.Fatal("invalid encryption keys", zap.Error(err))
+	}
+	if keyring == nil {
+		log.Fatal("no encryption keys configured: set ENCRYPTION_KEYS or ENCRYPTION_KEYS_FILE")
+	}
+
+	ctx := context.Background()
+	db, err := database.OpenPostgres(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("failed to connect to database", zap.Error(err))
+	}
+
+	log.Info("rotating encrypted columns", zap.String("primary_key", keyring.PrimaryKeyID()), zap.Bool("dry_run", *dryRun))
+
+	total := 0
+	for _, t := range rotationTargets {
+		n, err := rotate(ctx, db, keyring, t, *batchSize, *dryRun)
+		if err != nil {
+			log.Fatal("rotation failed", zap.String("table", t.table), zap.String("column", t.column), zap.Error(err))
+		}
+		log.Info("column rotated", zap.String("table", t.table), zap.String("column", t.column), zap.Int("rows", n))
+		total += n
+	}
+
+	if *dryRun {
+		fmt.Fprintf(os.Stdout, "%d rows need re-encryption\n", total)
+		return
+	}
+	fmt.Fprintf(os.Stdout, "✓ %d rows re-encrypted with key %s\n", total, keyring.PrimaryKeyID())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/pkg/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// rotationTarget is a column holding data sealed by utils.Keyring.
+type rotationTarget struct {
+	table  string
+	column string
+	jsonb  bool
+	text   bool
+}
+
+var rotationTargets = []rotationTarget{
+	{table: "deployments", column: "terraform_state", jsonb: true},
+	{table: "state_versions", column: "state", jsonb: true},
+	{table: "cloud_accounts", column: "credentials"},
+	{table: "webhooks", column: "secret", text: true},
+}
+
+type sealedRow struct {
+	ID   uuid.UUID
+	Data []byte
+}
+
+// rotate walks t in primary-key order and reseals every value that is
+// plaintext or sealed with a non-primary key. It returns the number of rows
+// that needed rotation.
+func rotate(ctx context.Context, db *gorm.DB, keyring *utils.Keyring, t rotationTarget, batchSize int, dryRun bool) (int, error) {
+	count := 0
+	after := uuid.Nil
+	for {
+		var rows []sealedRow
+		err := db.WithContext(ctx).Table(t.table).
+			Select(fmt.Sprintf("id, %s AS data", t.column)).
+			Where(fmt.Sprintf("%s IS NOT NULL AND id > ?", t.column), after).
+			Order("id").Limit(batchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return count, fmt.Errorf("load rows: %w", err)
+		}
+		if len(rows) == 0 {
+			return count, nil
+		}
+
+		for _, row := range rows {
+			after = row.ID
+			if !keyring.NeedsRotation(row.Data) {
+				continue
+			}
+			count++
+			if dryRun {
+				continue
+			}
+			plain, err := keyring.Open(row.Data)
+			if err != nil {
+				return count, fmt.Errorf("decrypt %s: %w", row.ID, err)
+			}
+			sealed, err := keyring.Seal(plain)
+			if err != nil {
+				return count, fmt.Errorf("encrypt %s: %w", row.ID, err)
+			}
+			var value any = sealed
+			if t.jsonb {
+				value = datatypes.JSON(sealed)
+			} else if t.text {
+				value = string(sealed)
+			}
+			if err := db.WithContext(ctx).Table(t.table).Where("id = ?", row.ID).Update(t.column, value).Error; err != nil {
+				return count, fmt.Errorf("update %s: %w", row.ID, err)
+			}
+		}
+	}
+}
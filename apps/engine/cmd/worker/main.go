@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
@@ -13,9 +15,13 @@ import (
 	"github.com/iac-studio/engine/pkg/config"
 	"github.com/iac-studio/engine/pkg/database"
 	"github.com/iac-studio/engine/pkg/logger"
+	"github.com/iac-studio/engine/pkg/utils"
 
+	"github.com/iac-studio/engine/internal/ai/monitor"
+	"github.com/iac-studio/engine/internal/events"
 	"github.com/iac-studio/engine/internal/provisioner"
 	terraformstate "github.com/iac-studio/engine/internal/provisioner/terraform"
+	"github.com/iac-studio/engine/internal/queue"
 	"github.com/iac-studio/engine/internal/queue/tasks"
 	"github.com/iac-studio/engine/internal/repository"
 	"github.com/iac-studio/engine/internal/services"
@@ -39,14 +45,21 @@ func main() {
 		log.Fatal("redis connection failed", zap.Error(err))
 	}
 
+	redisOpt := asynq.RedisClientOpt{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       0,
+	}
+
 	srv := asynq.NewServer(
-		asynq.RedisClientOpt{
-			Addr:     cfg.RedisAddr,
-			Password: cfg.RedisPassword,
-			DB:       0,
-		},
+		redisOpt,
 		asynq.Config{
 			Concurrency: cfg.AsynqConcurrency,
+			// deployment handlers only return retryable errors for
+			// transient failures; see provisioner.IsTransient
+			RetryDelayFunc: queue.RetryDelay,
+			// waiting for the project lock is not a failure
+			IsFailure: queue.IsFailure,
 		},
 	)
 
@@ -58,12 +71,29 @@ func main() {
 		logger.L().Fatal("failed to open database", zap.Error(err))
 	}
 
+	keyring, err := utils.LoadKeyring(cfg.EncryptionKeys, cfg.EncryptionKeysFile, cfg.EncryptionPrimaryKey)
+	if err != nil {
+		logger.L().Fatal("invalid encryption keys", zap.Error(err))
+	}
+	if keyring == nil {
+		logger.L().Warn("ENCRYPTION_KEYS not set, terraform state is stored unencrypted")
+	}
+
 	projectRepo := repository.NewProjectRepository(db)
 	deploymentRepo := repository.NewDeploymentRepository(db)
 	graphRepo := repository.NewGraphRepository(db)
+	stateRepo := repository.NewStateVersionRepository(db, keyring)
+	driftRepo := repository.NewDriftRepository(db)
+	logRepo := repository.NewDeploymentLogRepository(db)
+	resourceRepo := repository.NewResourceRepository(db)
+	policyRepo := repository.NewPolicyRepository(db)
+	anomalyRepo := repository.NewAnomalyRepository(db)
+	alertRepo := repository.NewAlertRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db, keyring)
+	cloudAccountRepo := repository.NewCloudAccountRepository(db, keyring)
 
 	// provisioner + state store
-	stateStore := terraformstate.NewDatabaseStateStore(deploymentRepo)
+	stateStore := terraformstate.NewDatabaseStateStore(deploymentRepo, stateRepo)
 
 	// Determine working directory for the provisioner. If WORKING_DIR is set
 	// in config, use it (create if necessary). Otherwise fall back to
@@ -80,12 +110,98 @@ func main() {
 
 	prov := provisioner.NewTerraformProvisioner(workingDir, stateStore)
 
+	// deployment logs are written in batches; Close flushes on shutdown
+	logBatcher := services.NewLogBatcher(logRepo, time.Second, 100)
+
+	// enqueues drift checks, expiry destroys and alert and webhook deliveries
+	client := asynq.NewClient(redisOpt)
+	defer client.Close()
+
+	// events go to the log, the optional webhook, the project alert rules
+	// and project webhooks
+	alertSvc := services.NewAlertService(projectRepo, alertRepo, client, monitor.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}, cfg.AlertRateLimit, cfg.AlertDedupeWindow)
+	webhookSvc := services.NewWebhookService(projectRepo, webhookRepo, client)
+	publisher := events.Multi(events.NewLogPublisher(), alertSvc, webhookSvc)
+	if cfg.EventWebhookURL != "" {
+		publisher = events.Multi(publisher, events.NewWebhookPublisher(cfg.EventWebhookURL, 10*time.Second))
+	}
+
 	// deployment service (worker doesn't need asynq client)
-	deploySvc := services.NewDeploymentService(db, projectRepo, deploymentRepo, nil)
+	deploySvc := services.NewDeploymentService(db, projectRepo, deploymentRepo, stateRepo, logRepo, logBatcher, nil, nil, nil, publisher)
+	inventorySvc := services.NewInventoryService(projectRepo, deploymentRepo, resourceRepo)
+	policySvc := services.NewPolicyService(projectRepo, deploymentRepo, policyRepo)
+
+	// deployments of a project run one at a time across all workers
+	locker := queue.NewRedisLocker(rdb, queue.DefaultLockTTL)
+	handler := tasks.NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deploymentRepo, inventorySvc, policySvc, locker, cloudAccountRepo)
+	mux.HandleFunc(queue.TypeProvision, handler.HandleProvision)
+	mux.HandleFunc(queue.TypeDestroy, handler.HandleDestroy)
+	mux.HandleFunc(queue.TypePlan, handler.HandlePlan)
+
+	// signed deliveries to project webhooks, retried with queue.RetryDelay
+	webhookHandler := tasks.NewWebhookTaskHandler(webhookSvc)
+	mux.HandleFunc(queue.TypeWebhookDeliver, webhookHandler.HandleDeliver)
+
+	// drift detection: a periodic scan enqueues checks for due deployments
+	if _, err := services.DriftInterval("", cfg.DriftDefaultSchedule); err != nil {
+		logger.L().Fatal("invalid DRIFT_DEFAULT_SCHEDULE", zap.Error(err))
+	}
+	driftHandler := tasks.NewDriftTaskHandler(prov, projectRepo, graphRepo, deploymentRepo, driftRepo, inventorySvc, client, cfg.DriftDefaultSchedule, publisher, cloudAccountRepo)
+	mux.HandleFunc(tasks.TypeDriftScan, driftHandler.HandleScan)
+	mux.HandleFunc(tasks.TypeDriftCheck, driftHandler.HandleCheck)
+
+	// periodic scans, run by the scheduler of the leading worker
+	periodic := map[string]string{tasks.TypeDriftScan: "@every " + cfg.DriftScanInterval.String()}
+
+	// ephemeral deployments: destroy once their TTL lapses
+	expiryHandler := tasks.NewExpiryTaskHandler(deploymentRepo, client, publisher, cfg.TTLWarningLead)
+	mux.HandleFunc(tasks.TypeExpiryScan, expiryHandler.HandleScan)
+	periodic[tasks.TypeExpiryScan] = "@every 1m"
+
+	// alerts on approvals pending for longer than a rule allows; alert
+	// deliveries are retried with queue.RetryDelay
+	alertHandler := tasks.NewAlertTaskHandler(alertSvc)
+	mux.HandleFunc(tasks.TypeAlertScan, alertHandler.HandleScan)
+	mux.HandleFunc(queue.TypeAlertDeliver, alertHandler.HandleDeliver)
+	periodic[tasks.TypeAlertScan] = "@every 1m"
+
+	// anomaly detection over the deployment history; 0 disables the scan
+	if cfg.AnomalyScanInterval > 0 {
+		anomalySvc := services.NewAnomalyService(projectRepo, anomalyRepo, monitor.NewDetector(monitor.DefaultConfig()), publisher)
+		anomalyHandler := tasks.NewAnomalyTaskHandler(anomalySvc, cfg.AnomalyScanInterval)
+		mux.HandleFunc(tasks.TypeAnomalyScan, anomalyHandler.HandleScan)
+		periodic[tasks.TypeAnomalyScan] = "@every " + cfg.AnomalyScanInterval.String()
+	}
 
-	handler := tasks.NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deploymentRepo)
-	mux.HandleFunc("deployment:provision", handler.HandleProvision)
-	mux.HandleFunc("deployment:destroy", handler.HandleDestroy)
+	// only one worker runs the scheduler; the others stand by to take over.
+	// A stopped asynq.Scheduler cannot start again, so each term as leader
+	// gets its own.
+	leaderCtx, stopLeading := context.WithCancel(context.Background())
+	defer stopLeading()
+	leaderDone := queue.RunWhenLeader(leaderCtx, locker, queue.SchedulerLockKey, queue.DefaultLockTTL, func(ctx context.Context) {
+		logger.L().Info("elected scheduler leader")
+		scheduler := asynq.NewScheduler(redisOpt, nil)
+		for typ, spec := range periodic {
+			if _, err := scheduler.Register(spec, asynq.NewTask(typ, nil)); err != nil {
+				logger.L().Error("register periodic task failed", zap.String("type", typ), zap.String("spec", spec), zap.Error(err))
+			}
+		}
+		if err := scheduler.Start(); err != nil {
+			logger.L().Error("scheduler start failed", zap.Error(err))
+			return
+		}
+		<-ctx.Done()
+		if cause := context.Cause(ctx); errors.Is(cause, queue.ErrLockLost) {
+			logger.L().Warn("scheduler leadership lost", zap.Error(cause))
+		}
+		scheduler.Shutdown()
+	})
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -107,5 +223,10 @@ func main() {
 
 	// Allow in-flight tasks to finish gracefully
 	// NOTE: asynq.Server's Shutdown does not take any arguments and returns no value.
+	stopLeading()
+	<-leaderDone
 	srv.Shutdown()
+	if err := logBatcher.Close(); err != nil {
+		logger.L().Warn("flush deployment logs failed", zap.Error(err))
+	}
 }
@@ -1,5 +1,132 @@
-//go:build ignore
-
+// Package advisor uses a language model to design infrastructure graphs.
+// Whatever the model proposes is checked by the compiler before it is
+// returned, so callers only ever see graphs that compile.
 package advisor
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/iac-studio/engine/internal/ai/llm"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+)
+
+// DefaultMaxRepairs is how often an invalid proposal is sent back to the
+// model with the compiler's errors before giving up.
+const DefaultMaxRepairs = 3
+
+// Advisor asks a model for infrastructure designs.
+type Advisor struct {
+	client   llm.Client
+	compiler *compiler.Compiler
+	// maxRepairs bounds the repair rounds of one request
+	maxRepairs int
+}
+
+func New(client llm.Client, maxRepairs int) *Advisor {
+	if maxRepairs < 0 {
+		maxRepairs = DefaultMaxRepairs
+	}
+	return &Advisor{client: client, compiler: compiler.NewCompiler(), maxRepairs: maxRepairs}
+}
+
+// InvalidProposalError is returned when the model did not produce a valid
+// answer within the repair rounds.
+type InvalidProposalError struct {
+	Attempts int
+	Errors   []string
+}
+
+func (e *InvalidProposalError) Error() string {
+	return fmt.Sprintf("no valid proposal after %d attempts: %s", e.Attempts, strings.Join(e.Errors, "; "))
+}
+
+// submit has the model answer prompt by calling tool. accept checks the
+// arguments of each answer; the problems it returns are sent back to the
+// model to repair, up to the advisor's repair limit, after which an
+// InvalidProposalError is returned. It returns the number of attempts and
+// adds the token usage to usage.
+func (a *Advisor) submit(ctx context.Context, system, prompt string, tool llm.Tool, usage *llm.Usage, accept func(args json.RawMessage) []string) (int, error) {
+	msgs := []llm.Message{{Role: llm.RoleUser, Content: prompt}}
+	attempts := 0
+	var problems []string
+
+	for attempts <= a.maxRepairs {
+		attempts++
+		resp, err := a.client.Chat(ctx, &llm.Request{System: system, Messages: msgs, Tools: []llm.Tool{tool}})
+		if err != nil {
+			return attempts, err
+		}
+		addUsage(usage, resp.Usage)
+		msgs = append(msgs, llm.Message{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+
+		call := findCall(resp.ToolCalls, tool.Name)
+		if call == nil {
+			problems = []string{"the answer was not submitted with " + tool.Name}
+			msgs = append(msgs, toolResults(resp.ToolCalls, tool.Name, "")...)
+			msgs = append(msgs, llm.Message{Role: llm.RoleUser, Content: "Submit your answer by calling " + tool.Name + "."})
+			continue
+		}
+		if problems = accept(call.Arguments); len(problems) == 0 {
+			return attempts, nil
+		}
+		feedback := "The answer is invalid:\n- " + strings.Join(problems, "\n- ") + "\nFix every problem and call " + tool.Name + " again with the complete answer."
+		msgs = append(msgs, toolResults(resp.ToolCalls, tool.Name, feedback)...)
+	}
+	return attempts, &InvalidProposalError{Attempts: attempts, Errors: problems}
+}
+
+func findCall(calls []llm.ToolCall, name string) *llm.ToolCall {
+	for i := range calls {
+		if calls[i].Name == name {
+			return &calls[i]
+		}
+	}
+	return nil
+}
+
+// toolResults answers every call of an assistant turn, as the APIs
+// require: the first call of tool gets feedback, any other call is
+// refused.
+func toolResults(calls []llm.ToolCall, tool, feedback string) []llm.Message {
+	out := make([]llm.Message, 0, len(calls))
+	answered := false
+	for _, c := range calls {
+		content := "Unknown tool; only " + tool + " is available."
+		if c.Name == tool && !answered {
+			content = feedback
+			answered = true
+		} else if c.Name == tool {
+			content = "Ignored; submit the answer once."
+		}
+		out = append(out, llm.Message{Role: llm.RoleTool, ToolCallID: c.ID, Content: content})
+	}
+	return out
+}
+
+// check validates g and compiles it, which catches what validation alone
+// cannot.
+func (a *Advisor) check(g compiler.Graph) []string {
+	if len(g.Nodes) == 0 {
+		return []string{"the graph has no nodes"}
+	}
+	var out []string
+	for _, err := range a.compiler.Validate(g) {
+		out = append(out, err.Error())
+	}
+	if len(out) > 0 {
+		return out
+	}
+	if _, err := a.compiler.Compile(g, compiler.CloudConfig{Provider: "aws"}); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
 
+// addUsage sums token usage over the requests of one operation.
+func addUsage(total *llm.Usage, u llm.Usage) {
+	total.InputTokens += u.InputTokens
+	total.OutputTokens += u.OutputTokens
+}
@@ -0,0 +1,151 @@
+package advisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/iac-studio/engine/internal/ai/llm"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+)
+
+// Explanation is the model's plain-language reading of a plan or a failed
+// deployment.
+type Explanation struct {
+	Summary string `json:"summary"`
+	// Cause is the likely root cause of a failure.
+	Cause string `json:"cause,omitempty"`
+	// Risks are what a plan may break or cost; plans only.
+	Risks []string `json:"risks,omitempty"`
+	// Steps are what the user should do next.
+	Steps []string `json:"steps,omitempty"`
+	// Patches fix a failure in the graph; they are checked to keep the
+	// graph valid. Failures only, and empty when the fix is outside the
+	// graph (credentials, quotas and the like).
+	Patches  []compiler.NodePatch `json:"patches,omitempty"`
+	Attempts int                  `json:"attempts"`
+	Usage    llm.Usage            `json:"usage"`
+}
+
+// Failure is what went wrong in a deployment.
+type Failure struct {
+	Graph compiler.Graph
+	// Phase is the step that failed, e.g. apply or plan.
+	Phase string
+	// Logs are the last log lines of the deployment, oldest first.
+	Logs []string
+}
+
+const submitExplanationTool = "submit_explanation"
+
+const explainPlanSystemPrompt = `You explain Terraform plans to users of IaC Studio, a visual infrastructure editor, who may not know Terraform.
+You get the project's resource graph and the plan as JSON. Explain in plain language what will be created, changed, replaced and destroyed, and call out anything risky: data loss from replacement or deletion, downtime, public exposure, notable cost.
+Answer with the submit_explanation tool: summary (two to four sentences), risks (one sentence each, may be empty) and steps (what to check before applying, may be empty). Do not propose patches.`
+
+const explainFailureSystemPrompt = `You explain failed Terraform deployments to users of IaC Studio, a visual infrastructure editor, who may not know Terraform.
+You get the project's resource graph and the deployment's last log lines. Explain in plain language what went wrong and why, without quoting raw errors at length.
+Answer with the submit_explanation tool: summary (two to four sentences), cause (one sentence), steps (what to do next) and patches.
+A patch fixes the failure in the graph: node_id names a node of the graph, set gives the properties to change and unset the properties to remove. Propose patches only when changing node properties fixes the failure; when the fix is elsewhere, such as credentials, quotas or permissions, leave patches empty and say so in steps.`
+
+var submitExplanationSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "summary": {"type": "string"},
+    "cause": {"type": "string"},
+    "risks": {"type": "array", "items": {"type": "string"}},
+    "steps": {"type": "array", "items": {"type": "string"}},
+    "patches": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "node_id": {"type": "string"},
+          "set": {"type": "object"},
+          "unset": {"type": "array", "items": {"type": "string"}},
+          "reason": {"type": "string"}
+        },
+        "required": ["node_id", "reason"]
+      }
+    }
+  },
+  "required": ["summary"]
+}`)
+
+// ExplainPlan explains plan, the JSON of a provisioner.Plan, of graph.
+func (a *Advisor) ExplainPlan(ctx context.Context, graph compiler.Graph, plan json.RawMessage) (*Explanation, error) {
+	prompt, err := explainPrompt(graph, "Plan", string(plan))
+	if err != nil {
+		return nil, err
+	}
+	return a.explain(ctx, explainPlanSystemPrompt, prompt, func(e *Explanation) []string {
+		e.Patches = nil
+		return nil
+	})
+}
+
+// ExplainFailure explains f and proposes patches to fix it. A patch must
+// name a node of the graph and must not make the graph invalid; problems
+// the graph already had do not count against it.
+func (a *Advisor) ExplainFailure(ctx context.Context, f Failure) (*Explanation, error) {
+	prompt, err := explainPrompt(f.Graph, "Last log lines of the failed "+f.Phase+" phase", strings.Join(f.Logs, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	before := map[string]bool{}
+	for _, p := range a.check(f.Graph) {
+		before[p] = true
+	}
+	return a.explain(ctx, explainFailureSystemPrompt, prompt, func(e *Explanation) []string {
+		if len(e.Patches) == 0 {
+			return nil
+		}
+		patched, err := compiler.ApplyPatches(f.Graph, e.Patches)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		var problems []string
+		for _, p := range a.check(patched) {
+			if !before[p] {
+				problems = append(problems, "the patches make the graph invalid: "+p)
+			}
+		}
+		return problems
+	})
+}
+
+// explain runs one explanation conversation; accept checks the decoded
+// answer and may adjust it.
+func (a *Advisor) explain(ctx context.Context, system, prompt string, accept func(*Explanation) []string) (*Explanation, error) {
+	tool := llm.Tool{Name: submitExplanationTool, Description: "Submit the explanation.", Parameters: submitExplanationSchema}
+	out := &Explanation{}
+	var usage llm.Usage
+	attempts, err := a.submit(ctx, system, prompt, tool, &usage, func(args json.RawMessage) []string {
+		var e Explanation
+		if err := json.Unmarshal(args, &e); err != nil {
+			return []string{"arguments are not valid JSON: " + err.Error()}
+		}
+		if strings.TrimSpace(e.Summary) == "" {
+			return []string{"summary is empty"}
+		}
+		if problems := accept(&e); len(problems) > 0 {
+			return problems
+		}
+		*out = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Attempts = attempts
+	out.Usage = usage
+	return out, nil
+}
+
+func explainPrompt(graph compiler.Graph, title, body string) (string, error) {
+	g, err := json.Marshal(graph)
+	if err != nil {
+		return "", fmt.Errorf("marshal graph: %w", err)
+	}
+	return fmt.Sprintf("Graph:\n%s\n\n%s:\n%s", g, title, body), nil
+}
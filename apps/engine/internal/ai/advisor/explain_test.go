@@ -0,0 +1,53 @@
+package advisor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainFailure(t *testing.T) {
+	graph := compiler.Graph{Nodes: []compiler.Node{
+		{ID: "web", Type: "aws_instance", Properties: map[string]interface{}{"ami": "ami-0c55b159cbfafe1f0", "instance_type": "t9.huge"}},
+	}}
+	failure := Failure{Graph: graph, Phase: "apply", Logs: []string{"[error] apply error: InvalidParameterValue: t9.huge is not a valid instance type"}}
+
+	unknownNode := `{"summary": "The instance type does not exist.", "cause": "Invalid instance type.",
+		"patches": [{"node_id": "server", "set": {"instance_type": "t3.micro"}, "reason": "Use a real type."}]}`
+	breaksGraph := `{"summary": "The instance type does not exist.", "cause": "Invalid instance type.",
+		"patches": [{"node_id": "web", "unset": ["ami"], "set": {"instance_type": "t3.micro"}, "reason": "Use a real type."}]}`
+	valid := `{"summary": "The instance type does not exist.", "cause": "Invalid instance type.", "steps": ["Apply the patch and deploy again."],
+		"patches": [{"node_id": "web", "set": {"instance_type": "t3.micro"}, "reason": "Use a real type."}]}`
+
+	client := &scriptedClient{tool: submitExplanationTool, answers: []string{unknownNode, breaksGraph, valid}}
+	exp, err := New(client, DefaultMaxRepairs).ExplainFailure(context.Background(), failure)
+	require.NoError(t, err)
+	require.Equal(t, 3, exp.Attempts)
+	require.Equal(t, "Invalid instance type.", exp.Cause)
+	require.Equal(t, []compiler.NodePatch{{NodeID: "web", Set: map[string]interface{}{"instance_type": "t3.micro"}, Reason: "Use a real type."}}, exp.Patches)
+
+	require.Contains(t, client.requests[0].Messages[0].Content, "t9.huge is not a valid instance type")
+	feedback := func(i int) string {
+		msgs := client.requests[i].Messages
+		return msgs[len(msgs)-1].Content
+	}
+	require.Contains(t, feedback(1), `unknown node "server"`)
+	require.Contains(t, feedback(2), "the patches make the graph invalid")
+}
+
+func TestExplainPlan(t *testing.T) {
+	answer := `{"summary": "Creates one bucket.", "risks": [],
+		"patches": [{"node_id": "logs", "set": {"versioning": true}, "reason": "Keep versions."}]}`
+	client := &scriptedClient{tool: submitExplanationTool, answers: []string{answer}}
+	plan := json.RawMessage(`{"changes": 1, "resource_adds": 1, "resource_changes": [{"address": "aws_s3_bucket.logs", "action": "create"}]}`)
+
+	exp, err := New(client, DefaultMaxRepairs).ExplainPlan(context.Background(), compiler.Graph{}, plan)
+	require.NoError(t, err)
+	require.Equal(t, "Creates one bucket.", exp.Summary)
+	// plans never carry patches
+	require.Empty(t, exp.Patches)
+	require.Contains(t, client.requests[0].Messages[0].Content, "aws_s3_bucket.logs")
+}
@@ -0,0 +1,120 @@
+package advisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/iac-studio/engine/internal/ai/llm"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+)
+
+// Generation is a graph the model proposed for a description.
+type Generation struct {
+	Graph compiler.Graph `json:"graph"`
+	// Summary is the model's short explanation of the design.
+	Summary string `json:"summary"`
+	// Attempts counts the proposals it took to get a valid graph.
+	Attempts int       `json:"attempts"`
+	Usage    llm.Usage `json:"usage"`
+}
+
+const submitGraphTool = "submit_graph"
+
+const generateSystemPrompt = `You design AWS infrastructure for IaC Studio, a visual Terraform editor.
+Turn the user's description into a graph of resources and submit it with the submit_graph tool. Always call the tool; do not answer in prose.
+
+Nodes have an id (a Terraform name: letters, digits, _ and -; start with a letter), a type and properties.
+Supported types and their properties:
+- aws_vpc: cidr_block
+- aws_subnet: vpc (id of an aws_vpc node), cidr_block, map_public_ip_on_launch (bool)
+- aws_security_group: name and description (required), vpc (id of an aws_vpc node), ingress (list of {from_port, to_port, protocol, cidr_blocks}; cidr_blocks is a single CIDR string such as "10.0.0.0/16")
+- aws_instance: ami and instance_type (required; use ami "ami-0c55b159cbfafe1f0" unless told otherwise), name, security_group (id of an aws_security_group node), subnet (id of an aws_subnet node), metadata_options ({"http_tokens": "required"})
+- aws_s3_bucket: bucket_name (required, globally unique, lowercase), versioning (bool), acl
+- aws_db_instance: engine, instance_class, allocated_storage (GB), storage_encrypted (bool), publicly_accessible (bool), subnet (id of an aws_subnet node)
+
+Edges {id, from, to, type} mean "from depends on to" and use type "depends_on"; references through properties need no edge.
+Prefer secure defaults: private buckets, encrypted databases that are not publicly accessible, IMDSv2 on instances, and ingress open to the internet only for 80 and 443.
+Use only the types listed above. In summary, explain the design in one or two sentences.`
+
+var submitGraphSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "summary": {"type": "string"},
+    "nodes": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "type": {"type": "string"},
+          "properties": {"type": "object"}
+        },
+        "required": ["id", "type", "properties"]
+      }
+    },
+    "edges": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "from": {"type": "string"},
+          "to": {"type": "string"},
+          "type": {"type": "string"}
+        },
+        "required": ["from", "to"]
+      }
+    }
+  },
+  "required": ["summary", "nodes", "edges"]
+}`)
+
+type submittedGraph struct {
+	Summary string `json:"summary"`
+	compiler.Graph
+}
+
+// GenerateGraph asks the model for a graph matching description. Each
+// proposal is validated and compiled; the errors of an invalid one are
+// returned to the model to repair.
+func (a *Advisor) GenerateGraph(ctx context.Context, description string) (*Generation, error) {
+	tool := llm.Tool{Name: submitGraphTool, Description: "Submit the complete infrastructure graph.", Parameters: submitGraphSchema}
+	gen := &Generation{}
+	attempts, err := a.submit(ctx, generateSystemPrompt, description, tool, &gen.Usage, func(args json.RawMessage) []string {
+		var submitted submittedGraph
+		if err := json.Unmarshal(args, &submitted); err != nil {
+			return []string{"arguments are not valid JSON: " + err.Error()}
+		}
+		normalize(&submitted.Graph)
+		if problems := a.check(submitted.Graph); len(problems) > 0 {
+			return problems
+		}
+		gen.Graph = submitted.Graph
+		gen.Summary = submitted.Summary
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	gen.Attempts = attempts
+	return gen, nil
+}
+
+// normalize fills in what the model may leave out: edge IDs and types and
+// property maps.
+func normalize(g *compiler.Graph) {
+	for i := range g.Nodes {
+		if g.Nodes[i].Properties == nil {
+			g.Nodes[i].Properties = map[string]interface{}{}
+		}
+	}
+	for i := range g.Edges {
+		if g.Edges[i].ID == "" {
+			g.Edges[i].ID = fmt.Sprintf("%s-%s", g.Edges[i].From, g.Edges[i].To)
+		}
+		if g.Edges[i].Type == "" {
+			g.Edges[i].Type = "depends_on"
+		}
+	}
+}
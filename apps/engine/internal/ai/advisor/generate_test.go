@@ -0,0 +1,79 @@
+package advisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/iac-studio/engine/internal/ai/llm"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedClient answers each Chat with the next tool arguments and
+// records the requests.
+type scriptedClient struct {
+	tool     string
+	answers  []string
+	requests []*llm.Request
+}
+
+func (c *scriptedClient) Chat(_ context.Context, req *llm.Request) (*llm.Response, error) {
+	c.requests = append(c.requests, req)
+	if len(c.answers) == 0 {
+		return nil, errors.New("no more responses")
+	}
+	args := c.answers[0]
+	c.answers = c.answers[1:]
+	return &llm.Response{
+		ToolCalls:  []llm.ToolCall{{ID: "call", Name: c.tool, Arguments: json.RawMessage(args)}},
+		StopReason: llm.StopToolUse,
+		Usage:      llm.Usage{InputTokens: 100, OutputTokens: 50},
+	}, nil
+}
+
+func (c *scriptedClient) Stream(ctx context.Context, req *llm.Request, _ func(llm.Chunk) error) (*llm.Response, error) {
+	return c.Chat(ctx, req)
+}
+
+const (
+	missingAMI = `{"summary": "A web server.", "nodes": [
+		{"id": "web", "type": "aws_instance", "properties": {"instance_type": "t3.micro", "subnet": "public"}}
+	], "edges": []}`
+	validGraph = `{"summary": "A web server in a VPC.", "nodes": [
+		{"id": "main", "type": "aws_vpc", "properties": {"cidr_block": "10.0.0.0/16"}},
+		{"id": "public", "type": "aws_subnet", "properties": {"vpc": "main", "cidr_block": "10.0.1.0/24"}},
+		{"id": "web", "type": "aws_instance", "properties": {"ami": "ami-0c55b159cbfafe1f0", "instance_type": "t3.micro", "subnet": "public"}}
+	], "edges": [{"from": "web", "to": "public"}]}`
+)
+
+func TestGenerateGraph(t *testing.T) {
+	t.Run("repairs an invalid proposal", func(t *testing.T) {
+		client := &scriptedClient{tool: submitGraphTool, answers: []string{missingAMI, validGraph}}
+		gen, err := New(client, DefaultMaxRepairs).GenerateGraph(context.Background(), "a web server")
+		require.NoError(t, err)
+		require.Equal(t, 2, gen.Attempts)
+		require.Equal(t, 300, gen.Usage.Total())
+		require.Equal(t, "A web server in a VPC.", gen.Summary)
+		require.Len(t, gen.Graph.Nodes, 3)
+		require.Equal(t, "web-public", gen.Graph.Edges[0].ID)
+		require.Equal(t, "depends_on", gen.Graph.Edges[0].Type)
+
+		// the second request carries the errors of the first proposal
+		msgs := client.requests[1].Messages
+		feedback := msgs[len(msgs)-1]
+		require.Equal(t, llm.RoleTool, feedback.Role)
+		require.Equal(t, "call", feedback.ToolCallID)
+		require.Contains(t, feedback.Content, "ami")
+		require.Contains(t, feedback.Content, `subnet "public" is not a aws_subnet node`)
+	})
+
+	t.Run("gives up after the repair limit", func(t *testing.T) {
+		client := &scriptedClient{tool: submitGraphTool, answers: []string{missingAMI, missingAMI}}
+		_, err := New(client, 1).GenerateGraph(context.Background(), "a web server")
+		var invalid *InvalidProposalError
+		require.ErrorAs(t, err, &invalid)
+		require.Equal(t, 2, invalid.Attempts)
+		require.NotEmpty(t, invalid.Errors)
+	})
+}
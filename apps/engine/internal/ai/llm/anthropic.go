@@ -1,5 +1,266 @@
-//go:build ignore
-
 package llm
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Anthropic is a client of the Anthropic Messages API.
+type Anthropic struct {
+	t       *transport
+	baseURL string
+	model   string
+}
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+)
+
+var _ Client = (*Anthropic)(nil)
+
+func NewAnthropic(cfg Config) *Anthropic {
+	base := strings.TrimRight(cfg.BaseURL, "/")
+	if base == "" {
+		base = anthropicBaseURL
+	}
+	return &Anthropic{t: newTransport(ProviderAnthropic, cfg, anthropicError), baseURL: base, model: cfg.Model}
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+func (c *Anthropic) Chat(ctx context.Context, req *Request) (*Response, error) {
+	resp, done, err := c.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	var out anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("anthropic: decode response: %w", err)
+	}
+
+	r := &Response{Model: out.Model, StopReason: anthropicStop(out.StopReason), Usage: Usage(out.Usage)}
+	for _, b := range out.Content {
+		switch b.Type {
+		case "text":
+			r.Content += b.Text
+		case "tool_use":
+			r.ToolCalls = append(r.ToolCalls, ToolCall{ID: b.ID, Name: b.Name, Arguments: b.Input})
+		}
+	}
+	c.t.report(r.Model, r.Usage)
+	return r, nil
+}
+
+// anthropicEvent is any event of a message stream; fields are set by
+// event type.
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Model string         `json:"model"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c *Anthropic) Stream(ctx context.Context, req *Request, fn func(Chunk) error) (*Response, error) {
+	resp, done, err := c.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	r := &Response{}
+	// tool calls being streamed, by content block index
+	calls := map[int]*ToolCall{}
+	args := map[int]*strings.Builder{}
+	err = readEvents(resp.Body, func(_ string, data []byte) error {
+		var ev anthropicEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("anthropic: decode event: %w", err)
+		}
+		switch ev.Type {
+		case "message_start":
+			r.Model = ev.Message.Model
+			r.Usage.InputTokens = ev.Message.Usage.InputTokens
+			r.Usage.OutputTokens = ev.Message.Usage.OutputTokens
+		case "content_block_start":
+			if ev.ContentBlock.Type == "tool_use" {
+				calls[ev.Index] = &ToolCall{ID: ev.ContentBlock.ID, Name: ev.ContentBlock.Name}
+				args[ev.Index] = &strings.Builder{}
+			}
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				r.Content += ev.Delta.Text
+				return fn(Chunk{Text: ev.Delta.Text})
+			case "input_json_delta":
+				if b, ok := args[ev.Index]; ok {
+					b.WriteString(ev.Delta.PartialJSON)
+				}
+			}
+		case "content_block_stop":
+			call, ok := calls[ev.Index]
+			if !ok {
+				return nil
+			}
+			call.Arguments = json.RawMessage(args[ev.Index].String())
+			if len(call.Arguments) == 0 {
+				call.Arguments = json.RawMessage("{}")
+			}
+			r.ToolCalls = append(r.ToolCalls, *call)
+			return fn(Chunk{ToolCall: call})
+		case "message_delta":
+			r.StopReason = anthropicStop(ev.Delta.StopReason)
+			r.Usage.OutputTokens = ev.Usage.OutputTokens
+		case "message_stop":
+			return errStreamDone
+		case "error":
+			return &APIError{Provider: ProviderAnthropic, StatusCode: http.StatusOK, Type: ev.Error.Type, Message: ev.Error.Message}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.t.report(r.Model, r.Usage)
+	return r, nil
+}
+
+func (c *Anthropic) send(ctx context.Context, req *Request, stream bool) (*http.Response, func(), error) {
+	body := anthropicRequest{
+		Model:       req.model(c.model),
+		System:      req.System,
+		MaxTokens:   req.maxTokens(),
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+	for _, t := range req.Tools {
+		body.Tools = append(body.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: t.Parameters})
+	}
+	for _, m := range req.Messages {
+		body.Messages = appendAnthropicMessage(body.Messages, m)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := http.Header{}
+	h.Set("x-api-key", c.t.cfg.APIKey)
+	h.Set("anthropic-version", anthropicVersion)
+	if stream {
+		h.Set("Accept", "text/event-stream")
+	}
+	return c.t.post(ctx, c.baseURL+"/v1/messages", h, b)
+}
+
+// appendAnthropicMessage adds m in the Messages API shape: tool results
+// are user messages, and consecutive messages of one role are merged
+// because roles must alternate.
+func appendAnthropicMessage(msgs []anthropicMessage, m Message) []anthropicMessage {
+	role := m.Role
+	var blocks []anthropicBlock
+	switch m.Role {
+	case RoleTool:
+		role = RoleUser
+		blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+	default:
+		if m.Content != "" {
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+		}
+		for _, call := range m.ToolCalls {
+			input := call.Arguments
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+		}
+	}
+	if n := len(msgs); n > 0 && msgs[n-1].Role == role {
+		msgs[n-1].Content = append(msgs[n-1].Content, blocks...)
+		return msgs
+	}
+	return append(msgs, anthropicMessage{Role: role, Content: blocks})
+}
+
+func anthropicStop(reason string) string {
+	switch reason {
+	case "tool_use":
+		return StopToolUse
+	case "max_tokens":
+		return StopMaxTokens
+	case "":
+		return ""
+	}
+	return StopEnd
+}
 
+func anthropicError(status int, body []byte) *APIError {
+	var e struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Error.Message == "" {
+		return &APIError{Provider: ProviderAnthropic, StatusCode: status, Message: strings.TrimSpace(string(body))}
+	}
+	return &APIError{Provider: ProviderAnthropic, StatusCode: status, Type: e.Error.Type, Message: e.Error.Message}
+}
@@ -0,0 +1,197 @@
+// Package llm talks to large language model APIs behind one Client
+// interface. Anthropic and OpenAI are supported; the OpenAI client also
+// serves any OpenAI-compatible API, such as a self-hosted model, through
+// its base URL.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Providers.
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+)
+
+// Roles of conversation messages. The system prompt is Request.System.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	// RoleTool messages carry the result of a tool call back to the model.
+	RoleTool = "tool"
+)
+
+// Why the model stopped generating.
+const (
+	StopEnd       = "end"
+	StopToolUse   = "tool_use"
+	StopMaxTokens = "max_tokens"
+)
+
+// Client sends chat requests to a model.
+type Client interface {
+	// Chat returns the model's complete response.
+	Chat(ctx context.Context, req *Request) (*Response, error)
+	// Stream calls fn with each piece of the response as it is generated
+	// and returns the complete response. An error from fn aborts the
+	// stream.
+	Stream(ctx context.Context, req *Request, fn func(Chunk) error) (*Response, error)
+}
+
+// Message is one turn of the conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content,omitempty"`
+	// ToolCalls are the calls an assistant message asked for.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID is the call a tool message answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// Tool is a function the model may call.
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the arguments.
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is the model asking to run a tool.
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Request is one chat completion.
+type Request struct {
+	// Model defaults to the client's model.
+	Model    string
+	System   string
+	Messages []Message
+	Tools    []Tool
+	// MaxTokens caps the response; zero uses DefaultMaxTokens.
+	MaxTokens   int
+	Temperature *float64
+}
+
+// DefaultMaxTokens caps responses of requests that set no limit.
+const DefaultMaxTokens = 4096
+
+// Usage counts the tokens of one request.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Total is the number of tokens billed.
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Response is the model's answer.
+type Response struct {
+	Model      string     `json:"model"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	StopReason string     `json:"stop_reason"`
+	Usage      Usage      `json:"usage"`
+}
+
+// Chunk is a piece of a streamed response: text, or a tool call once its
+// arguments are complete.
+type Chunk struct {
+	Text     string
+	ToolCall *ToolCall
+}
+
+// Config configures a client.
+type Config struct {
+	// Provider is ProviderAnthropic or ProviderOpenAI.
+	Provider string
+	APIKey   string
+	// BaseURL overrides the provider's API endpoint, e.g. an
+	// OpenAI-compatible server at http://localhost:8000/v1.
+	BaseURL string
+	Model   string
+	// Timeout bounds each attempt, streaming included. Zero uses
+	// DefaultTimeout.
+	Timeout time.Duration
+	// MaxRetries is how often a request failing with a rate limit, server
+	// error or network error is retried.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry; it doubles on each
+	// one. Zero uses DefaultRetryBackoff.
+	RetryBackoff time.Duration
+	// OnUsage, when set, is called with the token usage of every request.
+	OnUsage func(provider, model string, u Usage)
+	// HTTPClient defaults to a plain http.Client.
+	HTTPClient *http.Client
+}
+
+// Client defaults.
+const (
+	DefaultTimeout      = 2 * time.Minute
+	DefaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// New returns a client for cfg.Provider.
+func New(cfg Config) (Client, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("llm: model is required")
+	}
+	switch cfg.Provider {
+	case ProviderAnthropic:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("llm: anthropic needs an API key")
+		}
+		return NewAnthropic(cfg), nil
+	case ProviderOpenAI:
+		// self-hosted OpenAI-compatible servers often need no key
+		if cfg.APIKey == "" && cfg.BaseURL == "" {
+			return nil, fmt.Errorf("llm: openai needs an API key")
+		}
+		return NewOpenAI(cfg), nil
+	}
+	return nil, fmt.Errorf("llm: unknown provider %q", cfg.Provider)
+}
+
+// APIError is an error response of a model API.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s: %d %s: %s", e.Provider, e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("%s: %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the request may succeed when sent again.
+func (e *APIError) Retryable() bool {
+	return retryableStatus(e.StatusCode)
+}
+
+func (r *Request) model(def string) string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return def
+}
+
+func (r *Request) maxTokens() int {
+	if r.MaxTokens > 0 {
+		return r.MaxTokens
+	}
+	return DefaultMaxTokens
+}
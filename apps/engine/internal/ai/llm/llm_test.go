@@ -0,0 +1,277 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer serves handler and returns a config pointing at it.
+func fakeServer(t *testing.T, provider string, handler http.HandlerFunc) Config {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return Config{Provider: provider, APIKey: "test-key", BaseURL: srv.URL, Model: "test-model", RetryBackoff: time.Millisecond}
+}
+
+func decodeBody(t *testing.T, r *http.Request) map[string]interface{} {
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	return body
+}
+
+func sse(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\n\n", e)
+	}
+}
+
+var weatherTool = Tool{Name: "get_weather", Description: "Weather of a city", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}
+
+func TestAnthropic(t *testing.T) {
+	t.Run("chat with tools", func(t *testing.T) {
+		var usage Usage
+		cfg := fakeServer(t, ProviderAnthropic, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/messages", r.URL.Path)
+			require.Equal(t, "test-key", r.Header.Get("x-api-key"))
+			require.Equal(t, anthropicVersion, r.Header.Get("anthropic-version"))
+			body := decodeBody(t, r)
+			require.Equal(t, "be brief", body["system"])
+			require.Equal(t, float64(DefaultMaxTokens), body["max_tokens"])
+			require.Len(t, body["tools"], 1)
+			// the tool result is sent as a user message
+			msgs := body["messages"].([]interface{})
+			require.Len(t, msgs, 3)
+			last := msgs[2].(map[string]interface{})
+			require.Equal(t, "user", last["role"])
+			require.Equal(t, "tool_result", last["content"].([]interface{})[0].(map[string]interface{})["type"])
+
+			io.WriteString(w, `{"model":"test-model","stop_reason":"tool_use","usage":{"input_tokens":12,"output_tokens":7},
+				"content":[{"type":"text","text":"Checking."},{"type":"tool_use","id":"tu_1","name":"get_weather","input":{"city":"Accra"}}]}`)
+		})
+		cfg.OnUsage = func(provider, model string, u Usage) { usage = u }
+		c, err := New(cfg)
+		require.NoError(t, err)
+
+		resp, err := c.Chat(context.Background(), &Request{
+			System: "be brief",
+			Tools:  []Tool{weatherTool},
+			Messages: []Message{
+				{Role: RoleUser, Content: "weather?"},
+				{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "tu_0", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Lagos"}`)}}},
+				{Role: RoleTool, ToolCallID: "tu_0", Content: "sunny"},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Checking.", resp.Content)
+		require.Equal(t, StopToolUse, resp.StopReason)
+		require.Len(t, resp.ToolCalls, 1)
+		require.JSONEq(t, `{"city":"Accra"}`, string(resp.ToolCalls[0].Arguments))
+		require.Equal(t, Usage{InputTokens: 12, OutputTokens: 7}, resp.Usage)
+		require.Equal(t, resp.Usage, usage)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		cfg := fakeServer(t, ProviderAnthropic, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, true, decodeBody(t, r)["stream"])
+			sse(w,
+				`event: message_start`+"\n"+`data: {"type":"message_start","message":{"model":"test-model","usage":{"input_tokens":20,"output_tokens":1}}}`,
+				`event: content_block_start`+"\n"+`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`event: content_block_delta`+"\n"+`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+				`: ping`,
+				`event: content_block_delta`+"\n"+`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+				`event: content_block_start`+"\n"+`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tu_1","name":"get_weather","input":{}}}`,
+				`event: content_block_delta`+"\n"+`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+				`event: content_block_delta`+"\n"+`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Accra\"}"}}`,
+				`event: content_block_stop`+"\n"+`data: {"type":"content_block_stop","index":1}`,
+				`event: message_delta`+"\n"+`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":15}}`,
+				`event: message_stop`+"\n"+`data: {"type":"message_stop"}`,
+			)
+		})
+		c := NewAnthropic(cfg)
+
+		var text strings.Builder
+		var calls []ToolCall
+		resp, err := c.Stream(context.Background(), &Request{Messages: []Message{{Role: RoleUser, Content: "hi"}}}, func(ch Chunk) error {
+			text.WriteString(ch.Text)
+			if ch.ToolCall != nil {
+				calls = append(calls, *ch.ToolCall)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Hello", text.String())
+		require.Equal(t, "Hello", resp.Content)
+		require.Len(t, calls, 1)
+		require.JSONEq(t, `{"city":"Accra"}`, string(calls[0].Arguments))
+		require.Equal(t, StopToolUse, resp.StopReason)
+		require.Equal(t, Usage{InputTokens: 20, OutputTokens: 15}, resp.Usage)
+	})
+}
+
+func TestOpenAI(t *testing.T) {
+	t.Run("chat with tools", func(t *testing.T) {
+		cfg := fakeServer(t, ProviderOpenAI, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/chat/completions", r.URL.Path)
+			require.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+			body := decodeBody(t, r)
+			msgs := body["messages"].([]interface{})
+			require.Equal(t, "system", msgs[0].(map[string]interface{})["role"])
+			tools := body["tools"].([]interface{})
+			require.Equal(t, "get_weather", tools[0].(map[string]interface{})["function"].(map[string]interface{})["name"])
+
+			io.WriteString(w, `{"model":"test-model","usage":{"prompt_tokens":30,"completion_tokens":9},
+				"choices":[{"finish_reason":"tool_calls","message":{"role":"assistant","content":null,
+				"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Accra\"}"}}]}}]}`)
+		})
+		c, err := New(cfg)
+		require.NoError(t, err)
+
+		resp, err := c.Chat(context.Background(), &Request{System: "be brief", Tools: []Tool{weatherTool}, Messages: []Message{{Role: RoleUser, Content: "weather?"}}})
+		require.NoError(t, err)
+		require.Equal(t, StopToolUse, resp.StopReason)
+		require.Len(t, resp.ToolCalls, 1)
+		require.Equal(t, "call_1", resp.ToolCalls[0].ID)
+		require.JSONEq(t, `{"city":"Accra"}`, string(resp.ToolCalls[0].Arguments))
+		require.Equal(t, 39, resp.Usage.Total())
+	})
+
+	t.Run("compatible server without key", func(t *testing.T) {
+		cfg := fakeServer(t, ProviderOpenAI, func(w http.ResponseWriter, r *http.Request) {
+			require.Empty(t, r.Header.Get("Authorization"))
+			require.Equal(t, "llama-3", decodeBody(t, r)["model"])
+			io.WriteString(w, `{"model":"llama-3","choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"hi"}}]}`)
+		})
+		cfg.APIKey = ""
+		cfg.BaseURL += "/"
+		c, err := New(cfg)
+		require.NoError(t, err)
+
+		resp, err := c.Chat(context.Background(), &Request{Model: "llama-3", Messages: []Message{{Role: RoleUser, Content: "hello"}}})
+		require.NoError(t, err)
+		require.Equal(t, "hi", resp.Content)
+		require.Equal(t, StopEnd, resp.StopReason)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		cfg := fakeServer(t, ProviderOpenAI, func(w http.ResponseWriter, r *http.Request) {
+			body := decodeBody(t, r)
+			require.Equal(t, true, body["stream"])
+			require.Equal(t, map[string]interface{}{"include_usage": true}, body["stream_options"])
+			sse(w,
+				`data: {"model":"test-model","choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+				`data: {"model":"test-model","choices":[{"delta":{"content":"lo"}}]}`,
+				`data: {"model":"test-model","choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"ci"}}]}}]}`,
+				`data: {"model":"test-model","choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"Accra\"}"}}]}}]}`,
+				`data: {"model":"test-model","choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+				`data: {"model":"test-model","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":4}}`,
+				`data: [DONE]`,
+			)
+		})
+		c := NewOpenAI(cfg)
+
+		var chunks []Chunk
+		resp, err := c.Stream(context.Background(), &Request{Messages: []Message{{Role: RoleUser, Content: "hi"}}}, func(ch Chunk) error {
+			chunks = append(chunks, ch)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, chunks, 3)
+		require.Equal(t, "Hello", resp.Content)
+		require.Len(t, resp.ToolCalls, 1)
+		require.Equal(t, "get_weather", resp.ToolCalls[0].Name)
+		require.JSONEq(t, `{"city":"Accra"}`, string(resp.ToolCalls[0].Arguments))
+		require.Equal(t, StopToolUse, resp.StopReason)
+		require.Equal(t, Usage{InputTokens: 5, OutputTokens: 4}, resp.Usage)
+	})
+
+	t.Run("callback error aborts the stream", func(t *testing.T) {
+		cfg := fakeServer(t, ProviderOpenAI, func(w http.ResponseWriter, r *http.Request) {
+			sse(w, `data: {"choices":[{"delta":{"content":"a"}}]}`, `data: {"choices":[{"delta":{"content":"b"}}]}`, `data: [DONE]`)
+		})
+		stop := errors.New("stop")
+		_, err := NewOpenAI(cfg).Stream(context.Background(), &Request{}, func(Chunk) error { return stop })
+		require.ErrorIs(t, err, stop)
+	})
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("rate limits and server errors are retried", func(t *testing.T) {
+		var calls int32
+		cfg := fakeServer(t, ProviderOpenAI, func(w http.ResponseWriter, r *http.Request) {
+			switch atomic.AddInt32(&calls, 1) {
+			case 1:
+				w.WriteHeader(http.StatusTooManyRequests)
+				io.WriteString(w, `{"error":{"type":"rate_limit","message":"slow down"}}`)
+			case 2:
+				w.WriteHeader(http.StatusBadGateway)
+			default:
+				io.WriteString(w, `{"choices":[{"finish_reason":"stop","message":{"content":"ok"}}]}`)
+			}
+		})
+		cfg.MaxRetries = 2
+		resp, err := NewOpenAI(cfg).Chat(context.Background(), &Request{})
+		require.NoError(t, err)
+		require.Equal(t, "ok", resp.Content)
+		require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		var calls int32
+		cfg := fakeServer(t, ProviderAnthropic, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(529)
+			io.WriteString(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+		})
+		cfg.MaxRetries = 1
+		_, err := NewAnthropic(cfg).Chat(context.Background(), &Request{})
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, "overloaded_error", apiErr.Type)
+		require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		var calls int32
+		cfg := fakeServer(t, ProviderAnthropic, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`)
+		})
+		cfg.MaxRetries = 3
+		_, err := NewAnthropic(cfg).Chat(context.Background(), &Request{})
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("attempts time out", func(t *testing.T) {
+		var calls int32
+		cfg := fakeServer(t, ProviderOpenAI, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(200 * time.Millisecond):
+				}
+				return
+			}
+			io.WriteString(w, `{"choices":[{"finish_reason":"stop","message":{"content":"ok"}}]}`)
+		})
+		cfg.Timeout = 50 * time.Millisecond
+		cfg.MaxRetries = 1
+		resp, err := NewOpenAI(cfg).Chat(context.Background(), &Request{})
+		require.NoError(t, err)
+		require.Equal(t, "ok", resp.Content)
+	})
+}
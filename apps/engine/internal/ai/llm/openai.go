@@ -1,5 +1,273 @@
-//go:build ignore
-
 package llm
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// OpenAI is a client of the OpenAI Chat Completions API and of servers
+// compatible with it.
+type OpenAI struct {
+	t       *transport
+	baseURL string
+	model   string
+}
+
+const openAIBaseURL = "https://api.openai.com/v1"
+
+var _ Client = (*OpenAI)(nil)
+
+// NewOpenAI returns an OpenAI client. Point cfg.BaseURL at the /v1 root of
+// an OpenAI-compatible server to use a self-hosted model.
+func NewOpenAI(cfg Config) *OpenAI {
+	base := strings.TrimRight(cfg.BaseURL, "/")
+	if base == "" {
+		base = openAIBaseURL
+	}
+	return &OpenAI{t: newTransport(ProviderOpenAI, cfg, openAIError), baseURL: base, model: cfg.Model}
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+type openAIToolCall struct {
+	// Index orders tool calls in stream deltas.
+	Index    int                `json:"index"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model         string          `json:"model"`
+	Messages      []openAIMessage `json:"messages"`
+	Tools         []openAITool    `json:"tools,omitempty"`
+	MaxTokens     int             `json:"max_tokens"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+func (c *OpenAI) Chat(ctx context.Context, req *Request) (*Response, error) {
+	resp, done, err := c.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	var out openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("openai: decode response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("openai: response has no choices")
+	}
+
+	choice := out.Choices[0]
+	r := &Response{Model: out.Model, StopReason: openAIStop(choice.FinishReason)}
+	if choice.Message.Content != nil {
+		r.Content = *choice.Message.Content
+	}
+	for _, call := range choice.Message.ToolCalls {
+		r.ToolCalls = append(r.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: arguments(call.Function.Arguments)})
+	}
+	if out.Usage != nil {
+		r.Usage = Usage{InputTokens: out.Usage.PromptTokens, OutputTokens: out.Usage.CompletionTokens}
+	}
+	c.t.report(r.Model, r.Usage)
+	return r, nil
+}
+
+func (c *OpenAI) Stream(ctx context.Context, req *Request, fn func(Chunk) error) (*Response, error) {
+	resp, done, err := c.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	r := &Response{}
+	// tool call arguments arrive in pieces, keyed by the call's index
+	calls := map[int]*ToolCall{}
+	args := map[int]*strings.Builder{}
+	err = readEvents(resp.Body, func(_ string, data []byte) error {
+		if string(data) == "[DONE]" {
+			return errStreamDone
+		}
+		var chunk openAIResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("openai: decode chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			r.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			r.Usage = Usage{InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		choice := chunk.Choices[0]
+		for _, d := range choice.Delta.ToolCalls {
+			call, ok := calls[d.Index]
+			if !ok {
+				call = &ToolCall{}
+				calls[d.Index] = call
+				args[d.Index] = &strings.Builder{}
+			}
+			if d.ID != "" {
+				call.ID = d.ID
+			}
+			if d.Function.Name != "" {
+				call.Name = d.Function.Name
+			}
+			args[d.Index].WriteString(d.Function.Arguments)
+		}
+		if choice.FinishReason != "" {
+			r.StopReason = openAIStop(choice.FinishReason)
+		}
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			r.Content += *choice.Delta.Content
+			return fn(Chunk{Text: *choice.Delta.Content})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// calls are complete once the stream ends
+	indexes := make([]int, 0, len(calls))
+	for i := range calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		call := calls[i]
+		call.Arguments = arguments(args[i].String())
+		r.ToolCalls = append(r.ToolCalls, *call)
+		if err := fn(Chunk{ToolCall: call}); err != nil {
+			return nil, err
+		}
+	}
+	c.t.report(r.Model, r.Usage)
+	return r, nil
+}
+
+func (c *OpenAI) send(ctx context.Context, req *Request, stream bool) (*http.Response, func(), error) {
+	body := openAIRequest{
+		Model:       req.model(c.model),
+		MaxTokens:   req.maxTokens(),
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+	if stream {
+		body.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+	}
+	if req.System != "" {
+		system := req.System
+		body.Messages = append(body.Messages, openAIMessage{Role: "system", Content: &system})
+	}
+	for _, m := range req.Messages {
+		msg := openAIMessage{Role: m.Role, ToolCallID: m.ToolCallID}
+		if m.Content != "" || len(m.ToolCalls) == 0 {
+			content := m.Content
+			msg.Content = &content
+		}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, openAIToolCall{ID: call.ID, Type: "function", Function: openAIFunctionCall{Name: call.Name, Arguments: string(call.Arguments)}})
+		}
+		body.Messages = append(body.Messages, msg)
+	}
+	for _, t := range req.Tools {
+		tool := openAITool{Type: "function"}
+		tool.Function.Name = t.Name
+		tool.Function.Description = t.Description
+		tool.Function.Parameters = t.Parameters
+		body.Tools = append(body.Tools, tool)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := http.Header{}
+	if c.t.cfg.APIKey != "" {
+		h.Set("Authorization", "Bearer "+c.t.cfg.APIKey)
+	}
+	if stream {
+		h.Set("Accept", "text/event-stream")
+	}
+	return c.t.post(ctx, c.baseURL+"/chat/completions", h, b)
+}
+
+// arguments keeps tool call arguments valid JSON when the model sent none.
+func arguments(s string) json.RawMessage {
+	if strings.TrimSpace(s) == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(s)
+}
+
+func openAIStop(reason string) string {
+	switch reason {
+	case "tool_calls", "function_call":
+		return StopToolUse
+	case "length":
+		return StopMaxTokens
+	case "":
+		return ""
+	}
+	return StopEnd
+}
 
+func openAIError(status int, body []byte) *APIError {
+	var e struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Error.Message == "" {
+		return &APIError{Provider: ProviderOpenAI, StatusCode: status, Message: strings.TrimSpace(string(body))}
+	}
+	return &APIError{Provider: ProviderOpenAI, StatusCode: status, Type: e.Error.Type, Message: e.Error.Message}
+}
@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transport sends requests of one provider with timeouts and retries.
+type transport struct {
+	provider string
+	cfg      Config
+	http     *http.Client
+	// decodeError turns an error response body into an APIError.
+	decodeError func(status int, body []byte) *APIError
+}
+
+func newTransport(provider string, cfg Config, decodeError func(int, []byte) *APIError) *transport {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultRetryBackoff
+	}
+	hc := cfg.HTTPClient
+	if hc == nil {
+		hc = &http.Client{}
+	}
+	return &transport{provider: provider, cfg: cfg, http: hc, decodeError: decodeError}
+}
+
+// post sends body to url and returns the successful response. Rate
+// limits, server errors and network errors are retried with exponential
+// backoff. The response body stays readable until the returned func is
+// called; the attempt timeout covers reading it.
+func (t *transport) post(ctx context.Context, url string, header http.Header, body []byte) (*http.Response, func(), error) {
+	for attempt := 0; ; attempt++ {
+		actx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+		req, err := http.NewRequestWithContext(actx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		req.Header = header.Clone()
+		req.Header.Set("Content-Type", "application/json")
+
+		var wait time.Duration
+		resp, err := t.http.Do(req)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				cancel()
+				return nil, nil, ctx.Err()
+			}
+		case resp.StatusCode < 300:
+			return resp, func() { resp.Body.Close(); cancel() }, nil
+		default:
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			resp.Body.Close()
+			apiErr := t.decodeError(resp.StatusCode, b)
+			if !apiErr.Retryable() {
+				cancel()
+				return nil, nil, apiErr
+			}
+			err = apiErr
+			wait = retryAfter(resp.Header)
+		}
+		cancel()
+
+		if attempt >= t.cfg.MaxRetries {
+			return nil, nil, err
+		}
+		if wait == 0 {
+			wait = t.backoff(attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff doubles the wait on each attempt, with up to 20% jitter so
+// clients rate limited together do not retry together.
+func (t *transport) backoff(attempt int) time.Duration {
+	d := t.cfg.RetryBackoff << attempt
+	if d <= 0 || d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// report passes the usage of a request to the OnUsage hook.
+func (t *transport) report(model string, u Usage) {
+	if t.cfg.OnUsage != nil {
+		t.cfg.OnUsage(t.provider, model, u)
+	}
+}
+
+func retryableStatus(status int) bool {
+	// 529 is Anthropic's "overloaded"
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+// retryAfter reads the Retry-After header, in seconds.
+func retryAfter(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	d := time.Duration(secs) * time.Second
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d
+}
+
+// errStreamDone ends readEvents early without an error.
+var errStreamDone = errors.New("stream done")
+
+// readEvents calls fn with the event name and data of each server-sent
+// event in r.
+func readEvents(r io.Reader, fn func(event string, data []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+	var event string
+	var data bytes.Buffer
+	flush := func() error {
+		defer func() { event = ""; data.Reset() }()
+		if data.Len() == 0 {
+			return nil
+		}
+		return fn(event, data.Bytes())
+	}
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return ignoreDone(err)
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment, used as keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return ignoreDone(flush())
+}
+
+func ignoreDone(err error) error {
+	if errors.Is(err, errStreamDone) {
+		return nil
+	}
+	return err
+}
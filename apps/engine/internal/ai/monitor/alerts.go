@@ -1,5 +1,291 @@
-//go:build ignore
-
 package monitor
 
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/pkg/utils"
+)
+
+// Alert triggers a rule can fire on.
+const (
+	TriggerDeploymentFailed = "deployment_failed"
+	TriggerDriftDetected    = "drift_detected"
+	TriggerBudgetExceeded   = "budget_exceeded"
+	TriggerApprovalPending  = "approval_pending" // awaiting approval for longer than the rule allows
+	TriggerAnomalyDetected  = "anomaly_detected"
+	// TriggerTest marks notifications sent to try a channel out.
+	TriggerTest = "test"
+)
+
+// Channel types.
+const (
+	ChannelWebhook = "webhook" // JSON POST of the alert
+	ChannelSlack   = "slack"   // Slack-compatible incoming webhook
+	ChannelEmail   = "email"   // mail through the configured SMTP server
+)
+
+// MaxRecipients caps the addresses of an email channel.
+const MaxRecipients = 20
+
+// ErrSMTPDisabled is returned for email channels when no SMTP server is
+// configured.
+var ErrSMTPDisabled = errors.New("email alerts need an SMTP server")
+
+// Alert is a notification about something that happened in a project.
+type Alert struct {
+	Trigger      string    `json:"trigger"`
+	ProjectID    uuid.UUID `json:"project_id"`
+	ProjectName  string    `json:"project_name,omitempty"`
+	DeploymentID string    `json:"deployment_id,omitempty"`
+	// Subject is what the alert is about, e.g. the deployment; alerts with
+	// the same trigger and subject are duplicates within the dedupe window.
+	Subject    string                 `json:"subject"`
+	Title      string                 `json:"title"`
+	Text       string                 `json:"text"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// DedupeKey identifies the alert within a window: alerts with the same
+// trigger and subject in one window share a key.
+func (a Alert) DedupeKey(window time.Duration) string {
+	key := a.Trigger + ":" + a.Subject
+	if window > 0 {
+		key += ":" + strconv.FormatInt(a.OccurredAt.Truncate(window).Unix(), 10)
+	}
+	return key
+}
+
+// ChannelConfig is where a channel delivers: URL for webhook and Slack
+// channels, To for email ones.
+type ChannelConfig struct {
+	URL string   `json:"url,omitempty" example:"https://hooks.slack.com/services/T000/B000/XXXX"`
+	To  []string `json:"to,omitempty" example:"ops@example.com"`
+}
+
+// Validate checks the config fits a channel of type typ and that its URL
+// does not name an internal host.
+func (c ChannelConfig) Validate(typ string) error {
+	if err := c.validate(typ); err != nil {
+		return err
+	}
+	if c.URL != "" {
+		u, _ := url.Parse(c.URL)
+		if err := utils.CheckPublicHost(u.Hostname()); err != nil {
+			return errors.New("url must not point to a loopback, private or link-local address")
+		}
+	}
+	return nil
+}
+
+func (c ChannelConfig) validate(typ string) error {
+	switch typ {
+	case ChannelWebhook, ChannelSlack:
+		if len(c.To) > 0 {
+			return fmt.Errorf("%s channels take a url, not recipients", typ)
+		}
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an absolute http or https URL")
+		}
+	case ChannelEmail:
+		if c.URL != "" {
+			return errors.New("email channels take recipients, not a url")
+		}
+		if len(c.To) == 0 || len(c.To) > MaxRecipients {
+			return fmt.Errorf("email channels need 1 to %d recipients", MaxRecipients)
+		}
+		for _, to := range c.To {
+			if a, err := mail.ParseAddress(to); err != nil || a.Name != "" {
+				return fmt.Errorf("invalid recipient %q", to)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown channel type %q", typ)
+	}
+	return nil
+}
+
+// Notifier delivers alerts to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// SMTPConfig is the mail server email channels send through.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NewNotifier returns the notifier for a channel of type typ. Webhook and
+// Slack channels post with client, which should refuse internal addresses
+// when it dials; see utils.NewSafeHTTPClient.
+func NewNotifier(typ string, cfg ChannelConfig, mailer SMTPConfig, client *http.Client) (Notifier, error) {
+	if err := cfg.validate(typ); err != nil {
+		return nil, err
+	}
+	switch typ {
+	case ChannelWebhook:
+		return &webhookNotifier{url: cfg.URL, client: client}, nil
+	case ChannelSlack:
+		return &slackNotifier{url: cfg.URL, client: client}, nil
+	default:
+		if mailer.Host == "" || mailer.From == "" {
+			return nil, ErrSMTPDisabled
+		}
+		return &emailNotifier{cfg: mailer, to: cfg.To, send: sendMail}, nil
+	}
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, n.client, n.url, a)
+}
+
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, n.client, n.url, map[string]string{"text": "*" + a.Title + "*\n" + a.Text})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPTimeout bounds a mail send when the context has no earlier deadline.
+const SMTPTimeout = 30 * time.Second
+
+type emailNotifier struct {
+	cfg  SMTPConfig
+	to   []string
+	send func(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, a Alert) error {
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+	}
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	if err := n.send(ctx, addr, auth, n.cfg.From, n.to, n.message(a)); err != nil {
+		return fmt.Errorf("send alert mail: %w", err)
+	}
+	return nil
+}
+
+func (n *emailNotifier) message(a Alert) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + n.cfg.From + "\r\n")
+	b.WriteString("To: " + strings.Join(n.to, ", ") + "\r\n")
+	b.WriteString("Subject: " + oneLine(a.Title) + "\r\n")
+	b.WriteString("Date: " + a.OccurredAt.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(a.Text, "\n", "\r\n") + "\r\n")
+	return []byte(b.String())
+}
+
+// sendMail is smtp.SendMail bounded by ctx: the dial times out, the whole
+// conversation has a deadline and cancelling ctx closes the connection.
+func sendMail(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > SMTPTimeout {
+		deadline = time.Now().Add(SMTPTimeout)
+	}
+	dialer := net.Dialer{Timeout: 10 * time.Second, Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
 
+// oneLine keeps a header value from breaking out of its header.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testAlert() Alert {
+	return Alert{
+		Trigger:    TriggerDeploymentFailed,
+		ProjectID:  uuid.New(),
+		Subject:    "d1",
+		Title:      "Deployment failed\r\nBcc: x@example.com",
+		Text:       "line one\nline two",
+		OccurredAt: base.Add(20 * time.Minute),
+	}
+}
+
+func TestAlertDedupeKey(t *testing.T) {
+	a := testAlert()
+	b := a
+	b.OccurredAt = base.Add(50 * time.Minute)
+	require.Equal(t, a.DedupeKey(time.Hour), b.DedupeKey(time.Hour))
+	b.OccurredAt = base.Add(61 * time.Minute)
+	require.NotEqual(t, a.DedupeKey(time.Hour), b.DedupeKey(time.Hour))
+	require.Equal(t, "deployment_failed:d1", a.DedupeKey(0))
+}
+
+func TestChannelConfigValidate(t *testing.T) {
+	require.NoError(t, ChannelConfig{URL: "https://hooks.example.com/x"}.Validate(ChannelSlack))
+	require.Error(t, ChannelConfig{URL: "ftp://hooks.example.com/x"}.Validate(ChannelWebhook))
+	require.Error(t, ChannelConfig{URL: "https://x", To: []string{"a@example.com"}}.Validate(ChannelWebhook))
+	require.NoError(t, ChannelConfig{To: []string{"a@example.com"}}.Validate(ChannelEmail))
+	require.Error(t, ChannelConfig{To: []string{"A <a@example.com>"}}.Validate(ChannelEmail))
+	require.Error(t, ChannelConfig{}.Validate(ChannelEmail))
+	require.Error(t, ChannelConfig{URL: "https://x"}.Validate("pager"))
+	for _, internal := range []string{"http://localhost:8080/x", "http://127.0.0.1/x", "http://10.0.0.5/x", "http://169.254.169.254/latest/meta-data", "http://[::1]/x"} {
+		require.Error(t, ChannelConfig{URL: internal}.Validate(ChannelWebhook), internal)
+	}
+}
+
+func TestWebhookAndSlackNotifiers(t *testing.T) {
+	var got []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		got = append(got, body)
+	}))
+	defer srv.Close()
+
+	a := testAlert()
+	for _, typ := range []string{ChannelWebhook, ChannelSlack} {
+		n, err := NewNotifier(typ, ChannelConfig{URL: srv.URL}, SMTPConfig{}, srv.Client())
+		require.NoError(t, err)
+		require.NoError(t, n.Notify(context.Background(), a))
+	}
+	require.Len(t, got, 2)
+	require.Equal(t, TriggerDeploymentFailed, got[0]["trigger"])
+	require.Equal(t, a.ProjectID.String(), got[0]["project_id"])
+	require.Equal(t, "*"+a.Title+"*\nline one\nline two", got[1]["text"])
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	n, _ := NewNotifier(ChannelWebhook, ChannelConfig{URL: failing.URL}, SMTPConfig{}, failing.Client())
+	require.EqualError(t, n.Notify(context.Background(), a), "alert endpoint returned status 500")
+}
+
+func TestEmailNotifier(t *testing.T) {
+	_, err := NewNotifier(ChannelEmail, ChannelConfig{To: []string{"ops@example.com"}}, SMTPConfig{}, nil)
+	require.ErrorIs(t, err, ErrSMTPDisabled)
+
+	n, err := NewNotifier(ChannelEmail, ChannelConfig{To: []string{"ops@example.com", "dev@example.com"}}, SMTPConfig{Host: "mail.example.com", Port: 587, Username: "u", Password: "p", From: "alerts@example.com"}, nil)
+	require.NoError(t, err)
+	var addr string
+	var to []string
+	var msg string
+	n.(*emailNotifier).send = func(ctx context.Context, a string, auth smtp.Auth, from string, rcpt []string, m []byte) error {
+		require.NotNil(t, auth)
+		require.Equal(t, "alerts@example.com", from)
+		addr, to, msg = a, rcpt, string(m)
+		return nil
+	}
+	require.NoError(t, n.Notify(context.Background(), testAlert()))
+	require.Equal(t, "mail.example.com:587", addr)
+	require.Equal(t, []string{"ops@example.com", "dev@example.com"}, to)
+	require.Contains(t, msg, "To: ops@example.com, dev@example.com\r\n")
+	require.Contains(t, msg, "Subject: Deployment failed Bcc: x@example.com\r\n")
+	require.Contains(t, msg, "\r\n\r\nline one\r\nline two\r\n")
+}
+
+func TestSendMailHonoursContext(t *testing.T) {
+	// a server that accepts and never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = sendMail(ctx, ln.Addr().String(), nil, "alerts@example.com", []string{"ops@example.com"}, []byte("hi"))
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
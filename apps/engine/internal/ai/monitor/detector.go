@@ -1,5 +1,336 @@
-//go:build ignore
-
+// Package monitor watches deployment history for anomalies and alerts on
+// them.
 package monitor
 
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Anomaly kinds.
+const (
+	KindSlowPhase     = "slow_phase"     // a phase took far longer than the project's median
+	KindFailureBurst  = "failure_burst"  // failures piled up across projects on one provider/region
+	KindRepeatedError = "repeated_error" // the same error failed several deployments
+)
+
+// Anomaly severities.
+const (
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// Run is one stretch a deployment spent in a working status, from the
+// transition into it to the transition out of it.
+type Run struct {
+	DeploymentID uuid.UUID
+	ProjectID    uuid.UUID
+	Provider     string
+	Region       string
+	// Phase is the status of the stretch: planning, applying or destroying.
+	Phase     string
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Failed is set when the run ended in the failed status; Error is then
+	// the deployment's last error, if known.
+	Failed bool
+	Error  string
+}
+
+// Duration is how long the run took.
+func (r Run) Duration() time.Duration {
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// Anomaly is something unusual in the deployment history. Fingerprint
+// identifies it, so detecting it again is not a new anomaly.
+type Anomaly struct {
+	Kind      string
+	Severity  string
+	ProjectID uuid.UUID
+	// DeploymentID is the run the anomaly is about, or the latest one of
+	// several; uuid.Nil for failure bursts.
+	DeploymentID uuid.UUID
+	Provider     string
+	Region       string
+	Fingerprint  string
+	Message      string
+	Details      map[string]interface{}
+}
+
+// Config tunes the detector.
+type Config struct {
+	// SlowFactor flags runs slower than this multiple of the median of the
+	// project's earlier successful runs of the same phase.
+	SlowFactor float64
+	// SlowMinSamples earlier runs are needed before a median is trusted.
+	SlowMinSamples int
+	// SlowMinDuration keeps short runs from being flagged on noise.
+	SlowMinDuration time.Duration
+	// BurstFailures failures within BurstWindow on one provider and region,
+	// across at least BurstProjects projects, make a burst.
+	BurstWindow   time.Duration
+	BurstFailures int
+	BurstProjects int
+	// RepeatCount failures of one project with the same error within
+	// RepeatWindow are flagged.
+	RepeatWindow time.Duration
+	RepeatCount  int
+}
+
+// DefaultConfig returns the detector defaults.
+func DefaultConfig() Config {
+	return Config{
+		SlowFactor:      5,
+		SlowMinSamples:  5,
+		SlowMinDuration: time.Minute,
+		BurstWindow:     30 * time.Minute,
+		BurstFailures:   5,
+		BurstProjects:   2,
+		RepeatWindow:    24 * time.Hour,
+		RepeatCount:     3,
+	}
+}
+
+// Detector finds anomalies in deployment runs.
+type Detector struct {
+	cfg Config
+}
+
+func NewDetector(cfg Config) *Detector {
+	return &Detector{cfg: cfg}
+}
+
+// Window is how long before now a failure still counts towards a burst or
+// a repeated error; projects with runs in it need scanning.
+func (d *Detector) Window() time.Duration {
+	if d.cfg.BurstWindow > d.cfg.RepeatWindow {
+		return d.cfg.BurstWindow
+	}
+	return d.cfg.RepeatWindow
+}
+
+// Detect checks runs for anomalies that involve a run ended at or after
+// since. Earlier runs serve as the baseline, so runs should reach back well
+// before since. Anomalies are sorted by kind, then fingerprint.
+func (d *Detector) Detect(runs []Run, since, now time.Time) []Anomaly {
+	sorted := append([]Run(nil), runs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].EndedAt.Before(sorted[j].EndedAt) })
+
+	var out []Anomaly
+	out = append(out, d.slowPhases(sorted, since)...)
+	out = append(out, d.failureBursts(sorted, since, now)...)
+	out = append(out, d.repeatedErrors(sorted, since, now)...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	return out
+}
+
+// slowPhases flags runs much slower than the median of the project's
+// earlier successful runs of the phase. runs are sorted by end time.
+func (d *Detector) slowPhases(runs []Run, since time.Time) []Anomaly {
+	var out []Anomaly
+	baseline := map[string][]time.Duration{}
+	for _, r := range runs {
+		key := r.ProjectID.String() + "/" + r.Phase
+		prior := baseline[key]
+		if !r.EndedAt.Before(since) && len(prior) >= d.cfg.SlowMinSamples {
+			med := median(prior)
+			took := r.Duration()
+			if took >= d.cfg.SlowMinDuration && med > 0 && float64(took) > d.cfg.SlowFactor*float64(med) {
+				factor := float64(took) / float64(med)
+				severity := SeverityMedium
+				if factor >= 2*d.cfg.SlowFactor {
+					severity = SeverityHigh
+				}
+				out = append(out, Anomaly{
+					Kind:         KindSlowPhase,
+					Severity:     severity,
+					ProjectID:    r.ProjectID,
+					DeploymentID: r.DeploymentID,
+					Provider:     r.Provider,
+					Region:       r.Region,
+					Fingerprint:  fmt.Sprintf("%s:%s:%s:%d", KindSlowPhase, r.DeploymentID, r.Phase, r.StartedAt.Unix()),
+					Message:      fmt.Sprintf("%s took %s, %.1fx the project's median of %s", r.Phase, round(took), factor, round(med)),
+					Details: map[string]interface{}{
+						"phase":          r.Phase,
+						"seconds":        took.Seconds(),
+						"median_seconds": med.Seconds(),
+						"factor":         factor,
+						"samples":        len(prior),
+					},
+				})
+			}
+		}
+		if !r.Failed {
+			baseline[key] = append(prior, r.Duration())
+		}
+	}
+	return out
+}
+
+// failureBursts flags provider/regions where failures piled up across
+// projects within the burst window. Every affected project gets its own
+// anomaly, so it is only shown the projects it owns.
+func (d *Detector) failureBursts(runs []Run, since, now time.Time) []Anomaly {
+	type group struct {
+		failures int
+		recent   bool
+		projects map[uuid.UUID]int
+	}
+	groups := map[string]*group{}
+	from := now.Add(-d.cfg.BurstWindow)
+	for _, r := range runs {
+		if !r.Failed || r.EndedAt.Before(from) {
+			continue
+		}
+		key := r.Provider + "/" + r.Region
+		g := groups[key]
+		if g == nil {
+			g = &group{projects: map[uuid.UUID]int{}}
+			groups[key] = g
+		}
+		g.failures++
+		g.projects[r.ProjectID]++
+		if !r.EndedAt.Before(since) {
+			g.recent = true
+		}
+	}
+
+	var out []Anomaly
+	bucket := now.Truncate(d.cfg.BurstWindow).Unix()
+	for key, g := range groups {
+		if !g.recent || g.failures < d.cfg.BurstFailures || len(g.projects) < d.cfg.BurstProjects {
+			continue
+		}
+		provider, region, _ := strings.Cut(key, "/")
+		where := provider
+		if region != "" {
+			where += "/" + region
+		}
+		for projectID, own := range g.projects {
+			out = append(out, Anomaly{
+				Kind:        KindFailureBurst,
+				Severity:    SeverityHigh,
+				ProjectID:   projectID,
+				Provider:    provider,
+				Region:      region,
+				Fingerprint: fmt.Sprintf("%s:%s:%d:%s", KindFailureBurst, key, bucket, projectID),
+				Message:     fmt.Sprintf("%d deployments failed across %d projects on %s in the last %s", g.failures, len(g.projects), where, d.cfg.BurstWindow),
+				Details: map[string]interface{}{
+					"failures":         g.failures,
+					"projects":         len(g.projects),
+					"project_failures": own,
+					"window_seconds":   d.cfg.BurstWindow.Seconds(),
+				},
+			})
+		}
+	}
+	return out
+}
+
+// repeatedErrors flags errors that failed several of a project's
+// deployments within the repeat window. runs are sorted by end time.
+func (d *Detector) repeatedErrors(runs []Run, since, now time.Time) []Anomaly {
+	type group struct {
+		count  int
+		latest Run
+		sample string
+	}
+	groups := map[string]*group{}
+	var keys []string
+	from := now.Add(-d.cfg.RepeatWindow)
+	for _, r := range runs {
+		if !r.Failed || r.Error == "" || r.EndedAt.Before(from) {
+			continue
+		}
+		key := r.ProjectID.String() + "\x00" + NormalizeError(r.Error)
+		g := groups[key]
+		if g == nil {
+			g = &group{}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.count++
+		g.latest = r
+		g.sample = r.Error
+	}
+
+	var out []Anomaly
+	bucket := now.Truncate(d.cfg.RepeatWindow).Unix()
+	for _, key := range keys {
+		g := groups[key]
+		if g.count < d.cfg.RepeatCount || g.latest.EndedAt.Before(since) {
+			continue
+		}
+		sum := sha1.Sum([]byte(key))
+		severity := SeverityMedium
+		if g.count >= 2*d.cfg.RepeatCount {
+			severity = SeverityHigh
+		}
+		out = append(out, Anomaly{
+			Kind:         KindRepeatedError,
+			Severity:     severity,
+			ProjectID:    g.latest.ProjectID,
+			DeploymentID: g.latest.DeploymentID,
+			Provider:     g.latest.Provider,
+			Region:       g.latest.Region,
+			Fingerprint:  fmt.Sprintf("%s:%s:%d", KindRepeatedError, hex.EncodeToString(sum[:8]), bucket),
+			Message:      fmt.Sprintf("the same error failed %d deployments in the last %s: %s", g.count, d.cfg.RepeatWindow, truncate(g.sample, 200)),
+			Details: map[string]interface{}{
+				"count":          g.count,
+				"error":          truncate(g.sample, 1000),
+				"window_seconds": d.cfg.RepeatWindow.Seconds(),
+			},
+		})
+	}
+	return out
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	idPattern     = regexp.MustCompile(`(?i)\b[a-z]+-[0-9a-f]{8,}\b`)
+	numberPattern = regexp.MustCompile(`\d+`)
+	spacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeError strips what differs between occurrences of one error:
+// UUIDs, cloud resource IDs such as i-0abc..., numbers and whitespace.
+func NormalizeError(msg string) string {
+	msg = uuidPattern.ReplaceAllString(msg, "<uuid>")
+	msg = idPattern.ReplaceAllString(msg, "<id>")
+	msg = numberPattern.ReplaceAllString(msg, "<n>")
+	return strings.TrimSpace(spacePattern.ReplaceAllString(msg, " "))
+}
+
+func median(ds []time.Duration) time.Duration {
+	s := append([]time.Duration(nil), ds...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Second)
+}
 
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func run(project uuid.UUID, phase string, start time.Time, took time.Duration) Run {
+	return Run{DeploymentID: uuid.New(), ProjectID: project, Provider: "aws", Region: "us-east-1", Phase: phase, StartedAt: start, EndedAt: start.Add(took)}
+}
+
+func failed(project uuid.UUID, end time.Time, msg string) Run {
+	r := run(project, "applying", end.Add(-time.Minute), time.Minute)
+	r.Failed, r.Error = true, msg
+	return r
+}
+
+func TestDetectSlowPhase(t *testing.T) {
+	project := uuid.New()
+	var runs []Run
+	for i := 0; i < 5; i++ {
+		runs = append(runs, run(project, "applying", base.Add(time.Duration(i)*time.Hour), 2*time.Minute))
+	}
+	// a failed run does not count towards the median
+	runs = append(runs, Run{ProjectID: project, Phase: "applying", StartedAt: base.Add(5 * time.Hour), EndedAt: base.Add(5*time.Hour + time.Second), Failed: true})
+	slow := run(project, "applying", base.Add(6*time.Hour), 11*time.Minute)
+	ok := run(project, "applying", base.Add(7*time.Hour), 9*time.Minute)
+	runs = append(runs, slow, ok)
+	// planning has no baseline of its own
+	runs = append(runs, run(project, "planning", base.Add(6*time.Hour), time.Hour))
+
+	since := base.Add(6 * time.Hour)
+	got := NewDetector(DefaultConfig()).Detect(runs, since, base.Add(8*time.Hour))
+	require.Len(t, got, 1)
+	require.Equal(t, KindSlowPhase, got[0].Kind)
+	require.Equal(t, slow.DeploymentID, got[0].DeploymentID)
+	require.Equal(t, SeverityMedium, got[0].Severity)
+	require.Equal(t, "applying took 11m0s, 5.5x the project's median of 2m0s", got[0].Message)
+
+	// runs before since are baseline only
+	require.Empty(t, NewDetector(DefaultConfig()).Detect(runs, base.Add(9*time.Hour), base.Add(9*time.Hour)))
+}
+
+func TestDetectFailureBurst(t *testing.T) {
+	now := base.Add(time.Hour)
+	a, b := uuid.New(), uuid.New()
+	runs := []Run{
+		failed(a, now.Add(-25*time.Minute), "x"),
+		failed(a, now.Add(-20*time.Minute), "y"),
+		failed(a, now.Add(-15*time.Minute), "z"),
+		failed(b, now.Add(-10*time.Minute), "w"),
+		failed(b, now.Add(-5*time.Minute), "v"),
+		// outside the window
+		failed(b, now.Add(-40*time.Minute), "u"),
+	}
+	other := failed(uuid.New(), now.Add(-5*time.Minute), "t")
+	other.Region = "eu-west-1"
+	runs = append(runs, other)
+
+	got := NewDetector(DefaultConfig()).Detect(runs, now.Add(-10*time.Minute), now)
+	require.Len(t, got, 2)
+	projects := map[uuid.UUID]bool{}
+	for _, an := range got {
+		require.Equal(t, KindFailureBurst, an.Kind)
+		require.Equal(t, "us-east-1", an.Region)
+		require.Equal(t, "5 deployments failed across 2 projects on aws/us-east-1 in the last 30m0s", an.Message)
+		projects[an.ProjectID] = true
+	}
+	require.Equal(t, map[uuid.UUID]bool{a: true, b: true}, projects)
+
+	// a burst that is over is not reported again
+	require.Empty(t, NewDetector(DefaultConfig()).Detect(runs, now, now))
+}
+
+func TestDetectRepeatedError(t *testing.T) {
+	now := base.Add(time.Hour)
+	project := uuid.New()
+	msg := func(id string) string {
+		return "apply error: creating EC2 Instance: InvalidSubnetID.NotFound: The subnet ID '" + id + "' does not exist (request 4f6c1a2e-8d0b-4c5e-9b7a-1f2e3d4c5b6a)"
+	}
+	runs := []Run{
+		failed(project, now.Add(-3*time.Hour), msg("subnet-0a1b2c3d4e5f60718")),
+		failed(project, now.Add(-2*time.Hour), msg("subnet-0f9e8d7c6b5a40312")),
+		failed(project, now.Add(-time.Hour), "apply error: something else"),
+		failed(project, now.Add(-time.Minute), msg("subnet-0123456789abcdef0")),
+		// other projects do not count
+		failed(uuid.New(), now.Add(-time.Minute), msg("subnet-0123456789abcdef0")),
+	}
+
+	got := NewDetector(DefaultConfig()).Detect(runs, now.Add(-10*time.Minute), now)
+	require.Len(t, got, 1)
+	require.Equal(t, KindRepeatedError, got[0].Kind)
+	require.Equal(t, project, got[0].ProjectID)
+	require.Equal(t, runs[3].DeploymentID, got[0].DeploymentID)
+	require.Equal(t, 3, got[0].Details["count"])
+}
+
+func TestNormalizeError(t *testing.T) {
+	require.Equal(t,
+		"InvalidSubnetID.NotFound: subnet '<id>' (request <uuid>) after <n> retries",
+		NormalizeError("InvalidSubnetID.NotFound:  subnet 'subnet-0a1b2c3d4e5f60718' (request 4f6c1a2e-8d0b-4c5e-9b7a-1f2e3d4c5b6a) after 25 retries\n"))
+}
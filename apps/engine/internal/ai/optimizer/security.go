@@ -1,5 +1,365 @@
-//go:build ignore
-
+// Package optimizer analyzes project graphs for improvements. The analyzers
+// are deterministic: they read node properties and need no model.
 package optimizer
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iac-studio/engine/internal/provisioner"
+)
+
+// Severity ranks how urgently a finding should be fixed.
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Rank orders severities, critical highest.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
+
+// Security checks.
+const (
+	CheckPublicBucket           = "public-bucket"
+	CheckUnencryptedRDS         = "unencrypted-rds"
+	CheckOpenIngress            = "open-ingress"
+	CheckIMDSv1                 = "imdsv1-allowed"
+	CheckPublicDatabase         = "public-database"
+	CheckDatabaseInPublicSubnet = "database-public-subnet"
+)
+
+// SecurityFinding is one misconfiguration of a node. Patch holds node
+// properties that fix it, to be merged into the node's properties; it is
+// empty when the fix needs a decision only the user can make.
+type SecurityFinding struct {
+	Check     string                 `json:"check"`
+	Severity  Severity               `json:"severity"`
+	NodeID    string                 `json:"node_id"`
+	Title     string                 `json:"title"`
+	Rationale string                 `json:"rationale"`
+	Patch     map[string]interface{} `json:"patch,omitempty"`
+}
+
+// Fingerprint identifies the finding across runs.
+func (f SecurityFinding) Fingerprint() string {
+	return f.Check + ":" + f.NodeID
+}
+
+// openPorts may be open to the internet without a finding.
+var openPorts = map[float64]bool{80: true, 443: true}
+
+// AnalyzeSecurity checks g for public buckets, unencrypted or exposed
+// databases, ingress open to the internet and instances accepting IMDSv1.
+// Findings are ordered by severity, then node ID.
+func AnalyzeSecurity(g provisioner.Graph) []SecurityFinding {
+	nodes := map[string]provisioner.Node{}
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+
+	out := []SecurityFinding{}
+	for _, n := range g.Nodes {
+		switch n.Type {
+		case "aws_s3_bucket":
+			out = append(out, checkBucket(n)...)
+		case "aws_db_instance":
+			out = append(out, checkDatabase(n, nodes, g.Edges)...)
+		case "aws_security_group":
+			out = append(out, checkIngress(n, nodes)...)
+		case "aws_instance":
+			out = append(out, checkIMDS(n)...)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Severity != out[j].Severity {
+			return out[i].Severity.Rank() > out[j].Severity.Rank()
+		}
+		if out[i].NodeID != out[j].NodeID {
+			return out[i].NodeID < out[j].NodeID
+		}
+		return out[i].Check < out[j].Check
+	})
+	return out
+}
+
+func checkBucket(n provisioner.Node) []SecurityFinding {
+	acl, _ := n.Properties["acl"].(string)
+	blocked, set := n.Properties["block_public_access"].(bool)
+	if !strings.HasPrefix(acl, "public-") && (!set || blocked) {
+		return nil
+	}
+	sev := SeverityHigh
+	if acl == "public-read-write" {
+		sev = SeverityCritical
+	}
+	why := "public access block is disabled, so a bucket policy or ACL can expose objects"
+	if strings.HasPrefix(acl, "public-") {
+		why = fmt.Sprintf("the %s ACL lets anyone on the internet access objects", acl)
+	}
+	return []SecurityFinding{{
+		Check:     CheckPublicBucket,
+		Severity:  sev,
+		NodeID:    n.ID,
+		Title:     fmt.Sprintf("Bucket %s is publicly accessible", n.ID),
+		Rationale: why + "; serve public content through a CDN with origin access instead.",
+		Patch:     map[string]interface{}{"acl": "private", "block_public_access": true},
+	}}
+}
+
+func checkDatabase(n provisioner.Node, nodes map[string]provisioner.Node, edges []provisioner.Edge) []SecurityFinding {
+	var out []SecurityFinding
+	if enc, _ := n.Properties["storage_encrypted"].(bool); !enc {
+		out = append(out, SecurityFinding{
+			Check:     CheckUnencryptedRDS,
+			Severity:  SeverityHigh,
+			NodeID:    n.ID,
+			Title:     fmt.Sprintf("Database %s storage is not encrypted", n.ID),
+			Rationale: "Unencrypted storage, snapshots and replicas expose data to anyone who gets hold of them; encryption can only be enabled when the instance is created.",
+			Patch:     map[string]interface{}{"storage_encrypted": true},
+		})
+	}
+	if public, _ := n.Properties["publicly_accessible"].(bool); public {
+		out = append(out, SecurityFinding{
+			Check:     CheckPublicDatabase,
+			Severity:  SeverityCritical,
+			NodeID:    n.ID,
+			Title:     fmt.Sprintf("Database %s is publicly accessible", n.ID),
+			Rationale: "A publicly accessible instance gets a public endpoint; databases should only be reachable from inside the VPC.",
+			Patch:     map[string]interface{}{"publicly_accessible": false},
+		})
+	}
+
+	for _, subnetID := range databaseSubnets(n, nodes, edges) {
+		if !isPublicSubnet(nodes[subnetID]) {
+			continue
+		}
+		f := SecurityFinding{
+			Check:     CheckDatabaseInPublicSubnet,
+			Severity:  SeverityHigh,
+			NodeID:    n.ID,
+			Title:     fmt.Sprintf("Database %s is placed in public subnet %s", n.ID, subnetID),
+			Rationale: fmt.Sprintf("Subnet %s assigns public IPs, so the database is one setting away from the internet; place databases in private subnets.", subnetID),
+		}
+		if private := privateSubnet(n, nodes); private != "" {
+			f.Patch = map[string]interface{}{"subnet": private}
+		}
+		out = append(out, f)
+		break
+	}
+	return out
+}
+
+// databaseSubnets returns the subnets a database is placed in, from its
+// subnet properties and its edges to subnet nodes.
+func databaseSubnets(n provisioner.Node, nodes map[string]provisioner.Node, edges []provisioner.Edge) []string {
+	var ids []string
+	if s, ok := n.Properties["subnet"].(string); ok {
+		ids = append(ids, s)
+	}
+	if list, ok := n.Properties["subnets"].([]interface{}); ok {
+		for _, s := range list {
+			ids = append(ids, fmt.Sprint(s))
+		}
+	}
+	for _, e := range edges {
+		other := ""
+		switch n.ID {
+		case e.From:
+			other = e.To
+		case e.To:
+			other = e.From
+		}
+		if nodes[other].Type == "aws_subnet" {
+			ids = append(ids, other)
+		}
+	}
+	return ids
+}
+
+func isPublicSubnet(n provisioner.Node) bool {
+	if n.Type != "aws_subnet" {
+		return false
+	}
+	public, _ := n.Properties["map_public_ip_on_launch"].(bool)
+	return public
+}
+
+// privateSubnet picks a private subnet in the database's VPC, or any
+// private subnet when its VPC is unknown.
+func privateSubnet(db provisioner.Node, nodes map[string]provisioner.Node) string {
+	vpc, _ := db.Properties["vpc"].(string)
+	var ids []string
+	for id, n := range nodes {
+		if n.Type != "aws_subnet" || isPublicSubnet(n) {
+			continue
+		}
+		if v, _ := n.Properties["vpc"].(string); vpc != "" && v != vpc {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	sort.Strings(ids)
+	return ids[0]
+}
+
+func checkIngress(n provisioner.Node, nodes map[string]provisioner.Node) []SecurityFinding {
+	rules, _ := n.Properties["ingress"].([]interface{})
+	var open []string
+	sev := SeverityMedium
+	patched := make([]interface{}, 0, len(rules))
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok || !openToWorld(rule["cidr_blocks"]) || webOnly(rule) {
+			patched = append(patched, r)
+			continue
+		}
+		open = append(open, describeRule(rule))
+		if s := ingressSeverity(rule); s.Rank() > sev.Rank() {
+			sev = s
+		}
+		fixed := make(map[string]interface{}, len(rule))
+		for k, v := range rule {
+			fixed[k] = v
+		}
+		fixed["cidr_blocks"] = internalCIDR(n, nodes)
+		patched = append(patched, fixed)
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	return []SecurityFinding{{
+		Check:     CheckOpenIngress,
+		Severity:  sev,
+		NodeID:    n.ID,
+		Title:     fmt.Sprintf("Security group %s allows %s from anywhere", n.ID, strings.Join(open, ", ")),
+		Rationale: "Ingress from 0.0.0.0/0 exposes these ports to the whole internet; only HTTP and HTTPS are expected to be public. Restrict the source to the VPC or a known range.",
+		Patch:     map[string]interface{}{"ingress": patched},
+	}}
+}
+
+func openToWorld(v interface{}) bool {
+	var cidrs []interface{}
+	switch t := v.(type) {
+	case string:
+		cidrs = []interface{}{t}
+	case []interface{}:
+		cidrs = t
+	}
+	for _, c := range cidrs {
+		if c == "0.0.0.0/0" || c == "::/0" {
+			return true
+		}
+	}
+	return false
+}
+
+func allProtocols(rule map[string]interface{}) bool {
+	p := strings.ToLower(fmt.Sprint(rule["protocol"]))
+	return p == "-1" || p == "all"
+}
+
+func ports(rule map[string]interface{}) (float64, float64) {
+	return toFloat(rule["from_port"]), toFloat(rule["to_port"])
+}
+
+// webOnly reports whether the rule opens nothing but HTTP or HTTPS.
+func webOnly(rule map[string]interface{}) bool {
+	if allProtocols(rule) {
+		return false
+	}
+	from, to := ports(rule)
+	return from == to && openPorts[from]
+}
+
+func ingressSeverity(rule map[string]interface{}) Severity {
+	from, to := ports(rule)
+	switch {
+	case allProtocols(rule), to-from > 1000:
+		return SeverityCritical
+	case covers(from, to, 22), covers(from, to, 3389), covers(from, to, 3306), covers(from, to, 5432):
+		return SeverityHigh
+	}
+	return SeverityMedium
+}
+
+func covers(from, to, port float64) bool {
+	return from <= port && port <= to
+}
+
+func describeRule(rule map[string]interface{}) string {
+	if allProtocols(rule) {
+		return "all traffic"
+	}
+	from, to := ports(rule)
+	if from == to {
+		return fmt.Sprintf("port %v", from)
+	}
+	return fmt.Sprintf("ports %v-%v", from, to)
+}
+
+// internalCIDR is the CIDR of the security group's VPC, falling back to
+// the private 10.0.0.0/8 range.
+func internalCIDR(sg provisioner.Node, nodes map[string]provisioner.Node) string {
+	if vpc, ok := sg.Properties["vpc"].(string); ok {
+		if cidr, ok := nodes[vpc].Properties["cidr_block"].(string); ok && cidr != "" {
+			return cidr
+		}
+	}
+	return "10.0.0.0/8"
+}
+
+func checkIMDS(n provisioner.Node) []SecurityFinding {
+	opts, _ := n.Properties["metadata_options"].(map[string]interface{})
+	if opts["http_tokens"] == "required" {
+		return nil
+	}
+	patch := map[string]interface{}{}
+	for k, v := range opts {
+		patch[k] = v
+	}
+	patch["http_tokens"] = "required"
+	return []SecurityFinding{{
+		Check:     CheckIMDSv1,
+		Severity:  SeverityMedium,
+		NodeID:    n.ID,
+		Title:     fmt.Sprintf("Instance %s does not require IMDSv2", n.ID),
+		Rationale: "IMDSv1 answers unauthenticated requests, so an SSRF flaw in the instance's software can leak its IAM credentials; requiring session tokens (IMDSv2) blocks this.",
+		Patch:     map[string]interface{}{"metadata_options": patch},
+	}}
+}
 
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case string:
+		var f float64
+		fmt.Sscan(n, &f)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeSecurity(t *testing.T) {
+	graph := provisioner.Graph{
+		Nodes: []provisioner.Node{
+			{ID: "main", Type: "aws_vpc", Properties: map[string]interface{}{"cidr_block": "10.1.0.0/16"}},
+			{ID: "public_a", Type: "aws_subnet", Properties: map[string]interface{}{"vpc": "main", "map_public_ip_on_launch": true}},
+			{ID: "private_a", Type: "aws_subnet", Properties: map[string]interface{}{"vpc": "main"}},
+			{ID: "site", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "site", "acl": "public-read"}},
+			{ID: "logs", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "logs"}},
+			{ID: "db", Type: "aws_db_instance", Properties: map[string]interface{}{"vpc": "main", "publicly_accessible": true}},
+			{ID: "web_sg", Type: "aws_security_group", Properties: map[string]interface{}{"vpc": "main", "ingress": []interface{}{
+				map[string]interface{}{"from_port": 443, "to_port": 443, "protocol": "tcp", "cidr_blocks": "0.0.0.0/0"},
+				map[string]interface{}{"from_port": 22, "to_port": 22, "protocol": "tcp", "cidr_blocks": "0.0.0.0/0"},
+			}}},
+			{ID: "app", Type: "aws_instance", Properties: map[string]interface{}{"instance_type": "t3.micro", "metadata_options": map[string]interface{}{"http_endpoint": "enabled"}}},
+			{ID: "hardened", Type: "aws_instance", Properties: map[string]interface{}{"metadata_options": map[string]interface{}{"http_tokens": "required"}}},
+		},
+		Edges: []provisioner.Edge{{ID: "e1", From: "db", To: "public_a", Type: "depends_on"}},
+	}
+
+	findings := AnalyzeSecurity(graph)
+	got := map[string]SecurityFinding{}
+	for _, f := range findings {
+		got[f.Fingerprint()] = f
+	}
+	require.Len(t, findings, 6)
+	require.Equal(t, SeverityCritical, findings[0].Severity)
+
+	require.Equal(t, SeverityHigh, got["public-bucket:site"].Severity)
+	require.Equal(t, true, got["public-bucket:site"].Patch["block_public_access"])
+	require.NotContains(t, got, "public-bucket:logs")
+
+	require.Equal(t, map[string]interface{}{"storage_encrypted": true}, got["unencrypted-rds:db"].Patch)
+	require.Equal(t, SeverityCritical, got["public-database:db"].Severity)
+	require.Equal(t, map[string]interface{}{"subnet": "private_a"}, got["database-public-subnet:db"].Patch)
+
+	// only the SSH rule is narrowed, to the VPC range
+	ingress := got["open-ingress:web_sg"]
+	require.Equal(t, SeverityHigh, ingress.Severity)
+	rules := ingress.Patch["ingress"].([]interface{})
+	require.Equal(t, "0.0.0.0/0", rules[0].(map[string]interface{})["cidr_blocks"])
+	require.Equal(t, "10.1.0.0/16", rules[1].(map[string]interface{})["cidr_blocks"])
+
+	require.Equal(t, map[string]interface{}{"metadata_options": map[string]interface{}{"http_endpoint": "enabled", "http_tokens": "required"}}, got["imdsv1-allowed:app"].Patch)
+	require.NotContains(t, got, "imdsv1-allowed:hardened")
+
+	require.Empty(t, AnalyzeSecurity(provisioner.Graph{}))
+}
@@ -1,5 +1,170 @@
-//go:build ignore
-
 package handlers
 
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// AIHandler serves the model-backed features.
+type AIHandler struct {
+	svc services.AIService
+}
+
+func NewAIHandler(svc services.AIService) *AIHandler {
+	return &AIHandler{svc: svc}
+}
+
+// GenerateGraphRequest describes the infrastructure to generate.
+type GenerateGraphRequest struct {
+	Prompt string `json:"prompt" example:"A VPC with a public web server and a private Postgres database"`
+	// Save stores the proposal as a new graph version right away.
+	Save bool `json:"save"`
+}
+
+// ApplyPatchRequest patches the current graph version.
+type ApplyPatchRequest struct {
+	// GraphVersion is the version the patches were proposed for; it must
+	// still be the current one.
+	GraphVersion int                  `json:"graph_version" example:"3"`
+	Patches      []compiler.NodePatch `json:"patches"`
+}
+
+// Status godoc
+// @Summary      AI status
+// @Description  Tell whether the AI features are configured, and with which provider and model
+// @Tags         AI
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} types.APIResponse{data=services.AIStatus}
+// @Router       /ai/status [get]
+func (h *AIHandler) Status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: h.svc.Status()})
+}
+
+// GenerateGraph godoc
+// @Summary      Generate a graph from a description
+// @Description  Propose an infrastructure graph for a plain-language description. The proposal is validated and compiled, and compiler errors are sent back to the model to repair. With save set, the proposal is stored as a new graph version.
+// @Tags         AI
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        request body GenerateGraphRequest true "Description"
+// @Success      200 {object} types.APIResponse{data=services.GeneratedGraph}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      503 {object} types.APIResponse{error=types.APIError}
+// @Router       /ai/projects/{id}/graph/generate [post]
+func (h *AIHandler) GenerateGraph(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req GenerateGraphRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	out, err := h.svc.GenerateGraph(r.Context(), projectID, userID, &services.GenerateGraphInput{Prompt: req.Prompt, Save: req.Save})
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: out})
+}
+
+// SaveGraph godoc
+// @Summary      Save a proposed graph
+// @Description  Validate a graph with the compiler and save it as a new graph version of the project
+// @Tags         AI
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        graph body services.GraphData true "Graph"
+// @Success      201 {object} types.APIResponse{data=models.ProjectGraph}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /ai/projects/{id}/graph/save [post]
+func (h *AIHandler) SaveGraph(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var data services.GraphData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	g, err := h.svc.SaveGraph(r.Context(), projectID, userID, &data)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: g})
+}
+
+// ExplainDeployment godoc
+// @Summary      Explain a deployment
+// @Description  Explain a failed deployment in plain language from its logs and graph, with property patches that fix the failure when the fix is in the graph. For a deployment that did not fail, explain its plan and call out risks. Patches can be applied with POST /ai/projects/{id}/graph/patch.
+// @Tags         AI
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=services.DeploymentExplanation}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      503 {object} types.APIResponse{error=types.APIError}
+// @Router       /ai/deployments/{id}/explain [post]
+func (h *AIHandler) ExplainDeployment(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+	out, err := h.svc.ExplainDeployment(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: out})
+}
 
+// ApplyPatch godoc
+// @Summary      Apply a graph patch
+// @Description  Apply property patches, such as those of a failure explanation, to the current graph and save the result as a new graph version. The patched graph is validated by the compiler.
+// @Tags         AI
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        request body ApplyPatchRequest true "Patches"
+// @Success      201 {object} types.APIResponse{data=models.ProjectGraph}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /ai/projects/{id}/graph/patch [post]
+func (h *AIHandler) ApplyPatch(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req ApplyPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	g, err := h.svc.ApplyPatch(r.Context(), projectID, userID, &services.ApplyPatchInput{GraphVersion: req.GraphVersion, Patches: req.Patches})
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: g})
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// AlertsHandler serves a project's alert channels, rules and deliveries.
+type AlertsHandler struct {
+	svc services.AlertService
+}
+
+func NewAlertsHandler(svc services.AlertService) *AlertsHandler {
+	return &AlertsHandler{svc: svc}
+}
+
+// ListChannels godoc
+// @Summary      List alert channels
+// @Tags         Alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.AlertChannel}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/channels [get]
+func (h *AlertsHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	channels, err := h.svc.ListChannels(r.Context(), projectID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: channels})
+}
+
+// GetChannel godoc
+// @Summary      Get an alert channel
+// @Tags         Alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        channelID path string true "Channel ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.AlertChannel}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/channels/{channelID} [get]
+func (h *AlertsHandler) GetChannel(w http.ResponseWriter, r *http.Request) {
+	projectID, channelID, userID, ok := projectItemAndUser(w, r, "channelID", "channel")
+	if !ok {
+		return
+	}
+	ch, err := h.svc.GetChannel(r.Context(), projectID, channelID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: ch})
+}
+
+// CreateChannel godoc
+// @Summary      Create an alert channel
+// @Description  Add a generic webhook (JSON POST of the alert), a Slack-compatible incoming webhook or an email channel. Email needs the server's SMTP settings
+// @Tags         Alerts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        request body services.AlertChannelInput true "Channel"
+// @Success      201 {object} types.APIResponse{data=models.AlertChannel}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      503 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/channels [post]
+func (h *AlertsHandler) CreateChannel(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req services.AlertChannelInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	ch, err := h.svc.CreateChannel(r.Context(), projectID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: ch})
+}
+
+// UpdateChannel godoc
+// @Summary      Update an alert channel
+// @Description  Change the fields that are set: rename, re-target or enable and disable a channel
+// @Tags         Alerts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        channelID path string true "Channel ID" format(uuid)
+// @Param        request body services.AlertChannelUpdate true "Changes"
+// @Success      200 {object} types.APIResponse{data=models.AlertChannel}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/channels/{channelID} [put]
+func (h *AlertsHandler) UpdateChannel(w http.ResponseWriter, r *http.Request) {
+	projectID, channelID, userID, ok := projectItemAndUser(w, r, "channelID", "channel")
+	if !ok {
+		return
+	}
+	var req services.AlertChannelUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	ch, err := h.svc.UpdateChannel(r.Context(), projectID, channelID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: ch})
+}
+
+// DeleteChannel godoc
+// @Summary      Delete an alert channel
+// @Description  Delete a channel and take it off the project's rules
+// @Tags         Alerts
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        channelID path string true "Channel ID" format(uuid)
+// @Success      204
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/channels/{channelID} [delete]
+func (h *AlertsHandler) DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	projectID, channelID, userID, ok := projectItemAndUser(w, r, "channelID", "channel")
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteChannel(r.Context(), projectID, channelID, userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestChannel godoc
+// @Summary      Send a test notification
+// @Description  Send a test notification to the channel, even if it is disabled. The delivery tells whether it was sent; a channel over its hourly limit is not sent to
+// @Tags         Alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        channelID path string true "Channel ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.AlertDelivery}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/channels/{channelID}/test [post]
+func (h *AlertsHandler) TestChannel(w http.ResponseWriter, r *http.Request) {
+	projectID, channelID, userID, ok := projectItemAndUser(w, r, "channelID", "channel")
+	if !ok {
+		return
+	}
+	d, err := h.svc.TestChannel(r.Context(), projectID, channelID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: d})
+}
+
+// ListRules godoc
+// @Summary      List alert rules
+// @Tags         Alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.AlertRule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/rules [get]
+func (h *AlertsHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	rules, err := h.svc.ListRules(r.Context(), projectID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rules})
+}
+
+// GetRule godoc
+// @Summary      Get an alert rule
+// @Tags         Alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        ruleID path string true "Rule ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.AlertRule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/rules/{ruleID} [get]
+func (h *AlertsHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	projectID, ruleID, userID, ok := projectItemAndUser(w, r, "ruleID", "rule")
+	if !ok {
+		return
+	}
+	rule, err := h.svc.GetRule(r.Context(), projectID, ruleID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rule})
+}
+
+// CreateRule godoc
+// @Summary      Create an alert rule
+// @Description  Route a trigger to channels of the project: a failed deployment, detected drift, a deployment over budget, a deployment awaiting approval for longer than after_minutes, or a detected anomaly
+// @Tags         Alerts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        request body services.AlertRuleInput true "Rule"
+// @Success      201 {object} types.APIResponse{data=models.AlertRule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/rules [post]
+func (h *AlertsHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req services.AlertRuleInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rule, err := h.svc.CreateRule(r.Context(), projectID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: rule})
+}
+
+// UpdateRule godoc
+// @Summary      Update an alert rule
+// @Description  Change the fields that are set
+// @Tags         Alerts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        ruleID path string true "Rule ID" format(uuid)
+// @Param        request body services.AlertRuleUpdate true "Changes"
+// @Success      200 {object} types.APIResponse{data=models.AlertRule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/rules/{ruleID} [put]
+func (h *AlertsHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	projectID, ruleID, userID, ok := projectItemAndUser(w, r, "ruleID", "rule")
+	if !ok {
+		return
+	}
+	var req services.AlertRuleUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rule, err := h.svc.UpdateRule(r.Context(), projectID, ruleID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rule})
+}
+
+// DeleteRule godoc
+// @Summary      Delete an alert rule
+// @Tags         Alerts
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        ruleID path string true "Rule ID" format(uuid)
+// @Success      204
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/rules/{ruleID} [delete]
+func (h *AlertsHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	projectID, ruleID, userID, ok := projectItemAndUser(w, r, "ruleID", "rule")
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteRule(r.Context(), projectID, ruleID, userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries godoc
+// @Summary      List alert deliveries
+// @Description  List the alerts queued, sent, failed or dropped by the rate limit, newest first. Failed sends are retried with backoff before a delivery is marked failed
+// @Tags         Alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        limit query int false "Maximum number of deliveries" default(500)
+// @Success      200 {object} types.APIResponse{data=[]models.AlertDelivery}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/alerts/deliveries [get]
+func (h *AlertsHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorStr(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	deliveries, err := h.svc.ListDeliveries(r.Context(), projectID, userID, limit)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: deliveries})
+}
+
+// projectItemAndUser reads the project, the item ID in param and the user;
+// name is used in the error for an invalid item ID.
+func projectItemAndUser(w http.ResponseWriter, r *http.Request, param, name string) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(chi.URLParam(r, param))
+	if err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid "+name+" id")
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return projectID, id, userID, true
+}
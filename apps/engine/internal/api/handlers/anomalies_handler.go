@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// AnomaliesHandler serves the anomalies found in a project's deployment
+// history.
+type AnomaliesHandler struct {
+	svc services.AnomalyService
+}
+
+func NewAnomaliesHandler(svc services.AnomalyService) *AnomaliesHandler {
+	return &AnomaliesHandler{svc: svc}
+}
+
+// List godoc
+// @Summary      List project anomalies
+// @Description  List the anomalies found in a project's deployments, newest first: phases far slower than the project's median, failure bursts on the project's provider and region, and errors that failed several deployments
+// @Tags         Projects
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        kind query string false "Kind" Enums(slow_phase, failure_burst, repeated_error)
+// @Param        limit query int false "Maximum number of anomalies" default(500)
+// @Success      200 {object} types.APIResponse{data=[]models.Anomaly}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/anomalies [get]
+func (h *AnomaliesHandler) List(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter := repository.AnomalyFilter{Kind: q.Get("kind")}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorStr(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = n
+	}
+	anomalies, err := h.svc.List(r.Context(), projectID, userID, filter)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: anomalies})
+}
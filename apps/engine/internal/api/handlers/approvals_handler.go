@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// ApprovalsHandler serves the approval workflow of deployments held at
+// awaiting_approval.
+type ApprovalsHandler struct {
+	svc services.ApprovalService
+}
+
+func NewApprovalsHandler(svc services.ApprovalService) *ApprovalsHandler {
+	return &ApprovalsHandler{svc: svc}
+}
+
+// DecisionRequest carries an approver's comment
+type DecisionRequest struct {
+	Comment string `json:"comment,omitempty" example:"Checked the plan, deletes are expected"`
+}
+
+// List godoc
+// @Summary      Get deployment approvals
+// @Description  Show how many approvals a deployment needs and the decisions so far; open to the project owner and its approvers
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=services.ApprovalStatus}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/approvals [get]
+func (h *ApprovalsHandler) List(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+	st, err := h.svc.GetApprovals(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: st})
+}
+
+// Approve godoc
+// @Summary      Approve deployment
+// @Description  Approve a deployment awaiting approval. The author cannot approve; once enough approvers did, the apply is enqueued
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        request body DecisionRequest false "Comment"
+// @Success      200 {object} types.APIResponse{data=services.ApprovalStatus}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/approve [post]
+func (h *ApprovalsHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.svc.Approve)
+}
+
+// Reject godoc
+// @Summary      Reject deployment
+// @Description  Reject a deployment awaiting approval; it ends as rejected and leaves the queue
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        request body DecisionRequest false "Comment"
+// @Success      200 {object} types.APIResponse{data=services.ApprovalStatus}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/reject [post]
+func (h *ApprovalsHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.svc.Reject)
+}
+
+func (h *ApprovalsHandler) decide(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, deploymentID, userID uuid.UUID, comment string) (*services.ApprovalStatus, error)) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+	// the body is optional
+	var req DecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	st, err := fn(r.Context(), deploymentID, userID, req.Comment)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: st})
+}
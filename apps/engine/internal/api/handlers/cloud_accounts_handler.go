@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/datatypes"
+)
+
+// CloudAccountsHandler serves the user's cloud accounts. Credentials are
+// write-only: they are sealed at rest by the repository and never returned.
+type CloudAccountsHandler struct {
+	repo repository.CloudAccountRepository
+}
+
+func NewCloudAccountsHandler(repo repository.CloudAccountRepository) *CloudAccountsHandler {
+	return &CloudAccountsHandler{repo: repo}
+}
+
+// CloudAccountInput creates a cloud account. Projects use it by setting
+// cloud_account_id in their settings.
+type CloudAccountInput struct {
+	Provider    string                 `json:"provider" example:"aws" enums:"aws,azure,gcp"`
+	Credentials map[string]interface{} `json:"credentials"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// List godoc
+// @Summary      List cloud accounts
+// @Tags         Cloud Accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} types.APIResponse{data=[]models.CloudAccount}
+// @Failure      401 {object} types.APIResponse{error=types.APIError}
+// @Router       /cloud-accounts [get]
+func (h *CloudAccountsHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := policyUser(w, r)
+	if !ok {
+		return
+	}
+	accounts, err := h.repo.ListByUser(r.Context(), userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: accounts})
+}
+
+// Create godoc
+// @Summary      Add a cloud account
+// @Description  Store provider credentials, encrypted at rest. They are used by deployments of projects whose settings name the account in cloud_account_id and are never returned
+// @Tags         Cloud Accounts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body CloudAccountInput true "Account"
+// @Success      201 {object} types.APIResponse{data=models.CloudAccount}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      401 {object} types.APIResponse{error=types.APIError}
+// @Router       /cloud-accounts [post]
+func (h *CloudAccountsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := policyUser(w, r)
+	if !ok {
+		return
+	}
+	var req CloudAccountInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	switch req.Provider {
+	case "aws", "azure", "gcp":
+	default:
+		writeErrorStr(w, http.StatusBadRequest, "provider must be aws, azure or gcp")
+		return
+	}
+	if len(req.Credentials) == 0 {
+		writeErrorStr(w, http.StatusBadRequest, "credentials are required")
+		return
+	}
+	creds, _ := json.Marshal(req.Credentials)
+	account := &models.CloudAccount{UserID: userID, Provider: req.Provider, Credentials: creds}
+	if req.Metadata != nil {
+		meta, _ := json.Marshal(req.Metadata)
+		account.Metadata = datatypes.JSON(meta)
+	}
+	if err := h.repo.Create(r.Context(), account); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: account})
+}
+
+// Delete godoc
+// @Summary      Delete a cloud account
+// @Tags         Cloud Accounts
+// @Security     BearerAuth
+// @Param        accountID path string true "Account ID" format(uuid)
+// @Success      204
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /cloud-accounts/{accountID} [delete]
+func (h *CloudAccountsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	accountID, err := uuid.Parse(chi.URLParam(r, "accountID"))
+	if err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid account id")
+		return
+	}
+	userID, ok := policyUser(w, r)
+	if !ok {
+		return
+	}
+	var account models.CloudAccount
+	if err := h.repo.GetByID(r.Context(), accountID, &account); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	if account.UserID != userID {
+		writeAppError(w, appErr.New(appErr.CodeUnauthorized, "user does not own cloud account"))
+		return
+	}
+	if err := h.repo.Delete(r.Context(), accountID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
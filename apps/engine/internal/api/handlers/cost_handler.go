@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// CostHandler serves cost estimates of project graphs.
+type CostHandler struct {
+	svc services.CostService
+}
+
+func NewCostHandler(svc services.CostService) *CostHandler {
+	return &CostHandler{svc: svc}
+}
+
+// Estimate godoc
+// @Summary      Estimate project cost
+// @Description  Estimate the monthly cost of a saved graph version from the offline price catalog, with a breakdown per node
+// @Tags         Projects
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        version query int false "Graph version, defaults to the current one"
+// @Success      200 {object} types.APIResponse{data=cloud.Estimate}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/cost [get]
+func (h *CostHandler) Estimate(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorStr(w, http.StatusBadRequest, "version must be a positive integer")
+			return
+		}
+		version = n
+	}
+	est, err := h.svc.EstimateProject(r.Context(), projectID, userID, version)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: est})
+}
+
+// EstimateGraph godoc
+// @Summary      Estimate an unsaved graph
+// @Description  Estimate the monthly cost of a graph in the project's provider and region without saving it
+// @Tags         Projects
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        graph body services.GraphData true "Graph"
+// @Success      200 {object} types.APIResponse{data=cloud.Estimate}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/cost [post]
+func (h *CostHandler) EstimateGraph(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var data services.GraphData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	est, err := h.svc.EstimateGraph(r.Context(), projectID, userID, &data)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: est})
+}
+
+// Diff godoc
+// @Summary      Compare the cost of graph versions
+// @Description  Compare the estimated monthly cost of two saved graph versions, with the nodes added, removed or resized between them
+// @Tags         Projects
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        from query int true "Base graph version"
+// @Param        to query int true "Compared graph version"
+// @Success      200 {object} types.APIResponse{data=services.VersionCostDiff}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/cost/diff [get]
+func (h *CostHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || from < 1 {
+		writeErrorStr(w, http.StatusBadRequest, "from must be a positive integer")
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || to < 1 {
+		writeErrorStr(w, http.StatusBadRequest, "to must be a positive integer")
+		return
+	}
+	diff, err := h.svc.Diff(r.Context(), projectID, userID, from, to)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: diff})
+}
+
+// BudgetOverrides godoc
+// @Summary      List budget overrides
+// @Description  Audit trail of deployments created over the project's soft budget
+// @Tags         Projects
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.BudgetOverride}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/budget/overrides [get]
+func (h *CostHandler) BudgetOverrides(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	overrides, err := h.svc.ListBudgetOverrides(r.Context(), projectID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: overrides})
+}
+
+// Catalog godoc
+// @Summary      Describe the price catalog
+// @Description  List the providers in the price catalog with their catalog version and currency
+// @Tags         Cost
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} types.APIResponse{data=[]cloud.CatalogInfo}
+// @Router       /cost/catalog [get]
+func (h *CostHandler) Catalog(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: h.svc.Catalog()})
+}
@@ -1,15 +1,34 @@
 package handlers
 
 import (
+    "encoding/json"
+    "fmt"
+    "io"
     "net/http"
+    "strconv"
+    "strings"
+    "time"
     "github.com/google/uuid"
+    "github.com/iac-studio/engine/internal/api/middleware"
     "github.com/iac-studio/engine/internal/api/types"
+    "github.com/iac-studio/engine/internal/models"
     "github.com/iac-studio/engine/internal/repository"
+    "github.com/iac-studio/engine/internal/services"
 )
 
-type DeploymentsHandler struct { repo repository.DeploymentRepository }
+type DeploymentsHandler struct {
+    repo repository.DeploymentRepository
+    svc  services.DeploymentService
+}
 
-func NewDeploymentsHandler(repo repository.DeploymentRepository) *DeploymentsHandler { return &DeploymentsHandler{repo: repo} }
+func NewDeploymentsHandler(repo repository.DeploymentRepository, svc services.DeploymentService) *DeploymentsHandler {
+    return &DeploymentsHandler{repo: repo, svc: svc}
+}
+
+// DestroyRequest selects graph nodes to destroy; no nodes means all of them.
+type DestroyRequest struct {
+    NodeIDs []string `json:"node_ids"`
+}
 
 func (h *DeploymentsHandler) List(w http.ResponseWriter, r *http.Request) {
     pidStr := r.URL.Query().Get("project_id")
@@ -19,11 +38,455 @@ func (h *DeploymentsHandler) List(w http.ResponseWriter, r *http.Request) {
     writeJSON(w, http.StatusOK, types.APIResponse{ Success: true, Data: items })
 }
 
+// CreateDeploymentRequest starts a deployment of a project graph
+type CreateDeploymentRequest struct {
+    ProjectID   uuid.UUID `json:"project_id"`
+    GraphID     uuid.UUID `json:"graph_id,omitempty"`
+    Environment string    `json:"environment,omitempty" example:"preview"`
+    TTL         string    `json:"ttl,omitempty" example:"72h"`
+    // Supersede cancels deployments of the project still waiting in the queue
+    Supersede bool `json:"supersede,omitempty"`
+    // OverrideBudget deploys over a soft project budget, or under a hard one
+    // when the graph could not be priced; the override is audited
+    OverrideBudget bool   `json:"override_budget,omitempty"`
+    OverrideReason string `json:"override_reason,omitempty" example:"launch traffic"`
+}
+
+// CreatePlanRequest starts a plan-only run of a graph version
+type CreatePlanRequest struct {
+    ProjectID uuid.UUID `json:"project_id"`
+    // GraphVersion defaults to the latest version
+    GraphVersion int `json:"graph_version,omitempty" example:"3"`
+    // BaseDeploymentID defaults to the project's latest applied deployment
+    BaseDeploymentID *uuid.UUID `json:"base_deployment_id,omitempty"`
+}
+
+// ExtendTTLRequest either extends the expiry or sets a new one
+type ExtendTTLRequest struct {
+    ExtendBy  string     `json:"extend_by,omitempty" example:"24h"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Create godoc
+// @Summary      Create deployment
+// @Description  Deploy a graph version (the latest when graph_id is omitted). Deployments of a project run one at a time in creation order; queue_position tells where the new one stands and supersede cancels those still waiting. Ephemeral deployments are destroyed once their TTL lapses; without ttl the project default for the environment applies. A deployment estimated over the project budget is rejected with budget_exceeded; a soft budget can be overridden with override_budget. Under a hard budget, a graph that could not be priced, even in part, is rejected too unless override_budget is set
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body CreateDeploymentRequest true "Deployment"
+// @Success      201 {object} types.APIResponse{data=models.Deployment}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Failure      422 {object} types.APIResponse{error=types.APIError} "Over the project budget"
+// @Router       /deployments [post]
 func (h *DeploymentsHandler) Create(w http.ResponseWriter, r *http.Request) {
-    // placeholder: would create a record and enqueue job
-    writeJSON(w, http.StatusCreated, types.APIResponse{ Success: true })
+    userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+    if err != nil {
+        writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+        return
+    }
+    var req CreateDeploymentRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeErrorStr(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+    if req.ProjectID == uuid.Nil {
+        writeErrorStr(w, http.StatusBadRequest, "project_id is required")
+        return
+    }
+    input := &services.CreateDeploymentInput{GraphID: req.GraphID, Environment: req.Environment, Supersede: req.Supersede, OverrideBudget: req.OverrideBudget, OverrideReason: req.OverrideReason}
+    if req.TTL != "" {
+        if input.TTL, err = time.ParseDuration(req.TTL); err != nil || input.TTL <= 0 {
+            writeErrorStr(w, http.StatusBadRequest, "ttl must be a positive duration such as 72h")
+            return
+        }
+    }
+
+    d, err := h.svc.CreateDeployment(r.Context(), req.ProjectID, userID, input)
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, types.APIResponse{ Success: true, Data: d })
+}
+
+// CreatePlan godoc
+// @Summary      Plan graph version
+// @Description  Start a plan-only run: terraform plan of a graph version against the state of the project's live deployment (or base_deployment_id). Nothing is applied; the change set is available from /deployments/{id}/plan once the status is planned
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body CreatePlanRequest true "Plan"
+// @Success      202 {object} types.APIResponse{data=models.Deployment}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/plan [post]
+func (h *DeploymentsHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+    userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+    if err != nil {
+        writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+        return
+    }
+    var req CreatePlanRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeErrorStr(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+    if req.ProjectID == uuid.Nil {
+        writeErrorStr(w, http.StatusBadRequest, "project_id is required")
+        return
+    }
+    if req.GraphVersion < 0 {
+        writeErrorStr(w, http.StatusBadRequest, "graph_version must be positive")
+        return
+    }
+
+    d, err := h.svc.CreatePlan(r.Context(), req.ProjectID, userID, &services.PlanInput{GraphVersion: req.GraphVersion, BaseDeploymentID: req.BaseDeploymentID})
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusAccepted, types.APIResponse{ Success: true, Data: d })
+}
+
+// Plan godoc
+// @Summary      Get deployment plan
+// @Description  Return the change set of a finished plan: one entry per resource to create, update, replace or delete, linked to its graph node
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=provisioner.Plan}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/plan [get]
+func (h *DeploymentsHandler) Plan(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    plan, err := h.svc.GetPlan(r.Context(), deploymentID, userID)
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, types.APIResponse{ Success: true, Data: plan })
+}
+
+// QueueStatus godoc
+// @Summary      Get deployment queue position
+// @Description  Report where a deployment stands in its project's queue; position 1 is running or next, 0 means it has left the queue
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=services.QueueStatus}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/queue [get]
+func (h *DeploymentsHandler) QueueStatus(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    qs, err := h.svc.GetQueueStatus(r.Context(), deploymentID, userID)
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, types.APIResponse{ Success: true, Data: qs })
+}
+
+// History godoc
+// @Summary      Get deployment status history
+// @Description  List a deployment's status transitions with their actor, and the time spent in each phase
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=services.StatusHistory}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/history [get]
+func (h *DeploymentsHandler) History(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    hist, err := h.svc.GetStatusHistory(r.Context(), deploymentID, userID)
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, types.APIResponse{ Success: true, Data: hist })
 }
 
+// Cancel godoc
+// @Summary      Cancel queued deployment
+// @Description  Withdraw a deployment that is still waiting in its project's queue, or one stuck planning or applying whose task is gone; a stuck run that may have changed infrastructure is marked failed instead
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/cancel [post]
+func (h *DeploymentsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    if err := h.svc.CancelDeployment(r.Context(), deploymentID, userID); err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, types.APIResponse{ Success: true })
+}
+
+// Logs godoc
+// @Summary      List deployment logs
+// @Description  Page through a deployment's log lines in sequence order; pass next_seq as after_seq to continue
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        after_seq query int false "Return lines after this sequence number"
+// @Param        limit query int false "Page size (default 200, max 1000)"
+// @Param        level query string false "Comma-separated levels, e.g. warn,error"
+// @Param        q query string false "Case-insensitive message search"
+// @Success      200 {object} types.APIResponse{data=services.LogPage}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/logs [get]
+func (h *DeploymentsHandler) Logs(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    q, ok := parseLogQuery(w, r)
+    if !ok { return }
+
+    page, err := h.svc.GetDeploymentLogs(r.Context(), deploymentID, userID, q)
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, types.APIResponse{ Success: true, Data: page })
+}
+
+// DownloadLogs godoc
+// @Summary      Download deployment logs
+// @Description  Download the full log of a deployment as plain text or NDJSON; level and q filter as in the list endpoint
+// @Tags         Deployments
+// @Produce      plain
+// @Produce      x-ndjson
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        format query string false "text (default) or ndjson"
+// @Param        level query string false "Comma-separated levels, e.g. warn,error"
+// @Param        q query string false "Case-insensitive message search"
+// @Success      200 {file} file
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/logs/download [get]
+func (h *DeploymentsHandler) DownloadLogs(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    q, ok := parseLogQuery(w, r)
+    if !ok { return }
+    q.AfterSeq = 0
+
+    format := r.URL.Query().Get("format")
+    var contentType, ext string
+    switch format {
+    case "", "text":
+        contentType, ext = "text/plain; charset=utf-8", "log"
+    case "ndjson":
+        contentType, ext = "application/x-ndjson", "ndjson"
+    default:
+        writeErrorStr(w, http.StatusBadRequest, "format must be text or ndjson")
+        return
+    }
+
+    // headers go out with the first page, so ownership errors still get JSON
+    started := false
+    start := func() {
+        if started { return }
+        started = true
+        w.Header().Set("Content-Type", contentType)
+        w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="deployment-%s.%s"`, deploymentID, ext))
+        w.WriteHeader(http.StatusOK)
+    }
+    enc := json.NewEncoder(w)
+    err := h.svc.ExportDeploymentLogs(r.Context(), deploymentID, userID, q, func(items []models.DeploymentLog) error {
+        start()
+        for _, l := range items {
+            if ext == "ndjson" {
+                if err := enc.Encode(l); err != nil { return err }
+                continue
+            }
+            if err := writeLogLine(w, l); err != nil { return err }
+        }
+        return nil
+    })
+    if err != nil {
+        if !started { writeAppError(w, err) }
+        return
+    }
+    start()
+}
+
+// writeLogLine renders a log line as "<ts> <LEVEL> [phase] message {data}".
+func writeLogLine(w io.Writer, l models.DeploymentLog) error {
+    line := l.Timestamp.UTC().Format(time.RFC3339Nano) + " " + strings.ToUpper(l.Level)
+    if l.Phase != "" {
+        line += " [" + l.Phase + "]"
+    }
+    line += " " + l.Message
+    if len(l.Data) > 0 && string(l.Data) != "null" {
+        line += " " + string(l.Data)
+    }
+    _, err := io.WriteString(w, line+"\n")
+    return err
+}
 
+// parseLogQuery reads the log filters shared by the list and download endpoints.
+func parseLogQuery(w http.ResponseWriter, r *http.Request) (repository.LogQuery, bool) {
+    var q repository.LogQuery
+    v := r.URL.Query()
+    if s := v.Get("after_seq"); s != "" {
+        n, err := strconv.ParseInt(s, 10, 64)
+        if err != nil || n < 0 {
+            writeErrorStr(w, http.StatusBadRequest, "after_seq must be a non-negative integer")
+            return q, false
+        }
+        q.AfterSeq = n
+    }
+    if s := v.Get("limit"); s != "" {
+        n, err := strconv.Atoi(s)
+        if err != nil || n <= 0 {
+            writeErrorStr(w, http.StatusBadRequest, "limit must be a positive integer")
+            return q, false
+        }
+        q.Limit = n
+    }
+    if s := v.Get("level"); s != "" {
+        for _, l := range strings.Split(s, ",") {
+            if l = strings.TrimSpace(strings.ToLower(l)); l != "" {
+                q.Levels = append(q.Levels, l)
+            }
+        }
+    }
+    q.Search = strings.TrimSpace(v.Get("q"))
+    return q, true
+}
+
+// ExtendTTL godoc
+// @Summary      Extend deployment TTL
+// @Description  Push back when an ephemeral deployment is destroyed, or give a deployment an expiry
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        request body ExtendTTLRequest true "New expiry"
+// @Success      200 {object} types.APIResponse{data=models.Deployment}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/ttl [post]
+func (h *DeploymentsHandler) ExtendTTL(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    var req ExtendTTLRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeErrorStr(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+    input := &services.ExtendTTLInput{ExpiresAt: req.ExpiresAt}
+    if req.ExtendBy != "" {
+        by, err := time.ParseDuration(req.ExtendBy)
+        if err != nil || by <= 0 {
+            writeErrorStr(w, http.StatusBadRequest, "extend_by must be a positive duration such as 24h")
+            return
+        }
+        input.ExtendBy = by
+    }
+
+    d, err := h.svc.ExtendDeploymentTTL(r.Context(), deploymentID, userID, input)
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, types.APIResponse{ Success: true, Data: d })
+}
+
+// Destroy godoc
+// @Summary      Destroy deployment
+// @Description  Destroy a deployment, or only the given nodes together with every node depending on them
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        request body DestroyRequest false "Nodes to destroy"
+// @Success      202 {object} types.APIResponse
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/destroy [post]
+func (h *DeploymentsHandler) Destroy(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    req, ok := decodeDestroyRequest(w, r)
+    if !ok { return }
 
+    if err := h.svc.DestroyDeployment(r.Context(), deploymentID, userID, req.NodeIDs); err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusAccepted, types.APIResponse{ Success: true })
+}
+
+// PreviewDestroy godoc
+// @Summary      Preview destroy
+// @Description  List the nodes and Terraform resources a destroy request would remove
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        request body DestroyRequest false "Nodes to destroy"
+// @Success      200 {object} types.APIResponse{data=services.DestroyPreview}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/destroy/preview [post]
+func (h *DeploymentsHandler) PreviewDestroy(w http.ResponseWriter, r *http.Request) {
+    deploymentID, userID, ok := deploymentAndUser(w, r)
+    if !ok { return }
+    req, ok := decodeDestroyRequest(w, r)
+    if !ok { return }
 
+    preview, err := h.svc.PreviewDestroy(r.Context(), deploymentID, userID, req.NodeIDs)
+    if err != nil {
+        writeAppError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, types.APIResponse{ Success: true, Data: preview })
+}
+
+// decodeDestroyRequest reads an optional DestroyRequest body.
+func decodeDestroyRequest(w http.ResponseWriter, r *http.Request) (DestroyRequest, bool) {
+    var req DestroyRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+        writeErrorStr(w, http.StatusBadRequest, "invalid request body")
+        return req, false
+    }
+    return req, true
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// DriftHandler serves drift reports of deployments.
+type DriftHandler struct {
+	svc services.DriftService
+}
+
+func NewDriftHandler(svc services.DriftService) *DriftHandler {
+	return &DriftHandler{svc: svc}
+}
+
+// ResolveDriftRequest picks, per drift change ID, "keep_graph" or "accept_actual".
+type ResolveDriftRequest struct {
+	ReportID  uuid.UUID         `json:"report_id"`
+	Decisions map[string]string `json:"decisions"`
+}
+
+// Latest godoc
+// @Summary      Get latest drift report
+// @Description  Get the most recent drift check result of a deployment
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.DriftReport}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/drift [get]
+func (h *DriftHandler) Latest(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.svc.GetLatestDriftReport(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: report})
+}
+
+// ListReports godoc
+// @Summary      List drift reports
+// @Description  List the recent drift check results of a deployment, newest first
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.DriftReport}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/drift/reports [get]
+func (h *DriftHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.ListDriftReports(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: items})
+}
+
+// Check godoc
+// @Summary      Check for drift now
+// @Description  Queue a drift check of an applied deployment outside its project schedule
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      202 {object} types.APIResponse
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/drift/check [post]
+func (h *DriftHandler) Check(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.RequestDriftCheck(r.Context(), deploymentID, userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, types.APIResponse{Success: true})
+}
+
+// Changes godoc
+// @Summary      List drift changes
+// @Description  Map the latest drift report onto the deployed graph as per-property graph/actual values
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=services.DriftChanges}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/drift/changes [get]
+func (h *DriftHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	changes, err := h.svc.ListDriftChanges(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: changes})
+}
+
+// Resolve godoc
+// @Summary      Accept drift into the graph
+// @Description  Write the actual values of accepted drift changes into a new graph version
+// @Tags         Deployments
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        request body ResolveDriftRequest true "Decisions per change"
+// @Success      201 {object} types.APIResponse{data=models.ProjectGraph}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/drift/resolve [post]
+func (h *DriftHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req ResolveDriftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	g, err := h.svc.ResolveDrift(r.Context(), deploymentID, userID, &services.ResolveDriftInput{ReportID: req.ReportID, Decisions: req.Decisions})
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: g})
+}
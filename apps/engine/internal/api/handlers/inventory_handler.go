@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/iac-studio/engine/internal/api/middleware"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// InventoryHandler serves the resource inventory synced from Terraform state.
+type InventoryHandler struct {
+	svc services.InventoryService
+}
+
+func NewInventoryHandler(svc services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{svc: svc}
+}
+
+// ListProject godoc
+// @Summary      List project resources
+// @Description  List the live cloud resources of every deployment of a project
+// @Tags         Projects
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        type query string false "Resource type, e.g. aws_instance"
+// @Param        node_id query string false "Graph node ID"
+// @Success      200 {object} types.APIResponse{data=[]models.Resource}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/resources [get]
+func (h *InventoryHandler) ListProject(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	filter := repository.ResourceFilter{
+		Type:   r.URL.Query().Get("type"),
+		NodeID: r.URL.Query().Get("node_id"),
+	}
+	items, err := h.svc.ListProjectResources(r.Context(), projectID, userID, filter)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: items})
+}
+
+// ListDeployment godoc
+// @Summary      List deployment resources
+// @Description  List the live cloud resources managed by a deployment
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.Resource}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/resources [get]
+func (h *InventoryHandler) ListDeployment(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.ListDeploymentResources(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: items})
+}
+
+// projectAndUser parses the project ID path parameter and the caller's ID,
+// writing the error response when either is invalid.
+func projectAndUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid project id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return projectID, userID, true
+}
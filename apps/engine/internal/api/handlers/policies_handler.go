@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/iac-studio/engine/internal/api/middleware"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// PoliciesHandler serves custom policy rules and the policy findings of
+// deployments.
+type PoliciesHandler struct {
+	svc services.PolicyService
+}
+
+func NewPoliciesHandler(svc services.PolicyService) *PoliciesHandler {
+	return &PoliciesHandler{svc: svc}
+}
+
+// List godoc
+// @Summary      List account-wide policy rules
+// @Description  List the custom policy rules that apply to all of the user's projects
+// @Tags         Policies
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} types.APIResponse{data=[]models.PolicyRule}
+// @Failure      401 {object} types.APIResponse{error=types.APIError}
+// @Router       /policies [get]
+func (h *PoliciesHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := policyUser(w, r)
+	if !ok {
+		return
+	}
+	rules, err := h.svc.ListRules(r.Context(), userID, nil)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rules})
+}
+
+// ListProject godoc
+// @Summary      List project policy rules
+// @Description  List the custom policy rules evaluated for a project: its own and the account-wide ones
+// @Tags         Policies
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.PolicyRule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/policies [get]
+func (h *PoliciesHandler) ListProject(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	rules, err := h.svc.ListRules(r.Context(), userID, &projectID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rules})
+}
+
+// ListBuiltin godoc
+// @Summary      List built-in policy rules
+// @Description  List the built-in policy rules with their enforcement as configured by the project's policy_settings
+// @Tags         Policies
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]policy.Rule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/policies/builtin [get]
+func (h *PoliciesHandler) ListBuiltin(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	rules, err := h.svc.ListBuiltinRules(r.Context(), projectID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rules})
+}
+
+// Create godoc
+// @Summary      Create policy rule
+// @Description  Create a declarative policy rule for a project, or for all projects when project_id is omitted. Mandatory rules fail deployments before apply
+// @Tags         Policies
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body services.PolicyRuleInput true "Rule"
+// @Success      201 {object} types.APIResponse{data=models.PolicyRule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Router       /policies [post]
+func (h *PoliciesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := policyUser(w, r)
+	if !ok {
+		return
+	}
+	var req services.PolicyRuleInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rule, err := h.svc.CreateRule(r.Context(), userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: rule})
+}
+
+// Update godoc
+// @Summary      Update policy rule
+// @Description  Replace a policy rule's name, description, enforcement and definition; its scope cannot change
+// @Tags         Policies
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        ruleID path string true "Rule ID" format(uuid)
+// @Param        request body services.PolicyRuleInput true "Rule"
+// @Success      200 {object} types.APIResponse{data=models.PolicyRule}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /policies/{ruleID} [put]
+func (h *PoliciesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ruleID, userID, ok := ruleAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req services.PolicyRuleInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rule, err := h.svc.UpdateRule(r.Context(), ruleID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rule})
+}
+
+// Delete godoc
+// @Summary      Delete policy rule
+// @Tags         Policies
+// @Security     BearerAuth
+// @Param        ruleID path string true "Rule ID" format(uuid)
+// @Success      204
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /policies/{ruleID} [delete]
+func (h *PoliciesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ruleID, userID, ok := ruleAndUser(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteRule(r.Context(), ruleID, userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFindings godoc
+// @Summary      Get deployment policy findings
+// @Description  List the findings of the deployment's last policy evaluation; mandatory findings blocked the apply
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.PolicyFinding}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/policy-findings [get]
+func (h *PoliciesHandler) ListFindings(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+	findings, err := h.svc.ListFindings(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: findings})
+}
+
+func policyUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func ruleAndUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	ruleID, err := uuid.Parse(chi.URLParam(r, "ruleID"))
+	if err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid rule id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, ok := policyUser(w, r)
+	return ruleID, userID, ok
+}
@@ -7,11 +7,15 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 
 	"github.com/iac-studio/engine/internal/api/middleware"
 	"github.com/iac-studio/engine/internal/api/types"
 	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/policy"
 	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/internal/services"
+	appErr "github.com/iac-studio/engine/pkg/errors"
 )
 
 type ProjectsHandler struct {
@@ -34,6 +38,15 @@ type UpdateProjectRequest struct {
 	Description   *string `json:"description,omitempty" example:"Updated description"`
 	CloudProvider *string `json:"cloud_provider,omitempty" example:"aws"`
 	Archived      *bool   `json:"archived,omitempty" example:"false"`
+	DriftSchedule *string `json:"drift_schedule,omitempty" example:"6h"`
+	// EnvironmentTTLs sets default deployment lifetimes per environment type
+	EnvironmentTTLs map[string]string `json:"environment_ttls,omitempty"`
+	// ApprovalRules holds applies until approvers sign off; required_approvals 0 turns it off
+	ApprovalRules *services.ApprovalRules `json:"approval_rules,omitempty"`
+	// PolicySettings sets the enforcement of built-in policy rules and the instance type allowlist
+	PolicySettings *policy.Settings `json:"policy_settings,omitempty"`
+	// Budget caps the estimated monthly cost of deployments; monthly_limit 0 turns it off
+	Budget *services.Budget `json:"budget,omitempty"`
 }
 
 // List godoc
@@ -282,6 +295,46 @@ func (h *ProjectsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if req.Archived != nil {
 		project.Archived = *req.Archived
 	}
+	if req.DriftSchedule != nil {
+		// empty resets to the server default
+		if _, err := services.DriftInterval(*req.DriftSchedule, ""); err != nil {
+			writeAppError(w, err)
+			return
+		}
+		project.DriftSchedule = *req.DriftSchedule
+	}
+	if req.EnvironmentTTLs != nil {
+		b, _ := json.Marshal(req.EnvironmentTTLs)
+		if _, err := services.ParseEnvironmentTTLs(b); err != nil {
+			writeAppError(w, err)
+			return
+		}
+		project.EnvironmentTTLs = datatypes.JSON(b)
+	}
+	if req.ApprovalRules != nil {
+		b, _ := json.Marshal(req.ApprovalRules)
+		if _, err := services.ParseApprovalRules(b, project.UserID); err != nil {
+			writeAppError(w, err)
+			return
+		}
+		project.ApprovalRules = datatypes.JSON(b)
+	}
+	if req.PolicySettings != nil {
+		b, _ := json.Marshal(req.PolicySettings)
+		if _, err := policy.ParseSettings(b); err != nil {
+			writeErrorStr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		project.PolicySettings = datatypes.JSON(b)
+	}
+	if req.Budget != nil {
+		b, _ := json.Marshal(req.Budget)
+		if _, err := services.ParseBudget(b); err != nil {
+			writeAppError(w, err)
+			return
+		}
+		project.Budget = datatypes.JSON(b)
+	}
 
 	// Save updates
 	if err := h.repo.Update(r.Context(), &project); err != nil {
@@ -376,4 +429,29 @@ func writeErrorStr(w http.ResponseWriter, status int, message string) {
 			Message: message,
 		},
 	})
-}
\ No newline at end of file
+}
+
+// writeAppError maps service errors to an HTTP status using their appErr code.
+// Services report ownership failures as unauthorized, which is a 403 for an
+// authenticated caller.
+func writeAppError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case appErr.IsCode(err, appErr.CodeInvalid):
+		status = http.StatusBadRequest
+	case appErr.IsCode(err, appErr.CodeNotFound):
+		status = http.StatusNotFound
+	case appErr.IsCode(err, appErr.CodeConflict), appErr.IsCode(err, appErr.CodeAlreadyExists):
+		status = http.StatusConflict
+	case appErr.IsCode(err, appErr.CodeUnauthorized), appErr.IsCode(err, appErr.CodeForbidden):
+		status = http.StatusForbidden
+	case appErr.IsCode(err, appErr.CodeUnavailable):
+		status = http.StatusServiceUnavailable
+	case appErr.IsCode(err, appErr.CodeBudgetExceeded):
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, types.APIResponse{
+		Success: false,
+		Error:   types.FromAppError(err),
+	})
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// RecommendationsHandler serves project recommendations and their
+// lifecycle.
+type RecommendationsHandler struct {
+	svc services.RecommendationService
+}
+
+func NewRecommendationsHandler(svc services.RecommendationService) *RecommendationsHandler {
+	return &RecommendationsHandler{svc: svc}
+}
+
+// List godoc
+// @Summary      List project recommendations
+// @Description  List a project's recommendations, newest first. Snoozed recommendations whose snooze ran out are open again
+// @Tags         Recommendations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        source query string false "Source" Enums(security, cost, performance, ai)
+// @Param        severity query string false "Severity" Enums(low, medium, high, critical)
+// @Param        status query string false "Status" Enums(open, dismissed, applied, snoozed)
+// @Success      200 {object} types.APIResponse{data=[]models.Recommendation}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/recommendations [get]
+func (h *RecommendationsHandler) List(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter := repository.RecommendationFilter{Source: q.Get("source"), Severity: q.Get("severity"), Status: q.Get("status")}
+	recs, err := h.svc.List(r.Context(), projectID, userID, filter)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: recs})
+}
+
+// Get godoc
+// @Summary      Get a recommendation
+// @Tags         Recommendations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        recID path string true "Recommendation ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.Recommendation}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/recommendations/{recID} [get]
+func (h *RecommendationsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	projectID, recID, userID, ok := recommendationAndUser(w, r)
+	if !ok {
+		return
+	}
+	rec, err := h.svc.Get(r.Context(), projectID, recID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rec})
+}
+
+// Create godoc
+// @Summary      Create a recommendation
+// @Description  Add a recommendation to a project, optionally with a graph patch that applies it
+// @Tags         Recommendations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        request body services.RecommendationInput true "Recommendation"
+// @Success      201 {object} types.APIResponse{data=models.Recommendation}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/recommendations [post]
+func (h *RecommendationsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req services.RecommendationInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rec, err := h.svc.Create(r.Context(), projectID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: rec})
+}
+
+// Update godoc
+// @Summary      Update a recommendation
+// @Description  Change the fields that are set: dismiss, snooze until a time or reopen a recommendation, or edit its severity, title and description
+// @Tags         Recommendations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        recID path string true "Recommendation ID" format(uuid)
+// @Param        request body services.RecommendationUpdate true "Changes"
+// @Success      200 {object} types.APIResponse{data=models.Recommendation}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/recommendations/{recID} [put]
+func (h *RecommendationsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	projectID, recID, userID, ok := recommendationAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req services.RecommendationUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	rec, err := h.svc.Update(r.Context(), projectID, recID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rec})
+}
+
+// Delete godoc
+// @Summary      Delete a recommendation
+// @Tags         Recommendations
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        recID path string true "Recommendation ID" format(uuid)
+// @Success      204
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/recommendations/{recID} [delete]
+func (h *RecommendationsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	projectID, recID, userID, ok := recommendationAndUser(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(r.Context(), projectID, recID, userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Apply godoc
+// @Summary      Apply a recommendation
+// @Description  Apply the recommendation's patch to the current graph, save the result as a new graph version and mark the recommendation applied. Conflicts when the graph changed since the recommendation was made
+// @Tags         Recommendations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        recID path string true "Recommendation ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.Recommendation}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/recommendations/{recID}/apply [post]
+func (h *RecommendationsHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	projectID, recID, userID, ok := recommendationAndUser(w, r)
+	if !ok {
+		return
+	}
+	rec, err := h.svc.Apply(r.Context(), projectID, recID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: rec})
+}
+
+func recommendationAndUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	recID, err := uuid.Parse(chi.URLParam(r, "recID"))
+	if err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid recommendation id")
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return projectID, recID, userID, true
+}
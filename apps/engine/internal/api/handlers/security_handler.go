@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// SecurityHandler serves the security analysis of project graphs.
+type SecurityHandler struct {
+	svc services.SecurityService
+}
+
+func NewSecurityHandler(svc services.SecurityService) *SecurityHandler {
+	return &SecurityHandler{svc: svc}
+}
+
+// Analyze godoc
+// @Summary      Analyze project security
+// @Description  Check a graph version for public buckets, unencrypted or exposed databases, open ingress and instances without IMDSv2. The findings update the project's security recommendations; known findings keep their status and resolved open ones are removed
+// @Tags         Projects
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        version query int false "Graph version, defaults to the current one"
+// @Success      200 {object} types.APIResponse{data=services.SecurityReport}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/security/analyze [post]
+func (h *SecurityHandler) Analyze(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorStr(w, http.StatusBadRequest, "version must be a positive integer")
+			return
+		}
+		version = n
+	}
+	report, err := h.svc.Analyze(r.Context(), projectID, userID, version)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: report})
+}
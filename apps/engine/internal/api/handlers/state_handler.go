@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/iac-studio/engine/internal/api/middleware"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// StateHandler serves the Terraform state history of deployments.
+type StateHandler struct {
+	svc services.StateService
+}
+
+func NewStateHandler(svc services.StateService) *StateHandler {
+	return &StateHandler{svc: svc}
+}
+
+// ListVersions godoc
+// @Summary      List state versions
+// @Description  List every recorded Terraform state version of a deployment, newest first
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.StateVersion}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/state/versions [get]
+func (h *StateHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.ListStateVersions(r.Context(), deploymentID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: items})
+}
+
+// DownloadVersion godoc
+// @Summary      Download state version
+// @Description  Download the raw Terraform state stored in a version
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        serial path int true "State serial"
+// @Success      200 {object} object
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/state/versions/{serial} [get]
+func (h *StateHandler) DownloadVersion(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+	serial, err := strconv.Atoi(chi.URLParam(r, "serial"))
+	if err != nil || serial <= 0 {
+		writeErrorStr(w, http.StatusBadRequest, "invalid serial")
+		return
+	}
+
+	v, err := h.svc.GetStateVersion(r.Context(), deploymentID, userID, serial)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%d.tfstate"`, deploymentID, serial))
+	w.Header().Set("X-State-Checksum", v.Checksum)
+	w.WriteHeader(http.StatusOK)
+	w.Write(v.State)
+}
+
+// PromoteVersion godoc
+// @Summary      Promote state version
+// @Description  Copy an earlier state version into a new version and make it current
+// @Tags         Deployments
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Deployment ID" format(uuid)
+// @Param        serial path int true "State serial"
+// @Success      200 {object} types.APIResponse{data=models.StateVersion}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /deployments/{id}/state/versions/{serial}/promote [post]
+func (h *StateHandler) PromoteVersion(w http.ResponseWriter, r *http.Request) {
+	deploymentID, userID, ok := deploymentAndUser(w, r)
+	if !ok {
+		return
+	}
+	serial, err := strconv.Atoi(chi.URLParam(r, "serial"))
+	if err != nil || serial <= 0 {
+		writeErrorStr(w, http.StatusBadRequest, "invalid serial")
+		return
+	}
+
+	v, err := h.svc.PromoteStateVersion(r.Context(), deploymentID, userID, serial)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: v})
+}
+
+// deploymentAndUser parses the {id} URL param and the authenticated user,
+// writing a 400/401 response and returning false when either is invalid.
+func deploymentAndUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	deploymentID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid deployment id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return deploymentID, userID, true
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/iac-studio/engine/internal/api/middleware"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// TasksHandler serves the dead-letter queue of deployment tasks.
+type TasksHandler struct {
+	svc services.TaskService
+}
+
+func NewTasksHandler(svc services.TaskService) *TasksHandler {
+	return &TasksHandler{svc: svc}
+}
+
+// ListArchived godoc
+// @Summary      List archived tasks
+// @Description  List the caller's deployment tasks that failed permanently or exhausted their retries
+// @Tags         Tasks
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} types.APIResponse{data=[]services.ArchivedTask}
+// @Failure      401 {object} types.APIResponse{error=types.APIError}
+// @Failure      503 {object} types.APIResponse{error=types.APIError}
+// @Router       /tasks/archived [get]
+func (h *TasksHandler) ListArchived(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+		return
+	}
+
+	items, err := h.svc.ListArchivedTasks(r.Context(), userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: items})
+}
+
+// RetryArchived godoc
+// @Summary      Retry an archived task
+// @Description  Move an archived deployment task back to the queue; the worker resumes from the deployment's checkpoint
+// @Tags         Tasks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        taskID path string true "Task ID"
+// @Success      202 {object} types.APIResponse
+// @Failure      401 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /tasks/archived/{taskID}/retry [post]
+func (h *TasksHandler) RetryArchived(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+		return
+	}
+
+	if err := h.svc.RetryArchivedTask(r.Context(), chi.URLParam(r, "taskID"), userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, types.APIResponse{Success: true})
+}
+
+// DeleteArchived godoc
+// @Summary      Delete an archived task
+// @Description  Drop an archived deployment task and mark its deployment failed, so it leaves the project queue and a new task can be queued for it
+// @Tags         Tasks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        taskID path string true "Task ID"
+// @Success      200 {object} types.APIResponse
+// @Failure      401 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /tasks/archived/{taskID} [delete]
+func (h *TasksHandler) DeleteArchived(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		writeErrorStr(w, http.StatusUnauthorized, "invalid user id")
+		return
+	}
+
+	if err := h.svc.DeleteArchivedTask(r.Context(), chi.URLParam(r, "taskID"), userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true})
+}
@@ -1,5 +1,265 @@
-//go:build ignore
-
 package handlers
 
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/api/types"
+	"github.com/iac-studio/engine/internal/services"
+)
+
+// WebhooksHandler serves a project's outbound webhooks and their deliveries.
+type WebhooksHandler struct {
+	svc services.WebhookService
+}
+
+func NewWebhooksHandler(svc services.WebhookService) *WebhooksHandler {
+	return &WebhooksHandler{svc: svc}
+}
+
+// ListWebhooks godoc
+// @Summary      List webhooks
+// @Tags         Webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=[]models.Webhook}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks [get]
+func (h *WebhooksHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	hooks, err := h.svc.List(r.Context(), projectID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: hooks})
+}
+
+// GetWebhook godoc
+// @Summary      Get a webhook
+// @Tags         Webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        webhookID path string true "Webhook ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.Webhook}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks/{webhookID} [get]
+func (h *WebhooksHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID, webhookID, userID, ok := projectItemAndUser(w, r, "webhookID", "webhook")
+	if !ok {
+		return
+	}
+	hook, err := h.svc.Get(r.Context(), projectID, webhookID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: hook})
+}
+
+// CreateWebhook godoc
+// @Summary      Register a webhook
+// @Description  Register an endpoint for the project's events: deployment.created, .planned, .applied, .failed, .destroyed, graph.saved and the other event types. Each delivery is a JSON POST of the event with X-IaC-Event, X-IaC-Delivery, X-IaC-Timestamp, Idempotency-Key and an X-IaC-Signature of "sha256=" and the hex HMAC-SHA256 of the timestamp, a dot and the body. The signing secret is only returned here
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        request body services.WebhookInput true "Webhook"
+// @Success      201 {object} types.APIResponse{data=services.CreatedWebhook}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks [post]
+func (h *WebhooksHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, ok := projectAndUser(w, r)
+	if !ok {
+		return
+	}
+	var req services.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	hook, err := h.svc.Create(r.Context(), projectID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.APIResponse{Success: true, Data: hook})
+}
+
+// UpdateWebhook godoc
+// @Summary      Update a webhook
+// @Description  Change the fields that are set: re-target, re-filter or enable and disable a webhook
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        webhookID path string true "Webhook ID" format(uuid)
+// @Param        request body services.WebhookUpdate true "Changes"
+// @Success      200 {object} types.APIResponse{data=models.Webhook}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks/{webhookID} [put]
+func (h *WebhooksHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID, webhookID, userID, ok := projectItemAndUser(w, r, "webhookID", "webhook")
+	if !ok {
+		return
+	}
+	var req services.WebhookUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	hook, err := h.svc.Update(r.Context(), projectID, webhookID, userID, &req)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: hook})
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Delete a webhook and its delivery history; queued deliveries are dropped
+// @Tags         Webhooks
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        webhookID path string true "Webhook ID" format(uuid)
+// @Success      204
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks/{webhookID} [delete]
+func (h *WebhooksHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID, webhookID, userID, ok := projectItemAndUser(w, r, "webhookID", "webhook")
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(r.Context(), projectID, webhookID, userID); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries godoc
+// @Summary      List webhook deliveries
+// @Description  List a webhook's deliveries, newest first, without their payloads
+// @Tags         Webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        webhookID path string true "Webhook ID" format(uuid)
+// @Param        limit query int false "Maximum number of deliveries" default(500)
+// @Success      200 {object} types.APIResponse{data=[]models.WebhookDelivery}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks/{webhookID}/deliveries [get]
+func (h *WebhooksHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	projectID, webhookID, userID, ok := projectItemAndUser(w, r, "webhookID", "webhook")
+	if !ok {
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorStr(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	deliveries, err := h.svc.ListDeliveries(r.Context(), projectID, webhookID, userID, limit)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: deliveries})
+}
+
+// GetDelivery godoc
+// @Summary      Get a webhook delivery
+// @Description  Get a delivery with the payload it sent
+// @Tags         Webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        webhookID path string true "Webhook ID" format(uuid)
+// @Param        deliveryID path string true "Delivery ID" format(uuid)
+// @Success      200 {object} types.APIResponse{data=models.WebhookDelivery}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks/{webhookID}/deliveries/{deliveryID} [get]
+func (h *WebhooksHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	projectID, webhookID, deliveryID, userID, ok := webhookDeliveryAndUser(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.svc.GetDelivery(r.Context(), projectID, webhookID, deliveryID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.APIResponse{Success: true, Data: d})
+}
+
+// Redeliver godoc
+// @Summary      Redeliver a webhook delivery
+// @Description  Queue the delivery's payload again as a new delivery, signed anew. The Idempotency-Key stays the event ID, so receivers that already handled it can skip it
+// @Tags         Webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Project ID" format(uuid)
+// @Param        webhookID path string true "Webhook ID" format(uuid)
+// @Param        deliveryID path string true "Delivery ID" format(uuid)
+// @Success      202 {object} types.APIResponse{data=models.WebhookDelivery}
+// @Failure      400 {object} types.APIResponse{error=types.APIError}
+// @Failure      403 {object} types.APIResponse{error=types.APIError}
+// @Failure      404 {object} types.APIResponse{error=types.APIError}
+// @Failure      409 {object} types.APIResponse{error=types.APIError}
+// @Router       /projects/{id}/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver [post]
+func (h *WebhooksHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	projectID, webhookID, deliveryID, userID, ok := webhookDeliveryAndUser(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.svc.Redeliver(r.Context(), projectID, webhookID, deliveryID, userID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, types.APIResponse{Success: true, Data: d})
+}
 
+// webhookDeliveryAndUser reads the project, webhook and delivery IDs and the
+// user; on failure it has written the response.
+func webhookDeliveryAndUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	projectID, webhookID, userID, ok := projectItemAndUser(w, r, "webhookID", "webhook")
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	deliveryID, err := uuid.Parse(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		writeErrorStr(w, http.StatusBadRequest, "invalid delivery id")
+		return uuid.Nil, uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return projectID, webhookID, deliveryID, userID, true
+}
@@ -12,11 +12,25 @@ import (
 )
 
 type Dependencies struct {
-	HMACSecret         []byte
-	AuthHandler        *handlers.AuthHandler
-	ProjectsHandler    *handlers.ProjectsHandler
-	DeploymentsHandler *handlers.DeploymentsHandler
-	GraphsHandler      *handlers.GraphsHandler
+	HMACSecret             []byte
+	AuthHandler            *handlers.AuthHandler
+	ProjectsHandler        *handlers.ProjectsHandler
+	DeploymentsHandler     *handlers.DeploymentsHandler
+	GraphsHandler          *handlers.GraphsHandler
+	StateHandler           *handlers.StateHandler
+	DriftHandler           *handlers.DriftHandler
+	TasksHandler           *handlers.TasksHandler
+	InventoryHandler       *handlers.InventoryHandler
+	ApprovalsHandler       *handlers.ApprovalsHandler
+	PoliciesHandler        *handlers.PoliciesHandler
+	SecurityHandler        *handlers.SecurityHandler
+	CostHandler            *handlers.CostHandler
+	AIHandler              *handlers.AIHandler
+	RecommendationsHandler *handlers.RecommendationsHandler
+	AnomaliesHandler       *handlers.AnomaliesHandler
+	AlertsHandler          *handlers.AlertsHandler
+	WebhooksHandler        *handlers.WebhooksHandler
+	CloudAccountsHandler   *handlers.CloudAccountsHandler
 }
 
 func NewRouter(dep Dependencies) http.Handler {
@@ -60,6 +74,41 @@ func NewRouter(dep Dependencies) http.Handler {
 				pr.Get("/{id}", dep.ProjectsHandler.Get)
 				pr.Put("/{id}", dep.ProjectsHandler.Update)
 				pr.Delete("/{id}", dep.ProjectsHandler.Delete)
+				pr.Get("/{id}/resources", dep.InventoryHandler.ListProject)
+				pr.Get("/{id}/policies", dep.PoliciesHandler.ListProject)
+				pr.Get("/{id}/policies/builtin", dep.PoliciesHandler.ListBuiltin)
+				pr.Post("/{id}/security/analyze", dep.SecurityHandler.Analyze)
+				pr.Get("/{id}/recommendations", dep.RecommendationsHandler.List)
+				pr.Post("/{id}/recommendations", dep.RecommendationsHandler.Create)
+				pr.Get("/{id}/recommendations/{recID}", dep.RecommendationsHandler.Get)
+				pr.Put("/{id}/recommendations/{recID}", dep.RecommendationsHandler.Update)
+				pr.Delete("/{id}/recommendations/{recID}", dep.RecommendationsHandler.Delete)
+				pr.Post("/{id}/recommendations/{recID}/apply", dep.RecommendationsHandler.Apply)
+				pr.Get("/{id}/anomalies", dep.AnomaliesHandler.List)
+				pr.Get("/{id}/alerts/channels", dep.AlertsHandler.ListChannels)
+				pr.Post("/{id}/alerts/channels", dep.AlertsHandler.CreateChannel)
+				pr.Get("/{id}/alerts/channels/{channelID}", dep.AlertsHandler.GetChannel)
+				pr.Put("/{id}/alerts/channels/{channelID}", dep.AlertsHandler.UpdateChannel)
+				pr.Delete("/{id}/alerts/channels/{channelID}", dep.AlertsHandler.DeleteChannel)
+				pr.Post("/{id}/alerts/channels/{channelID}/test", dep.AlertsHandler.TestChannel)
+				pr.Get("/{id}/alerts/rules", dep.AlertsHandler.ListRules)
+				pr.Post("/{id}/alerts/rules", dep.AlertsHandler.CreateRule)
+				pr.Get("/{id}/alerts/rules/{ruleID}", dep.AlertsHandler.GetRule)
+				pr.Put("/{id}/alerts/rules/{ruleID}", dep.AlertsHandler.UpdateRule)
+				pr.Delete("/{id}/alerts/rules/{ruleID}", dep.AlertsHandler.DeleteRule)
+				pr.Get("/{id}/alerts/deliveries", dep.AlertsHandler.ListDeliveries)
+				pr.Get("/{id}/webhooks", dep.WebhooksHandler.ListWebhooks)
+				pr.Post("/{id}/webhooks", dep.WebhooksHandler.CreateWebhook)
+				pr.Get("/{id}/webhooks/{webhookID}", dep.WebhooksHandler.GetWebhook)
+				pr.Put("/{id}/webhooks/{webhookID}", dep.WebhooksHandler.UpdateWebhook)
+				pr.Delete("/{id}/webhooks/{webhookID}", dep.WebhooksHandler.DeleteWebhook)
+				pr.Get("/{id}/webhooks/{webhookID}/deliveries", dep.WebhooksHandler.ListDeliveries)
+				pr.Get("/{id}/webhooks/{webhookID}/deliveries/{deliveryID}", dep.WebhooksHandler.GetDelivery)
+				pr.Post("/{id}/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", dep.WebhooksHandler.Redeliver)
+				pr.Get("/{id}/cost", dep.CostHandler.Estimate)
+				pr.Post("/{id}/cost", dep.CostHandler.EstimateGraph)
+				pr.Get("/{id}/cost/diff", dep.CostHandler.Diff)
+				pr.Get("/{id}/budget/overrides", dep.CostHandler.BudgetOverrides)
 			})
 
 
@@ -67,6 +116,58 @@ func NewRouter(dep Dependencies) http.Handler {
 			protected.Route("/deployments", func(dr chi.Router) {
 				dr.Get("/", dep.DeploymentsHandler.List)
 				dr.Post("/", dep.DeploymentsHandler.Create)
+				dr.Post("/plan", dep.DeploymentsHandler.CreatePlan)
+				dr.Get("/{id}/plan", dep.DeploymentsHandler.Plan)
+				dr.Post("/{id}/destroy", dep.DeploymentsHandler.Destroy)
+				dr.Post("/{id}/destroy/preview", dep.DeploymentsHandler.PreviewDestroy)
+				dr.Post("/{id}/ttl", dep.DeploymentsHandler.ExtendTTL)
+				dr.Get("/{id}/queue", dep.DeploymentsHandler.QueueStatus)
+				dr.Post("/{id}/cancel", dep.DeploymentsHandler.Cancel)
+				dr.Get("/{id}/history", dep.DeploymentsHandler.History)
+				dr.Get("/{id}/approvals", dep.ApprovalsHandler.List)
+				dr.Post("/{id}/approve", dep.ApprovalsHandler.Approve)
+				dr.Post("/{id}/reject", dep.ApprovalsHandler.Reject)
+				dr.Get("/{id}/resources", dep.InventoryHandler.ListDeployment)
+				dr.Get("/{id}/policy-findings", dep.PoliciesHandler.ListFindings)
+				dr.Get("/{id}/logs", dep.DeploymentsHandler.Logs)
+				dr.Get("/{id}/logs/download", dep.DeploymentsHandler.DownloadLogs)
+
+				// Terraform state history
+				dr.Get("/{id}/state/versions", dep.StateHandler.ListVersions)
+				dr.Get("/{id}/state/versions/{serial}", dep.StateHandler.DownloadVersion)
+				dr.Post("/{id}/state/versions/{serial}/promote", dep.StateHandler.PromoteVersion)
+
+				// Drift detection
+				dr.Get("/{id}/drift", dep.DriftHandler.Latest)
+				dr.Get("/{id}/drift/reports", dep.DriftHandler.ListReports)
+				dr.Post("/{id}/drift/check", dep.DriftHandler.Check)
+				dr.Get("/{id}/drift/changes", dep.DriftHandler.Changes)
+				dr.Post("/{id}/drift/resolve", dep.DriftHandler.Resolve)
+			})
+
+			// Custom policy rules, per project or account-wide
+			protected.Route("/policies", func(pr chi.Router) {
+				pr.Get("/", dep.PoliciesHandler.List)
+				pr.Post("/", dep.PoliciesHandler.Create)
+				pr.Put("/{ruleID}", dep.PoliciesHandler.Update)
+				pr.Delete("/{ruleID}", dep.PoliciesHandler.Delete)
+			})
+
+			// Cloud accounts, with credentials encrypted at rest
+			protected.Route("/cloud-accounts", func(cr chi.Router) {
+				cr.Get("/", dep.CloudAccountsHandler.List)
+				cr.Post("/", dep.CloudAccountsHandler.Create)
+				cr.Delete("/{accountID}", dep.CloudAccountsHandler.Delete)
+			})
+
+			// Price catalog of cost estimates
+			protected.Get("/cost/catalog", dep.CostHandler.Catalog)
+
+			// Dead-lettered deployment tasks
+			protected.Route("/tasks", func(tr chi.Router) {
+				tr.Get("/archived", dep.TasksHandler.ListArchived)
+				tr.Post("/archived/{taskID}/retry", dep.TasksHandler.RetryArchived)
+				tr.Delete("/archived/{taskID}", dep.TasksHandler.DeleteArchived)
 			})
 
 			// Graphs
@@ -77,10 +178,11 @@ func NewRouter(dep Dependencies) http.Handler {
 
 			// AI
 			protected.Route("/ai", func(ar chi.Router) {
-				ar.Get("/status", func(w http.ResponseWriter, r *http.Request) {
-					w.WriteHeader(200)
-					w.Write([]byte(`{"success":true,"data":{"status":"ready"}}`))
-				})
+				ar.Get("/status", dep.AIHandler.Status)
+				ar.Post("/projects/{id}/graph/generate", dep.AIHandler.GenerateGraph)
+				ar.Post("/projects/{id}/graph/save", dep.AIHandler.SaveGraph)
+				ar.Post("/projects/{id}/graph/patch", dep.AIHandler.ApplyPatch)
+				ar.Post("/deployments/{id}/explain", dep.AIHandler.ExplainDeployment)
 			})
 		})
 	})
@@ -1,5 +1,51 @@
-//go:build ignore
-
 package aws
 
+import (
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/provisioner"
+)
+
+// Pricer prices AWS resources. Catalog resources are "compute" (EC2
+// instance types), "ebs" (volume types), "database" (RDS instance
+// classes), "database-storage", "object-storage" (S3 storage classes) and
+// "network".
+type Pricer struct{}
+
+var _ cloud.Pricer = Pricer{}
+
+func (Pricer) DefaultRegion() string { return "us-east-1" }
 
+func (Pricer) Usage(n provisioner.Node) ([]cloud.Usage, bool) {
+	switch n.Type {
+	case "aws_instance":
+		return []cloud.Usage{
+			{Resource: "compute", SKU: cloud.String(n, "instance_type", "t3.micro"), Quantity: 1, Description: "instance hours"},
+			{Resource: "ebs", SKU: cloud.String(n, "root_volume_type", "gp3"), Quantity: cloud.Number(n, "root_volume_size", 8), Description: "root volume"},
+		}, true
+	case "aws_db_instance":
+		instances := 1.0
+		if cloud.Bool(n, "multi_az") {
+			instances = 2
+		}
+		return []cloud.Usage{
+			{Resource: "database", SKU: cloud.String(n, "instance_class", "db.t3.micro"), Quantity: instances, Description: "database instance hours"},
+			{Resource: "database-storage", SKU: cloud.String(n, "storage_type", "gp2"), Quantity: cloud.Number(n, "allocated_storage", 20) * instances, Description: "database storage"},
+		}, true
+	case "aws_s3_bucket":
+		// storage is usage based; size_gb lets the graph state an expectation
+		return []cloud.Usage{
+			{Resource: "object-storage", SKU: cloud.String(n, "storage_class", "STANDARD"), Quantity: cloud.Number(n, "size_gb", 0), Description: "stored data"},
+		}, true
+	case "aws_ebs_volume":
+		return []cloud.Usage{
+			{Resource: "ebs", SKU: cloud.String(n, "type", "gp3"), Quantity: cloud.Number(n, "size", 8), Description: "volume"},
+		}, true
+	case "aws_nat_gateway":
+		return []cloud.Usage{{Resource: "network", SKU: "nat-gateway", Quantity: 1, Description: "NAT gateway hours"}}, true
+	case "aws_lb", "aws_alb":
+		return []cloud.Usage{{Resource: "network", SKU: "alb", Quantity: 1, Description: "load balancer hours"}}, true
+	case "aws_vpc", "aws_subnet", "aws_security_group", "aws_route_table", "aws_internet_gateway", "aws_iam_role", "aws_iam_policy":
+		return nil, true
+	}
+	return nil, false
+}
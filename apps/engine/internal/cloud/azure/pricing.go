@@ -1,5 +1,31 @@
-//go:build ignore
-
 package azure
 
+import (
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/provisioner"
+)
+
+// Pricer prices Azure resources. Catalog resources are "compute" (VM
+// sizes), "managed-disk" and "blob-storage" (access tiers).
+type Pricer struct{}
+
+var _ cloud.Pricer = Pricer{}
+
+func (Pricer) DefaultRegion() string { return "eastus" }
 
+func (Pricer) Usage(n provisioner.Node) ([]cloud.Usage, bool) {
+	switch n.Type {
+	case "azurerm_linux_virtual_machine", "azurerm_windows_virtual_machine", "azurerm_virtual_machine":
+		return []cloud.Usage{
+			{Resource: "compute", SKU: cloud.String(n, "size", "Standard_B1s"), Quantity: 1, Description: "VM hours"},
+			{Resource: "managed-disk", SKU: cloud.String(n, "os_disk_type", "Standard_LRS"), Quantity: cloud.Number(n, "os_disk_size", 30), Description: "OS disk"},
+		}, true
+	case "azurerm_storage_account":
+		return []cloud.Usage{
+			{Resource: "blob-storage", SKU: cloud.String(n, "access_tier", "Hot"), Quantity: cloud.Number(n, "size_gb", 0), Description: "stored data"},
+		}, true
+	case "azurerm_resource_group", "azurerm_virtual_network", "azurerm_subnet", "azurerm_network_security_group":
+		return nil, true
+	}
+	return nil, false
+}
@@ -0,0 +1,241 @@
+package cloud
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Price units.
+const (
+	UnitHour    = "hour"     // per instance-hour, billed 730 hours a month
+	UnitMonth   = "month"    // per instance-month
+	UnitGBMonth = "gb-month" // per GB stored for a month
+)
+
+// HoursPerMonth is the average number of hours in a month.
+const HoursPerMonth = 730
+
+// AnyRegion prices apply in regions without a price of their own.
+const AnyRegion = "*"
+
+//go:embed catalog
+var defaultCatalog embed.FS
+
+// Price is one catalog entry, keyed by provider, region, resource kind and
+// SKU (instance type, storage class, ...).
+type Price struct {
+	Region   string  `json:"region"`
+	Resource string  `json:"resource"`
+	SKU      string  `json:"sku"`
+	Unit     string  `json:"unit"`
+	Price    float64 `json:"price"`
+}
+
+// ProviderCatalog holds one provider's prices at one catalog version.
+type ProviderCatalog struct {
+	Provider string  `json:"provider"`
+	Version  string  `json:"version"`
+	Currency string  `json:"currency"`
+	Prices   []Price `json:"prices"`
+}
+
+// Catalog is an offline price list. It is loaded from JSON and CSV files,
+// one or more per provider, so it can be refreshed without network access.
+type Catalog struct {
+	providers map[string]*ProviderCatalog
+	index     map[string]Price
+}
+
+// CatalogInfo describes the prices loaded for a provider.
+type CatalogInfo struct {
+	Provider string `json:"provider"`
+	Version  string `json:"version"`
+	Currency string `json:"currency"`
+	Prices   int    `json:"prices"`
+}
+
+// DefaultCatalog loads the catalog built into the binary.
+func DefaultCatalog() (*Catalog, error) {
+	sub, err := fs.Sub(defaultCatalog, "catalog")
+	if err != nil {
+		return nil, err
+	}
+	return LoadCatalog(sub)
+}
+
+// LoadCatalog reads every .json and .csv file at the root of fsys.
+//
+// JSON files hold a ProviderCatalog. CSV files have the header
+// provider,version,currency,region,resource,sku,unit,price. Files of one
+// provider must agree on version and currency.
+func LoadCatalog(fsys fs.FS) (*Catalog, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read price catalog: %w", err)
+	}
+	c := &Catalog{providers: map[string]*ProviderCatalog{}, index: map[string]Price{}}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		var parts []ProviderCatalog
+		switch strings.ToLower(path.Ext(e.Name())) {
+		case ".json":
+			parts, err = readJSONCatalog(fsys, e.Name())
+		case ".csv":
+			parts, err = readCSVCatalog(fsys, e.Name())
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("price catalog %s: %w", e.Name(), err)
+		}
+		for _, p := range parts {
+			if err := c.add(p); err != nil {
+				return nil, fmt.Errorf("price catalog %s: %w", e.Name(), err)
+			}
+		}
+	}
+	if len(c.providers) == 0 {
+		return nil, fmt.Errorf("price catalog has no prices")
+	}
+	return c, nil
+}
+
+func (c *Catalog) add(p ProviderCatalog) error {
+	if p.Provider == "" || p.Version == "" {
+		return fmt.Errorf("provider and version are required")
+	}
+	if p.Currency == "" {
+		p.Currency = "USD"
+	}
+	cur, ok := c.providers[p.Provider]
+	if !ok {
+		cur = &ProviderCatalog{Provider: p.Provider, Version: p.Version, Currency: p.Currency}
+		c.providers[p.Provider] = cur
+	}
+	if cur.Version != p.Version || cur.Currency != p.Currency {
+		return fmt.Errorf("%s prices mix versions %s/%s and currencies %s/%s", p.Provider, cur.Version, p.Version, cur.Currency, p.Currency)
+	}
+	for _, price := range p.Prices {
+		switch price.Unit {
+		case UnitHour, UnitMonth, UnitGBMonth:
+		default:
+			return fmt.Errorf("%s %s %s: unknown unit %q", p.Provider, price.Resource, price.SKU, price.Unit)
+		}
+		if price.Region == "" || price.Resource == "" || price.SKU == "" || price.Price < 0 {
+			return fmt.Errorf("%s: incomplete price %+v", p.Provider, price)
+		}
+		cur.Prices = append(cur.Prices, price)
+		c.index[priceKey(p.Provider, price.Region, price.Resource, price.SKU)] = price
+	}
+	return nil
+}
+
+// Lookup finds the price of a SKU in a region, falling back to a price for
+// any region.
+func (c *Catalog) Lookup(provider, region, resource, sku string) (Price, bool) {
+	if p, ok := c.index[priceKey(provider, region, resource, sku)]; ok {
+		return p, true
+	}
+	p, ok := c.index[priceKey(provider, AnyRegion, resource, sku)]
+	return p, ok
+}
+
+// Provider returns the catalog header of a provider.
+func (c *Catalog) Provider(provider string) (CatalogInfo, bool) {
+	p, ok := c.providers[provider]
+	if !ok {
+		return CatalogInfo{}, false
+	}
+	return CatalogInfo{Provider: p.Provider, Version: p.Version, Currency: p.Currency, Prices: len(p.Prices)}, true
+}
+
+// Info describes every provider in the catalog.
+func (c *Catalog) Info() []CatalogInfo {
+	out := make([]CatalogInfo, 0, len(c.providers))
+	for name := range c.providers {
+		info, _ := c.Provider(name)
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
+
+func priceKey(provider, region, resource, sku string) string {
+	return provider + "|" + region + "|" + resource + "|" + sku
+}
+
+func readJSONCatalog(fsys fs.FS, name string) ([]ProviderCatalog, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var p ProviderCatalog
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	return []ProviderCatalog{p}, nil
+}
+
+var csvHeader = []string{"provider", "version", "currency", "region", "resource", "sku", "unit", "price"}
+
+func readCSVCatalog(fsys fs.FS, name string) ([]ProviderCatalog, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = len(csvHeader)
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, col := range csvHeader {
+		if strings.TrimSpace(header[i]) != col {
+			return nil, fmt.Errorf("header must be %s", strings.Join(csvHeader, ","))
+		}
+	}
+
+	byProvider := map[string]*ProviderCatalog{}
+	var order []string
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		price, err := strconv.ParseFloat(rec[7], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid price %q", line, rec[7])
+		}
+		p, ok := byProvider[rec[0]]
+		if !ok {
+			p = &ProviderCatalog{Provider: rec[0], Version: rec[1], Currency: rec[2]}
+			byProvider[rec[0]] = p
+			order = append(order, rec[0])
+		}
+		if p.Version != rec[1] || p.Currency != rec[2] {
+			return nil, fmt.Errorf("line %d: %s version or currency differs from earlier lines", line, rec[0])
+		}
+		p.Prices = append(p.Prices, Price{Region: rec[3], Resource: rec[4], SKU: rec[5], Unit: rec[6], Price: price})
+	}
+	out := make([]ProviderCatalog, 0, len(order))
+	for _, name := range order {
+		out = append(out, *byProvider[name])
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package cloud
+
+import "sort"
+
+// Node cost changes between two estimates.
+const (
+	CostAdded   = "added"
+	CostRemoved = "removed"
+	CostResized = "resized"
+)
+
+// NodeDelta is how the monthly cost of one node changes.
+type NodeDelta struct {
+	NodeID   string  `json:"node_id"`
+	Type     string  `json:"type"`
+	Change   string  `json:"change" enums:"added,removed,resized"`
+	FromCost float64 `json:"from_cost"`
+	ToCost   float64 `json:"to_cost"`
+	Delta    float64 `json:"delta"`
+}
+
+// CostDiff compares the monthly cost of two graphs.
+type CostDiff struct {
+	Currency string  `json:"currency"`
+	FromCost float64 `json:"from_cost"`
+	ToCost   float64 `json:"to_cost"`
+	Delta    float64 `json:"delta"`
+	// DeltaPercent is Delta relative to FromCost; 0 when FromCost is 0.
+	DeltaPercent float64 `json:"delta_percent"`
+	// Nodes lists the nodes whose cost changes, largest change first.
+	Nodes []NodeDelta `json:"nodes"`
+}
+
+// Diff compares two estimates node by node. Nodes present in both whose
+// cost differs are resized; nodes whose cost is unchanged are left out.
+func Diff(from, to *Estimate) *CostDiff {
+	d := &CostDiff{Currency: to.Currency, FromCost: from.MonthlyCost, ToCost: to.MonthlyCost, Nodes: []NodeDelta{}}
+	d.Delta = round2(to.MonthlyCost - from.MonthlyCost)
+	if from.MonthlyCost > 0 {
+		d.DeltaPercent = round2(d.Delta / from.MonthlyCost * 100)
+	}
+
+	for _, n := range to.Nodes {
+		old, ok := from.Node(n.NodeID)
+		switch {
+		case !ok:
+			d.Nodes = append(d.Nodes, NodeDelta{NodeID: n.NodeID, Type: n.Type, Change: CostAdded, ToCost: n.MonthlyCost, Delta: n.MonthlyCost})
+		case old.MonthlyCost != n.MonthlyCost:
+			d.Nodes = append(d.Nodes, NodeDelta{NodeID: n.NodeID, Type: n.Type, Change: CostResized, FromCost: old.MonthlyCost, ToCost: n.MonthlyCost, Delta: round2(n.MonthlyCost - old.MonthlyCost)})
+		}
+	}
+	for _, n := range from.Nodes {
+		if _, ok := to.Node(n.NodeID); !ok {
+			d.Nodes = append(d.Nodes, NodeDelta{NodeID: n.NodeID, Type: n.Type, Change: CostRemoved, FromCost: n.MonthlyCost, Delta: -n.MonthlyCost})
+		}
+	}
+	sort.SliceStable(d.Nodes, func(i, j int) bool {
+		return abs(d.Nodes[i].Delta) > abs(d.Nodes[j].Delta)
+	})
+	return d
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -1,5 +1,31 @@
-//go:build ignore
-
 package digitalocean
 
+import (
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/provisioner"
+)
+
+// Pricer prices DigitalOcean resources, which bill flat monthly rates.
+// Catalog resources are "droplet" and "database" (size slugs), "volume"
+// and "spaces".
+type Pricer struct{}
+
+var _ cloud.Pricer = Pricer{}
+
+func (Pricer) DefaultRegion() string { return "nyc1" }
 
+func (Pricer) Usage(n provisioner.Node) ([]cloud.Usage, bool) {
+	switch n.Type {
+	case "digitalocean_droplet":
+		return []cloud.Usage{{Resource: "droplet", SKU: cloud.String(n, "size", "s-1vcpu-1gb"), Quantity: 1, Description: "droplet"}}, true
+	case "digitalocean_database_cluster":
+		return []cloud.Usage{{Resource: "database", SKU: cloud.String(n, "size", "db-s-1vcpu-1gb"), Quantity: cloud.Number(n, "node_count", 1), Description: "database nodes"}}, true
+	case "digitalocean_volume":
+		return []cloud.Usage{{Resource: "volume", SKU: "standard", Quantity: cloud.Number(n, "size", 10), Description: "block storage"}}, true
+	case "digitalocean_spaces_bucket":
+		return []cloud.Usage{{Resource: "spaces", SKU: "subscription", Quantity: 1, Description: "Spaces subscription"}}, true
+	case "digitalocean_vpc", "digitalocean_firewall", "digitalocean_project":
+		return nil, true
+	}
+	return nil, false
+}
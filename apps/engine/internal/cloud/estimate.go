@@ -0,0 +1,187 @@
+package cloud
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/iac-studio/engine/internal/provisioner"
+)
+
+// Usage is what a node bills for: a quantity of one catalog SKU.
+// Quantity counts instances for hourly and monthly prices and GB for
+// per-GB prices.
+type Usage struct {
+	Resource    string
+	SKU         string
+	Quantity    float64
+	Description string
+}
+
+// Pricer maps a provider's resource types to their billable usage.
+type Pricer interface {
+	// DefaultRegion is used when a project sets no region.
+	DefaultRegion() string
+	// Usage returns what n bills for. known is false for resource types
+	// the pricer does not cover; free resources are known with no usage.
+	Usage(n provisioner.Node) (usage []Usage, known bool)
+}
+
+// LineItem is one priced usage of a node.
+type LineItem struct {
+	Description string  `json:"description"`
+	Resource    string  `json:"resource"`
+	SKU         string  `json:"sku"`
+	Unit        string  `json:"unit"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	MonthlyCost float64 `json:"monthly_cost"`
+}
+
+// NodeCost is the monthly cost of one graph node.
+type NodeCost struct {
+	NodeID      string     `json:"node_id"`
+	Type        string     `json:"type"`
+	MonthlyCost float64    `json:"monthly_cost"`
+	Items       []LineItem `json:"items"`
+	// Notes explain usage that could not be priced.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Estimate is the monthly cost of a graph.
+type Estimate struct {
+	Provider       string     `json:"provider"`
+	Region         string     `json:"region"`
+	Currency       string     `json:"currency"`
+	CatalogVersion string     `json:"catalog_version"`
+	MonthlyCost    float64    `json:"monthly_cost"`
+	Nodes          []NodeCost `json:"nodes"`
+	// Unpriced lists nodes missing from the total because the catalog has
+	// no price for them.
+	Unpriced []string `json:"unpriced,omitempty"`
+}
+
+// Node returns the cost of a node, if the estimate has it.
+func (e *Estimate) Node(id string) (NodeCost, bool) {
+	for _, n := range e.Nodes {
+		if n.NodeID == id {
+			return n, true
+		}
+	}
+	return NodeCost{}, false
+}
+
+// Estimator prices graphs against a catalog.
+type Estimator struct {
+	catalog *Catalog
+	pricers map[string]Pricer
+}
+
+func NewEstimator(catalog *Catalog) *Estimator {
+	return &Estimator{catalog: catalog, pricers: map[string]Pricer{}}
+}
+
+// RegisterPricer adds a pricer for a cloud provider, e.g. "aws".
+func (e *Estimator) RegisterPricer(provider string, p Pricer) {
+	e.pricers[provider] = p
+}
+
+// Catalog returns the catalog the estimator prices from.
+func (e *Estimator) Catalog() *Catalog {
+	return e.catalog
+}
+
+// Estimate prices every node of g in region, the provider's default when
+// empty.
+func (e *Estimator) Estimate(provider, region string, g provisioner.Graph) (*Estimate, error) {
+	pricer, ok := e.pricers[provider]
+	if !ok {
+		return nil, fmt.Errorf("no pricing for provider %q", provider)
+	}
+	info, ok := e.catalog.Provider(provider)
+	if !ok {
+		return nil, fmt.Errorf("price catalog has no %s prices", provider)
+	}
+	if region == "" {
+		region = pricer.DefaultRegion()
+	}
+
+	est := &Estimate{Provider: provider, Region: region, Currency: info.Currency, CatalogVersion: info.Version, Nodes: []NodeCost{}}
+	for _, n := range g.Nodes {
+		nc := NodeCost{NodeID: n.ID, Type: n.Type, Items: []LineItem{}}
+		usage, known := pricer.Usage(n)
+		if !known {
+			nc.Notes = append(nc.Notes, fmt.Sprintf("no pricing for resource type %s", n.Type))
+			est.Unpriced = append(est.Unpriced, n.ID)
+		}
+		missing := false
+		for _, u := range usage {
+			price, ok := e.catalog.Lookup(provider, region, u.Resource, u.SKU)
+			if !ok {
+				nc.Notes = append(nc.Notes, fmt.Sprintf("no %s price for %s %s", region, u.Resource, u.SKU))
+				missing = true
+				continue
+			}
+			item := LineItem{Description: u.Description, Resource: u.Resource, SKU: u.SKU, Unit: price.Unit, Quantity: u.Quantity, UnitPrice: price.Price}
+			item.MonthlyCost = round2(monthly(price, u.Quantity))
+			nc.Items = append(nc.Items, item)
+			nc.MonthlyCost += item.MonthlyCost
+		}
+		if missing && known {
+			est.Unpriced = append(est.Unpriced, n.ID)
+		}
+		nc.MonthlyCost = round2(nc.MonthlyCost)
+		est.MonthlyCost += nc.MonthlyCost
+		est.Nodes = append(est.Nodes, nc)
+	}
+	est.MonthlyCost = round2(est.MonthlyCost)
+	sort.SliceStable(est.Nodes, func(i, j int) bool { return est.Nodes[i].MonthlyCost > est.Nodes[j].MonthlyCost })
+	return est, nil
+}
+
+func monthly(p Price, quantity float64) float64 {
+	switch p.Unit {
+	case UnitHour:
+		return p.Price * HoursPerMonth * quantity
+	default:
+		return p.Price * quantity
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Property helpers for pricers.
+
+// String returns a string property, or def when unset.
+func String(n provisioner.Node, key, def string) string {
+	if s, ok := n.Properties[key].(string); ok && s != "" {
+		return s
+	}
+	return def
+}
+
+// Number returns a numeric property, or def when unset.
+func Number(n provisioner.Node, key string, def float64) float64 {
+	switch v := n.Properties[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// Bool returns a boolean property, false when unset.
+func Bool(n provisioner.Node, key string) bool {
+	b, _ := n.Properties[key].(bool)
+	return b
+}
@@ -0,0 +1,135 @@
+package cloud_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/cloud/aws"
+	"github.com/iac-studio/engine/internal/cloud/digitalocean"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimate(t *testing.T) {
+	catalog, err := cloud.DefaultCatalog()
+	require.NoError(t, err)
+	e := cloud.NewEstimator(catalog)
+	e.RegisterPricer("aws", aws.Pricer{})
+
+	g := provisioner.Graph{Nodes: []provisioner.Node{
+		{ID: "vpc", Type: "aws_vpc"},
+		{ID: "web", Type: "aws_instance", Properties: map[string]interface{}{"instance_type": "t3.micro"}},
+		{ID: "db", Type: "aws_db_instance", Properties: map[string]interface{}{"instance_class": "db.t3.micro", "multi_az": true}},
+		{ID: "queue", Type: "aws_sqs_queue"},
+	}}
+
+	t.Run("breakdown per node", func(t *testing.T) {
+		est, err := e.Estimate("aws", "", g)
+		require.NoError(t, err)
+		require.Equal(t, "us-east-1", est.Region)
+		require.Equal(t, "2025-10-01", est.CatalogVersion)
+		require.Len(t, est.Nodes, 4)
+		require.Equal(t, "db", est.Nodes[0].NodeID, "nodes are ordered by cost")
+
+		web, ok := est.Node("web")
+		require.True(t, ok)
+		require.Len(t, web.Items, 2)
+		require.Equal(t, 7.59, web.Items[0].MonthlyCost) // 0.0104/h * 730h
+		require.Equal(t, 0.64, web.Items[1].MonthlyCost) // 8 GB gp3
+		require.Equal(t, 8.23, web.MonthlyCost)
+
+		db, _ := est.Node("db")
+		require.Equal(t, 2.0, db.Items[0].Quantity, "multi-AZ bills a standby")
+
+		var total float64
+		for _, n := range est.Nodes {
+			total += n.MonthlyCost
+		}
+		require.InDelta(t, total, est.MonthlyCost, 0.001)
+	})
+
+	t.Run("unpriced nodes are listed", func(t *testing.T) {
+		est, err := e.Estimate("aws", "", g)
+		require.NoError(t, err)
+		require.Equal(t, []string{"queue"}, est.Unpriced)
+		q, _ := est.Node("queue")
+		require.Zero(t, q.MonthlyCost)
+		require.NotEmpty(t, q.Notes)
+
+		vpc, _ := est.Node("vpc")
+		require.Empty(t, vpc.Notes, "free resources are priced at zero")
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		_, err := e.Estimate("oracle", "", g)
+		require.Error(t, err)
+	})
+}
+
+func TestLoadCatalog(t *testing.T) {
+	t.Run("csv prices apply in any region", func(t *testing.T) {
+		catalog, err := cloud.LoadCatalog(fstest.MapFS{
+			"do.csv": {Data: []byte("# test prices\nprovider,version,currency,region,resource,sku,unit,price\ndo,v1,USD,*,droplet,s-1vcpu-1gb,month,6\n")},
+		})
+		require.NoError(t, err)
+		e := cloud.NewEstimator(catalog)
+		e.RegisterPricer("do", digitalocean.Pricer{})
+
+		est, err := e.Estimate("do", "fra1", provisioner.Graph{Nodes: []provisioner.Node{
+			{ID: "app", Type: "digitalocean_droplet", Properties: map[string]interface{}{"size": "s-1vcpu-1gb"}},
+		}})
+		require.NoError(t, err)
+		require.Equal(t, "v1", est.CatalogVersion)
+		require.Equal(t, 6.0, est.MonthlyCost)
+	})
+
+	t.Run("one version per provider", func(t *testing.T) {
+		_, err := cloud.LoadCatalog(fstest.MapFS{
+			"a.json": {Data: []byte(`{"provider":"aws","version":"v1","prices":[{"region":"*","resource":"compute","sku":"t3.micro","unit":"hour","price":0.01}]}`)},
+			"b.json": {Data: []byte(`{"provider":"aws","version":"v2","prices":[]}`)},
+		})
+		require.ErrorContains(t, err, "mix versions")
+	})
+
+	t.Run("unknown unit", func(t *testing.T) {
+		_, err := cloud.LoadCatalog(fstest.MapFS{
+			"a.json": {Data: []byte(`{"provider":"aws","version":"v1","prices":[{"region":"*","resource":"compute","sku":"t3.micro","unit":"day","price":1}]}`)},
+		})
+		require.ErrorContains(t, err, "unknown unit")
+	})
+}
+
+func TestDiff(t *testing.T) {
+	catalog, err := cloud.DefaultCatalog()
+	require.NoError(t, err)
+	e := cloud.NewEstimator(catalog)
+	e.RegisterPricer("aws", aws.Pricer{})
+
+	from, err := e.Estimate("aws", "", provisioner.Graph{Nodes: []provisioner.Node{
+		{ID: "vpc", Type: "aws_vpc"},
+		{ID: "web", Type: "aws_instance", Properties: map[string]interface{}{"instance_type": "t3.micro"}},
+		{ID: "old", Type: "aws_ebs_volume", Properties: map[string]interface{}{"size": 100.0}},
+	}})
+	require.NoError(t, err)
+	to, err := e.Estimate("aws", "", provisioner.Graph{Nodes: []provisioner.Node{
+		{ID: "vpc", Type: "aws_vpc"},
+		{ID: "web", Type: "aws_instance", Properties: map[string]interface{}{"instance_type": "t3.large"}},
+		{ID: "nat", Type: "aws_nat_gateway"},
+	}})
+	require.NoError(t, err)
+
+	d := cloud.Diff(from, to)
+	require.Equal(t, to.MonthlyCost-from.MonthlyCost, d.Delta)
+	require.Len(t, d.Nodes, 3, "unchanged nodes are left out")
+
+	changes := map[string]string{}
+	for _, n := range d.Nodes {
+		changes[n.NodeID] = n.Change
+	}
+	require.Equal(t, map[string]string{"web": cloud.CostResized, "nat": cloud.CostAdded, "old": cloud.CostRemoved}, changes)
+
+	old := d.Nodes[len(d.Nodes)-1]
+	require.Equal(t, "old", old.NodeID, "ordered by size of change")
+	require.Equal(t, -8.0, old.Delta)
+}
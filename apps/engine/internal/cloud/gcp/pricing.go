@@ -1,5 +1,37 @@
-//go:build ignore
-
 package gcp
 
+import (
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/provisioner"
+)
+
+// Pricer prices Google Cloud resources. Catalog resources are "compute"
+// (machine types), "disk", "sql" (Cloud SQL tiers), "sql-storage" and
+// "object-storage" (GCS storage classes).
+type Pricer struct{}
+
+var _ cloud.Pricer = Pricer{}
+
+func (Pricer) DefaultRegion() string { return "us-central1" }
 
+func (Pricer) Usage(n provisioner.Node) ([]cloud.Usage, bool) {
+	switch n.Type {
+	case "google_compute_instance":
+		return []cloud.Usage{
+			{Resource: "compute", SKU: cloud.String(n, "machine_type", "e2-micro"), Quantity: 1, Description: "instance hours"},
+			{Resource: "disk", SKU: cloud.String(n, "boot_disk_type", "pd-balanced"), Quantity: cloud.Number(n, "boot_disk_size", 10), Description: "boot disk"},
+		}, true
+	case "google_sql_database_instance":
+		return []cloud.Usage{
+			{Resource: "sql", SKU: cloud.String(n, "tier", "db-f1-micro"), Quantity: 1, Description: "database instance hours"},
+			{Resource: "sql-storage", SKU: "ssd", Quantity: cloud.Number(n, "disk_size", 10), Description: "database storage"},
+		}, true
+	case "google_storage_bucket":
+		return []cloud.Usage{
+			{Resource: "object-storage", SKU: cloud.String(n, "storage_class", "STANDARD"), Quantity: cloud.Number(n, "size_gb", 0), Description: "stored data"},
+		}, true
+	case "google_compute_network", "google_compute_subnetwork", "google_compute_firewall":
+		return nil, true
+	}
+	return nil, false
+}
@@ -0,0 +1,138 @@
+// Package events publishes domain events (deployment lifecycle, warnings) to
+// interested sinks such as the log and outbound webhooks.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Event types
+const (
+	DeploymentCreated   = "deployment.created"
+	DeploymentPlanned   = "deployment.planned"
+	DeploymentApplied   = "deployment.applied"
+	DeploymentFailed    = "deployment.failed"
+	DeploymentDestroyed = "deployment.destroyed"
+	DeploymentExpiring  = "deployment.expiring"
+	DeploymentExpired   = "deployment.expired"
+	GraphSaved          = "graph.saved"
+	DriftDetected       = "drift.detected"
+	BudgetExceeded      = "budget.exceeded"
+	AnomalyDetected     = "anomaly.detected"
+)
+
+// Types lists every event type, in the order above.
+var Types = []string{
+	DeploymentCreated, DeploymentPlanned, DeploymentApplied, DeploymentFailed, DeploymentDestroyed,
+	DeploymentExpiring, DeploymentExpired, GraphSaved, DriftDetected, BudgetExceeded, AnomalyDetected,
+}
+
+// KnownType tells whether typ is one of Types.
+func KnownType(typ string) bool {
+	for _, t := range Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a single occurrence published to every sink.
+type Event struct {
+	ID           string                 `json:"id"`
+	Type         string                 `json:"type"`
+	ProjectID    string                 `json:"project_id"`
+	DeploymentID string                 `json:"deployment_id,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	OccurredAt   time.Time              `json:"occurred_at"`
+}
+
+// New builds an event with a fresh ID.
+func New(typ string, projectID, deploymentID uuid.UUID, data map[string]interface{}) Event {
+	e := Event{
+		ID:         uuid.NewString(),
+		Type:       typ,
+		ProjectID:  projectID.String(),
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	}
+	if deploymentID != uuid.Nil {
+		e.DeploymentID = deploymentID.String()
+	}
+	return e
+}
+
+// Publisher delivers events to a sink.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+type logPublisher struct{}
+
+// NewLogPublisher writes events to the application log.
+func NewLogPublisher() Publisher {
+	return logPublisher{}
+}
+
+func (logPublisher) Publish(ctx context.Context, e Event) error {
+	logger.L().Info("event", zap.String("event_id", e.ID), zap.String("type", e.Type), zap.String("project_id", e.ProjectID), zap.String("deployment_id", e.DeploymentID), zap.Any("data", e.Data))
+	return nil
+}
+
+type webhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher POSTs every event as JSON to url.
+func NewWebhookPublisher(url string, timeout time.Duration) Publisher {
+	return &webhookPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p *webhookPublisher) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type multiPublisher []Publisher
+
+// Multi publishes to every publisher, attempting all of them even if some fail.
+func Multi(publishers ...Publisher) Publisher {
+	return multiPublisher(publishers)
+}
+
+func (m multiPublisher) Publish(ctx context.Context, e Event) error {
+	var errs []error
+	for _, p := range m {
+		if err := p.Publish(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"deployment.applied"}`)
+	sig := Sign("s3cret", ts, body)
+	require.Regexp(t, `^sha256=[0-9a-f]{64}$`, sig)
+	require.Equal(t, sig, Sign("s3cret", ts, body))
+
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	require.True(t, Verify("s3cret", stamp, sig, body, 5*time.Minute, ts.Add(time.Minute)))
+	require.False(t, Verify("other", stamp, sig, body, 5*time.Minute, ts))
+	require.False(t, Verify("s3cret", stamp, sig, []byte(`{}`), 5*time.Minute, ts))
+	require.False(t, Verify("s3cret", "1700000001", sig, body, 5*time.Minute, ts))
+	require.False(t, Verify("s3cret", stamp, sig, body, 5*time.Minute, ts.Add(10*time.Minute)))
+	require.False(t, Verify("s3cret", stamp, sig[len("sha256="):], body, 5*time.Minute, ts))
+}
+
+func TestNewSignedRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	e := Event{ID: "evt-1", Type: DeploymentApplied}
+	body := []byte(`{"id":"evt-1"}`)
+	req, err := NewSignedRequest(context.Background(), "https://hooks.example.com/x", "s3cret", "dlv-1", e, body, now)
+	require.NoError(t, err)
+	require.Equal(t, http.MethodPost, req.Method)
+	require.Equal(t, DeploymentApplied, req.Header.Get(HeaderEvent))
+	require.Equal(t, "dlv-1", req.Header.Get(HeaderDelivery))
+	require.Equal(t, "evt-1", req.Header.Get(HeaderIdempotency))
+	require.Equal(t, "1700000000", req.Header.Get(HeaderTimestamp))
+	require.True(t, Verify("s3cret", req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body, time.Minute, now))
+}
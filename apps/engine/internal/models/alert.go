@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Alert delivery outcomes.
+const (
+	AlertDeliveryPending     = "pending" // queued, or awaiting a retry
+	AlertDeliverySent        = "sent"
+	AlertDeliveryFailed      = "failed"
+	AlertDeliveryRateLimited = "rate_limited" // dropped, the channel had reached its hourly limit
+)
+
+// AlertChannel is where a project's alerts are sent; see monitor.ChannelConfig.
+type AlertChannel struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProjectID uuid.UUID      `gorm:"type:uuid;index;not null" json:"project_id"`
+	Name      string         `gorm:"type:varchar(255);not null" json:"name"`
+	Type      string         `gorm:"type:varchar(16);not null" json:"type" enums:"webhook,slack,email"`
+	Config    datatypes.JSON `gorm:"type:jsonb;not null" json:"config" swaggertype:"object"`
+	Enabled   bool           `gorm:"not null" json:"enabled"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (AlertChannel) TableName() string {
+	return "alert_channels"
+}
+
+// AlertRule routes alerts of one trigger to channels of the project.
+type AlertRule struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProjectID uuid.UUID `gorm:"type:uuid;index;not null" json:"project_id"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	Trigger   string    `gorm:"type:varchar(32);not null;index" json:"trigger" enums:"deployment_failed,drift_detected,budget_exceeded,approval_pending,anomaly_detected"`
+	// AfterMinutes is how long a deployment may await approval before an
+	// approval_pending rule fires; unused by other triggers.
+	AfterMinutes int            `gorm:"not null;default:0" json:"after_minutes,omitempty"`
+	ChannelIDs   datatypes.JSON `gorm:"type:jsonb;not null" json:"channel_ids" swaggertype:"array,string"`
+	Enabled      bool           `gorm:"not null" json:"enabled"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// AlertDelivery records an alert sent, or not sent, to a channel. A channel
+// gets each DedupeKey once; Payload is the alert, kept for the retries.
+type AlertDelivery struct {
+	ID        int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID uuid.UUID      `gorm:"type:uuid;index;not null" json:"project_id"`
+	ChannelID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_alert_deliveries_channel_key" json:"channel_id"`
+	RuleID    *uuid.UUID     `gorm:"type:uuid" json:"rule_id,omitempty"`
+	Trigger   string         `gorm:"type:varchar(32);not null" json:"trigger"`
+	DedupeKey string         `gorm:"type:varchar(255);not null;uniqueIndex:idx_alert_deliveries_channel_key" json:"dedupe_key"`
+	Title     string         `gorm:"type:text;not null" json:"title"`
+	Status    string         `gorm:"type:varchar(16);not null" json:"status" enums:"pending,sent,failed,rate_limited"`
+	Error     string         `gorm:"type:text" json:"error,omitempty"`
+	Attempts  int            `gorm:"not null;default:0" json:"attempts"`
+	Payload   datatypes.JSON `gorm:"type:jsonb" json:"-" swaggerignore:"true"`
+	CreatedAt time.Time      `gorm:"index" json:"created_at"`
+}
+
+// TableName overrides the table name
+func (AlertDelivery) TableName() string {
+	return "alert_deliveries"
+}
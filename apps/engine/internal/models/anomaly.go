@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Anomaly is something unusual the detector found in a project's
+// deployment history; see monitor.Anomaly.
+type Anomaly struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProjectID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"project_id"`
+	DeploymentID *uuid.UUID `gorm:"type:uuid;index" json:"deployment_id,omitempty"`
+	Kind         string     `gorm:"type:varchar(32);not null;index" json:"kind" enums:"slow_phase,failure_burst,repeated_error"`
+	Severity     string     `gorm:"type:varchar(16);not null" json:"severity" enums:"medium,high"`
+	Provider     string     `gorm:"type:varchar(32)" json:"provider,omitempty"`
+	Region       string     `gorm:"type:varchar(64)" json:"region,omitempty"`
+	// Fingerprint identifies the anomaly so a rescan does not record it
+	// twice.
+	Fingerprint string         `gorm:"type:varchar(255);not null;uniqueIndex" json:"fingerprint"`
+	Message     string         `gorm:"type:text;not null" json:"message"`
+	Details     datatypes.JSON `gorm:"type:jsonb" json:"details,omitempty" swaggertype:"object"`
+	DetectedAt  time.Time      `gorm:"not null;index" json:"detected_at"`
+}
+
+// TableName overrides the table name
+func (Anomaly) TableName() string {
+	return "anomalies"
+}
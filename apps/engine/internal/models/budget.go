@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BudgetOverride audits a deployment created over a project's soft budget.
+type BudgetOverride struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID     uuid.UUID `gorm:"type:uuid;index;not null" json:"project_id"`
+	DeploymentID  uuid.UUID `gorm:"type:uuid;index;not null" json:"deployment_id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	MonthlyLimit  float64   `gorm:"not null" json:"monthly_limit"`
+	EstimatedCost float64   `gorm:"not null" json:"estimated_cost"`
+	Currency      string    `gorm:"type:varchar(8);not null" json:"currency"`
+	Reason        string    `gorm:"type:text" json:"reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (BudgetOverride) TableName() string {
+	return "budget_overrides"
+}
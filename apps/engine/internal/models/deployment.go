@@ -8,14 +8,73 @@ import (
 	"gorm.io/gorm"
 )
 
+// Checkpoints record how far a provisioning task got, so a retried task
+// resumes safely instead of repeating a finished phase.
+const (
+	CheckpointApplying   = "applying"   // apply started; state may be partial
+	CheckpointApplied    = "applied"    // apply finished and state persisted
+	CheckpointDestroying = "destroying" // destroy started
+	CheckpointDestroyed  = "destroyed"  // destroy finished and state cleared
+)
+
+// Deployment warning codes.
+const (
+	WarningCostJump         = "cost_jump"         // the graph costs much more than the version before it
+	WarningBudgetThreshold  = "budget_threshold"  // the estimate reached the budget's warning threshold
+	WarningBudgetExceeded   = "budget_exceeded"   // a plan is over budget; plans are never blocked
+	WarningBudgetOverridden = "budget_overridden" // the author overrode the budget
+	WarningBudgetUnchecked  = "budget_unchecked"  // the graph, or part of it, could not be priced
+)
+
+// DeploymentWarning is a problem noticed when a deployment is created.
+type DeploymentWarning struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
 // Deployment represents an execution of a ProjectGraph.
 type Deployment struct {
 	ID             uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	ProjectID      uuid.UUID      `gorm:"type:uuid;index;not null" json:"project_id" validate:"required"`
 	GraphID        uuid.UUID      `gorm:"type:uuid;index;not null" json:"graph_id" validate:"required"`
-	Status         string         `gorm:"type:varchar(32);index;not null" json:"status" validate:"required,oneof=pending planning applying completed failed"`
+	Status         DeploymentStatus `gorm:"type:varchar(32);index;not null" json:"status" validate:"required,oneof=pending planning planned awaiting_approval approved rejected applying applied failed destroy_queued destroying destroyed cancelled superseded"`
 	TerraformState datatypes.JSON `gorm:"type:jsonb" json:"terraform_state" swaggertype:"object"`
 	Outputs        datatypes.JSON `gorm:"type:jsonb" json:"outputs" swaggertype:"object"`
+	DriftStatus    string         `gorm:"type:varchar(16);not null;default:unknown" json:"drift_status" enums:"unknown,in_sync,drifted,error"`
+	DriftCheckedAt *time.Time     `json:"drift_checked_at,omitempty"`
+	Environment    string         `gorm:"type:varchar(32);index" json:"environment,omitempty" example:"preview"`
+	ExpiresAt      *time.Time     `gorm:"index" json:"expires_at,omitempty"`
+	ExpiryWarnedAt *time.Time     `json:"expiry_warned_at,omitempty"`
+	Checkpoint     string         `gorm:"type:varchar(16)" json:"checkpoint,omitempty" enums:"applying,applied,destroying,destroyed"`
+	// CreatedBy is the user who started the deployment; approvers must be
+	// someone else.
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	// ApprovedAt is set once the deployment collected its approvals, so a
+	// retried task applies without asking again.
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	// PlanOnly deployments run terraform plan and stop; they never apply
+	// and do not wait in the project queue.
+	PlanOnly bool `gorm:"not null;default:false;index" json:"plan_only,omitempty"`
+	// BaseDeploymentID is the deployment whose state a plan compares
+	// against; nil plans from scratch.
+	BaseDeploymentID *uuid.UUID `gorm:"type:uuid" json:"base_deployment_id,omitempty"`
+	// Plan is the change set of the last plan; see provisioner.Plan.
+	Plan datatypes.JSON `gorm:"type:jsonb" json:"plan,omitempty" swaggertype:"object"`
+	// PolicyStatus is the outcome of the last policy evaluation; findings
+	// are in policy_findings.
+	PolicyStatus string `gorm:"type:varchar(16)" json:"policy_status,omitempty" enums:"passed,warned,blocked"`
+	// CostEstimate is the estimated monthly cost of the deployed graph;
+	// see cloud.Estimate.
+	CostEstimate datatypes.JSON `gorm:"type:jsonb" json:"cost_estimate,omitempty" swaggertype:"object"`
+	// Warnings are raised when the deployment is created and do not block
+	// it; see DeploymentWarning.
+	Warnings datatypes.JSON `gorm:"type:jsonb" json:"warnings,omitempty" swaggertype:"array,object"`
+	// LogSeq is the seq of the deployment's last log line.
+	LogSeq int64 `gorm:"not null;default:0" json:"-"`
+	// QueuePosition is filled in when a deployment is created; see
+	// services.QueueStatus.
+	QueuePosition int `gorm:"-" json:"queue_position,omitempty"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-" swaggerignore:"true"`
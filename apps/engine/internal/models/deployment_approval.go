@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Approval decisions.
+const (
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// DeploymentApproval is one approver's decision on a deployment awaiting
+// approval. Each approver decides once per deployment.
+type DeploymentApproval struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	DeploymentID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_deployment_approvals_deployment_user" json:"deployment_id"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_deployment_approvals_deployment_user" json:"user_id"`
+	Decision     string    `gorm:"type:varchar(16);not null" json:"decision" enums:"approved,rejected"`
+	Comment      string    `gorm:"type:text" json:"comment,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (DeploymentApproval) TableName() string {
+	return "deployment_approvals"
+}
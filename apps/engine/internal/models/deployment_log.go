@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// DeploymentLog is one line of a deployment's log. Seq increases by one per
+// line within a deployment and is the paging cursor.
+type DeploymentLog struct {
+	ID           int64          `gorm:"primaryKey;autoIncrement" json:"-"`
+	DeploymentID uuid.UUID      `gorm:"type:uuid;not null;index:idx_deployment_logs_deployment_seq,unique" json:"deployment_id"`
+	Seq          int64          `gorm:"not null;index:idx_deployment_logs_deployment_seq,unique" json:"seq"`
+	Timestamp    time.Time      `gorm:"column:ts;not null" json:"timestamp"`
+	Level        string         `gorm:"type:varchar(16);not null" json:"level" enums:"debug,info,warn,error"`
+	Phase        string         `gorm:"type:varchar(32)" json:"phase,omitempty" example:"apply"`
+	Message      string         `gorm:"type:text;not null" json:"message"`
+	Data         datatypes.JSON `gorm:"type:jsonb" json:"data,omitempty" swaggertype:"object"`
+}
+
+// TableName overrides the table name
+func (DeploymentLog) TableName() string {
+	return "deployment_logs"
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeploymentStatus is the lifecycle state of a deployment. Changes go
+// through repository.DeploymentRepository.UpdateStatus, which enforces
+// deploymentTransitions and records each change in the status history.
+type DeploymentStatus string
+
+const (
+	DeploymentPending          DeploymentStatus = "pending"           // queued for a provision task
+	DeploymentPlanning         DeploymentStatus = "planning"          // worker picked it up and prepares the run
+	DeploymentPlanned          DeploymentStatus = "planned"           // a plan-only run finished
+	DeploymentAwaitingApproval DeploymentStatus = "awaiting_approval" // planned; keeps its queue place until approved
+	DeploymentApproved         DeploymentStatus = "approved"          // approved, the apply task is queued
+	DeploymentRejected         DeploymentStatus = "rejected"          // an approver rejected the plan
+	DeploymentApplying         DeploymentStatus = "applying"          // terraform apply is running
+	DeploymentApplied          DeploymentStatus = "applied"           // infrastructure is live
+	DeploymentFailed           DeploymentStatus = "failed"            // the last provision or destroy failed
+	DeploymentDestroyQueued    DeploymentStatus = "destroy_queued"    // a destroy task is queued; the infrastructure is still live
+	DeploymentDestroying       DeploymentStatus = "destroying"        // terraform destroy is running
+	DeploymentDestroyed        DeploymentStatus = "destroyed"         // everything was torn down
+	DeploymentCancelled        DeploymentStatus = "cancelled"         // left the queue before it ran
+	DeploymentSuperseded       DeploymentStatus = "superseded"        // replaced in the queue by a newer deployment
+)
+
+// deploymentTransitions lists the statuses each status may move to. Moving
+// back to planning covers a task retried after a transient failure, and a
+// run cancelled while planning or applying one whose task is gone, and
+// back to pending (or destroy_queued) an archived task retried by hand.
+// Deployments queued for a destroy before destroy_queued existed are pending
+// and may still move on from there.
+var deploymentTransitions = map[DeploymentStatus][]DeploymentStatus{
+	DeploymentPending:          {DeploymentPlanning, DeploymentApplied, DeploymentFailed, DeploymentDestroying, DeploymentDestroyed, DeploymentCancelled, DeploymentSuperseded},
+	DeploymentPlanning:         {DeploymentApplying, DeploymentPlanned, DeploymentAwaitingApproval, DeploymentFailed, DeploymentPending, DeploymentCancelled},
+	DeploymentPlanned:          {},
+	DeploymentAwaitingApproval: {DeploymentApproved, DeploymentRejected, DeploymentCancelled, DeploymentSuperseded, DeploymentFailed},
+	DeploymentApproved:         {DeploymentPlanning, DeploymentApplying, DeploymentFailed, DeploymentCancelled, DeploymentPending},
+	DeploymentRejected:         {},
+	DeploymentApplying:         {DeploymentApplied, DeploymentFailed, DeploymentPlanning, DeploymentPending, DeploymentCancelled},
+	DeploymentApplied:          {DeploymentPending, DeploymentDestroyQueued, DeploymentDestroying},
+	DeploymentFailed:           {DeploymentPending, DeploymentPlanning, DeploymentApplied, DeploymentDestroyQueued, DeploymentDestroying, DeploymentDestroyed},
+	DeploymentDestroyQueued:    {DeploymentDestroying, DeploymentDestroyed, DeploymentApplied, DeploymentFailed},
+	DeploymentDestroying:       {DeploymentDestroyed, DeploymentApplied, DeploymentFailed, DeploymentPending, DeploymentDestroyQueued},
+	DeploymentDestroyed:        {},
+	DeploymentCancelled:        {},
+	DeploymentSuperseded:       {},
+}
+
+// Valid reports whether s is a known status.
+func (s DeploymentStatus) Valid() bool {
+	_, ok := deploymentTransitions[s]
+	return ok
+}
+
+// Terminal reports whether no further transitions are possible from s.
+func (s DeploymentStatus) Terminal() bool {
+	next, ok := deploymentTransitions[s]
+	return ok && len(next) == 0
+}
+
+// CanTransitionTo reports whether a deployment in status s may move to next.
+// Staying in the same status is always allowed and is not recorded.
+func (s DeploymentStatus) CanTransitionTo(next DeploymentStatus) bool {
+	if s == next {
+		return s.Valid()
+	}
+	for _, n := range deploymentTransitions[s] {
+		if n == next {
+			return true
+		}
+	}
+	return false
+}
+
+// DeploymentStatusHistory records one status change of a deployment.
+type DeploymentStatusHistory struct {
+	ID           int64            `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeploymentID uuid.UUID        `gorm:"type:uuid;index;not null" json:"deployment_id"`
+	FromStatus   DeploymentStatus `gorm:"type:varchar(32);not null" json:"from_status"`
+	ToStatus     DeploymentStatus `gorm:"type:varchar(32);not null" json:"to_status"`
+	// Actor is who caused the change, e.g. "user:<id>" or "task:deployment:provision".
+	Actor     string    `gorm:"type:varchar(128);not null" json:"actor"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName overrides the table name
+func (DeploymentStatusHistory) TableName() string {
+	return "deployment_status_history"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Drift statuses of a deployment.
+const (
+	DriftUnknown = "unknown"
+	DriftInSync  = "in_sync"
+	DriftDrifted = "drifted"
+	DriftError   = "error"
+)
+
+// DriftReport is the result of one drift check of a deployment.
+type DriftReport struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	DeploymentID  uuid.UUID      `gorm:"type:uuid;index;not null" json:"deployment_id"`
+	Status        string         `gorm:"type:varchar(16);not null" json:"status" enums:"in_sync,drifted,error"`
+	ResourceCount int            `gorm:"not null;default:0" json:"resource_count"`
+	Resources     datatypes.JSON `gorm:"type:jsonb" json:"resources" swaggertype:"array,object"`
+	Error         string         `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (DriftReport) TableName() string {
+	return "drift_reports"
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Policy evaluation outcomes, kept in Deployment.PolicyStatus.
+const (
+	PolicyPassed  = "passed"  // no findings
+	PolicyWarned  = "warned"  // advisory findings only
+	PolicyBlocked = "blocked" // a mandatory rule was violated
+)
+
+// PolicyRule is a custom declarative policy rule. A rule with a ProjectID
+// applies to that project; without one it applies to every project of the
+// owning account.
+type PolicyRule struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OwnerID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"owner_id"`
+	ProjectID   *uuid.UUID `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	Name        string     `gorm:"type:varchar(128);not null" json:"name" validate:"required"`
+	Description string     `gorm:"type:text" json:"description,omitempty"`
+	Enforcement string     `gorm:"type:varchar(16);not null" json:"enforcement" enums:"advisory,mandatory,off"`
+	// Definition is a policy.Definition.
+	Definition datatypes.JSON `gorm:"type:jsonb;not null" json:"definition" swaggertype:"object"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-" swaggerignore:"true"`
+}
+
+// TableName overrides the table name
+func (PolicyRule) TableName() string {
+	return "policy_rules"
+}
+
+// PolicyFinding is a rule violation found by the last policy evaluation of
+// a deployment. RuleID is a built-in rule ID or a PolicyRule ID.
+type PolicyFinding struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeploymentID uuid.UUID `gorm:"type:uuid;index;not null" json:"deployment_id"`
+	RuleID       string    `gorm:"type:varchar(64);not null" json:"rule_id"`
+	RuleName     string    `gorm:"type:varchar(128);not null" json:"rule_name"`
+	Enforcement  string    `gorm:"type:varchar(16);not null" json:"enforcement" enums:"advisory,mandatory"`
+	Source       string    `gorm:"type:varchar(16);not null" json:"source" enums:"graph,plan"`
+	NodeID       string    `gorm:"type:varchar(255)" json:"node_id,omitempty"`
+	Address      string    `gorm:"type:varchar(512)" json:"address,omitempty"`
+	Message      string    `gorm:"type:text;not null" json:"message"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (PolicyFinding) TableName() string {
+	return "policy_findings"
+}
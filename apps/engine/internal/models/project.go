@@ -17,6 +17,21 @@ type Project struct {
 	CloudProvider string         `gorm:"type:varchar(32);index" json:"cloud_provider" validate:"required,oneof=aws gcp azure do"`
 	Settings      datatypes.JSON `gorm:"type:jsonb" json:"settings" swaggertype:"object"`
 	Archived      bool           `gorm:"not null;default:false;index" json:"archived"`
+	// DriftSchedule is how often applied deployments are checked for drift,
+	// as a duration such as "6h". Empty uses the server default, "off" disables.
+	DriftSchedule string         `gorm:"type:varchar(32)" json:"drift_schedule" example:"6h"`
+	// EnvironmentTTLs maps an environment type to the default lifetime of
+	// its deployments, e.g. {"preview": "72h"}.
+	EnvironmentTTLs datatypes.JSON `gorm:"column:environment_ttls;type:jsonb" json:"environment_ttls" swaggertype:"object"`
+	// ApprovalRules gate applies behind approvals; see
+	// services.ApprovalRules. Empty applies without approval.
+	ApprovalRules datatypes.JSON `gorm:"type:jsonb" json:"approval_rules,omitempty" swaggertype:"object"`
+	// PolicySettings configures the built-in policy rules; see
+	// policy.Settings. Empty keeps their defaults.
+	PolicySettings datatypes.JSON `gorm:"type:jsonb" json:"policy_settings,omitempty" swaggertype:"object"`
+	// Budget caps the estimated monthly cost of deployments; see
+	// services.Budget. Empty sets no budget.
+	Budget datatypes.JSON `gorm:"type:jsonb" json:"budget,omitempty" swaggertype:"object"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-" swaggerignore:"true"`
@@ -1,5 +1,69 @@
-//go:build ignore
-
 package models
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Recommendation sources.
+const (
+	RecommendationSourceSecurity    = "security"
+	RecommendationSourceCost        = "cost"
+	RecommendationSourcePerformance = "performance"
+	RecommendationSourceAI          = "ai"
+)
+
+// Recommendation severities.
+const (
+	RecommendationSeverityLow      = "low"
+	RecommendationSeverityMedium   = "medium"
+	RecommendationSeverityHigh     = "high"
+	RecommendationSeverityCritical = "critical"
+)
+
+// Recommendation statuses.
+const (
+	RecommendationOpen      = "open"      // waiting for the user
+	RecommendationDismissed = "dismissed" // the user does not want it
+	RecommendationApplied   = "applied"   // its patch was saved as a graph version
+	RecommendationSnoozed   = "snoozed"   // hidden until SnoozedUntil, then open again
+)
+
+// Recommendation is an improvement suggested for a project, such as a
+// security finding of the graph analyzer.
+type Recommendation struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProjectID   uuid.UUID `gorm:"type:uuid;index;not null" json:"project_id"`
+	Source      string    `gorm:"type:varchar(16);not null;index" json:"source" enums:"security,cost,performance,ai"`
+	Severity    string    `gorm:"type:varchar(16);not null" json:"severity" enums:"low,medium,high,critical"`
+	Status      string    `gorm:"type:varchar(16);not null;default:open;index" json:"status" enums:"open,dismissed,applied,snoozed"`
+	Title       string    `gorm:"type:text;not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description,omitempty"`
+	// NodeIDs are the graph nodes the recommendation is about.
+	NodeIDs datatypes.JSON `gorm:"type:jsonb" json:"node_ids" swaggertype:"array,string"`
+	// Patch applies the recommendation to the graph; see
+	// compiler.NodePatch. Empty when it needs a decision only the user can
+	// make.
+	Patch datatypes.JSON `gorm:"type:jsonb" json:"patch,omitempty" swaggertype:"array,object"`
+	// Fingerprint identifies a finding across analyzer runs, so a rerun
+	// keeps its status.
+	Fingerprint string `gorm:"type:varchar(255)" json:"fingerprint,omitempty"`
+	// Details hold source-specific data; for security findings see
+	// optimizer.SecurityFinding plus its graph version.
+	Details      datatypes.JSON `gorm:"type:jsonb" json:"details,omitempty" swaggertype:"object"`
+	SnoozedUntil *time.Time     `json:"snoozed_until,omitempty"`
+	// GraphVersion is the graph version the patch was made against; zero
+	// when unknown. Apply refuses the patch once the graph moved on.
+	GraphVersion int `gorm:"not null;default:0" json:"graph_version,omitempty"`
+	// AppliedVersion is the graph version created by applying the patch.
+	AppliedVersion *int      `json:"applied_version,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
 
+// TableName overrides the table name
+func (Recommendation) TableName() string {
+	return "recommendations"
+}
@@ -8,12 +8,18 @@ import (
 	"gorm.io/gorm"
 )
 
-// Resource represents a managed cloud resource in a deployment.
+// Resource represents a managed cloud resource in a deployment. Rows are
+// synced from Terraform state by the worker; Properties holds the key
+// attributes of the resource.
 type Resource struct {
 	ID           uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	DeploymentID uuid.UUID      `gorm:"type:uuid;index;not null" json:"deployment_id" validate:"required"`
+	ProjectID    uuid.UUID      `gorm:"type:uuid;index" json:"project_id"`
 	ResourceType string         `gorm:"type:varchar(64);index;not null" json:"resource_type" validate:"required"`
 	ResourceName string         `gorm:"type:varchar(128);index;not null" json:"resource_name" validate:"required"`
+	Address      string         `gorm:"type:varchar(256)" json:"address" example:"aws_instance.web"`
+	CloudID      string         `gorm:"type:varchar(256);index" json:"cloud_id,omitempty" example:"i-0abc123"`
+	NodeID       string         `gorm:"type:varchar(128)" json:"node_id,omitempty"`
 	Properties   datatypes.JSON `gorm:"type:jsonb" json:"properties" swaggertype:"object"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
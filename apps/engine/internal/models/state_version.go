@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// StateVersion is an immutable snapshot of a deployment's Terraform state.
+// Serial increases by one for every write to the same deployment.
+type StateVersion struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	DeploymentID uuid.UUID      `gorm:"type:uuid;not null;index:idx_state_versions_deployment_serial,unique" json:"deployment_id"`
+	Serial       int            `gorm:"not null;index:idx_state_versions_deployment_serial,unique" json:"serial"`
+	Lineage      string         `gorm:"type:varchar(64);not null" json:"lineage"`
+	Checksum     string         `gorm:"type:varchar(64);not null" json:"checksum"`
+	Size         int            `gorm:"not null" json:"size"`
+	Author       string         `gorm:"type:varchar(128);not null" json:"author"`
+	RestoredFrom *int           `json:"restored_from,omitempty"`
+	State        datatypes.JSON `gorm:"type:jsonb" json:"-" swaggerignore:"true"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (StateVersion) TableName() string {
+	return "state_versions"
+}
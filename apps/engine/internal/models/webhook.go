@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Webhook delivery outcomes.
+const (
+	WebhookDeliveryPending   = "pending" // queued or being retried
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed" // out of attempts
+)
+
+// Webhook is an endpoint a project's events are POSTed to, signed with
+// Secret; see events.Sign.
+type Webhook struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ProjectID   uuid.UUID `gorm:"type:uuid;index;not null" json:"project_id"`
+	URL         string    `gorm:"type:text;not null" json:"url"`
+	Description string    `gorm:"type:text" json:"description,omitempty"`
+	// Events are the event types sent to the endpoint; see events.Types.
+	Events datatypes.JSON `gorm:"type:jsonb;not null" json:"events" swaggertype:"array,string"`
+	// Secret is only shown when the webhook is created. It is stored sealed
+	// by the encryption keyring.
+	Secret    string    `gorm:"type:text;not null" json:"-"`
+	Enabled   bool      `gorm:"not null" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// WebhookDelivery is one event sent, with retries, to a webhook. Its ID is
+// sent as the delivery ID and its EventID as the idempotency key.
+type WebhookDelivery struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	WebhookID uuid.UUID `gorm:"type:uuid;index;not null" json:"webhook_id"`
+	ProjectID uuid.UUID `gorm:"type:uuid;index;not null" json:"project_id"`
+	EventID   string    `gorm:"type:varchar(64);not null" json:"event_id"`
+	EventType string    `gorm:"type:varchar(64);not null" json:"event_type"`
+	// Payload is the JSON body sent, the same on every attempt.
+	Payload datatypes.JSON `gorm:"type:jsonb;not null" json:"payload,omitempty" swaggertype:"object"`
+	Status  string         `gorm:"type:varchar(16);not null" json:"status" enums:"pending,succeeded,failed"`
+	// Attempts counts the requests made so far; ResponseStatus and Error
+	// describe the last one.
+	Attempts       int    `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus int    `gorm:"not null;default:0" json:"response_status,omitempty"`
+	Error          string `gorm:"type:text" json:"error,omitempty"`
+	// RedeliveryOf is the delivery this one repeats, if any.
+	RedeliveryOf *uuid.UUID `gorm:"type:uuid" json:"redelivery_of,omitempty"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,222 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+)
+
+// Built-in rule IDs.
+const (
+	RuleSSHOpenToWorld        = "ssh-open-to-world"
+	RuleS3Versioning          = "s3-versioning"
+	RuleInstanceTypeAllowlist = "instance-type-allowlist"
+)
+
+// Builtins returns the built-in rule pack with its default enforcement,
+// adjusted by settings. The instance type allowlist is off until the
+// settings list allowed types.
+func Builtins(settings Settings) []Rule {
+	rules := []Rule{
+		{
+			ID:          RuleSSHOpenToWorld,
+			Name:        "SSH open to the world",
+			Description: "Security groups must not allow SSH (port 22) from 0.0.0.0/0 or ::/0.",
+			Enforcement: Mandatory,
+			check:       checkSSHOpen,
+		},
+		{
+			ID:          RuleS3Versioning,
+			Name:        "S3 bucket versioning",
+			Description: "S3 buckets should have versioning enabled.",
+			Enforcement: Advisory,
+			check:       checkS3Versioning,
+		},
+		{
+			ID:          RuleInstanceTypeAllowlist,
+			Name:        "Instance type allowlist",
+			Description: "EC2 instances must use one of the allowed instance types.",
+			Enforcement: Mandatory,
+			check:       checkInstanceTypes,
+		},
+	}
+	for i := range rules {
+		rules[i].Builtin = true
+		if e, ok := settings.Builtin[rules[i].ID]; ok {
+			rules[i].Enforcement = e
+		}
+		if rules[i].ID == RuleInstanceTypeAllowlist && len(settings.AllowedInstanceTypes) == 0 {
+			rules[i].Enforcement = Off
+		}
+	}
+	return rules
+}
+
+// isBuiltin reports whether id names a built-in rule.
+func isBuiltin(id string) bool {
+	switch id {
+	case RuleSSHOpenToWorld, RuleS3Versioning, RuleInstanceTypeAllowlist:
+		return true
+	}
+	return false
+}
+
+func checkSSHOpen(in *Input) []Finding {
+	var out []Finding
+	for _, n := range in.Graph.Nodes {
+		if n.Type != "aws_security_group" {
+			continue
+		}
+		if rule, ok := openSSHRule(n.Properties["ingress"]); ok {
+			out = append(out, Finding{Source: SourceGraph, NodeID: n.ID, Address: compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type}),
+				Message: fmt.Sprintf("security group %s allows SSH from %s", n.ID, rule)})
+		}
+	}
+	for _, c := range planned(in.Plan, "aws_security_group") {
+		if rule, ok := openSSHRule(c.Attrs["ingress"]); ok {
+			out = append(out, Finding{Source: SourcePlan, NodeID: c.NodeID, Address: c.Address,
+				Message: fmt.Sprintf("%s allows SSH from %s", c.Address, rule)})
+		}
+	}
+	return out
+}
+
+// openSSHRule finds an ingress rule covering port 22 from anywhere. Graph
+// rules hold cidr_blocks as a string or a list, plan rules as lists with
+// IPv6 ranges apart.
+func openSSHRule(v interface{}) (string, bool) {
+	rules, _ := v.([]interface{})
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok || !coversPort(rule, 22) {
+			continue
+		}
+		for _, key := range []string{"cidr_blocks", "ipv6_cidr_blocks"} {
+			for _, cidr := range stringList(rule[key]) {
+				if cidr == "0.0.0.0/0" || cidr == "::/0" {
+					return cidr, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func coversPort(rule map[string]interface{}, port float64) bool {
+	proto := strings.ToLower(fmt.Sprint(rule["protocol"]))
+	if proto == "-1" || proto == "all" {
+		return true
+	}
+	if proto != "tcp" && proto != "6" {
+		return false
+	}
+	from, ok1 := number(rule["from_port"])
+	to, ok2 := number(rule["to_port"])
+	return ok1 && ok2 && from <= port && port <= to
+}
+
+func checkS3Versioning(in *Input) []Finding {
+	var out []Finding
+	for _, n := range in.Graph.Nodes {
+		if n.Type != "aws_s3_bucket" {
+			continue
+		}
+		if v, _ := n.Properties["versioning"].(bool); !v {
+			out = append(out, Finding{Source: SourceGraph, NodeID: n.ID, Address: compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type}),
+				Message: fmt.Sprintf("bucket %s does not have versioning enabled", n.ID)})
+		}
+	}
+	if in.Plan == nil {
+		return out
+	}
+	// the compiler emits versioning as a separate <bucket>_versioning resource
+	versioned := map[string]bool{}
+	for _, c := range planned(in.Plan, "aws_s3_bucket_versioning") {
+		versioned[strings.TrimSuffix(c.Address[strings.LastIndex(c.Address, ".")+1:], "_versioning")] = true
+	}
+	for _, c := range planned(in.Plan, "aws_s3_bucket") {
+		name := c.Address[strings.LastIndex(c.Address, ".")+1:]
+		if versioned[name] || inlineVersioning(c.Attrs["versioning"]) {
+			continue
+		}
+		out = append(out, Finding{Source: SourcePlan, NodeID: c.NodeID, Address: c.Address,
+			Message: fmt.Sprintf("%s does not have versioning enabled", c.Address)})
+	}
+	return out
+}
+
+// inlineVersioning reads the deprecated versioning block of aws_s3_bucket.
+func inlineVersioning(v interface{}) bool {
+	blocks, _ := v.([]interface{})
+	for _, b := range blocks {
+		if m, ok := b.(map[string]interface{}); ok && m["enabled"] == true {
+			return true
+		}
+	}
+	return false
+}
+
+func checkInstanceTypes(in *Input) []Finding {
+	allowed := map[string]bool{}
+	for _, t := range in.Settings.AllowedInstanceTypes {
+		allowed[t] = true
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	list := strings.Join(in.Settings.AllowedInstanceTypes, ", ")
+	var out []Finding
+	for _, n := range in.Graph.Nodes {
+		if n.Type != "aws_instance" {
+			continue
+		}
+		if t := fmt.Sprint(n.Properties["instance_type"]); !allowed[t] {
+			out = append(out, Finding{Source: SourceGraph, NodeID: n.ID, Address: compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type}),
+				Message: fmt.Sprintf("instance %s uses %s, allowed: %s", n.ID, t, list)})
+		}
+	}
+	for _, c := range planned(in.Plan, "aws_instance") {
+		t, ok := c.Attrs["instance_type"].(string)
+		if ok && !allowed[t] {
+			out = append(out, Finding{Source: SourcePlan, NodeID: c.NodeID, Address: c.Address,
+				Message: fmt.Sprintf("%s uses %s, allowed: %s", c.Address, t, list)})
+		}
+	}
+	return out
+}
+
+// stringList reads a string or a list of strings.
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, s := range t {
+			out = append(out, fmt.Sprint(s))
+		}
+		return out
+	}
+	return nil
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		var f float64
+		_, err := fmt.Sscan(n, &f)
+		return f, err == nil
+	}
+	return 0, false
+}
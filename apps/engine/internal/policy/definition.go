@@ -0,0 +1,249 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+)
+
+// Condition operators.
+const (
+	OpEquals      = "equals"
+	OpNotEquals   = "not_equals"
+	OpIn          = "in"
+	OpNotIn       = "not_in"
+	OpExists      = "exists"
+	OpNotExists   = "not_exists"
+	OpContains    = "contains"
+	OpNotContains = "not_contains"
+	OpMatches     = "matches"
+)
+
+// Definition is a declarative rule: every resource of ResourceType must
+// satisfy all Assert conditions, otherwise it is a finding.
+//
+//	{"resource_type": "aws_db_instance", "assert": [
+//	  {"path": "storage_encrypted", "op": "equals", "value": true}]}
+type Definition struct {
+	ResourceType string `json:"resource_type"`
+	// Source is "graph" (node properties, the default) or "plan" (planned
+	// resource attributes).
+	Source string      `json:"source,omitempty"`
+	Assert []Condition `json:"assert"`
+	// Message replaces the generated finding message.
+	Message string `json:"message,omitempty"`
+}
+
+// Condition tests the values at Path, a dotted path into the resource's
+// attributes where "*" walks every element of a list, e.g.
+// "ingress.*.from_port". With a wildcard every value must pass.
+type Condition struct {
+	Path   string        `json:"path"`
+	Op     string        `json:"op"`
+	Value  interface{}   `json:"value,omitempty"`
+	Values []interface{} `json:"values,omitempty"`
+}
+
+// ParseDefinition decodes and validates a rule definition.
+func ParseDefinition(raw []byte) (*Definition, error) {
+	var d Definition
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, fmt.Errorf("invalid rule definition: %w", err)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Validate checks the definition is complete and its patterns compile.
+func (d *Definition) Validate() error {
+	if d.ResourceType == "" {
+		return fmt.Errorf("resource_type is required")
+	}
+	if d.Source != "" && d.Source != SourceGraph && d.Source != SourcePlan {
+		return fmt.Errorf("source must be %q or %q", SourceGraph, SourcePlan)
+	}
+	if len(d.Assert) == 0 {
+		return fmt.Errorf("assert needs at least one condition")
+	}
+	for i, c := range d.Assert {
+		if c.Path == "" {
+			return fmt.Errorf("assert[%d]: path is required", i)
+		}
+		switch c.Op {
+		case OpEquals, OpNotEquals, OpContains, OpNotContains:
+			if c.Value == nil {
+				return fmt.Errorf("assert[%d]: %s needs a value", i, c.Op)
+			}
+		case OpIn, OpNotIn:
+			if len(c.Values) == 0 {
+				return fmt.Errorf("assert[%d]: %s needs values", i, c.Op)
+			}
+		case OpMatches:
+			s, ok := c.Value.(string)
+			if !ok {
+				return fmt.Errorf("assert[%d]: matches needs a string pattern", i)
+			}
+			if _, err := regexp.Compile(s); err != nil {
+				return fmt.Errorf("assert[%d]: invalid pattern: %w", i, err)
+			}
+		case OpExists, OpNotExists:
+		default:
+			return fmt.Errorf("assert[%d]: unknown op %q", i, c.Op)
+		}
+	}
+	return nil
+}
+
+func (d *Definition) evaluate(in *Input) []Finding {
+	var out []Finding
+	if d.Source == SourcePlan {
+		for _, c := range planned(in.Plan, d.ResourceType) {
+			if failed := d.firstFailure(c.Attrs); failed != nil {
+				out = append(out, Finding{Source: SourcePlan, NodeID: c.NodeID, Address: c.Address, Message: d.message(c.Address, failed)})
+			}
+		}
+		return out
+	}
+	for _, n := range in.Graph.Nodes {
+		if n.Type != d.ResourceType {
+			continue
+		}
+		if failed := d.firstFailure(n.Properties); failed != nil {
+			addr := compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type})
+			out = append(out, Finding{Source: SourceGraph, NodeID: n.ID, Address: addr, Message: d.message(addr, failed)})
+		}
+	}
+	return out
+}
+
+func (d *Definition) firstFailure(attrs map[string]interface{}) *Condition {
+	for i := range d.Assert {
+		if !d.Assert[i].holds(attrs) {
+			return &d.Assert[i]
+		}
+	}
+	return nil
+}
+
+func (d *Definition) message(addr string, c *Condition) string {
+	if d.Message != "" {
+		return d.Message
+	}
+	want := c.Value
+	if c.Op == OpIn || c.Op == OpNotIn {
+		want = c.Values
+	}
+	if c.Op == OpExists || c.Op == OpNotExists {
+		return fmt.Sprintf("%s: %s must %s", addr, c.Path, opPhrases[c.Op])
+	}
+	return fmt.Sprintf("%s: %s must %s %v", addr, c.Path, opPhrases[c.Op], want)
+}
+
+var opPhrases = map[string]string{
+	OpEquals:      "be",
+	OpNotEquals:   "not be",
+	OpIn:          "be one of",
+	OpNotIn:       "not be one of",
+	OpExists:      "be set",
+	OpNotExists:   "not be set",
+	OpContains:    "contain",
+	OpNotContains: "not contain",
+	OpMatches:     "match",
+}
+
+func (c *Condition) holds(attrs map[string]interface{}) bool {
+	values := lookup(attrs, strings.Split(c.Path, "."))
+	switch c.Op {
+	case OpExists:
+		return len(values) > 0
+	case OpNotExists:
+		return len(values) == 0
+	}
+	// a missing value only passes the negative operators
+	if len(values) == 0 {
+		return c.Op == OpNotEquals || c.Op == OpNotIn || c.Op == OpNotContains
+	}
+	for _, v := range values {
+		if !c.test(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Condition) test(v interface{}) bool {
+	switch c.Op {
+	case OpEquals:
+		return same(v, c.Value)
+	case OpNotEquals:
+		return !same(v, c.Value)
+	case OpIn, OpNotIn:
+		found := false
+		for _, want := range c.Values {
+			if same(v, want) {
+				found = true
+				break
+			}
+		}
+		return found == (c.Op == OpIn)
+	case OpContains, OpNotContains:
+		return contains(v, c.Value) == (c.Op == OpContains)
+	case OpMatches:
+		re, err := regexp.Compile(c.Value.(string))
+		return err == nil && re.MatchString(fmt.Sprint(v))
+	}
+	return false
+}
+
+// lookup returns the values at path, walking every element of a list for
+// "*". Nil values count as missing.
+func lookup(v interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if v == nil {
+			return nil
+		}
+		return []interface{}{v}
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return lookup(t[path[0]], path[1:])
+	case []interface{}:
+		if path[0] != "*" {
+			return nil
+		}
+		var out []interface{}
+		for _, e := range t {
+			out = append(out, lookup(e, path[1:])...)
+		}
+		return out
+	}
+	return nil
+}
+
+// same compares scalars by their printed form so that 22, 22.0 and "22"
+// from properties, JSON and plans all match.
+func same(a, b interface{}) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// contains reports whether a list holds want, or a string holds it as a
+// substring.
+func contains(v, want interface{}) bool {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			if same(e, want) {
+				return true
+			}
+		}
+		return false
+	case string:
+		return strings.Contains(t, fmt.Sprint(want))
+	}
+	return same(v, want)
+}
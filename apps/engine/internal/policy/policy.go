@@ -0,0 +1,161 @@
+// Package policy evaluates guardrails against a deployment's graph and its
+// Terraform plan. Rules are either built in (see Builtins) or declared as
+// data (see Definition); each is advisory or mandatory, and a mandatory
+// violation blocks the apply.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/iac-studio/engine/internal/provisioner"
+)
+
+// ErrViolation is returned when mandatory rules are violated.
+var ErrViolation = errors.New("policy violation")
+
+// Enforcement is what happens when a rule is violated.
+type Enforcement string
+
+const (
+	Advisory  Enforcement = "advisory"  // recorded, the deployment goes ahead
+	Mandatory Enforcement = "mandatory" // the deployment fails before apply
+	Off       Enforcement = "off"       // the rule is not evaluated
+)
+
+// Valid reports whether e is a known enforcement level.
+func (e Enforcement) Valid() bool {
+	return e == Advisory || e == Mandatory || e == Off
+}
+
+// Sources a finding can come from.
+const (
+	SourceGraph = "graph"
+	SourcePlan  = "plan"
+)
+
+// Input is what rules are evaluated against. Plan is nil when no plan was
+// made; rules then only see the graph.
+type Input struct {
+	Graph    provisioner.Graph
+	Plan     *provisioner.Plan
+	Settings Settings
+}
+
+// Finding is one rule violation.
+type Finding struct {
+	RuleID      string      `json:"rule_id"`
+	RuleName    string      `json:"rule_name"`
+	Enforcement Enforcement `json:"enforcement"`
+	Source      string      `json:"source"`
+	NodeID      string      `json:"node_id,omitempty"`
+	Address     string      `json:"address,omitempty"`
+	Message     string      `json:"message"`
+}
+
+// Rule is a built-in or custom rule. Built-in rules check in code; custom
+// rules carry a Definition.
+type Rule struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Enforcement Enforcement `json:"enforcement"`
+	Builtin     bool        `json:"builtin"`
+	Definition  *Definition `json:"definition,omitempty"`
+
+	check func(in *Input) []Finding
+}
+
+// Result is the outcome of evaluating a set of rules.
+type Result struct {
+	Findings  []Finding `json:"findings"`
+	Mandatory int       `json:"mandatory"`
+	Advisory  int       `json:"advisory"`
+}
+
+// Blocked reports whether a mandatory rule was violated.
+func (r *Result) Blocked() bool {
+	return r.Mandatory > 0
+}
+
+// Err returns ErrViolation describing the mandatory findings, or nil.
+func (r *Result) Err() error {
+	if !r.Blocked() {
+		return nil
+	}
+	for _, f := range r.Findings {
+		if f.Enforcement == Mandatory {
+			return fmt.Errorf("%w: %d mandatory rule(s) violated, first: %s: %s", ErrViolation, r.Mandatory, f.RuleID, f.Message)
+		}
+	}
+	return ErrViolation
+}
+
+// Evaluate runs rules against in. A rule reporting the same node (or,
+// without a node, the same address) from both the graph and the plan is
+// only reported once.
+func Evaluate(rules []Rule, in *Input) *Result {
+	res := &Result{Findings: []Finding{}}
+	for _, rule := range rules {
+		if rule.Enforcement == Off {
+			continue
+		}
+		var found []Finding
+		switch {
+		case rule.check != nil:
+			found = rule.check(in)
+		case rule.Definition != nil:
+			found = rule.Definition.evaluate(in)
+		}
+		seen := map[string]bool{}
+		for _, f := range found {
+			key := f.NodeID
+			if key == "" {
+				key = f.Address
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			f.RuleID, f.RuleName, f.Enforcement = rule.ID, rule.Name, rule.Enforcement
+			res.Findings = append(res.Findings, f)
+			if rule.Enforcement == Mandatory {
+				res.Mandatory++
+			} else {
+				res.Advisory++
+			}
+		}
+	}
+	sort.SliceStable(res.Findings, func(i, j int) bool {
+		a, b := res.Findings[i], res.Findings[j]
+		if a.Enforcement != b.Enforcement {
+			return a.Enforcement == Mandatory
+		}
+		return a.RuleID < b.RuleID
+	})
+	return res
+}
+
+// planned returns the resources of the plan that exist after apply,
+// optionally limited to one type.
+func planned(p *provisioner.Plan, resourceType string) []changeView {
+	if p == nil {
+		return nil
+	}
+	var out []changeView
+	for _, c := range p.ResourceChanges {
+		if c.After == nil || (resourceType != "" && c.Type != resourceType) {
+			continue
+		}
+		out = append(out, changeView{Address: c.Address, NodeID: c.NodeID, Type: c.Type, Attrs: c.After})
+	}
+	return out
+}
+
+type changeView struct {
+	Address string
+	NodeID  string
+	Type    string
+	Attrs   map[string]interface{}
+}
@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/provisioner/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltins(t *testing.T) {
+	graph := provisioner.Graph{Nodes: []provisioner.Node{
+		{ID: "web_sg", Type: "aws_security_group", Properties: map[string]interface{}{
+			"ingress": []interface{}{
+				map[string]interface{}{"from_port": 443, "to_port": 443, "protocol": "tcp", "cidr_blocks": "0.0.0.0/0"},
+				map[string]interface{}{"from_port": 22, "to_port": 22, "protocol": "tcp", "cidr_blocks": "0.0.0.0/0"},
+			},
+		}},
+		{ID: "db_sg", Type: "aws_security_group", Properties: map[string]interface{}{
+			"ingress": []interface{}{map[string]interface{}{"from_port": 0, "to_port": 65535, "protocol": "tcp", "cidr_blocks": "10.0.0.0/16"}},
+		}},
+		{ID: "logs", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "logs"}},
+		{ID: "assets", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "assets", "versioning": true}},
+		{ID: "app", Type: "aws_instance", Properties: map[string]interface{}{"instance_type": "m5.4xlarge"}},
+	}}
+	plan := &provisioner.Plan{ResourceChanges: []terraform.ResourceChange{
+		{Address: "aws_security_group.web_sg", Type: "aws_security_group", NodeID: "web_sg", Action: terraform.ChangeCreate, After: map[string]interface{}{
+			"ingress": []interface{}{map[string]interface{}{"from_port": 22.0, "to_port": 22.0, "protocol": "tcp", "cidr_blocks": []interface{}{"0.0.0.0/0"}}},
+		}},
+		{Address: "aws_security_group.legacy", Type: "aws_security_group", Action: terraform.ChangeUpdate, After: map[string]interface{}{
+			"ingress": []interface{}{map[string]interface{}{"from_port": 0.0, "to_port": 0.0, "protocol": "-1", "ipv6_cidr_blocks": []interface{}{"::/0"}}},
+		}},
+		{Address: "aws_s3_bucket.assets", Type: "aws_s3_bucket", NodeID: "assets", Action: terraform.ChangeCreate, After: map[string]interface{}{"bucket": "assets"}},
+		{Address: "aws_s3_bucket_versioning.assets_versioning", Type: "aws_s3_bucket_versioning", Action: terraform.ChangeCreate, After: map[string]interface{}{}},
+		{Address: "aws_instance.old", Type: "aws_instance", Action: terraform.ChangeDelete},
+	}}
+
+	t.Run("defaults", func(t *testing.T) {
+		res := Evaluate(Builtins(Settings{}), &Input{Graph: graph, Plan: plan})
+		require.True(t, res.Blocked())
+		require.Equal(t, 2, res.Mandatory)
+		require.Equal(t, 1, res.Advisory)
+
+		// the graph and plan report web_sg once; legacy only shows in the plan
+		require.Equal(t, RuleSSHOpenToWorld, res.Findings[0].RuleID)
+		require.Equal(t, "web_sg", res.Findings[0].NodeID)
+		require.Equal(t, SourceGraph, res.Findings[0].Source)
+		require.Equal(t, "aws_security_group.legacy", res.Findings[1].Address)
+		require.Equal(t, SourcePlan, res.Findings[1].Source)
+
+		require.Equal(t, RuleS3Versioning, res.Findings[2].RuleID)
+		require.Equal(t, Advisory, res.Findings[2].Enforcement)
+		require.Equal(t, "logs", res.Findings[2].NodeID)
+
+		err := res.Err()
+		require.True(t, errors.Is(err, ErrViolation))
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		settings := Settings{
+			Builtin:              map[string]Enforcement{RuleSSHOpenToWorld: Off, RuleS3Versioning: Mandatory},
+			AllowedInstanceTypes: []string{"t3.micro", "t3.small"},
+		}
+		res := Evaluate(Builtins(settings), &Input{Graph: graph, Plan: plan, Settings: settings})
+		require.Equal(t, 2, res.Mandatory)
+		require.Equal(t, 0, res.Advisory)
+		require.Equal(t, RuleInstanceTypeAllowlist, res.Findings[0].RuleID)
+		require.Equal(t, "app", res.Findings[0].NodeID)
+		require.Equal(t, RuleS3Versioning, res.Findings[1].RuleID)
+	})
+
+	t.Run("clean", func(t *testing.T) {
+		res := Evaluate(Builtins(Settings{}), &Input{Graph: provisioner.Graph{Nodes: graph.Nodes[1:2]}})
+		require.False(t, res.Blocked())
+		require.Empty(t, res.Findings)
+		require.NoError(t, res.Err())
+	})
+}
+
+func TestDefinition(t *testing.T) {
+	def, err := ParseDefinition([]byte(`{"resource_type":"aws_security_group","assert":[
+		{"path":"ingress.*.from_port","op":"not_in","values":[3389, 5432]},
+		{"path":"description","op":"exists"}]}`))
+	require.NoError(t, err)
+	rules := []Rule{{ID: "custom", Name: "custom", Enforcement: Mandatory, Definition: def}}
+
+	graph := provisioner.Graph{Nodes: []provisioner.Node{
+		{ID: "ok", Type: "aws_security_group", Properties: map[string]interface{}{"description": "web",
+			"ingress": []interface{}{map[string]interface{}{"from_port": 443}}}},
+		{ID: "rdp", Type: "aws_security_group", Properties: map[string]interface{}{"description": "win",
+			"ingress": []interface{}{map[string]interface{}{"from_port": 443}, map[string]interface{}{"from_port": 3389}}}},
+		{ID: "nodesc", Type: "aws_security_group", Properties: map[string]interface{}{}},
+		{ID: "bucket", Type: "aws_s3_bucket", Properties: map[string]interface{}{}},
+	}}
+	res := Evaluate(rules, &Input{Graph: graph})
+	require.Len(t, res.Findings, 2)
+	require.Equal(t, "rdp", res.Findings[0].NodeID)
+	require.Contains(t, res.Findings[0].Message, "ingress.*.from_port must not be one of [3389 5432]")
+	require.Equal(t, "nodesc", res.Findings[1].NodeID)
+
+	plan := &provisioner.Plan{ResourceChanges: []terraform.ResourceChange{
+		{Address: "aws_db_instance.db", Type: "aws_db_instance", After: map[string]interface{}{"storage_encrypted": false, "engine": "postgres"}},
+		{Address: "aws_db_instance.ok", Type: "aws_db_instance", After: map[string]interface{}{"storage_encrypted": true, "engine": "postgres"}},
+	}}
+	def, err = ParseDefinition([]byte(`{"resource_type":"aws_db_instance","source":"plan","message":"encrypt it","assert":[
+		{"path":"storage_encrypted","op":"equals","value":true},{"path":"engine","op":"matches","value":"^(postgres|mysql)$"}]}`))
+	require.NoError(t, err)
+	res = Evaluate([]Rule{{ID: "enc", Enforcement: Advisory, Definition: def}}, &Input{Plan: plan})
+	require.Len(t, res.Findings, 1)
+	require.Equal(t, "aws_db_instance.db", res.Findings[0].Address)
+	require.Equal(t, "encrypt it", res.Findings[0].Message)
+	require.False(t, res.Blocked())
+
+	for _, bad := range []string{
+		`{"assert":[{"path":"a","op":"exists"}]}`,
+		`{"resource_type":"x","assert":[]}`,
+		`{"resource_type":"x","source":"state","assert":[{"path":"a","op":"exists"}]}`,
+		`{"resource_type":"x","assert":[{"path":"a","op":"greater"}]}`,
+		`{"resource_type":"x","assert":[{"path":"a","op":"in"}]}`,
+		`{"resource_type":"x","assert":[{"path":"a","op":"matches","value":"("}]}`,
+	} {
+		_, err := ParseDefinition([]byte(bad))
+		require.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,42 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Settings is a project's configuration of the built-in rules, stored in
+// models.Project.PolicySettings.
+type Settings struct {
+	// Builtin overrides the enforcement of built-in rules by ID; "off"
+	// disables a rule.
+	Builtin map[string]Enforcement `json:"builtin,omitempty"`
+	// AllowedInstanceTypes turns on the instance type allowlist.
+	AllowedInstanceTypes []string `json:"allowed_instance_types,omitempty"`
+}
+
+// ParseSettings decodes and validates policy settings. Empty settings keep
+// the defaults.
+func ParseSettings(raw []byte) (Settings, error) {
+	var s Settings
+	if len(raw) == 0 || string(raw) == "null" {
+		return s, nil
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return s, fmt.Errorf("invalid policy settings: %w", err)
+	}
+	for id, e := range s.Builtin {
+		if !isBuiltin(id) {
+			return s, fmt.Errorf("unknown built-in rule %q", id)
+		}
+		if !e.Valid() {
+			return s, fmt.Errorf("rule %s: enforcement must be advisory, mandatory or off", id)
+		}
+	}
+	for _, t := range s.AllowedInstanceTypes {
+		if t == "" {
+			return s, fmt.Errorf("allowed_instance_types must not contain empty values")
+		}
+	}
+	return s, nil
+}
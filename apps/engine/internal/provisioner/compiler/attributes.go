@@ -0,0 +1,85 @@
+package compiler
+
+import "strings"
+
+// AttributeMapping ties a node property to the Terraform attribute it is
+// compiled into, so values observed in state can be written back to the graph.
+type AttributeMapping struct {
+	Property string
+	// Attribute is a dotted path into the resource's state attributes,
+	// e.g. "tags.Name".
+	Attribute string
+	// FromState converts the state value into the property's shape. Nil
+	// means the value is used as-is.
+	FromState func(v interface{}) interface{}
+}
+
+var attributeMappings = map[string][]AttributeMapping{
+	"aws_instance": {
+		{Property: "ami", Attribute: "ami"},
+		{Property: "instance_type", Attribute: "instance_type"},
+		{Property: "name", Attribute: "tags.Name"},
+	},
+	"aws_s3_bucket": {
+		{Property: "bucket_name", Attribute: "bucket"},
+	},
+	"aws_security_group": {
+		{Property: "name", Attribute: "name"},
+		{Property: "description", Attribute: "description"},
+		{Property: "ingress", Attribute: "ingress", FromState: ingressFromState},
+	},
+}
+
+// AttributeMappings returns the property mappings of a resource type.
+func AttributeMappings(resourceType string) []AttributeMapping {
+	return attributeMappings[resourceType]
+}
+
+// Root returns the top-level state attribute of the mapping.
+func (m AttributeMapping) Root() string {
+	root, _, _ := strings.Cut(m.Attribute, ".")
+	return root
+}
+
+// Value extracts the property value from the top-level attribute value v.
+func (m AttributeMapping) Value(v interface{}) interface{} {
+	if _, rest, ok := strings.Cut(m.Attribute, "."); ok {
+		for _, key := range strings.Split(rest, ".") {
+			obj, isMap := v.(map[string]interface{})
+			if !isMap {
+				return nil
+			}
+			v = obj[key]
+		}
+	}
+	if v != nil && m.FromState != nil {
+		return m.FromState(v)
+	}
+	return v
+}
+
+// ingressFromState converts state ingress blocks into the rule objects
+// SecurityGroupCompiler reads, which carry a single CIDR block.
+func ingressFromState(v interface{}) interface{} {
+	blocks, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	rules := make([]interface{}, 0, len(blocks))
+	for _, b := range blocks {
+		block, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rule := map[string]interface{}{
+			"from_port": block["from_port"],
+			"to_port":   block["to_port"],
+			"protocol":  block["protocol"],
+		}
+		if cidrs, ok := block["cidr_blocks"].([]interface{}); ok && len(cidrs) > 0 {
+			rule["cidr_blocks"] = cidrs[0]
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
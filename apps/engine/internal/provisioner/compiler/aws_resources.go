@@ -48,6 +48,21 @@ func (c *EC2Compiler) Compile(node Node) (string, error) {
 `, subnet))
 	}
 
+	if opts, ok := node.Properties["metadata_options"].(map[string]interface{}); ok {
+		hcl.WriteString(`
+  metadata_options {
+`)
+		for _, key := range []string{"http_endpoint", "http_tokens", "http_put_response_hop_limit"} {
+			switch v := opts[key].(type) {
+			case string:
+				hcl.WriteString(fmt.Sprintf("    %s = %q\n", key, v))
+			case float64, int:
+				hcl.WriteString(fmt.Sprintf("    %s = %v\n", key, v))
+			}
+		}
+		hcl.WriteString("  }\n")
+	}
+
 	hcl.WriteString("}\n")
 	return hcl.String(), nil
 }
@@ -74,7 +89,7 @@ func (c *S3Compiler) Compile(node Node) (string, error) {
 `, node.ID, bucketName))
 
 	// Add versioning if specified
-	if versioning, ok := node.Properties["versioning"].(bool); ok && versioning {
+	if s3Versioned(node) {
 		hcl.WriteString(fmt.Sprintf(`
 resource "aws_s3_bucket_versioning" "%s_versioning" {
   bucket = aws_s3_bucket.%s.id
@@ -89,6 +104,13 @@ resource "aws_s3_bucket_versioning" "%s_versioning" {
 	return hcl.String(), nil
 }
 
+// s3Versioned reports whether a bucket node asks for versioning, which
+// S3Compiler writes as its own aws_s3_bucket_versioning resource.
+func s3Versioned(node Node) bool {
+	versioning, ok := node.Properties["versioning"].(bool)
+	return ok && versioning
+}
+
 // SecurityGroupCompiler compiles aws_security_group resources
 type SecurityGroupCompiler struct{}
 
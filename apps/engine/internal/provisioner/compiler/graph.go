@@ -0,0 +1,105 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// referenceProperties are node properties whose value is the ID of another
+// node the resource depends on (see EC2Compiler and SecurityGroupCompiler).
+var referenceProperties = []string{"security_group", "subnet", "vpc"}
+
+// ResourceAddress returns the Terraform address of the resource compiled from node.
+func ResourceAddress(node Node) string {
+	return node.Type + "." + node.ID
+}
+
+// ResourceAddresses returns the Terraform addresses of every resource
+// compiled from node: ResourceAddress first, then the resources that refer
+// to it, such as a bucket's versioning. Destroying the first destroys the
+// others as well.
+func ResourceAddresses(node Node) []string {
+	addrs := []string{ResourceAddress(node)}
+	if node.Type == "aws_s3_bucket" && s3Versioned(node) {
+		addrs = append(addrs, "aws_s3_bucket_versioning."+node.ID+"_versioning")
+	}
+	return addrs
+}
+
+// Dependencies returns, for every node ID, the IDs of the nodes it depends
+// on. An edge From -> To means From depends on To; property references such
+// as an instance's security_group are dependencies as well.
+func Dependencies(graph Graph) map[string][]string {
+	known := make(map[string]bool, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		known[n.ID] = true
+	}
+
+	deps := make(map[string][]string, len(graph.Nodes))
+	add := func(from, to string) {
+		if from == to || !known[from] || !known[to] {
+			return
+		}
+		for _, d := range deps[from] {
+			if d == to {
+				return
+			}
+		}
+		deps[from] = append(deps[from], to)
+	}
+
+	for _, e := range graph.Edges {
+		add(e.From, e.To)
+	}
+	for _, n := range graph.Nodes {
+		for _, key := range referenceProperties {
+			if ref, ok := n.Properties[key].(string); ok {
+				add(n.ID, ref)
+			}
+		}
+	}
+	return deps
+}
+
+// Dependents returns the nodes that transitively depend on any of ids, not
+// including ids themselves, sorted by ID. It fails if an ID is not in graph.
+func Dependents(graph Graph, ids []string) ([]string, error) {
+	known := make(map[string]bool, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		known[n.ID] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return nil, fmt.Errorf("node %q not found in graph", id)
+		}
+	}
+
+	// invert: node -> nodes depending on it
+	dependents := map[string][]string{}
+	for from, tos := range Dependencies(graph) {
+		for _, to := range tos {
+			dependents[to] = append(dependents[to], from)
+		}
+	}
+
+	selected := make(map[string]bool, len(ids))
+	queue := append([]string(nil), ids...)
+	for _, id := range ids {
+		selected[id] = true
+	}
+	var out []string
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, d := range dependents[id] {
+			if selected[d] {
+				continue
+			}
+			selected[d] = true
+			out = append(out, d)
+			queue = append(queue, d)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,51 @@
+package compiler
+
+import "fmt"
+
+// NodePatch changes the properties of one node: Set overwrites properties
+// and Unset removes them.
+type NodePatch struct {
+	NodeID string                 `json:"node_id"`
+	Set    map[string]interface{} `json:"set,omitempty"`
+	Unset  []string               `json:"unset,omitempty"`
+	// Reason tells the user why the change is proposed.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Apply returns a copy of props with the patch applied.
+func (p NodePatch) Apply(props map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(props)+len(p.Set))
+	for k, v := range props {
+		out[k] = v
+	}
+	for _, k := range p.Unset {
+		delete(out, k)
+	}
+	for k, v := range p.Set {
+		out[k] = v
+	}
+	return out
+}
+
+// ApplyPatches returns a copy of graph with patches applied in order. A
+// patch that changes nothing or names an unknown node is an error.
+func ApplyPatches(graph Graph, patches []NodePatch) (Graph, error) {
+	out := Graph{Nodes: make([]Node, len(graph.Nodes)), Edges: graph.Edges}
+	copy(out.Nodes, graph.Nodes)
+	for i, p := range patches {
+		if len(p.Set) == 0 && len(p.Unset) == 0 {
+			return graph, fmt.Errorf("patch %d: no properties to set or unset", i)
+		}
+		found := false
+		for j := range out.Nodes {
+			if out.Nodes[j].ID == p.NodeID {
+				out.Nodes[j].Properties = p.Apply(out.Nodes[j].Properties)
+				found = true
+			}
+		}
+		if !found {
+			return graph, fmt.Errorf("patch %d: unknown node %q", i, p.NodeID)
+		}
+	}
+	return out, nil
+}
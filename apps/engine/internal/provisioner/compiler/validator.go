@@ -1,5 +1,167 @@
-//go:build ignore
-
 package compiler
 
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// identifier is what Terraform accepts as a resource name.
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// referenceTypes is the node type each reference property must point to.
+var referenceTypes = map[string]string{
+	"security_group": "aws_security_group",
+	"subnet":         "aws_subnet",
+	"vpc":            "aws_vpc",
+}
+
+// Validate checks graph without compiling it and returns every problem
+// found, in node order: bad or duplicate IDs, unsupported types, missing
+// required properties, malformed properties, references and edges to
+// unknown nodes, and dependency cycles. An empty result means Compile will
+// accept the graph.
+func (c *Compiler) Validate(graph Graph) []error {
+	var errs []error
+	nodes := make(map[string]Node, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		if !identifier.MatchString(n.ID) {
+			errs = append(errs, fmt.Errorf("node %q: id must start with a letter or underscore and contain only letters, digits, _ and -", n.ID))
+			continue
+		}
+		if _, dup := nodes[n.ID]; dup {
+			errs = append(errs, fmt.Errorf("node %s: duplicate id", n.ID))
+			continue
+		}
+		nodes[n.ID] = n
+	}
+
+	for _, n := range graph.Nodes {
+		rc, ok := c.resourceCompilers[n.Type]
+		if !ok {
+			errs = append(errs, fmt.Errorf("node %s: unsupported resource type %q (supported: %v)", n.ID, n.Type, c.Types()))
+			continue
+		}
+		if err := rc.Validate(n); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", n.ID, err))
+		}
+		for _, key := range referenceProperties {
+			want := referenceTypes[key]
+			v, set := n.Properties[key]
+			if !set {
+				continue
+			}
+			ref, ok := v.(string)
+			if !ok {
+				errs = append(errs, fmt.Errorf("node %s: %s must be the id of a %s node", n.ID, key, want))
+				continue
+			}
+			if target, ok := nodes[ref]; !ok || target.Type != want {
+				errs = append(errs, fmt.Errorf("node %s: %s %q is not a %s node in the graph", n.ID, key, ref, want))
+			}
+		}
+		if n.Type == "aws_security_group" {
+			errs = append(errs, validateIngress(n)...)
+		}
+	}
+
+	for _, e := range graph.Edges {
+		for _, end := range []string{e.From, e.To} {
+			if _, ok := nodes[end]; !ok {
+				errs = append(errs, fmt.Errorf("edge %s: unknown node %q", e.ID, end))
+			}
+		}
+	}
+	if cycle := findCycle(Dependencies(graph)); cycle != nil {
+		errs = append(errs, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> ")))
+	}
+	return errs
+}
+
+// Types lists the resource types the compiler supports.
+func (c *Compiler) Types() []string {
+	out := make([]string, 0, len(c.resourceCompilers))
+	for t := range c.resourceCompilers {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// validateIngress checks the ingress rules SecurityGroupCompiler renders.
+func validateIngress(n Node) []error {
+	v, ok := n.Properties["ingress"]
+	if !ok {
+		return nil
+	}
+	rules, ok := v.([]interface{})
+	if !ok {
+		return []error{fmt.Errorf("node %s: ingress must be a list of rules", n.ID)}
+	}
+	var errs []error
+	for i, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Errorf("node %s: ingress[%d] must be an object", n.ID, i))
+			continue
+		}
+		for _, key := range []string{"from_port", "to_port", "protocol", "cidr_blocks"} {
+			if _, ok := rule[key]; !ok {
+				errs = append(errs, fmt.Errorf("node %s: ingress[%d] is missing %s", n.ID, i, key))
+			}
+		}
+		if cidr, ok := rule["cidr_blocks"]; ok {
+			if _, ok := cidr.(string); !ok {
+				errs = append(errs, fmt.Errorf("node %s: ingress[%d].cidr_blocks must be a single CIDR string", n.ID, i))
+			}
+		}
+	}
+	return errs
+}
+
+// findCycle returns the nodes of a dependency cycle, or nil.
+func findCycle(deps map[string][]string) []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, d := range deps[id] {
+			switch state[d] {
+			case visiting:
+				for i, s := range stack {
+					if s == d {
+						return append(append([]string{}, stack[i:]...), d)
+					}
+				}
+			case 0:
+				if c := visit(d); c != nil {
+					return c
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
 
+	ids := make([]string, 0, len(deps))
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if state[id] == 0 {
+			if c := visit(id); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
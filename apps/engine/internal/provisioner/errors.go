@@ -0,0 +1,54 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/iac-studio/engine/internal/provisioner/terraform"
+)
+
+// transientPatterns are fragments of provider and network errors that go
+// away on their own; matched case-insensitively against terraform output.
+var transientPatterns = []string{
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"no such host",
+	"tls handshake",
+	"unexpected eof",
+	"throttl",
+	"rate exceeded",
+	"requestlimitexceeded",
+	"too many requests",
+	"service unavailable",
+	"internal server error",
+}
+
+// IsTransient reports whether err is worth retrying: terraform init,
+// network and cloud API throttling failures. Compile and validation errors,
+// invalid input and resource errors reported by the provider are not.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrInvalidInput) || errors.Is(err, ErrInvalidState) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, terraform.ErrInit) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, p := range transientPatterns {
+		if strings.Contains(msg, p) {
+			return true
+		}
+	}
+	return false
+}
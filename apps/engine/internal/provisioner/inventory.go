@@ -0,0 +1,53 @@
+package provisioner
+
+import (
+	"sort"
+
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/provisioner/terraform"
+)
+
+// keyAttributes are the state attributes copied into the resource
+// inventory; everything else stays in the state blob.
+var keyAttributes = []string{
+	"arn", "name", "tags", "region", "availability_zone",
+	"instance_type", "ami", "public_ip", "private_ip",
+	"bucket", "vpc_id", "subnet_id", "cidr_block",
+	"engine", "instance_class", "endpoint",
+}
+
+// ResourcesFromState lists the managed resources in state, sorted by
+// address. NodeID is set for resources compiled from a node of graph.
+func ResourcesFromState(graph Graph, state []byte) ([]Resource, error) {
+	instances, err := terraform.ParseStateInstances(state)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]string, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		nodes[compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type})] = n.ID
+	}
+
+	out := make([]Resource, 0, len(instances))
+	for addr, inst := range instances {
+		r := Resource{
+			Type:       inst.Type,
+			Name:       inst.Name,
+			Address:    addr,
+			NodeID:     nodes[inst.Type+"."+inst.Name],
+			Attributes: map[string]interface{}{},
+		}
+		if id, ok := inst.Attributes["id"].(string); ok {
+			r.CloudID = id
+		}
+		for _, k := range keyAttributes {
+			if v, ok := inst.Attributes[k]; ok && v != nil {
+				r.Attributes[k] = v
+			}
+		}
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out, nil
+}
@@ -0,0 +1,34 @@
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourcesFromState(t *testing.T) {
+	graph := Graph{Nodes: []Node{{ID: "web", Type: "aws_instance"}}}
+	state := []byte(`{"version":4,"resources":[
+		{"mode":"managed","type":"aws_instance","name":"web","instances":[{"attributes":{"id":"i-1","instance_type":"t3.micro","user_data":"secret","public_ip":null}}]},
+		{"mode":"managed","type":"aws_eip","name":"extra","instances":[{"attributes":{"id":"eip-1"}}]},
+		{"mode":"data","type":"aws_ami","name":"ubuntu","instances":[{"attributes":{"id":"ami-1"}}]}
+	]}`)
+
+	resources, err := ResourcesFromState(graph, state)
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+
+	require.Equal(t, "aws_eip.extra", resources[0].Address)
+	require.Empty(t, resources[0].NodeID)
+
+	web := resources[1]
+	require.Equal(t, "aws_instance.web", web.Address)
+	require.Equal(t, "i-1", web.CloudID)
+	require.Equal(t, "web", web.NodeID)
+	require.Equal(t, map[string]interface{}{"instance_type": "t3.micro"}, web.Attributes)
+
+	none, err := ResourcesFromState(graph, nil)
+	require.NoError(t, err)
+	require.NotNil(t, none)
+	require.Empty(t, none)
+}
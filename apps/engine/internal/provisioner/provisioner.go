@@ -24,17 +24,24 @@ var (
 
 // Provisioner handles infrastructure provisioning via Terraform
 type Provisioner interface {
-	// Plan generates an execution plan
-	Plan(ctx context.Context, config *InfraConfig) (*Plan, error)
+	// Plan generates an execution plan against state (empty plans every
+	// resource as new). Nothing is applied and no state is stored.
+	Plan(ctx context.Context, config *InfraConfig, state []byte) (*Plan, error)
 
 	// Apply executes the plan and provisions infrastructure
 	Apply(ctx context.Context, config *InfraConfig) (*Result, error)
 
-	// Destroy tears down infrastructure
-	Destroy(ctx context.Context, deploymentID uuid.UUID, state []byte) (*Result, error)
+	// Destroy tears down the infrastructure compiled from config.Graph. When
+	// config.Targets is set only those nodes (and whatever Terraform finds
+	// depending on them) are destroyed.
+	Destroy(ctx context.Context, config *InfraConfig, state []byte) (*Result, error)
 
 	// GetState retrieves current Terraform state
 	GetState(ctx context.Context, deploymentID uuid.UUID) ([]byte, error)
+
+	// DetectDrift refreshes state against the real infrastructure and
+	// reports what changed outside Terraform. Stored state is not modified.
+	DetectDrift(ctx context.Context, config *InfraConfig, state []byte) (*DriftResult, error)
 }
 
 type InfraConfig struct {
@@ -45,6 +52,8 @@ type InfraConfig struct {
 	CloudProvider string
 	CloudConfig   CloudConfig
 	Variables     map[string]interface{}
+	// Targets limits destroy to these node IDs; empty means every node.
+	Targets []string
 }
 
 type Graph struct {
@@ -76,7 +85,10 @@ type Plan struct {
 	ResourceAdds int    `json:"resource_adds"`
 	ResourceMods int    `json:"resource_mods"`
 	ResourceDels int    `json:"resource_dels"`
-	PlanOutput   string `json:"plan_output"`
+	// ResourceChanges is the change set; a replace counts as an add and a
+	// delete above.
+	ResourceChanges []terraform.ResourceChange `json:"resource_changes"`
+	PlanOutput      string                     `json:"plan_output,omitempty"`
 }
 
 type Result struct {
@@ -88,11 +100,24 @@ type Result struct {
 }
 
 type Resource struct {
-	Type       string                 `json:"type"`
-	Name       string                 `json:"name"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// CloudID is the provider's ID of the resource (the state's "id").
+	CloudID string `json:"cloud_id,omitempty"`
+	// NodeID is the graph node the resource was compiled from, if any.
+	NodeID string `json:"node_id,omitempty"`
+	// Attributes holds key attributes only; see ResourcesFromState.
 	Attributes map[string]interface{} `json:"attributes"`
 }
 
+// DriftResult is the outcome of a drift check: what changed, and the
+// resources as they exist now.
+type DriftResult struct {
+	Drift     []terraform.ResourceDrift `json:"drift"`
+	Resources []Resource                `json:"resources"`
+}
+
 // TerraformProvisioner implements Provisioner using Terraform
 type TerraformProvisioner struct {
 	baseWorkingDir string
@@ -129,8 +154,8 @@ func convertGraph(g Graph) compiler.Graph {
 	return cg
 }
 
-func (t *TerraformProvisioner) Plan(ctx context.Context, config *InfraConfig) (*Plan, error) {
-	// 1. Compile graph to Terraform code
+// compile converts the graph in config to Terraform code for the executor.
+func (t *TerraformProvisioner) compile(config *InfraConfig) (*terraform.TerraformCode, error) {
 	tc, err := t.compiler.Compile(convertGraph(config.Graph), compiler.CloudConfig{
 		Provider: config.CloudConfig.Provider,
 		Region:   config.CloudConfig.Region,
@@ -140,11 +165,19 @@ func (t *TerraformProvisioner) Plan(ctx context.Context, config *InfraConfig) (*
 	}
 
 	// Convert compiler.TerraformCode -> terraform.TerraformCode
-	code := terraform.TerraformCode{
+	return &terraform.TerraformCode{
 		MainTF:      tc.MainTF,
 		VariablesTF: tc.VariablesTF,
 		OutputsTF:   tc.OutputsTF,
 		ProviderTF:  tc.ProviderTF,
+	}, nil
+}
+
+func (t *TerraformProvisioner) Plan(ctx context.Context, config *InfraConfig, state []byte) (*Plan, error) {
+	// 1. Compile graph to Terraform code
+	code, err := t.compile(config)
+	if err != nil {
+		return nil, err
 	}
 
 	// Prepare a per-deployment working directory (unique for this run)
@@ -156,7 +189,14 @@ func (t *TerraformProvisioner) Plan(ctx context.Context, config *InfraConfig) (*
 		_ = exec.Cleanup()
 	}()
 
-	if err := exec.Initialize(ctx, &code); err != nil {
+	// plan against a scratch copy of the state; the stored state stays untouched
+	if len(state) > 0 && string(state) != "null" {
+		if err := writeStateFile(depDir, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := exec.Initialize(ctx, code); err != nil {
 		return nil, fmt.Errorf("executor initialize: %w", err)
 	}
 
@@ -165,26 +205,40 @@ func (t *TerraformProvisioner) Plan(ctx context.Context, config *InfraConfig) (*
 		return nil, fmt.Errorf("executor plan: %w", err)
 	}
 
-	return &Plan{
-		Changes:    0,
-		PlanOutput: pr.PlanOutput,
-	}, nil
+	return NewPlan(config.Graph, pr.Changes, pr.PlanOutput), nil
 }
 
-func (t *TerraformProvisioner) Apply(ctx context.Context, config *InfraConfig) (*Result, error) {
-	tc, err := t.compiler.Compile(convertGraph(config.Graph), compiler.CloudConfig{
-		Provider: config.CloudConfig.Provider,
-		Region:   config.CloudConfig.Region,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("compile graph: %w", err)
+// NewPlan summarizes changes and links each one to the graph node it was
+// compiled from.
+func NewPlan(g Graph, changes []terraform.ResourceChange, output string) *Plan {
+	nodes := make(map[string]string, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes[compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type})] = n.ID
 	}
+	p := &Plan{ResourceChanges: []terraform.ResourceChange{}, PlanOutput: output}
+	for _, c := range changes {
+		c.NodeID = nodes[c.Address]
+		switch c.Action {
+		case terraform.ChangeCreate:
+			p.ResourceAdds++
+		case terraform.ChangeUpdate:
+			p.ResourceMods++
+		case terraform.ChangeDelete:
+			p.ResourceDels++
+		case terraform.ChangeReplace:
+			p.ResourceAdds++
+			p.ResourceDels++
+		}
+		p.ResourceChanges = append(p.ResourceChanges, c)
+	}
+	p.Changes = len(p.ResourceChanges)
+	return p
+}
 
-	code := terraform.TerraformCode{
-		MainTF:      tc.MainTF,
-		VariablesTF: tc.VariablesTF,
-		OutputsTF:   tc.OutputsTF,
-		ProviderTF:  tc.ProviderTF,
+func (t *TerraformProvisioner) Apply(ctx context.Context, config *InfraConfig) (*Result, error) {
+	code, err := t.compile(config)
+	if err != nil {
+		return nil, err
 	}
 
 	// Per-deployment working directory
@@ -196,12 +250,26 @@ func (t *TerraformProvisioner) Apply(ctx context.Context, config *InfraConfig) (
 		_ = exec.Cleanup()
 	}()
 
-	if err := exec.Initialize(ctx, &code); err != nil {
+	// Restore the recorded state so a retried apply picks up resources a
+	// previous, interrupted attempt already created.
+	if t.stateStore != nil {
+		if state, err := t.stateStore.GetState(ctx, config.DeploymentID); err == nil && len(state) > 0 && string(state) != "null" {
+			if err := writeStateFile(depDir, state); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := exec.Initialize(ctx, code); err != nil {
 		return nil, fmt.Errorf("executor initialize: %w", err)
 	}
 
 	ar, err := exec.Apply(ctx)
 	if err != nil {
+		// keep whatever a partial apply created so a retry can resume
+		if partial, serr := exec.State(ctx); serr == nil && t.stateStore != nil {
+			_ = t.stateStore.SaveState(ctx, config.DeploymentID, partial)
+		}
 		return &Result{Success: false, ErrorMessage: err.Error()}, fmt.Errorf("executor apply: %w", err)
 	}
 
@@ -210,13 +278,29 @@ func (t *TerraformProvisioner) Apply(ctx context.Context, config *InfraConfig) (
 		_ = t.stateStore.SaveState(ctx, config.DeploymentID, ar.State)
 	}
 
-	return &Result{Success: true, Outputs: ar.Outputs, State: ar.State}, nil
+	resources, err := ResourcesFromState(config.Graph, ar.State)
+	if err != nil {
+		logger.L().Warn("parse resources from state failed", zap.String("deployment_id", config.DeploymentID.String()), zap.Error(err))
+	}
+	return &Result{Success: true, Outputs: ar.Outputs, State: ar.State, Resources: resources}, nil
 }
 
-func (t *TerraformProvisioner) Destroy(ctx context.Context, deploymentID uuid.UUID, state []byte) (*Result, error) {
+func (t *TerraformProvisioner) Destroy(ctx context.Context, config *InfraConfig, state []byte) (*Result, error) {
+	// Recompile the deployed graph so providers and resource configuration
+	// are present; terraform cannot destroy from state alone.
+	code, err := t.compile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := targetAddresses(config.Graph, config.Targets)
+	if err != nil {
+		return nil, err
+	}
+
 	// For destroy, create a per-deployment dir and restore state if available
-	depDir := filepath.Join(t.baseWorkingDir, deploymentID.String(), strconv.FormatInt(time.Now().UnixNano(), 10))
-	logger.L().Info("using working dir for destroy", zap.String("dir", depDir))
+	depDir := filepath.Join(t.baseWorkingDir, config.DeploymentID.String(), strconv.FormatInt(time.Now().UnixNano(), 10))
+	logger.L().Info("using working dir for destroy", zap.String("dir", depDir), zap.Strings("targets", targets))
 	exec := terraform.NewExecutor(depDir)
 	defer func() {
 		_ = exec.Cleanup()
@@ -224,32 +308,116 @@ func (t *TerraformProvisioner) Destroy(ctx context.Context, deploymentID uuid.UU
 
 	// If state not provided, attempt to load from state store
 	if len(state) == 0 && t.stateStore != nil {
-		if s, err := t.stateStore.GetState(ctx, deploymentID); err == nil {
+		if s, err := t.stateStore.GetState(ctx, config.DeploymentID); err == nil {
 			state = s
 		}
 	}
 
 	// If we have state, write it to terraform.tfstate so terraform can use it
 	if len(state) > 0 {
-		if err := os.MkdirAll(depDir, 0755); err != nil {
-			return nil, fmt.Errorf("create working dir: %w", err)
-		}
-		if err := os.WriteFile(filepath.Join(depDir, "terraform.tfstate"), state, 0644); err != nil {
-			return nil, fmt.Errorf("write state file: %w", err)
+		if err := writeStateFile(depDir, state); err != nil {
+			return nil, err
 		}
 	}
 
-	if err := exec.Initialize(ctx, &terraform.TerraformCode{MainTF: "", VariablesTF: "", OutputsTF: "", ProviderTF: ""}); err != nil {
+	if err := exec.Initialize(ctx, code); err != nil {
 		return nil, fmt.Errorf("executor initialize: %w", err)
 	}
-	if err := exec.Destroy(ctx); err != nil {
+	if err := exec.Destroy(ctx, targets...); err != nil {
+		// resources destroyed before the failure are gone; keep state in step
+		if partial, serr := exec.State(ctx); serr == nil && t.stateStore != nil {
+			_ = t.stateStore.SaveState(ctx, config.DeploymentID, partial)
+		}
 		return &Result{Success: false, ErrorMessage: err.Error()}, fmt.Errorf("executor destroy: %w", err)
 	}
-	// clear state
+
+	// a full destroy clears state; a targeted one keeps what is left
+	var remaining []byte
+	if len(targets) > 0 {
+		if remaining, err = exec.State(ctx); err != nil {
+			return &Result{Success: false, ErrorMessage: err.Error()}, fmt.Errorf("read remaining state: %w", err)
+		}
+	}
 	if t.stateStore != nil {
-		_ = t.stateStore.SaveState(ctx, deploymentID, nil)
+		_ = t.stateStore.SaveState(ctx, config.DeploymentID, remaining)
+	}
+	resources, err := ResourcesFromState(config.Graph, remaining)
+	if err != nil {
+		logger.L().Warn("parse resources from state failed", zap.String("deployment_id", config.DeploymentID.String()), zap.Error(err))
+	}
+	return &Result{Success: true, State: remaining, Resources: resources}, nil
+}
+
+func (t *TerraformProvisioner) DetectDrift(ctx context.Context, config *InfraConfig, state []byte) (*DriftResult, error) {
+	if len(state) == 0 {
+		return nil, fmt.Errorf("%w: deployment has no state", ErrInvalidState)
+	}
+	code, err := t.compile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	depDir := filepath.Join(t.baseWorkingDir, config.DeploymentID.String(), strconv.FormatInt(time.Now().UnixNano(), 10))
+	logger.L().Info("using working dir for drift check", zap.String("dir", depDir))
+	exec := terraform.NewExecutor(depDir)
+	defer func() {
+		_ = exec.Cleanup()
+	}()
+
+	// refresh a scratch copy of the state; the stored state stays untouched
+	if err := writeStateFile(depDir, state); err != nil {
+		return nil, err
+	}
+	if err := exec.Initialize(ctx, code); err != nil {
+		return nil, fmt.Errorf("executor initialize: %w", err)
+	}
+	if err := exec.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("executor refresh: %w", err)
+	}
+	refreshed, err := exec.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	drift, err := terraform.DiffState(state, refreshed)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := ResourcesFromState(config.Graph, refreshed)
+	if err != nil {
+		return nil, err
+	}
+	return &DriftResult{Drift: drift, Resources: resources}, nil
+}
+
+// writeStateFile places state in dir as terraform.tfstate.
+func writeStateFile(dir string, state []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create working dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "terraform.tfstate"), state, 0644); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	return nil
+}
+
+// targetAddresses maps node IDs to the Terraform addresses of their resources.
+func targetAddresses(g Graph, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	byID := make(map[string]Node, len(g.Nodes))
+	for _, n := range g.Nodes {
+		byID[n.ID] = n
+	}
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		n, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: node %q not in graph", ErrInvalidInput, id)
+		}
+		out = append(out, compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type}))
 	}
-	return &Result{Success: true}, nil
+	return out, nil
 }
 
 func (t *TerraformProvisioner) GetState(ctx context.Context, deploymentID uuid.UUID) ([]byte, error) {
@@ -0,0 +1,76 @@
+package terraform
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Drift actions recorded for a resource.
+const (
+	DriftUpdated = "updated"
+	DriftDeleted = "deleted"
+)
+
+// ResourceDrift describes how a resource's real state differs from the state
+// Terraform last recorded for it.
+type ResourceDrift struct {
+	Address    string           `json:"address"`
+	Type       string           `json:"type"`
+	Name       string           `json:"name"`
+	Action     string           `json:"action"`
+	Attributes []AttributeDrift `json:"attributes,omitempty"`
+}
+
+// AttributeDrift is a single top-level attribute whose value changed.
+// Expected is the recorded value and Actual the refreshed one.
+type AttributeDrift struct {
+	Path     string      `json:"path"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+}
+
+// DiffState compares recorded state with state refreshed from the cloud and
+// returns the drifted resources sorted by address.
+func DiffState(recorded, refreshed []byte) ([]ResourceDrift, error) {
+	before, err := ParseStateInstances(recorded)
+	if err != nil {
+		return nil, err
+	}
+	after, err := ParseStateInstances(refreshed)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []ResourceDrift
+	for addr, b := range before {
+		a, ok := after[addr]
+		if !ok {
+			out = append(out, ResourceDrift{Address: addr, Type: b.Type, Name: b.Name, Action: DriftDeleted})
+			continue
+		}
+		if attrs := diffAttributes(b.Attributes, a.Attributes); len(attrs) > 0 {
+			out = append(out, ResourceDrift{Address: addr, Type: b.Type, Name: b.Name, Action: DriftUpdated, Attributes: attrs})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out, nil
+}
+
+func diffAttributes(before, after map[string]interface{}) []AttributeDrift {
+	keys := map[string]struct{}{}
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+
+	var out []AttributeDrift
+	for k := range keys {
+		if !reflect.DeepEqual(before[k], after[k]) {
+			out = append(out, AttributeDrift{Path: k, Expected: before[k], Actual: after[k]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
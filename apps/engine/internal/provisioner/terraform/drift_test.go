@@ -0,0 +1,59 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffState(t *testing.T) {
+	recorded := []byte(`{"version":4,"resources":[
+		{"mode":"managed","type":"aws_security_group","name":"web","instances":[{"attributes":{"id":"sg-1","ingress":[{"from_port":443}]}}]},
+		{"mode":"managed","type":"aws_s3_bucket","name":"logs","instances":[{"attributes":{"id":"logs"}}]},
+		{"mode":"managed","type":"aws_instance","name":"app","instances":[{"attributes":{"id":"i-1","instance_type":"t3.micro"}}]},
+		{"mode":"data","type":"aws_ami","name":"ubuntu","instances":[{"attributes":{"id":"ami-1"}}]}
+	]}`)
+	refreshed := []byte(`{"version":4,"resources":[
+		{"mode":"managed","type":"aws_security_group","name":"web","instances":[{"attributes":{"id":"sg-1","ingress":[{"from_port":22}]}}]},
+		{"mode":"managed","type":"aws_instance","name":"app","instances":[{"attributes":{"id":"i-1","instance_type":"t3.micro"}}]},
+		{"mode":"data","type":"aws_ami","name":"ubuntu","instances":[{"attributes":{"id":"ami-2"}}]}
+	]}`)
+
+	drift, err := DiffState(recorded, refreshed)
+	require.NoError(t, err)
+	require.Len(t, drift, 2)
+
+	require.Equal(t, "aws_s3_bucket.logs", drift[0].Address)
+	require.Equal(t, DriftDeleted, drift[0].Action)
+
+	require.Equal(t, "aws_security_group.web", drift[1].Address)
+	require.Equal(t, DriftUpdated, drift[1].Action)
+	require.Len(t, drift[1].Attributes, 1)
+	require.Equal(t, "ingress", drift[1].Attributes[0].Path)
+
+	none, err := DiffState(recorded, recorded)
+	require.NoError(t, err)
+	require.Empty(t, none)
+}
+
+func TestDiffStateAttributes(t *testing.T) {
+	recorded := []byte(`{"version":4,"resources":[
+		{"mode":"managed","type":"aws_instance","name":"app","instances":[{"attributes":{"id":"i-1","instance_type":"t3.micro","ami":"ami-1","tags":{"Name":"app"}}}]}
+	]}`)
+	refreshed := []byte(`{"version":4,"resources":[
+		{"mode":"managed","type":"aws_instance","name":"app","instances":[{"attributes":{"id":"i-1","instance_type":"t3.large","tags":{"Name":"app"},"monitoring":true}}]}
+	]}`)
+
+	drift, err := DiffState(recorded, refreshed)
+	require.NoError(t, err)
+	require.Len(t, drift, 1)
+	require.Equal(t, "aws_instance.app", drift[0].Address)
+	require.Equal(t, DriftUpdated, drift[0].Action)
+
+	// sorted by path: removed, changed, added
+	require.Equal(t, []AttributeDrift{
+		{Path: "ami", Expected: "ami-1", Actual: nil},
+		{Path: "instance_type", Expected: "t3.micro", Actual: "t3.large"},
+		{Path: "monitoring", Expected: nil, Actual: true},
+	}, drift[0].Attributes)
+}
@@ -3,6 +3,7 @@ package terraform
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,6 +14,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrInit marks a failed terraform init, usually a provider or module
+// download problem that is worth retrying.
+var ErrInit = errors.New("terraform init failed")
+
 // Executor wraps terraform-exec for running Terraform commands
 type Executor struct {
 	workingDir string
@@ -64,36 +69,38 @@ func (e *Executor) Initialize(ctx context.Context, code *TerraformCode) error {
 	// Run terraform init
 	logger.L().Info("running terraform init", zap.String("working_dir", e.workingDir))
 	if err := tf.Init(ctx, tfexec.Upgrade(true)); err != nil {
-		return fmt.Errorf("terraform init: %w", err)
+		return fmt.Errorf("%w: %w", ErrInit, err)
 	}
 
 	return nil
 }
 
-// Plan runs terraform plan
+// Plan runs terraform plan against the working directory's state and
+// returns the change set
 func (e *Executor) Plan(ctx context.Context) (*PlanResult, error) {
 	logger.L().Info("running terraform plan", zap.String("working_dir", e.workingDir))
 
-	hasChanges, err := e.tf.Plan(ctx)
+	planFile := filepath.Join(e.workingDir, "tfplan")
+	hasChanges, err := e.tf.Plan(ctx, tfexec.Out(planFile))
 	if err != nil {
 		return nil, fmt.Errorf("terraform plan: %w", err)
 	}
 
 	// Get plan output
-	planOutput, err := e.tf.Show(ctx)
-	var planOutStr string
+	plan, err := e.tf.ShowPlanFile(ctx, planFile)
 	if err != nil {
-		logger.L().Warn("failed to get plan output", zap.Error(err))
+		return nil, fmt.Errorf("terraform show plan: %w", err)
+	}
+	var planOutStr string
+	if b, err := json.Marshal(plan); err == nil {
+		planOutStr = string(b)
 	} else {
-		if b, err := json.Marshal(planOutput); err == nil {
-			planOutStr = string(b)
-		} else {
-			planOutStr = fmt.Sprintf("%v", planOutput)
-		}
+		logger.L().Warn("failed to encode plan output", zap.Error(err))
 	}
 
 	return &PlanResult{
 		HasChanges: hasChanges,
+		Changes:    PlanChanges(plan),
 		PlanOutput: planOutStr,
 	}, nil
 }
@@ -112,16 +119,10 @@ func (e *Executor) Apply(ctx context.Context) (*ApplyResult, error) {
 		logger.L().Warn("failed to get outputs", zap.Error(err))
 	}
 
-	// Get state
-	state, err := e.tf.Show(ctx)
+	// Get raw state so it can be restored as terraform.tfstate later
+	stateBytes, err := e.State(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get state: %w", err)
-	}
-	var stateBytes []byte
-	if b, err := json.Marshal(state); err == nil {
-		stateBytes = b
-	} else {
-		stateBytes = []byte(fmt.Sprintf("%v", state))
+		return nil, err
 	}
 
 	return &ApplyResult{
@@ -130,17 +131,42 @@ func (e *Executor) Apply(ctx context.Context) (*ApplyResult, error) {
 	}, nil
 }
 
-// Destroy runs terraform destroy
-func (e *Executor) Destroy(ctx context.Context) error {
-	logger.L().Info("running terraform destroy", zap.String("working_dir", e.workingDir))
+// Destroy runs terraform destroy, limited to the given resource addresses
+// when any are passed
+func (e *Executor) Destroy(ctx context.Context, targets ...string) error {
+	logger.L().Info("running terraform destroy", zap.String("working_dir", e.workingDir), zap.Strings("targets", targets))
 
-	if err := e.tf.Destroy(ctx); err != nil {
+	opts := make([]tfexec.DestroyOption, 0, len(targets))
+	for _, t := range targets {
+		opts = append(opts, tfexec.Target(t))
+	}
+	if err := e.tf.Destroy(ctx, opts...); err != nil {
 		return fmt.Errorf("terraform destroy: %w", err)
 	}
 
 	return nil
 }
 
+// Refresh updates the working directory's state from the real
+// infrastructure without changing any resource
+func (e *Executor) Refresh(ctx context.Context) error {
+	logger.L().Info("running terraform refresh", zap.String("working_dir", e.workingDir))
+
+	if err := e.tf.Refresh(ctx); err != nil {
+		return fmt.Errorf("terraform refresh: %w", err)
+	}
+	return nil
+}
+
+// State returns the raw Terraform state of the working directory
+func (e *Executor) State(ctx context.Context) ([]byte, error) {
+	state, err := e.tf.StatePull(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("terraform state pull: %w", err)
+	}
+	return []byte(state), nil
+}
+
 // Cleanup removes the working directory
 func (e *Executor) Cleanup() error {
 	return os.RemoveAll(e.workingDir)
@@ -155,6 +181,7 @@ type TerraformCode struct {
 
 type PlanResult struct {
 	HasChanges bool
+	Changes    []ResourceChange
 	PlanOutput string
 }
 
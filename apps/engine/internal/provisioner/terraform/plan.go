@@ -0,0 +1,133 @@
+package terraform
+
+import (
+	"sort"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Planned actions for a resource, one per ResourceChange.
+const (
+	ChangeCreate  = "create"
+	ChangeUpdate  = "update"
+	ChangeDelete  = "delete"
+	ChangeReplace = "replace"
+	ChangeRead    = "read"
+	ChangeNoOp    = "no-op"
+)
+
+// sensitiveValue replaces attribute values Terraform marks as sensitive.
+const sensitiveValue = "(sensitive)"
+
+// ResourceChange is one resource in a plan's change set. Before and After
+// hold top-level attributes with sensitive values masked; After misses
+// values only known after apply.
+type ResourceChange struct {
+	Address string                 `json:"address"`
+	Type    string                 `json:"type"`
+	Name    string                 `json:"name"`
+	NodeID  string                 `json:"node_id,omitempty"`
+	Action  string                 `json:"action"`
+	Before  map[string]interface{} `json:"before,omitempty"`
+	After   map[string]interface{} `json:"after,omitempty"`
+	// Changed lists top-level attributes whose value differs.
+	Changed []string `json:"changed,omitempty"`
+}
+
+// PlanChanges extracts the managed resources of a plan that would change,
+// sorted by address. No-op and data source reads are left out.
+func PlanChanges(p *tfjson.Plan) []ResourceChange {
+	out := []ResourceChange{}
+	if p == nil {
+		return out
+	}
+	for _, rc := range p.ResourceChanges {
+		if rc == nil || rc.Change == nil || rc.Mode == tfjson.DataResourceMode {
+			continue
+		}
+		action := planAction(rc.Change.Actions)
+		if action == ChangeNoOp || action == ChangeRead {
+			continue
+		}
+		before := maskSensitive(rc.Change.Before, rc.Change.BeforeSensitive)
+		after := maskSensitive(rc.Change.After, rc.Change.AfterSensitive)
+		out = append(out, ResourceChange{
+			Address: rc.Address,
+			Type:    rc.Type,
+			Name:    rc.Name,
+			Action:  action,
+			Before:  before,
+			After:   after,
+			Changed: changedKeys(before, after),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+func planAction(a tfjson.Actions) string {
+	switch {
+	case a.Replace():
+		return ChangeReplace
+	case a.Create():
+		return ChangeCreate
+	case a.Update():
+		return ChangeUpdate
+	case a.Delete():
+		return ChangeDelete
+	case a.Read():
+		return ChangeRead
+	default:
+		return ChangeNoOp
+	}
+}
+
+// maskSensitive returns the top-level attributes of v with every attribute
+// that holds a sensitive value masked.
+func maskSensitive(v, sensitive interface{}) map[string]interface{} {
+	attrs, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	marks, _ := sensitive.(map[string]interface{})
+	out := make(map[string]interface{}, len(attrs))
+	for k, val := range attrs {
+		if hasSensitive(marks[k]) {
+			out[k] = sensitiveValue
+			continue
+		}
+		out[k] = val
+	}
+	return out
+}
+
+func hasSensitive(mark interface{}) bool {
+	switch m := mark.(type) {
+	case bool:
+		return m
+	case map[string]interface{}:
+		for _, v := range m {
+			if hasSensitive(v) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, v := range m {
+			if hasSensitive(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func changedKeys(before, after map[string]interface{}) []string {
+	if before == nil || after == nil {
+		return nil
+	}
+	var out []string
+	for _, d := range diffAttributes(before, after) {
+		out = append(out, d.Path)
+	}
+	return out
+}
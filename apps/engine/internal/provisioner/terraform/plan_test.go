@@ -0,0 +1,40 @@
+package terraform
+
+import (
+	"encoding/json"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanChanges(t *testing.T) {
+	raw := []byte(`{"format_version":"1.0","resource_changes":[
+		{"address":"aws_s3_bucket.logs","mode":"managed","type":"aws_s3_bucket","name":"logs","change":{"actions":["delete"],"before":{"bucket":"logs"},"after":null}},
+		{"address":"aws_instance.app","mode":"managed","type":"aws_instance","name":"app","change":{"actions":["update"],"before":{"instance_type":"t3.micro","tags":{"a":"1"}},"after":{"instance_type":"t3.large","tags":{"a":"1"}}}},
+		{"address":"aws_db_instance.db","mode":"managed","type":"aws_db_instance","name":"db","change":{"actions":["delete","create"],"before":{"password":"old","engine":"postgres"},"after":{"password":"new","engine":"postgres"},"before_sensitive":{"password":true},"after_sensitive":{"password":true}}},
+		{"address":"aws_vpc.main","mode":"managed","type":"aws_vpc","name":"main","change":{"actions":["no-op"],"before":{"cidr_block":"10.0.0.0/16"},"after":{"cidr_block":"10.0.0.0/16"}}},
+		{"address":"data.aws_ami.ubuntu","mode":"data","type":"aws_ami","name":"ubuntu","change":{"actions":["read"]}}
+	]}`)
+	var p tfjson.Plan
+	require.NoError(t, json.Unmarshal(raw, &p))
+
+	changes := PlanChanges(&p)
+	require.Len(t, changes, 3)
+
+	require.Equal(t, "aws_db_instance.db", changes[0].Address)
+	require.Equal(t, ChangeReplace, changes[0].Action)
+	require.Equal(t, sensitiveValue, changes[0].Before["password"])
+	require.Equal(t, sensitiveValue, changes[0].After["password"])
+	require.Empty(t, changes[0].Changed)
+
+	require.Equal(t, "aws_instance.app", changes[1].Address)
+	require.Equal(t, ChangeUpdate, changes[1].Action)
+	require.Equal(t, []string{"instance_type"}, changes[1].Changed)
+
+	require.Equal(t, "aws_s3_bucket.logs", changes[2].Address)
+	require.Equal(t, ChangeDelete, changes[2].Action)
+	require.Nil(t, changes[2].After)
+
+	require.Empty(t, PlanChanges(nil))
+}
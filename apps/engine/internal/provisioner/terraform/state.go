@@ -4,10 +4,7 @@ import (
     "context"
 
     "github.com/google/uuid"
-    "github.com/iac-studio/engine/internal/models"
     "github.com/iac-studio/engine/internal/repository"
-    appErr "github.com/iac-studio/engine/pkg/errors"
-    "gorm.io/datatypes"
 )
 
 // StateStore handles Terraform state persistence
@@ -18,41 +15,27 @@ type StateStore interface {
     UnlockState(ctx context.Context, deploymentID uuid.UUID) error
 }
 
+// DatabaseStateStore keeps the current state on the deployment row and every
+// write in the state_versions history.
 type DatabaseStateStore struct {
     deploymentRepo repository.DeploymentRepository
+    versionRepo    repository.StateVersionRepository
 }
 
-func NewDatabaseStateStore(deploymentRepo repository.DeploymentRepository) *DatabaseStateStore {
+func NewDatabaseStateStore(deploymentRepo repository.DeploymentRepository, versionRepo repository.StateVersionRepository) *DatabaseStateStore {
     return &DatabaseStateStore{
         deploymentRepo: deploymentRepo,
+        versionRepo:    versionRepo,
     }
 }
 
 func (s *DatabaseStateStore) SaveState(ctx context.Context, deploymentID uuid.UUID, state []byte) error {
-    var d models.Deployment
-    if err := s.deploymentRepo.GetByID(ctx, deploymentID, &d); err != nil {
-        return err
-    }
-    if state == nil {
-        d.TerraformState = datatypes.JSON([]byte("null"))
-    } else {
-        d.TerraformState = datatypes.JSON(state)
-    }
-    if err := s.deploymentRepo.Update(ctx, &d); err != nil {
-        return appErr.Wrap(err, appErr.CodeInternal, "save state failed")
-    }
-    return nil
+    _, err := s.versionRepo.Record(ctx, deploymentID, state)
+    return err
 }
 
 func (s *DatabaseStateStore) GetState(ctx context.Context, deploymentID uuid.UUID) ([]byte, error) {
-    var d models.Deployment
-    if err := s.deploymentRepo.GetByID(ctx, deploymentID, &d); err != nil {
-        return nil, err
-    }
-    if len(d.TerraformState) == 0 {
-        return nil, nil
-    }
-    return []byte(d.TerraformState), nil
+    return s.versionRepo.GetCurrent(ctx, deploymentID)
 }
 
 func (s *DatabaseStateStore) LockState(ctx context.Context, deploymentID uuid.UUID) error {
@@ -0,0 +1,69 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StateInstance is a single managed resource instance read from a raw
+// Terraform state (format version 4).
+type StateInstance struct {
+	Address    string                 `json:"address"`
+	Type       string                 `json:"type"`
+	Name       string                 `json:"name"`
+	IndexKey   interface{}            `json:"index_key,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+type rawState struct {
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   interface{}            `json:"index_key"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// ParseStateInstances returns the managed resource instances in state,
+// keyed by address. Data sources are skipped. Empty or "null" state has no
+// instances.
+func ParseStateInstances(state []byte) (map[string]StateInstance, error) {
+	out := map[string]StateInstance{}
+	if len(state) == 0 || string(state) == "null" {
+		return out, nil
+	}
+	var s rawState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, fmt.Errorf("parse terraform state: %w", err)
+	}
+	for _, r := range s.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		base := r.Type + "." + r.Name
+		if r.Module != "" {
+			base = r.Module + "." + base
+		}
+		for _, inst := range r.Instances {
+			addr := base
+			switch k := inst.IndexKey.(type) {
+			case string:
+				addr = fmt.Sprintf("%s[%q]", base, k)
+			case float64:
+				addr = fmt.Sprintf("%s[%d]", base, int(k))
+			}
+			out[addr] = StateInstance{
+				Address:    addr,
+				Type:       r.Type,
+				Name:       r.Name,
+				IndexKey:   inst.IndexKey,
+				Attributes: inst.Attributes,
+			}
+		}
+	}
+	return out, nil
+}
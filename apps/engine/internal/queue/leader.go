@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// SchedulerLockKey is held by the one worker that runs the periodic scans,
+// so several workers enqueue each scan once.
+const SchedulerLockKey = "iac:scheduler-lock"
+
+// RunWhenLeader calls lead once this process holds key, trying again every
+// retry while another process holds it. lead must return when its context
+// is done: when ctx is, or when the lock is lost (see Locker), after which
+// this process stands by again. A leader that crashes frees the lock within
+// the lock TTL and another process takes over. The returned channel is
+// closed once ctx is done and lead has returned.
+func RunWhenLeader(ctx context.Context, l Locker, key string, retry time.Duration, lead func(ctx context.Context)) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(retry)
+		defer t.Stop()
+		for {
+			if lockCtx, release, err := l.TryLock(ctx, key); err == nil {
+				lead(lockCtx)
+				release()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return done
+}
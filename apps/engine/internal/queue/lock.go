@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrProjectBusy is returned by deployment handlers when another deployment
+// of the same project holds the project lock or is ahead in its queue. The
+// worker retries such tasks shortly without counting a failure.
+var ErrProjectBusy = errors.New("project has another deployment in progress")
+
+// DefaultLockTTL bounds how long a crashed worker keeps its project locked.
+const DefaultLockTTL = time.Minute
+
+// busyRetryDelay is how soon a task waiting for its project is retried.
+const busyRetryDelay = 15 * time.Second
+
+// ErrLockLost is the cause of a lock context cancelled because the lock
+// could not be extended or was taken over by someone else.
+var ErrLockLost = errors.New("lock lost")
+
+// Locker serializes work per key across workers.
+type Locker interface {
+	// TryLock acquires key without blocking and returns ErrProjectBusy when
+	// someone else holds it. The locked work must run with lockCtx, which is
+	// cancelled with ErrLockLost as its cause once the lock is no longer
+	// ours. release must be called once the work is done.
+	TryLock(ctx context.Context, key string) (lockCtx context.Context, release func(), err error)
+}
+
+// ProjectLockKey is the lock key that serializes deployments of a project.
+func ProjectLockKey(projectID uuid.UUID) string {
+	return "iac:deploy-lock:" + projectID.String()
+}
+
+// IsFailure reports whether a handler error counts against the task's retry
+// budget; waiting for the project lock does not. Used as the worker's
+// asynq.Config.IsFailure.
+func IsFailure(err error) bool {
+	return !errors.Is(err, ErrProjectBusy)
+}
+
+// Lua scripts only touch the key while it still holds our token, so a lock
+// that expired and was taken over is never extended or released by mistake.
+var (
+	refreshScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`)
+	releaseScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+)
+
+// RedisLocker is a Locker backed by SET NX with a TTL. The TTL is extended
+// while the lock is held, so a crashed worker frees it within one TTL. A
+// failed extension gives the lock up at once: redis may let it expire before
+// the next attempt, and the work must not go on unserialized.
+type RedisLocker struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func NewRedisLocker(rdb *redis.Client, ttl time.Duration) *RedisLocker {
+	return &RedisLocker{rdb: rdb, ttl: ttl}
+}
+
+var _ Locker = (*RedisLocker)(nil)
+
+func (l *RedisLocker) TryLock(ctx context.Context, key string) (context.Context, func(), error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, nil, fmt.Errorf("generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	ok, err := l.rdb.SetNX(ctx, key, token, l.ttl).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("acquire lock %s: %w", key, err)
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: lock %s is held", ErrProjectBusy, key)
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(l.ttl / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				if err := l.refresh(key, token); err != nil {
+					cancel(err)
+					return
+				}
+			}
+		}
+	}()
+
+	return lockCtx, func() {
+		close(stop)
+		<-done
+		cancel(context.Canceled)
+		_ = releaseScript.Run(context.Background(), l.rdb, []string{key}, token).Err()
+	}, nil
+}
+
+// refresh extends the lock's TTL; it fails when redis cannot be reached or
+// key no longer holds token.
+func (l *RedisLocker) refresh(key, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+	defer cancel()
+	n, err := refreshScript.Run(ctx, l.rdb, []string{key}, token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("%w: extend %s: %w", ErrLockLost, key, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s is held by someone else", ErrLockLost, key)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+// Deployment task types, shared by the API (enqueue) and the worker (handle).
+const (
+	TypeProvision = "deployment:provision"
+	TypeDestroy   = "deployment:destroy"
+	// TypePlan runs terraform plan for a plan-only deployment.
+	TypePlan = "deployment:plan"
+)
+
+// TypeWebhookDeliver sends one webhook delivery; see
+// services.WebhookService.Deliver.
+const TypeWebhookDeliver = "webhook:deliver"
+
+// TypeAlertDeliver sends one alert delivery; see
+// services.AlertService.Deliver.
+const TypeAlertDeliver = "alert:deliver"
+
+// DefaultQueue is the asynq queue deployment tasks run on.
+const DefaultQueue = "default"
+
+// Retry policy for deployment tasks. Handlers return retryable errors only
+// for transient failures (terraform init, network, throttling); anything
+// else is archived on the first attempt.
+const (
+	DeploymentMaxRetry = 5
+	DeploymentTimeout  = 2 * time.Hour
+
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = 10 * time.Minute
+)
+
+// DeploymentTaskID is the unique key of a deployment task. A deployment never
+// has two tasks of the same type pending, running or archived at once, so a
+// double submit or a re-enqueue after a crash is rejected by asynq with
+// asynq.ErrTaskIDConflict.
+func DeploymentTaskID(typ string, deploymentID uuid.UUID) string {
+	return typ + ":" + deploymentID.String()
+}
+
+// DeploymentTaskOptions returns the enqueue options for a deployment task.
+func DeploymentTaskOptions(typ string, deploymentID uuid.UUID) []asynq.Option {
+	return []asynq.Option{
+		asynq.TaskID(DeploymentTaskID(typ, deploymentID)),
+		asynq.Queue(DefaultQueue),
+		asynq.MaxRetry(DeploymentMaxRetry),
+		asynq.Timeout(DeploymentTimeout),
+	}
+}
+
+// Retry policy for webhook deliveries: failed requests are retried with
+// RetryDelay's backoff, for about 45 minutes in all.
+const (
+	WebhookMaxRetry = 8
+	WebhookTimeout  = time.Minute
+)
+
+// WebhookTaskOptions returns the enqueue options for a webhook delivery.
+func WebhookTaskOptions(deliveryID uuid.UUID) []asynq.Option {
+	return []asynq.Option{
+		asynq.TaskID(TypeWebhookDeliver + ":" + deliveryID.String()),
+		asynq.Queue(DefaultQueue),
+		asynq.MaxRetry(WebhookMaxRetry),
+		asynq.Timeout(WebhookTimeout),
+	}
+}
+
+// Retry policy for alert deliveries: failed sends are retried with
+// RetryDelay's backoff, for about 15 minutes in all.
+const (
+	AlertMaxRetry = 5
+	AlertTimeout  = time.Minute
+)
+
+// AlertTaskOptions returns the enqueue options for an alert delivery.
+func AlertTaskOptions(deliveryID int64) []asynq.Option {
+	return []asynq.Option{
+		asynq.TaskID(TypeAlertDeliver + ":" + strconv.FormatInt(deliveryID, 10)),
+		asynq.Queue(DefaultQueue),
+		asynq.MaxRetry(AlertMaxRetry),
+		asynq.Timeout(AlertTimeout),
+	}
+}
+
+// RetryDelay backs off exponentially from 30s, capped at 10 minutes; tasks
+// waiting for their project are retried after a short fixed delay. It is
+// used as the worker's asynq.Config.RetryDelayFunc.
+func RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	if errors.Is(err, ErrProjectBusy) {
+		return busyRetryDelay
+	}
+	d := retryBaseDelay
+	for i := 0; i < n && d < retryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d
+}
@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/services"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// TypeAlertScan periodically alerts on deployments that have awaited
+// approval for too long. Other alerts follow events as they are published.
+const TypeAlertScan = "monitor:alert-scan"
+
+// AlertPayload is the payload of a queue.TypeAlertDeliver task.
+type AlertPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+// AlertTaskHandler runs the alert scan and sends alert deliveries; asynq
+// retries the failed ones with queue.RetryDelay.
+type AlertTaskHandler struct {
+	alerts services.AlertService
+}
+
+func NewAlertTaskHandler(alerts services.AlertService) *AlertTaskHandler {
+	return &AlertTaskHandler{alerts: alerts}
+}
+
+func (h *AlertTaskHandler) HandleScan(ctx context.Context, t *asynq.Task) error {
+	if err := h.alerts.CheckPendingApprovals(ctx, time.Now()); err != nil {
+		logger.L().Error("alert scan failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (h *AlertTaskHandler) HandleDeliver(ctx context.Context, t *asynq.Task) error {
+	var p AlertPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		logger.L().Error("invalid alert task payload", zap.Error(err))
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	err := h.alerts.Deliver(ctx, p.DeliveryID, retried >= maxRetry)
+	if appErr.IsCode(err, appErr.CodeNotFound) {
+		// the channel, and its deliveries with it, was deleted
+		logger.L().Info("alert delivery gone, skipping", zap.Int64("delivery_id", p.DeliveryID))
+		return nil
+	}
+	if err != nil {
+		logger.L().Warn("alert delivery attempt failed", zap.Int64("delivery_id", p.DeliveryID), zap.Int("retried", retried), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/services"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// TypeAnomalyScan periodically checks the deployment history for
+// anomalies.
+const TypeAnomalyScan = "monitor:anomaly-scan"
+
+// AnomalyTaskHandler runs the anomaly scan.
+type AnomalyTaskHandler struct {
+	anomalies services.AnomalyService
+	interval  time.Duration
+}
+
+func NewAnomalyTaskHandler(anomalies services.AnomalyService, interval time.Duration) *AnomalyTaskHandler {
+	return &AnomalyTaskHandler{anomalies: anomalies, interval: interval}
+}
+
+// HandleScan looks back two intervals so a late or failed scan does not
+// leave a gap; anomalies found twice are recorded once.
+func (h *AnomalyTaskHandler) HandleScan(ctx context.Context, t *asynq.Task) error {
+	now := time.Now()
+	found, err := h.anomalies.Scan(ctx, now.Add(-2*h.interval), now)
+	if err != nil {
+		logger.L().Error("anomaly scan failed", zap.Error(err))
+		return err
+	}
+	if len(found) > 0 {
+		logger.L().Info("anomaly scan finished", zap.Int("anomalies", len(found)))
+	}
+	return nil
+}
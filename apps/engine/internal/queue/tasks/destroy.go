@@ -1,5 +1,100 @@
-//go:build ignore
-
 package tasks
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/events"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/queue"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// TypeExpiryScan periodically destroys deployments whose TTL has lapsed and
+// warns about those about to.
+const TypeExpiryScan = "deployment:expiry-scan"
+
+// ExpiryTaskHandler drives auto-destroy of ephemeral deployments.
+type ExpiryTaskHandler struct {
+	deployRepo repository.DeploymentRepository
+	client     *asynq.Client
+	publisher  events.Publisher
+	warnBefore time.Duration
+}
+
+func NewExpiryTaskHandler(deployRepo repository.DeploymentRepository, client *asynq.Client, publisher events.Publisher, warnBefore time.Duration) *ExpiryTaskHandler {
+	return &ExpiryTaskHandler{deployRepo: deployRepo, client: client, publisher: publisher, warnBefore: warnBefore}
+}
+
+func (h *ExpiryTaskHandler) HandleScan(ctx context.Context, t *asynq.Task) error {
+	now := time.Now()
+	items, err := h.deployRepo.ListExpiring(ctx, now.Add(h.warnBefore))
+	if err != nil {
+		logger.L().Error("list expiring deployments failed", zap.Error(err))
+		return err
+	}
+
+	for i := range items {
+		d := &items[i]
+		if !d.ExpiresAt.After(now) {
+			h.expire(ctx, d)
+		} else if d.ExpiryWarnedAt == nil {
+			h.warn(ctx, d)
+		}
+	}
+	return nil
+}
+
+// expire enqueues the destroy and clears the expiry so the deployment is not
+// picked up again if the destroy fails. ListExpiring leaves out deployments
+// with a destroy in flight; one requested between the scan and the enqueue
+// is left to run.
+func (h *ExpiryTaskHandler) expire(ctx context.Context, d *models.Deployment) {
+	ctx = repository.WithActor(ctx, "task:"+TypeExpiryScan)
+	pb, _ := json.Marshal(ProvisionPayload{DeploymentID: d.ID.String()})
+	if _, err := h.client.EnqueueContext(ctx, asynq.NewTask(queue.TypeDestroy, pb), queue.DeploymentTaskOptions(queue.TypeDestroy, d.ID)...); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			logger.L().Info("expired deployment already has a destroy task, leaving it", zap.String("deployment_id", d.ID.String()))
+			return
+		}
+		logger.L().Error("enqueue expiry destroy failed", zap.String("deployment_id", d.ID.String()), zap.Error(err))
+		return
+	}
+	if err := h.deployRepo.UpdateStatus(ctx, d.ID, models.DeploymentDestroyQueued); err != nil {
+		logger.L().Warn("update status destroy_queued failed", zap.Error(err))
+	}
+	if err := h.deployRepo.SetExpiry(ctx, d.ID, nil); err != nil {
+		logger.L().Warn("clear expiry failed", zap.Error(err))
+	}
+
+	logger.L().Info("deployment expired, destroy enqueued", zap.String("deployment_id", d.ID.String()), zap.Time("expires_at", *d.ExpiresAt))
+	h.publish(ctx, events.New(events.DeploymentExpired, d.ProjectID, d.ID, map[string]interface{}{
+		"environment": d.Environment,
+		"expires_at":  d.ExpiresAt,
+	}))
+}
+
+func (h *ExpiryTaskHandler) warn(ctx context.Context, d *models.Deployment) {
+	h.publish(ctx, events.New(events.DeploymentExpiring, d.ProjectID, d.ID, map[string]interface{}{
+		"environment": d.Environment,
+		"expires_at":  d.ExpiresAt,
+		"expires_in":  time.Until(*d.ExpiresAt).Round(time.Minute).String(),
+	}))
+	if err := h.deployRepo.MarkExpiryWarned(ctx, d.ID, time.Now()); err != nil {
+		logger.L().Warn("mark expiry warned failed", zap.Error(err))
+	}
+}
 
+func (h *ExpiryTaskHandler) publish(ctx context.Context, e events.Event) {
+	if h.publisher == nil {
+		return
+	}
+	if err := h.publisher.Publish(ctx, e); err != nil {
+		logger.L().Warn("publish event failed", zap.String("type", e.Type), zap.Error(err))
+	}
+}
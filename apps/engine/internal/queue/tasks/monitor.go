@@ -1,5 +1,158 @@
-//go:build ignore
-
 package tasks
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/events"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/internal/services"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// Drift task types. The scan runs periodically and enqueues a check for
+// every applied deployment whose project schedule is due.
+const (
+	TypeDriftScan  = "drift:scan"
+	TypeDriftCheck = "drift:check"
+)
+
+// DriftTaskHandler schedules and runs drift checks.
+type DriftTaskHandler struct {
+	provisioner     provisioner.Provisioner
+	projectRepo     repository.ProjectRepository
+	graphRepo       repository.GraphRepository
+	deployRepo      repository.DeploymentRepository
+	driftRepo       repository.DriftRepository
+	inventory       services.InventoryService
+	client          *asynq.Client
+	defaultSchedule string
+	// publisher gets drift.detected; nil publishes nothing
+	publisher events.Publisher
+	accounts  repository.CloudAccountRepository
+}
+
+func NewDriftTaskHandler(prov provisioner.Provisioner, projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, deployRepo repository.DeploymentRepository, driftRepo repository.DriftRepository, inventory services.InventoryService, client *asynq.Client, defaultSchedule string, publisher events.Publisher, accounts repository.CloudAccountRepository) *DriftTaskHandler {
+	return &DriftTaskHandler{provisioner: prov, projectRepo: projectRepo, graphRepo: graphRepo, deployRepo: deployRepo, driftRepo: driftRepo, inventory: inventory, client: client, defaultSchedule: defaultSchedule, publisher: publisher, accounts: accounts}
+}
+
+func (h *DriftTaskHandler) HandleScan(ctx context.Context, t *asynq.Task) error {
+	candidates, err := h.driftRepo.ListCandidates(ctx)
+	if err != nil {
+		logger.L().Error("list drift candidates failed", zap.Error(err))
+		return err
+	}
+
+	now := time.Now()
+	enqueued := 0
+	for _, c := range candidates {
+		every, err := services.DriftInterval(c.DriftSchedule, h.defaultSchedule)
+		if err != nil {
+			logger.L().Warn("invalid drift schedule", zap.String("project_id", c.ProjectID.String()), zap.String("schedule", c.DriftSchedule), zap.Error(err))
+			continue
+		}
+		if every == 0 || (c.DriftCheckedAt != nil && now.Sub(*c.DriftCheckedAt) < every) {
+			continue
+		}
+
+		pb, _ := json.Marshal(ProvisionPayload{DeploymentID: c.DeploymentID.String()})
+		// the uniqueness window keeps a slow check from being queued twice
+		if _, err := h.client.EnqueueContext(ctx, asynq.NewTask(TypeDriftCheck, pb), asynq.Unique(every)); err != nil {
+			if err != asynq.ErrDuplicateTask {
+				logger.L().Error("enqueue drift check failed", zap.String("deployment_id", c.DeploymentID.String()), zap.Error(err))
+			}
+			continue
+		}
+		enqueued++
+	}
+
+	logger.L().Info("drift scan completed", zap.Int("candidates", len(candidates)), zap.Int("enqueued", enqueued))
+	return nil
+}
+
+func (h *DriftTaskHandler) HandleCheck(ctx context.Context, t *asynq.Task) error {
+	var p ProvisionPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		logger.L().Error("invalid drift task payload", zap.Error(err))
+		return err
+	}
+	id, err := uuid.Parse(p.DeploymentID)
+	if err != nil {
+		logger.L().Error("invalid deployment id in task", zap.Error(err))
+		return err
+	}
+
+	logger.L().Info("handling drift check task", zap.String("deployment_id", id.String()))
+
+	var d models.Deployment
+	if err := h.deployRepo.GetByID(ctx, id, &d); err != nil {
+		logger.L().Error("get deployment failed", zap.Error(err))
+		return err
+	}
+	// the deployment may have moved on since the scan
+	if d.Status != models.DeploymentApplied {
+		logger.L().Info("skipping drift check", zap.String("deployment_id", id.String()), zap.String("status", string(d.Status)))
+		return nil
+	}
+
+	report := &models.DriftReport{DeploymentID: id, CreatedAt: time.Now()}
+	result, err := h.detect(ctx, &d)
+	if err != nil {
+		// recorded rather than retried; the next scheduled scan tries again
+		logger.L().Error("drift check failed", zap.String("deployment_id", id.String()), zap.Error(err))
+		report.Status = models.DriftError
+		report.Error = err.Error()
+	} else {
+		report.Status = models.DriftInSync
+		if len(result.Drift) > 0 {
+			report.Status = models.DriftDrifted
+		}
+		report.ResourceCount = len(result.Drift)
+		b, err := json.Marshal(result.Drift)
+		if err != nil {
+			return fmt.Errorf("marshal drift: %w", err)
+		}
+		report.Resources = datatypes.JSON(b)
+
+		// the inventory reflects what exists, drifted or not
+		if h.inventory != nil && result.Resources != nil {
+			if err := h.inventory.SyncResources(ctx, &d, result.Resources); err != nil {
+				logger.L().Warn("sync resource inventory failed", zap.String("deployment_id", id.String()), zap.Error(err))
+			}
+		}
+	}
+
+	if err := h.driftRepo.Record(ctx, report); err != nil {
+		logger.L().Error("record drift report failed", zap.Error(err))
+		return err
+	}
+	logger.L().Info("drift check completed", zap.String("deployment_id", id.String()), zap.String("status", report.Status), zap.Int("resources", report.ResourceCount))
+
+	if report.Status == models.DriftDrifted && h.publisher != nil {
+		e := events.New(events.DriftDetected, d.ProjectID, d.ID, map[string]interface{}{"report_id": report.ID.String(), "resources": report.ResourceCount})
+		if err := h.publisher.Publish(ctx, e); err != nil {
+			logger.L().Warn("publish drift event failed", zap.String("deployment_id", id.String()), zap.Error(err))
+		}
+	}
+	return nil
+}
 
+func (h *DriftTaskHandler) detect(ctx context.Context, d *models.Deployment) (*provisioner.DriftResult, error) {
+	infra, err := loadInfraConfig(ctx, h.projectRepo, h.graphRepo, h.accounts, d)
+	if err != nil {
+		return nil, err
+	}
+	state, err := h.provisioner.GetState(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return h.provisioner.DetectDrift(ctx, infra, state)
+}
@@ -0,0 +1,105 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/policy"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/internal/services"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// HandlePlan runs terraform plan for a plan-only deployment and stores the
+// change set. Nothing is applied, so it neither takes the project lock nor
+// waits in the queue.
+func (h *ProvisionTaskHandler) HandlePlan(ctx context.Context, t *asynq.Task) error {
+	var p ProvisionPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		logger.L().Error("invalid plan task payload", zap.Error(err))
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	id, err := uuid.Parse(p.DeploymentID)
+	if err != nil {
+		logger.L().Error("invalid deployment id in task", zap.Error(err))
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+
+	logger.L().Info("handling plan task", zap.String("deployment_id", id.String()))
+	ctx = repository.WithActor(ctx, "task:"+t.Type())
+
+	var d models.Deployment
+	if err := h.deployRepo.GetByID(ctx, id, &d); err != nil {
+		logger.L().Error("get deployment failed", zap.Error(err))
+		return h.fail(ctx, id, err)
+	}
+	if !d.PlanOnly {
+		logger.L().Error("plan task for a deployment that applies", zap.String("deployment_id", id.String()))
+		return fmt.Errorf("deployment %s is not plan-only: %w", id, asynq.SkipRetry)
+	}
+	switch d.Status {
+	case models.DeploymentPending, models.DeploymentPlanning:
+	default:
+		logger.L().Info("plan no longer needed, skipping", zap.String("deployment_id", id.String()), zap.String("status", string(d.Status)))
+		return nil
+	}
+
+	if err := h.advance(ctx, id, models.DeploymentPlanning); err != nil {
+		return err
+	}
+
+	infra, err := loadInfraConfig(ctx, h.projectRepo, h.graphRepo, h.accounts, &d)
+	if err != nil {
+		return h.fail(ctx, id, err)
+	}
+
+	var state []byte
+	if d.BaseDeploymentID != nil {
+		if state, err = h.provisioner.GetState(ctx, *d.BaseDeploymentID); err != nil {
+			logger.L().Error("get base state failed", zap.String("base_deployment_id", d.BaseDeploymentID.String()), zap.Error(err))
+			return h.fail(ctx, id, err)
+		}
+	}
+	h.planLog(ctx, id, "info", "plan started", map[string]interface{}{"base_deployment_id": d.BaseDeploymentID, "from_scratch": len(state) == 0})
+
+	plan, err := h.provisioner.Plan(ctx, infra, state)
+	if err != nil {
+		logger.L().Error("plan failed", zap.Error(err))
+		h.planLog(ctx, id, "error", fmt.Sprintf("plan error: %v", err), nil)
+		return h.fail(ctx, id, err)
+	}
+	if err := h.deploySvc.SaveDeploymentPlan(ctx, id, plan); err != nil {
+		return h.fail(ctx, id, err)
+	}
+
+	h.logPlan(ctx, id, plan)
+	// findings tell what an apply of this plan would run into; a plan is
+	// never blocked
+	if h.policies != nil {
+		if err := h.checkPolicies(ctx, &d, infra, plan); err != nil && !errors.Is(err, policy.ErrViolation) {
+			logger.L().Warn("policy evaluation failed", zap.String("deployment_id", id.String()), zap.Error(err))
+		}
+	}
+	return h.advance(ctx, id, models.DeploymentPlanned)
+}
+
+// logPlan writes the change set to the deployment log, one line per
+// resource and a summary.
+func (h *ProvisionTaskHandler) logPlan(ctx context.Context, id uuid.UUID, plan *provisioner.Plan) {
+	for _, c := range plan.ResourceChanges {
+		h.planLog(ctx, id, "info", c.Action+" "+c.Address, map[string]interface{}{"node_id": c.NodeID, "changed": c.Changed})
+	}
+	h.planLog(ctx, id, "info", fmt.Sprintf("plan completed: %d to add, %d to change, %d to destroy", plan.ResourceAdds, plan.ResourceMods, plan.ResourceDels), nil)
+}
+
+func (h *ProvisionTaskHandler) planLog(ctx context.Context, id uuid.UUID, level, msg string, data map[string]interface{}) {
+	_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: level, Phase: "plan", Message: msg, Data: data})
+}
@@ -3,13 +3,16 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/policy"
 	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/queue"
 	"github.com/iac-studio/engine/internal/repository"
 	"github.com/iac-studio/engine/internal/services"
 	appErr "github.com/iac-studio/engine/pkg/errors"
@@ -20,6 +23,8 @@ import (
 // ProvisionPayload is the task payload for provision/destroy tasks.
 type ProvisionPayload struct {
 	DeploymentID string `json:"deployment_id"`
+	// Targets limits a destroy to these graph node IDs.
+	Targets []string `json:"targets,omitempty"`
 }
 
 // ProvisionTaskHandler handles provisioning and destroy tasks.
@@ -29,107 +34,131 @@ type ProvisionTaskHandler struct {
 	projectRepo repository.ProjectRepository
 	graphRepo   repository.GraphRepository
 	deployRepo  repository.DeploymentRepository
+	inventory   services.InventoryService
+	// policies gate applies; nil applies without evaluating policies.
+	policies services.PolicyService
+	// locker serializes deployments of a project across workers; nil runs
+	// without a lock (single worker, tests).
+	locker queue.Locker
+	// accounts resolves the cloud_account_id of project settings; nil
+	// only takes inline credentials.
+	accounts repository.CloudAccountRepository
 }
 
-func NewProvisionTaskHandler(prov provisioner.Provisioner, deploySvc services.DeploymentService, projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, deployRepo repository.DeploymentRepository) *ProvisionTaskHandler {
-	return &ProvisionTaskHandler{provisioner: prov, deploySvc: deploySvc, projectRepo: projectRepo, graphRepo: graphRepo, deployRepo: deployRepo}
+func NewProvisionTaskHandler(prov provisioner.Provisioner, deploySvc services.DeploymentService, projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, deployRepo repository.DeploymentRepository, inventory services.InventoryService, policies services.PolicyService, locker queue.Locker, accounts repository.CloudAccountRepository) *ProvisionTaskHandler {
+	return &ProvisionTaskHandler{provisioner: prov, deploySvc: deploySvc, projectRepo: projectRepo, graphRepo: graphRepo, deployRepo: deployRepo, inventory: inventory, policies: policies, locker: locker, accounts: accounts}
 }
 
 func (h *ProvisionTaskHandler) HandleProvision(ctx context.Context, t *asynq.Task) error {
 	var p ProvisionPayload
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
 		logger.L().Error("invalid provision task payload", zap.Error(err))
-		return err
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
 	}
 	id, err := uuid.Parse(p.DeploymentID)
 	if err != nil {
 		logger.L().Error("invalid deployment id in task", zap.Error(err))
-		return err
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
 	}
 
 	logger.L().Info("handling provision task", zap.String("deployment_id", id.String()))
-
-	// mark planning
-	if err := h.deploySvc.UpdateDeploymentStatus(ctx, id, "planning"); err != nil {
-		logger.L().Error("update status failed", zap.Error(err))
-	}
+	ctx = repository.WithActor(ctx, "task:"+t.Type())
 
 	// load deployment, project, graph
 	var d models.Deployment
 	if err := h.deployRepo.GetByID(ctx, id, &d); err != nil {
 		logger.L().Error("get deployment failed", zap.Error(err))
-		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
-		return err
+		return h.fail(ctx, id, err)
 	}
 
-	var proj models.Project
-	if err := h.projectRepo.GetByID(ctx, d.ProjectID, &proj); err != nil {
-		logger.L().Error("get project failed", zap.Error(err))
-		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
-		return err
+	// a retry after the apply finished only has to record the outcome
+	switch {
+	case d.PlanOnly:
+		logger.L().Error("provision task for a plan-only deployment", zap.String("deployment_id", id.String()))
+		return fmt.Errorf("deployment %s is plan-only: %w", id, asynq.SkipRetry)
+	case d.Checkpoint == models.CheckpointApplied:
+		logger.L().Info("apply already completed, skipping", zap.String("deployment_id", id.String()))
+		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentApplied)
+		return nil
+	case d.Status == models.DeploymentDestroyQueued || d.Status == models.DeploymentDestroying || d.Status == models.DeploymentDestroyed:
+		logger.L().Warn("deployment is being destroyed, skipping provision", zap.String("deployment_id", id.String()), zap.String("status", string(d.Status)))
+		return nil
+	case d.Status == models.DeploymentAwaitingApproval || d.Status == models.DeploymentRejected:
+		logger.L().Info("deployment is held for approval, skipping provision", zap.String("deployment_id", id.String()), zap.String("status", string(d.Status)))
+		return nil
+	case d.Status == models.DeploymentCancelled || d.Status == models.DeploymentSuperseded:
+		logger.L().Info("deployment left the queue, skipping provision", zap.String("deployment_id", id.String()), zap.String("status", string(d.Status)))
+		return nil
 	}
 
-	var g models.ProjectGraph
-	if err := h.graphRepo.GetByID(ctx, d.GraphID, &g); err != nil {
-		logger.L().Error("get graph failed", zap.Error(err))
-		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
+	// one deployment per project at a time, in creation order
+	runCtx, release, err := h.lock(ctx, d.ProjectID)
+	if err != nil {
 		return err
 	}
-
-	// unmarshal nodes/edges into provisioner types
-	var nodes []provisioner.Node
-	var edges []provisioner.Edge
-	if len(g.Nodes) > 0 {
-		if err := json.Unmarshal(g.Nodes, &nodes); err != nil {
-			logger.L().Error("unmarshal nodes failed", zap.Error(err))
-			_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
-			return appErr.Wrap(err, appErr.CodeInternal, "unmarshal nodes failed")
-		}
+	defer release()
+	if err := h.awaitTurn(ctx, &d); err != nil {
+		return err
 	}
-	if len(g.Edges) > 0 {
-		if err := json.Unmarshal(g.Edges, &edges); err != nil {
-			logger.L().Error("unmarshal edges failed", zap.Error(err))
-			_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
-			return appErr.Wrap(err, appErr.CodeInternal, "unmarshal edges failed")
-		}
+	if d.Checkpoint == models.CheckpointApplying {
+		logger.L().Info("resuming interrupted apply", zap.String("deployment_id", id.String()))
 	}
 
-	provGraph := provisioner.Graph{Nodes: nodes, Edges: edges}
+	// mark planning
+	if err := h.advance(ctx, id, models.DeploymentPlanning); err != nil {
+		return err
+	}
 
-	// build cloud config from project settings if present
-	var settings map[string]interface{}
-	if len(proj.Settings) > 0 {
-		_ = json.Unmarshal(proj.Settings, &settings)
+	proj, infra, err := loadProjectInfra(ctx, h.projectRepo, h.graphRepo, h.accounts, &d)
+	if err != nil {
+		return h.fail(runCtx, id, err)
 	}
-	cloudCfg := provisioner.CloudConfig{Provider: proj.CloudProvider}
-	if v, ok := settings["region"].(string); ok {
-		cloudCfg.Region = v
+
+	rules, err := services.ParseApprovalRules(proj.ApprovalRules, proj.UserID)
+	if err != nil {
+		return h.fail(runCtx, id, err)
 	}
-	if v, ok := settings["credentials"].(map[string]interface{}); ok {
-		cloudCfg.Credentials = v
+	needsApproval := d.ApprovedAt == nil && rules.Enabled()
+
+	// policies and approvers judge the change set, so plan first
+	var plan *provisioner.Plan
+	if h.policies != nil || needsApproval {
+		if plan, err = h.preflightPlan(runCtx, &d, infra); err != nil {
+			return h.fail(runCtx, id, err)
+		}
+	}
+	if h.policies != nil {
+		if err := h.checkPolicies(ctx, &d, infra, plan); err != nil {
+			if errors.Is(err, policy.ErrViolation) {
+				_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentFailed)
+				return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+			}
+			return h.fail(runCtx, id, err)
+		}
 	}
 
-	infra := &provisioner.InfraConfig{
-		DeploymentID:  id,
-		ProjectID:     proj.ID,
-		GraphID:       g.ID,
-		Graph:         provGraph,
-		CloudProvider: proj.CloudProvider,
-		CloudConfig:   cloudCfg,
-		Variables:     map[string]interface{}{},
+	// four-eyes control: stop until approvers sign off
+	if needsApproval {
+		held, err := h.requestApproval(ctx, &d, plan, rules)
+		if err != nil {
+			return h.fail(runCtx, id, err)
+		}
+		if held {
+			return nil
+		}
 	}
 
 	// apply
-	if err := h.deploySvc.UpdateDeploymentStatus(ctx, id, "applying"); err != nil {
-		logger.L().Warn("update status applying failed", zap.Error(err))
+	if err := h.advance(ctx, id, models.DeploymentApplying); err != nil {
+		return err
 	}
+	h.checkpoint(ctx, id, models.CheckpointApplying)
 
-	res, err := h.provisioner.Apply(ctx, infra)
+	res, err := h.provisioner.Apply(runCtx, infra)
 	if err != nil {
 		logger.L().Error("provision apply failed", zap.Error(err))
-		_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "error", Message: fmt.Sprintf("apply error: %v", err)})
-		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
-		return err
+		_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "error", Phase: "apply", Message: fmt.Sprintf("apply error: %v", err)})
+		return h.fail(runCtx, id, err)
 	}
 
 	// persist outputs and state
@@ -140,10 +169,12 @@ func (h *ProvisionTaskHandler) HandleProvision(ctx context.Context, t *asynq.Tas
 		if res.State != nil {
 			_ = h.deploySvc.SaveTerraformState(ctx, id, res.State)
 		}
+		h.syncInventory(ctx, &d, res.Resources)
 	}
+	h.checkpoint(ctx, id, models.CheckpointApplied)
 
-	_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "info", Message: "apply completed"})
-	_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "applied")
+	_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "info", Phase: "apply", Message: "apply completed"})
+	_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentApplied)
 	return nil
 }
 
@@ -151,42 +182,325 @@ func (h *ProvisionTaskHandler) HandleDestroy(ctx context.Context, t *asynq.Task)
 	var p ProvisionPayload
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
 		logger.L().Error("invalid destroy task payload", zap.Error(err))
-		return err
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
 	}
 	id, err := uuid.Parse(p.DeploymentID)
 	if err != nil {
 		logger.L().Error("invalid deployment id in task", zap.Error(err))
-		return err
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
 	}
 
 	logger.L().Info("handling destroy task", zap.String("deployment_id", id.String()))
-	_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "destroying")
+	ctx = repository.WithActor(ctx, "task:"+t.Type())
 
 	// fetch state
 	var d models.Deployment
 	if err := h.deployRepo.GetByID(ctx, id, &d); err != nil {
 		logger.L().Error("get deployment failed", zap.Error(err))
-		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
+		return h.fail(ctx, id, err)
+	}
+	if d.Checkpoint == models.CheckpointDestroyed {
+		logger.L().Info("destroy already completed, skipping", zap.String("deployment_id", id.String()))
+		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentDestroyed)
+		return nil
+	}
+
+	runCtx, release, err := h.lock(ctx, d.ProjectID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := h.advance(ctx, id, models.DeploymentDestroying); err != nil {
 		return err
 	}
 
-	state := []byte(nil)
-	if len(d.TerraformState) > 0 {
-		state = []byte(d.TerraformState)
+	infra, err := loadInfraConfig(ctx, h.projectRepo, h.graphRepo, h.accounts, &d)
+	if err != nil {
+		return h.fail(runCtx, id, err)
 	}
+	infra.Targets = p.Targets
 
-	res, err := h.provisioner.Destroy(ctx, id, state)
+	// the column may hold sealed state; the provisioner's store decrypts it
+	state, err := h.provisioner.GetState(runCtx, id)
+	if err != nil {
+		logger.L().Error("get state failed", zap.Error(err))
+		return h.fail(runCtx, id, err)
+	}
+
+	h.checkpoint(ctx, id, models.CheckpointDestroying)
+	res, err := h.provisioner.Destroy(runCtx, infra, state)
 	if err != nil {
 		logger.L().Error("destroy failed", zap.Error(err))
-		_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "error", Message: fmt.Sprintf("destroy error: %v", err)})
-		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "failed")
+		_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "error", Phase: "destroy", Message: fmt.Sprintf("destroy error: %v", err)})
+		return h.fail(runCtx, id, err)
+	}
+
+	if res != nil {
+		if res.State != nil {
+			_ = h.deploySvc.SaveTerraformState(ctx, id, res.State)
+		}
+		h.syncInventory(ctx, &d, res.Resources)
+	}
+	// a targeted destroy leaves the rest of the deployment in place
+	if len(p.Targets) > 0 {
+		h.checkpoint(ctx, id, models.CheckpointApplied)
+		_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "info", Phase: "destroy", Message: "targeted destroy completed", Data: map[string]interface{}{"targets": p.Targets}})
+		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentApplied)
+		return nil
+	}
+	h.checkpoint(ctx, id, models.CheckpointDestroyed)
+	_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "info", Phase: "destroy", Message: "destroy completed"})
+	_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentDestroyed)
+	return nil
+}
+
+// lock takes the project lock. While another worker holds it the task is
+// retried shortly without using up its retries (see queue.IsFailure).
+// Terraform runs with the returned context, which is cancelled if the lock
+// is lost; outcomes are still recorded with ctx.
+func (h *ProvisionTaskHandler) lock(ctx context.Context, projectID uuid.UUID) (context.Context, func(), error) {
+	if h.locker == nil {
+		return ctx, func() {}, nil
+	}
+	lockCtx, release, err := h.locker.TryLock(ctx, queue.ProjectLockKey(projectID))
+	if err != nil {
+		if errors.Is(err, queue.ErrProjectBusy) {
+			logger.L().Debug("project locked, task will wait", zap.String("project_id", projectID.String()))
+		}
+		return nil, nil, err
+	}
+	return lockCtx, release, nil
+}
+
+// awaitTurn makes d wait until every older queued deployment of its project
+// has run, so deployments apply in the order they were created.
+func (h *ProvisionTaskHandler) awaitTurn(ctx context.Context, d *models.Deployment) error {
+	items, err := h.deployRepo.ListQueue(ctx, d.ProjectID)
+	if err != nil {
+		return err
+	}
+	if len(items) > 0 && items[0].ID != d.ID {
+		return fmt.Errorf("%w: deployment %s is ahead in the queue", queue.ErrProjectBusy, items[0].ID)
+	}
+	return nil
+}
+
+// syncInventory updates the resource inventory; nil resources means the
+// provisioner could not read them and the inventory is left as it is.
+func (h *ProvisionTaskHandler) syncInventory(ctx context.Context, d *models.Deployment, resources []provisioner.Resource) {
+	if resources == nil || h.inventory == nil {
+		return
+	}
+	if err := h.inventory.SyncResources(ctx, d, resources); err != nil {
+		logger.L().Warn("sync resource inventory failed", zap.String("deployment_id", d.ID.String()), zap.Error(err))
+	}
+}
+
+// checkpoint records a finished phase; failing to do so only costs a
+// redundant (but idempotent) terraform run on retry.
+func (h *ProvisionTaskHandler) checkpoint(ctx context.Context, id uuid.UUID, cp string) {
+	if err := h.deployRepo.SetCheckpoint(ctx, id, cp); err != nil {
+		logger.L().Warn("set checkpoint failed", zap.String("deployment_id", id.String()), zap.String("checkpoint", cp), zap.Error(err))
+	}
+}
+
+// advance moves the deployment to status before the work that needs it. A
+// move the state machine refuses, because the deployment was cancelled or
+// superseded meanwhile, ends the task without retry; other failures are
+// retried.
+func (h *ProvisionTaskHandler) advance(ctx context.Context, id uuid.UUID, status models.DeploymentStatus) error {
+	err := h.deploySvc.UpdateDeploymentStatus(ctx, id, status)
+	if err == nil {
+		return nil
+	}
+	logger.L().Error("update status failed", zap.String("deployment_id", id.String()), zap.String("status", string(status)), zap.Error(err))
+	if appErr.IsCode(err, appErr.CodeConflict) {
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	return err
+}
+
+// fail handles a failed attempt. Transient errors are left to asynq's retry
+// policy and only fail the deployment on the last attempt; anything else
+// fails it now and is archived without retrying.
+func (h *ProvisionTaskHandler) fail(ctx context.Context, id uuid.UUID, err error) error {
+	// another worker may hold the lock now; retry and resume from the
+	// checkpoint rather than fail a deployment that may still be running
+	if cause := context.Cause(ctx); errors.Is(cause, queue.ErrLockLost) {
+		logger.L().Warn("project lock lost, task will be retried", zap.String("deployment_id", id.String()), zap.Error(cause))
+		return fmt.Errorf("%w: %w", err, cause)
+	}
+	if !provisioner.IsTransient(err) {
+		_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentFailed)
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	if ok && retried < maxRetry {
+		logger.L().Warn("transient failure, task will be retried", zap.String("deployment_id", id.String()), zap.Int("retry", retried), zap.Int("max_retry", maxRetry), zap.Error(err))
 		return err
 	}
+	_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, models.DeploymentFailed)
+	return err
+}
 
-	if res != nil && res.State != nil {
-		_ = h.deploySvc.SaveTerraformState(ctx, id, res.State)
+// preflightPlan plans d against the state the apply starts from and saves
+// the change set. An approved deployment reuses the plan its approvers saw.
+func (h *ProvisionTaskHandler) preflightPlan(ctx context.Context, d *models.Deployment, infra *provisioner.InfraConfig) (*provisioner.Plan, error) {
+	if d.ApprovedAt != nil && len(d.Plan) > 0 {
+		var plan provisioner.Plan
+		if err := json.Unmarshal(d.Plan, &plan); err == nil {
+			return &plan, nil
+		}
+	}
+	state, err := h.provisioner.GetState(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := h.provisioner.Plan(ctx, infra, state)
+	if err != nil {
+		_ = h.deploySvc.AppendLog(ctx, d.ID, services.DeploymentLog{Timestamp: time.Now(), Level: "error", Phase: "plan", Message: fmt.Sprintf("plan error: %v", err)})
+		return nil, err
+	}
+	if err := h.deploySvc.SaveDeploymentPlan(ctx, d.ID, plan); err != nil {
+		return nil, err
+	}
+	h.logPlan(ctx, d.ID, plan)
+	return plan, nil
+}
+
+// checkPolicies evaluates the project's policies against d, logs the
+// findings and returns policy.ErrViolation when a mandatory rule fails.
+func (h *ProvisionTaskHandler) checkPolicies(ctx context.Context, d *models.Deployment, infra *provisioner.InfraConfig, plan *provisioner.Plan) error {
+	res, err := h.policies.Evaluate(ctx, d, infra.Graph, plan)
+	if err != nil {
+		return err
+	}
+	for _, f := range res.Findings {
+		level := "warn"
+		if f.Enforcement == policy.Mandatory {
+			level = "error"
+		}
+		_ = h.deploySvc.AppendLog(ctx, d.ID, services.DeploymentLog{Timestamp: time.Now(), Level: level, Phase: "policy", Message: f.Message,
+			Data: map[string]interface{}{"rule_id": f.RuleID, "enforcement": f.Enforcement, "source": f.Source, "node_id": f.NodeID}})
+	}
+	if err := res.Err(); err != nil {
+		_ = h.deploySvc.AppendLog(ctx, d.ID, services.DeploymentLog{Timestamp: time.Now(), Level: "error", Phase: "policy", Message: fmt.Sprintf("apply blocked: %d mandatory policy violation(s)", res.Mandatory)})
+		logger.L().Warn("deployment blocked by policy", zap.String("deployment_id", d.ID.String()), zap.Int("mandatory", res.Mandatory))
+		return err
 	}
-	_ = h.deploySvc.AppendLog(ctx, id, services.DeploymentLog{Timestamp: time.Now(), Level: "info", Message: "destroy completed"})
-	_ = h.deploySvc.UpdateDeploymentStatus(ctx, id, "destroyed")
 	return nil
 }
+
+// requestApproval holds d at awaiting_approval, unless the rules
+// auto-approve its plan. It reports whether d is held.
+func (h *ProvisionTaskHandler) requestApproval(ctx context.Context, d *models.Deployment, plan *provisioner.Plan, rules *services.ApprovalRules) (bool, error) {
+	if rules.AutoApproves(plan) {
+		if err := h.deployRepo.MarkApproved(ctx, d.ID, time.Now()); err != nil {
+			return false, err
+		}
+		_ = h.deploySvc.AppendLog(ctx, d.ID, services.DeploymentLog{Timestamp: time.Now(), Level: "info", Phase: "approval", Message: "auto-approved: plan deletes nothing"})
+		return false, nil
+	}
+	if err := h.deploySvc.UpdateDeploymentStatus(ctx, d.ID, models.DeploymentAwaitingApproval); err != nil {
+		return false, err
+	}
+	_ = h.deploySvc.AppendLog(ctx, d.ID, services.DeploymentLog{Timestamp: time.Now(), Level: "info", Phase: "approval", Message: fmt.Sprintf("waiting for %d approval(s)", rules.RequiredApprovals)})
+	logger.L().Info("deployment awaiting approval", zap.String("deployment_id", d.ID.String()), zap.Int("required", rules.RequiredApprovals))
+	return true, nil
+}
+
+// loadInfraConfig loads the project and graph of d and converts them into
+// the provisioner's input.
+func loadInfraConfig(ctx context.Context, projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, accounts repository.CloudAccountRepository, d *models.Deployment) (*provisioner.InfraConfig, error) {
+	_, infra, err := loadProjectInfra(ctx, projectRepo, graphRepo, accounts, d)
+	return infra, err
+}
+
+// loadProjectInfra is loadInfraConfig for callers that also need the project.
+func loadProjectInfra(ctx context.Context, projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, accounts repository.CloudAccountRepository, d *models.Deployment) (*models.Project, *provisioner.InfraConfig, error) {
+	var proj models.Project
+	if err := projectRepo.GetByID(ctx, d.ProjectID, &proj); err != nil {
+		logger.L().Error("get project failed", zap.Error(err))
+		return nil, nil, err
+	}
+
+	var g models.ProjectGraph
+	if err := graphRepo.GetByID(ctx, d.GraphID, &g); err != nil {
+		logger.L().Error("get graph failed", zap.Error(err))
+		return nil, nil, err
+	}
+
+	// unmarshal nodes/edges into provisioner types
+	var nodes []provisioner.Node
+	var edges []provisioner.Edge
+	if len(g.Nodes) > 0 {
+		if err := json.Unmarshal(g.Nodes, &nodes); err != nil {
+			logger.L().Error("unmarshal nodes failed", zap.Error(err))
+			return nil, nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal nodes failed")
+		}
+	}
+	if len(g.Edges) > 0 {
+		if err := json.Unmarshal(g.Edges, &edges); err != nil {
+			logger.L().Error("unmarshal edges failed", zap.Error(err))
+			return nil, nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal edges failed")
+		}
+	}
+
+	// build cloud config from project settings if present
+	var settings map[string]interface{}
+	if len(proj.Settings) > 0 {
+		_ = json.Unmarshal(proj.Settings, &settings)
+	}
+	cloudCfg := provisioner.CloudConfig{Provider: proj.CloudProvider}
+	if v, ok := settings["region"].(string); ok {
+		cloudCfg.Region = v
+	}
+	if v, ok := settings["credentials"].(map[string]interface{}); ok {
+		cloudCfg.Credentials = v
+	}
+	if v, ok := settings["cloud_account_id"].(string); ok && v != "" {
+		creds, err := accountCredentials(ctx, accounts, &proj, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		cloudCfg.Credentials = creds
+	}
+
+	return &proj, &provisioner.InfraConfig{
+		DeploymentID:  d.ID,
+		ProjectID:     proj.ID,
+		GraphID:       g.ID,
+		Graph:         provisioner.Graph{Nodes: nodes, Edges: edges},
+		CloudProvider: proj.CloudProvider,
+		CloudConfig:   cloudCfg,
+		Variables:     map[string]interface{}{},
+	}, nil
+}
+
+// accountCredentials decrypts the credentials of the project owner's cloud
+// account id, which must be for the project's provider.
+func accountCredentials(ctx context.Context, accounts repository.CloudAccountRepository, proj *models.Project, id string) (map[string]interface{}, error) {
+	if accounts == nil {
+		return nil, appErr.New(appErr.CodeUnavailable, "cloud accounts are not available")
+	}
+	accountID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, appErr.New(appErr.CodeInvalid, "invalid cloud_account_id in project settings")
+	}
+	var account models.CloudAccount
+	if err := accounts.GetByID(ctx, accountID, &account); err != nil {
+		return nil, err
+	}
+	if account.UserID != proj.UserID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "cloud account does not belong to the project owner")
+	}
+	if account.Provider != proj.CloudProvider {
+		return nil, appErr.New(appErr.CodeInvalid, "cloud account is for "+account.Provider+", the project uses "+proj.CloudProvider)
+	}
+	var creds map[string]interface{}
+	if err := json.Unmarshal(account.Credentials, &creds); err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "decode cloud account credentials failed")
+	}
+	return creds, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
@@ -14,7 +15,11 @@ import (
 
 	"github.com/iac-studio/engine/internal/models"
 	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/provisioner/terraform"
+	"github.com/iac-studio/engine/internal/queue"
+	"github.com/iac-studio/engine/internal/repository"
 	"github.com/iac-studio/engine/internal/services"
+	appErr "github.com/iac-studio/engine/pkg/errors"
 	"github.com/iac-studio/engine/pkg/logger"
 )
 
@@ -32,8 +37,8 @@ type mockProvisioner struct {
 	mock.Mock
 }
 
-func (m *mockProvisioner) Plan(ctx context.Context, config *provisioner.InfraConfig) (*provisioner.Plan, error) {
-	args := m.Called(ctx, config)
+func (m *mockProvisioner) Plan(ctx context.Context, config *provisioner.InfraConfig, state []byte) (*provisioner.Plan, error) {
+	args := m.Called(ctx, config, state)
 	if v := args.Get(0); v != nil {
 		return v.(*provisioner.Plan), args.Error(1)
 	}
@@ -48,14 +53,22 @@ func (m *mockProvisioner) Apply(ctx context.Context, config *provisioner.InfraCo
 	return nil, args.Error(1)
 }
 
-func (m *mockProvisioner) Destroy(ctx context.Context, deploymentID uuid.UUID, state []byte) (*provisioner.Result, error) {
-	args := m.Called(ctx, deploymentID, state)
+func (m *mockProvisioner) Destroy(ctx context.Context, config *provisioner.InfraConfig, state []byte) (*provisioner.Result, error) {
+	args := m.Called(ctx, config, state)
 	if v := args.Get(0); v != nil {
 		return v.(*provisioner.Result), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
+func (m *mockProvisioner) DetectDrift(ctx context.Context, config *provisioner.InfraConfig, state []byte) (*provisioner.DriftResult, error) {
+	args := m.Called(ctx, config, state)
+	if v := args.Get(0); v != nil {
+		return v.(*provisioner.DriftResult), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *mockProvisioner) GetState(ctx context.Context, deploymentID uuid.UUID) ([]byte, error) {
 	args := m.Called(ctx, deploymentID)
 	if v := args.Get(0); v != nil {
@@ -92,25 +105,83 @@ func (m *mockDeploymentService) ListDeployments(ctx context.Context, projectID,
 	return nil, args.Error(1)
 }
 
-func (m *mockDeploymentService) GetDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID) ([]services.DeploymentLog, error) {
-	args := m.Called(ctx, deploymentID, userID)
+func (m *mockDeploymentService) GetDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID, q repository.LogQuery) (*services.LogPage, error) {
+	args := m.Called(ctx, deploymentID, userID, q)
 	if v := args.Get(0); v != nil {
-		return v.([]services.DeploymentLog), args.Error(1)
+		return v.(*services.LogPage), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *mockDeploymentService) DestroyDeployment(ctx context.Context, deploymentID, userID uuid.UUID) error {
-	args := m.Called(ctx, deploymentID, userID)
+func (m *mockDeploymentService) ExportDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID, q repository.LogQuery, fn func([]models.DeploymentLog) error) error {
+	args := m.Called(ctx, deploymentID, userID, q, fn)
+	return args.Error(0)
+}
+
+func (m *mockDeploymentService) DestroyDeployment(ctx context.Context, deploymentID, userID uuid.UUID, nodeIDs []string) error {
+	args := m.Called(ctx, deploymentID, userID, nodeIDs)
 	return args.Error(0)
 }
 
+func (m *mockDeploymentService) PreviewDestroy(ctx context.Context, deploymentID, userID uuid.UUID, nodeIDs []string) (*services.DestroyPreview, error) {
+	args := m.Called(ctx, deploymentID, userID, nodeIDs)
+	if v := args.Get(0); v != nil {
+		return v.(*services.DestroyPreview), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeploymentService) ExtendDeploymentTTL(ctx context.Context, deploymentID, userID uuid.UUID, input *services.ExtendTTLInput) (*models.Deployment, error) {
+	args := m.Called(ctx, deploymentID, userID, input)
+	if v := args.Get(0); v != nil {
+		return v.(*models.Deployment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *mockDeploymentService) CancelDeployment(ctx context.Context, deploymentID, userID uuid.UUID) error {
 	args := m.Called(ctx, deploymentID, userID)
 	return args.Error(0)
 }
 
-func (m *mockDeploymentService) UpdateDeploymentStatus(ctx context.Context, deploymentID uuid.UUID, status string) error {
+func (m *mockDeploymentService) GetQueueStatus(ctx context.Context, deploymentID, userID uuid.UUID) (*services.QueueStatus, error) {
+	args := m.Called(ctx, deploymentID, userID)
+	if v := args.Get(0); v != nil {
+		return v.(*services.QueueStatus), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeploymentService) GetStatusHistory(ctx context.Context, deploymentID, userID uuid.UUID) (*services.StatusHistory, error) {
+	args := m.Called(ctx, deploymentID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.StatusHistory), args.Error(1)
+}
+
+func (m *mockDeploymentService) CreatePlan(ctx context.Context, projectID, userID uuid.UUID, input *services.PlanInput) (*models.Deployment, error) {
+	args := m.Called(ctx, projectID, userID, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Deployment), args.Error(1)
+}
+
+func (m *mockDeploymentService) GetPlan(ctx context.Context, deploymentID, userID uuid.UUID) (*provisioner.Plan, error) {
+	args := m.Called(ctx, deploymentID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*provisioner.Plan), args.Error(1)
+}
+
+func (m *mockDeploymentService) SaveDeploymentPlan(ctx context.Context, deploymentID uuid.UUID, plan *provisioner.Plan) error {
+	args := m.Called(ctx, deploymentID, plan)
+	return args.Error(0)
+}
+
+func (m *mockDeploymentService) UpdateDeploymentStatus(ctx context.Context, deploymentID uuid.UUID, status models.DeploymentStatus) error {
 	args := m.Called(ctx, deploymentID, status)
 	return args.Error(0)
 }
@@ -130,6 +201,31 @@ func (m *mockDeploymentService) AppendLog(ctx context.Context, deploymentID uuid
 	return args.Error(0)
 }
 
+type mockInventoryService struct {
+	mock.Mock
+}
+
+func (m *mockInventoryService) ListProjectResources(ctx context.Context, projectID, userID uuid.UUID, filter repository.ResourceFilter) ([]models.Resource, error) {
+	args := m.Called(ctx, projectID, userID, filter)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Resource), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockInventoryService) ListDeploymentResources(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.Resource, error) {
+	args := m.Called(ctx, deploymentID, userID)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Resource), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockInventoryService) SyncResources(ctx context.Context, d *models.Deployment, resources []provisioner.Resource) error {
+	args := m.Called(ctx, d, resources)
+	return args.Error(0)
+}
+
 type mockProjectRepository struct {
 	mock.Mock
 }
@@ -216,11 +312,60 @@ func (m *mockDeploymentRepository) GetLatestByProject(ctx context.Context, proje
 	return args.Error(0)
 }
 
-func (m *mockDeploymentRepository) UpdateStatus(ctx context.Context, deploymentID uuid.UUID, status string) error {
+func (m *mockDeploymentRepository) ListStatusHistory(ctx context.Context, deploymentID uuid.UUID) ([]models.DeploymentStatusHistory, error) {
+	args := m.Called(ctx, deploymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DeploymentStatusHistory), args.Error(1)
+}
+
+func (m *mockDeploymentRepository) MarkApproved(ctx context.Context, deploymentID uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, deploymentID, at)
+	return args.Error(0)
+}
+
+func (m *mockDeploymentRepository) UpdateStatus(ctx context.Context, deploymentID uuid.UUID, status models.DeploymentStatus) error {
 	args := m.Called(ctx, deploymentID, status)
 	return args.Error(0)
 }
 
+func (m *mockDeploymentRepository) ListExpiring(ctx context.Context, before time.Time) ([]models.Deployment, error) {
+	args := m.Called(ctx, before)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Deployment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeploymentRepository) SetExpiry(ctx context.Context, deploymentID uuid.UUID, expiresAt *time.Time) error {
+	args := m.Called(ctx, deploymentID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *mockDeploymentRepository) MarkExpiryWarned(ctx context.Context, deploymentID uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, deploymentID, at)
+	return args.Error(0)
+}
+
+func (m *mockDeploymentRepository) SetCheckpoint(ctx context.Context, deploymentID uuid.UUID, checkpoint string) error {
+	args := m.Called(ctx, deploymentID, checkpoint)
+	return args.Error(0)
+}
+
+func (m *mockDeploymentRepository) ListQueue(ctx context.Context, projectID uuid.UUID) ([]models.Deployment, error) {
+	args := m.Called(ctx, projectID)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Deployment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeploymentRepository) SupersedeQueued(ctx context.Context, projectID, deploymentID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, projectID, deploymentID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 type mockGraphRepository struct {
 	mock.Mock
 }
@@ -280,6 +425,22 @@ func (m *mockGraphRepository) SetCurrent(ctx context.Context, projectID uuid.UUI
 	return args.Error(0)
 }
 
+// losableLocker grants every lock; lose cancels the last one as if its
+// refresh had failed.
+type losableLocker struct {
+	cancel context.CancelCauseFunc
+}
+
+func (l *losableLocker) TryLock(ctx context.Context, key string) (context.Context, func(), error) {
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	l.cancel = cancel
+	return lockCtx, func() { cancel(context.Canceled) }, nil
+}
+
+func (l *losableLocker) lose() {
+	l.cancel(queue.ErrLockLost)
+}
+
 func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 	// Setup test data
 	deploymentID := uuid.New()
@@ -293,9 +454,10 @@ func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 	projectRepo := &mockProjectRepository{}
 	graphRepo := &mockGraphRepository{}
 	deployRepo := &mockDeploymentRepository{}
+	inventory := &mockInventoryService{}
 
 	// Create handler with mocks
-	handler := NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo)
+	handler := NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
 
 	// Test successful provision flow
 	t.Run("successful provision", func(t *testing.T) {
@@ -348,15 +510,21 @@ func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 			}).Return(nil, graph).Once()
 
 		// Mock status updates
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "planning").Return(nil).Once()
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "applying").Return(nil).Once()
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "applied").Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentPlanning).Return(nil).Once()
+		deployRepo.On("ListQueue", mock.Anything, projectID).Return([]models.Deployment{*deployment}, nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentApplying).Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentApplied).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointApplying).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointApplied).Return(nil).Once()
 
 		// Mock provisioner apply
 		result := &provisioner.Result{
 			Success: true,
 			Outputs: map[string]interface{}{"instance_ip": "1.2.3.4"},
 			State:   []byte(`{"version":4}`),
+			Resources: []provisioner.Resource{
+				{Type: "aws_instance", Name: "n1", Address: "aws_instance.n1", CloudID: "i-123", NodeID: "n1"},
+			},
 		}
 		prov.On("Apply", mock.Anything, mock.MatchedBy(func(cfg *provisioner.InfraConfig) bool {
 			return cfg.DeploymentID == deploymentID && cfg.ProjectID == projectID
@@ -365,6 +533,9 @@ func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 		// Mock output/state persistence
 		deploySvc.On("SaveDeploymentOutputs", mock.Anything, deploymentID, result.Outputs).Return(nil).Once()
 		deploySvc.On("SaveTerraformState", mock.Anything, deploymentID, result.State).Return(nil).Once()
+		inventory.On("SyncResources", mock.Anything, mock.MatchedBy(func(d *models.Deployment) bool {
+			return d.ID == deploymentID
+		}), result.Resources).Return(nil).Once()
 		deploySvc.On("AppendLog", mock.Anything, deploymentID, mock.MatchedBy(func(log services.DeploymentLog) bool {
 			return log.Level == "info" && log.Message == "apply completed"
 		})).Return(nil).Once()
@@ -374,7 +545,7 @@ func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 		require.NoError(t, err)
 
 		// Verify all mocked calls were made
-		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo)
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
 	})
 
 	// Test provision failure flow
@@ -385,7 +556,8 @@ func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 		projectRepo = &mockProjectRepository{}
 		graphRepo = &mockGraphRepository{}
 		deployRepo = &mockDeploymentRepository{}
-		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo)
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
 
 		// Create task payload
 		payload := ProvisionPayload{DeploymentID: deploymentID.String()}
@@ -436,9 +608,11 @@ func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 			}).Return(nil, graph).Once()
 
 		// Mock status updates
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "planning").Return(nil).Once()
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "applying").Return(nil).Once()
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "failed").Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentPlanning).Return(nil).Once()
+		deployRepo.On("ListQueue", mock.Anything, projectID).Return([]models.Deployment{*deployment}, nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentApplying).Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentFailed).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointApplying).Return(nil).Once()
 
 		// Mock provisioner failure
 		prov.On("Apply", mock.Anything, mock.MatchedBy(func(cfg *provisioner.InfraConfig) bool {
@@ -453,10 +627,133 @@ func TestProvisionTaskHandler_HandleProvision(t *testing.T) {
 		// Run the task handler
 		err := handler.HandleProvision(context.Background(), task)
 		require.Error(t, err)
-		require.Equal(t, provisioner.ErrInvalidInput, err)
+		require.ErrorIs(t, err, provisioner.ErrInvalidInput)
+		require.ErrorIs(t, err, asynq.SkipRetry)
 
 		// Verify all mocked calls were made
-		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo)
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+	})
+
+	// Test a deployment waits while an older one of the project is queued
+	t.Run("queued behind older deployment", func(t *testing.T) {
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
+
+		payload := ProvisionPayload{DeploymentID: deploymentID.String()}
+		payloadBytes, _ := json.Marshal(payload)
+		task := asynq.NewTask("deployment:provision", payloadBytes)
+
+		deployment := &models.Deployment{ID: deploymentID, ProjectID: projectID, GraphID: graphID, Status: "pending"}
+		older := models.Deployment{ID: uuid.New(), ProjectID: projectID, Status: "applying"}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).Return(nil, deployment).Once()
+		deployRepo.On("ListQueue", mock.Anything, projectID).Return([]models.Deployment{older, *deployment}, nil).Once()
+
+		err := handler.HandleProvision(context.Background(), task)
+		require.ErrorIs(t, err, queue.ErrProjectBusy)
+		require.False(t, queue.IsFailure(err))
+
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+	})
+
+	// Test a retry after the apply already completed does not apply again
+	t.Run("retry after completed apply", func(t *testing.T) {
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
+
+		payload := ProvisionPayload{DeploymentID: deploymentID.String()}
+		payloadBytes, _ := json.Marshal(payload)
+		task := asynq.NewTask("deployment:provision", payloadBytes)
+
+		deployment := &models.Deployment{
+			ID:         deploymentID,
+			ProjectID:  projectID,
+			GraphID:    graphID,
+			Status:     "applying",
+			Checkpoint: models.CheckpointApplied,
+		}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).Return(nil, deployment).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentApplied).Return(nil).Once()
+
+		err := handler.HandleProvision(context.Background(), task)
+		require.NoError(t, err)
+
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+		prov.AssertNotCalled(t, "Apply", mock.Anything, mock.Anything)
+	})
+
+	// Test a deployment cancelled mid-run is never applied
+	t.Run("refused transition stops before apply", func(t *testing.T) {
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
+
+		payload := ProvisionPayload{DeploymentID: deploymentID.String()}
+		payloadBytes, _ := json.Marshal(payload)
+		task := asynq.NewTask("deployment:provision", payloadBytes)
+
+		deployment := &models.Deployment{ID: deploymentID, ProjectID: projectID, GraphID: graphID, Status: "pending"}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).Return(nil, deployment).Once()
+		deployRepo.On("ListQueue", mock.Anything, projectID).Return([]models.Deployment{*deployment}, nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentPlanning).
+			Return(appErr.New(appErr.CodeConflict, "deployment cannot move from cancelled to planning")).Once()
+
+		err := handler.HandleProvision(context.Background(), task)
+		require.ErrorIs(t, err, asynq.SkipRetry)
+
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+		prov.AssertNotCalled(t, "Apply", mock.Anything, mock.Anything)
+	})
+
+	// Test losing the project lock stops terraform and retries instead of failing
+	t.Run("lock lost during apply", func(t *testing.T) {
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		locker := &losableLocker{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, locker, nil)
+
+		payload := ProvisionPayload{DeploymentID: deploymentID.String()}
+		payloadBytes, _ := json.Marshal(payload)
+		task := asynq.NewTask("deployment:provision", payloadBytes)
+
+		deployment := &models.Deployment{ID: deploymentID, ProjectID: projectID, GraphID: graphID, Status: models.DeploymentPending}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).Return(nil, deployment).Once()
+		deployRepo.On("ListQueue", mock.Anything, projectID).Return([]models.Deployment{*deployment}, nil).Once()
+		projectRepo.On("GetByID", mock.Anything, projectID, &models.Project{}).Return(nil, &models.Project{ID: projectID, UserID: userID, CloudProvider: "aws"}).Once()
+		graphRepo.On("GetByID", mock.Anything, graphID, &models.ProjectGraph{}).Return(nil, &models.ProjectGraph{ID: graphID, ProjectID: projectID}).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentPlanning).Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentApplying).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointApplying).Return(nil).Once()
+		deploySvc.On("AppendLog", mock.Anything, deploymentID, mock.Anything).Return(nil).Once()
+		prov.On("Apply", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), mock.Anything).
+			Run(func(args mock.Arguments) {
+				locker.lose()
+				<-args.Get(0).(context.Context).Done()
+			}).Return(nil, context.Canceled).Once()
+
+		err := handler.HandleProvision(context.Background(), task)
+		require.ErrorIs(t, err, queue.ErrLockLost)
+		require.NotErrorIs(t, err, asynq.SkipRetry)
+
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+		deploySvc.AssertNotCalled(t, "UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentFailed)
 	})
 }
 
@@ -464,6 +761,7 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 	// Setup test data
 	deploymentID := uuid.New()
 	projectID := uuid.New()
+	graphID := uuid.New()
 
 	// Create mock instances
 	prov := &mockProvisioner{}
@@ -471,9 +769,10 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 	projectRepo := &mockProjectRepository{}
 	graphRepo := &mockGraphRepository{}
 	deployRepo := &mockDeploymentRepository{}
+	inventory := &mockInventoryService{}
 
 	// Create handler with mocks
-	handler := NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo)
+	handler := NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
 
 	// Test successful destroy flow
 	t.Run("successful destroy", func(t *testing.T) {
@@ -487,6 +786,7 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 		deployment := &models.Deployment{
 			ID:             deploymentID,
 			ProjectID:      projectID,
+			GraphID:        graphID,
 			Status:         "destroying",
 			TerraformState: datatypes.JSON(state),
 		}
@@ -496,13 +796,24 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 				*dest = *deployment
 			}).Return(nil, deployment).Once()
 
+		// Mock project and graph load
+		project := &models.Project{ID: projectID, CloudProvider: "aws"}
+		projectRepo.On("GetByID", mock.Anything, projectID, &models.Project{}).Return(nil, project).Once()
+		graph := &models.ProjectGraph{ID: graphID, ProjectID: projectID, Nodes: datatypes.JSON(`[{"id":"n1","type":"aws_instance"}]`)}
+		graphRepo.On("GetByID", mock.Anything, graphID, &models.ProjectGraph{}).Return(nil, graph).Once()
+
 		// Mock status updates
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "destroying").Return(nil).Once()
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "destroyed").Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentDestroying).Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentDestroyed).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointDestroying).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointDestroyed).Return(nil).Once()
 
 		// Mock provisioner destroy
 		result := &provisioner.Result{Success: true}
-		prov.On("Destroy", mock.Anything, deploymentID, state).Return(result, nil).Once()
+		prov.On("GetState", mock.Anything, deploymentID).Return(state, nil).Once()
+		prov.On("Destroy", mock.Anything, mock.MatchedBy(func(cfg *provisioner.InfraConfig) bool {
+			return cfg.DeploymentID == deploymentID && len(cfg.Graph.Nodes) == 1 && len(cfg.Targets) == 0
+		}), state).Return(result, nil).Once()
 
 		// Mock logging
 		deploySvc.On("AppendLog", mock.Anything, deploymentID, mock.MatchedBy(func(log services.DeploymentLog) bool {
@@ -514,7 +825,7 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 		require.NoError(t, err)
 
 		// Verify all mocked calls were made
-		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo)
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
 	})
 
 	// Test destroy failure flow
@@ -525,7 +836,8 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 		projectRepo = &mockProjectRepository{}
 		graphRepo = &mockGraphRepository{}
 		deployRepo = &mockDeploymentRepository{}
-		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo)
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
 
 		// Create task payload
 		payload := ProvisionPayload{DeploymentID: deploymentID.String()}
@@ -537,6 +849,7 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 		deployment := &models.Deployment{
 			ID:             deploymentID,
 			ProjectID:      projectID,
+			GraphID:        graphID,
 			Status:         "destroying",
 			TerraformState: datatypes.JSON(state),
 		}
@@ -546,12 +859,22 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 				*dest = *deployment
 			}).Return(nil, deployment).Once()
 
+		// Mock project and graph load
+		project := &models.Project{ID: projectID, CloudProvider: "aws"}
+		projectRepo.On("GetByID", mock.Anything, projectID, &models.Project{}).Return(nil, project).Once()
+		graph := &models.ProjectGraph{ID: graphID, ProjectID: projectID, Nodes: datatypes.JSON(`[{"id":"n1","type":"aws_instance"}]`)}
+		graphRepo.On("GetByID", mock.Anything, graphID, &models.ProjectGraph{}).Return(nil, graph).Once()
+
 		// Mock status updates
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "destroying").Return(nil).Once()
-		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, "failed").Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentDestroying).Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentFailed).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointDestroying).Return(nil).Once()
 
 		// Mock provisioner failure
-		prov.On("Destroy", mock.Anything, deploymentID, state).Return(nil, provisioner.ErrInvalidState).Once()
+		prov.On("GetState", mock.Anything, deploymentID).Return(state, nil).Once()
+		prov.On("Destroy", mock.Anything, mock.MatchedBy(func(cfg *provisioner.InfraConfig) bool {
+			return cfg.DeploymentID == deploymentID && len(cfg.Graph.Nodes) == 1 && len(cfg.Targets) == 0
+		}), state).Return(nil, provisioner.ErrInvalidState).Once()
 
 		// Mock error logging
 		deploySvc.On("AppendLog", mock.Anything, deploymentID, mock.MatchedBy(func(log services.DeploymentLog) bool {
@@ -561,9 +884,179 @@ func TestProvisionTaskHandler_HandleDestroy(t *testing.T) {
 		// Run the task handler
 		err := handler.HandleDestroy(context.Background(), task)
 		require.Error(t, err)
-		require.Equal(t, provisioner.ErrInvalidState, err)
+		require.ErrorIs(t, err, provisioner.ErrInvalidState)
+		require.ErrorIs(t, err, asynq.SkipRetry)
 
 		// Verify all mocked calls were made
-		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo)
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+	})
+
+	// Test a destroy the state machine refuses never runs terraform
+	t.Run("refused transition stops before destroy", func(t *testing.T) {
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
+
+		payloadBytes, _ := json.Marshal(ProvisionPayload{DeploymentID: deploymentID.String()})
+		task := asynq.NewTask("deployment:destroy", payloadBytes)
+
+		deployment := &models.Deployment{ID: deploymentID, ProjectID: projectID, GraphID: graphID, Status: models.DeploymentCancelled}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).Return(nil, deployment).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentDestroying).
+			Return(appErr.New(appErr.CodeConflict, "deployment cannot move from cancelled to destroying")).Once()
+
+		err := handler.HandleDestroy(context.Background(), task)
+		require.ErrorIs(t, err, asynq.SkipRetry)
+
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+		prov.AssertNotCalled(t, "Destroy", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	// Test targeted destroy keeps the deployment applied
+	t.Run("targeted destroy", func(t *testing.T) {
+		// Reset mocks
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
+
+		payload := ProvisionPayload{DeploymentID: deploymentID.String(), Targets: []string{"n1"}}
+		payloadBytes, _ := json.Marshal(payload)
+		task := asynq.NewTask("deployment:destroy", payloadBytes)
+
+		state := []byte(`{"version":4,"resources":[{"type":"aws_instance","name":"n1"}]}`)
+		deployment := &models.Deployment{
+			ID:        deploymentID,
+			ProjectID: projectID,
+			GraphID:   graphID,
+			Status:    "destroying",
+		}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).
+			Run(func(args mock.Arguments) {
+				dest := args.Get(2).(*models.Deployment)
+				*dest = *deployment
+			}).Return(nil, deployment).Once()
+
+		// Mock project and graph load
+		project := &models.Project{ID: projectID, CloudProvider: "aws"}
+		projectRepo.On("GetByID", mock.Anything, projectID, &models.Project{}).Return(nil, project).Once()
+		graph := &models.ProjectGraph{ID: graphID, ProjectID: projectID, Nodes: datatypes.JSON(`[{"id":"n1","type":"aws_instance"}]`)}
+		graphRepo.On("GetByID", mock.Anything, graphID, &models.ProjectGraph{}).Return(nil, graph).Once()
+
+		// Mock status updates
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentDestroying).Return(nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentApplied).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointDestroying).Return(nil).Once()
+		deployRepo.On("SetCheckpoint", mock.Anything, deploymentID, models.CheckpointApplied).Return(nil).Once()
+
+		// Mock provisioner destroy returning the remaining state
+		remaining := []byte(`{"version":4,"resources":[]}`)
+		prov.On("GetState", mock.Anything, deploymentID).Return(state, nil).Once()
+		prov.On("Destroy", mock.Anything, mock.MatchedBy(func(cfg *provisioner.InfraConfig) bool {
+			return len(cfg.Targets) == 1 && cfg.Targets[0] == "n1"
+		}), state).Return(&provisioner.Result{Success: true, State: remaining}, nil).Once()
+		deploySvc.On("SaveTerraformState", mock.Anything, deploymentID, remaining).Return(nil).Once()
+		deploySvc.On("AppendLog", mock.Anything, deploymentID, mock.MatchedBy(func(log services.DeploymentLog) bool {
+			return log.Level == "info" && log.Message == "targeted destroy completed"
+		})).Return(nil).Once()
+
+		err := handler.HandleDestroy(context.Background(), task)
+		require.NoError(t, err)
+
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+	})
+
+	t.Run("plan-only run", func(t *testing.T) {
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
+
+		payloadBytes, _ := json.Marshal(ProvisionPayload{DeploymentID: deploymentID.String()})
+		task := asynq.NewTask("deployment:plan", payloadBytes)
+
+		baseID := uuid.New()
+		deployment := &models.Deployment{ID: deploymentID, ProjectID: projectID, GraphID: graphID, Status: "pending", PlanOnly: true, BaseDeploymentID: &baseID}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).
+			Run(func(args mock.Arguments) { *args.Get(2).(*models.Deployment) = *deployment }).
+			Return(nil, deployment).Once()
+		project := &models.Project{ID: projectID, UserID: uuid.New(), CloudProvider: "aws"}
+		projectRepo.On("GetByID", mock.Anything, projectID, &models.Project{}).
+			Run(func(args mock.Arguments) { *args.Get(2).(*models.Project) = *project }).
+			Return(nil, project).Once()
+		graph := &models.ProjectGraph{ID: graphID, ProjectID: projectID, Nodes: datatypes.JSON(`[{"id":"n1","type":"aws_instance"}]`), Edges: datatypes.JSON("[]")}
+		graphRepo.On("GetByID", mock.Anything, graphID, &models.ProjectGraph{}).
+			Run(func(args mock.Arguments) { *args.Get(2).(*models.ProjectGraph) = *graph }).
+			Return(nil, graph).Once()
+
+		// plans against the live deployment's state; never applies or locks
+		state := []byte(`{"version":4}`)
+		plan := &provisioner.Plan{Changes: 1, ResourceMods: 1, ResourceChanges: []terraform.ResourceChange{{Address: "aws_instance.n1", NodeID: "n1", Action: terraform.ChangeUpdate}}}
+		prov.On("GetState", mock.Anything, baseID).Return(state, nil).Once()
+		prov.On("Plan", mock.Anything, mock.Anything, state).Return(plan, nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentPlanning).Return(nil).Once()
+		deploySvc.On("SaveDeploymentPlan", mock.Anything, deploymentID, plan).Return(nil).Once()
+		deploySvc.On("AppendLog", mock.Anything, deploymentID, mock.MatchedBy(func(log services.DeploymentLog) bool {
+			return log.Phase == "plan"
+		})).Return(nil).Times(3)
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentPlanned).Return(nil).Once()
+
+		err := handler.HandlePlan(context.Background(), task)
+		require.NoError(t, err)
+
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
+	})
+
+	t.Run("held for approval", func(t *testing.T) {
+		prov = &mockProvisioner{}
+		deploySvc = &mockDeploymentService{}
+		projectRepo = &mockProjectRepository{}
+		graphRepo = &mockGraphRepository{}
+		deployRepo = &mockDeploymentRepository{}
+		inventory = &mockInventoryService{}
+		handler = NewProvisionTaskHandler(prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory, nil, nil, nil)
+
+		payloadBytes, _ := json.Marshal(ProvisionPayload{DeploymentID: deploymentID.String()})
+		task := asynq.NewTask("deployment:provision", payloadBytes)
+
+		deployment := &models.Deployment{ID: deploymentID, ProjectID: projectID, GraphID: graphID, Status: "pending"}
+		deployRepo.On("GetByID", mock.Anything, deploymentID, &models.Deployment{}).
+			Run(func(args mock.Arguments) { *args.Get(2).(*models.Deployment) = *deployment }).
+			Return(nil, deployment).Once()
+		deployRepo.On("ListQueue", mock.Anything, projectID).Return([]models.Deployment{*deployment}, nil).Once()
+		rules, _ := json.Marshal(services.ApprovalRules{RequiredApprovals: 1, Approvers: []uuid.UUID{uuid.New()}, AutoApproveNoDeletes: true})
+		project := &models.Project{ID: projectID, UserID: uuid.New(), CloudProvider: "aws", ApprovalRules: datatypes.JSON(rules)}
+		projectRepo.On("GetByID", mock.Anything, projectID, &models.Project{}).
+			Run(func(args mock.Arguments) { *args.Get(2).(*models.Project) = *project }).
+			Return(nil, project).Once()
+		graph := &models.ProjectGraph{ID: graphID, ProjectID: projectID, Nodes: datatypes.JSON(`[{"id":"n1","type":"aws_instance"}]`), Edges: datatypes.JSON("[]")}
+		graphRepo.On("GetByID", mock.Anything, graphID, &models.ProjectGraph{}).
+			Run(func(args mock.Arguments) { *args.Get(2).(*models.ProjectGraph) = *graph }).
+			Return(nil, graph).Once()
+
+		// the plan deletes a resource, so it is not auto-approved
+		plan := &provisioner.Plan{Changes: 1, ResourceDels: 1, ResourceChanges: []terraform.ResourceChange{{Address: "aws_instance.n1", NodeID: "n1", Action: terraform.ChangeDelete}}}
+		prov.On("GetState", mock.Anything, deploymentID).Return(nil, nil).Once()
+		prov.On("Plan", mock.Anything, mock.Anything, []byte(nil)).Return(plan, nil).Once()
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentPlanning).Return(nil).Once()
+		deploySvc.On("SaveDeploymentPlan", mock.Anything, deploymentID, plan).Return(nil).Once()
+		deploySvc.On("AppendLog", mock.Anything, deploymentID, mock.Anything).Return(nil).Times(3)
+		deploySvc.On("UpdateDeploymentStatus", mock.Anything, deploymentID, models.DeploymentAwaitingApproval).Return(nil).Once()
+
+		err := handler.HandleProvision(context.Background(), task)
+		require.NoError(t, err)
+
+		prov.AssertNotCalled(t, "Apply", mock.Anything, mock.Anything)
+		mock.AssertExpectationsForObjects(t, prov, deploySvc, projectRepo, graphRepo, deployRepo, inventory)
 	})
 }
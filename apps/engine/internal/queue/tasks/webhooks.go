@@ -0,0 +1,56 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/services"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// WebhookPayload is the payload of a queue.TypeWebhookDeliver task.
+type WebhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// WebhookTaskHandler sends webhook deliveries; asynq retries the failed
+// ones with queue.RetryDelay.
+type WebhookTaskHandler struct {
+	webhooks services.WebhookService
+}
+
+func NewWebhookTaskHandler(webhooks services.WebhookService) *WebhookTaskHandler {
+	return &WebhookTaskHandler{webhooks: webhooks}
+}
+
+func (h *WebhookTaskHandler) HandleDeliver(ctx context.Context, t *asynq.Task) error {
+	var p WebhookPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		logger.L().Error("invalid webhook task payload", zap.Error(err))
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	id, err := uuid.Parse(p.DeliveryID)
+	if err != nil {
+		logger.L().Error("invalid delivery id in task", zap.Error(err))
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	err = h.webhooks.Deliver(ctx, id, retried >= maxRetry)
+	if appErr.IsCode(err, appErr.CodeNotFound) {
+		// the webhook, and its deliveries with it, was deleted
+		logger.L().Info("webhook delivery gone, skipping", zap.String("delivery_id", id.String()))
+		return nil
+	}
+	if err != nil {
+		logger.L().Warn("webhook delivery attempt failed", zap.String("delivery_id", id.String()), zap.Int("retried", retried), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package repository
+
+import "context"
+
+type actorKeyType string
+
+const actorKey actorKeyType = "actor"
+
+// SystemActor is recorded when no actor was attached to the context.
+const SystemActor = "system"
+
+// WithActor attaches the identity performing a write (e.g. "user:<id>" or
+// "task:deployment:provision") so audit rows can record who made a change.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or SystemActor.
+func ActorFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(actorKey).(string); ok && v != "" {
+		return v
+	}
+	return SystemActor
+}
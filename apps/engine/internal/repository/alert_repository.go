@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PendingApproval is a deployment awaiting approval and since when.
+type PendingApproval struct {
+	DeploymentID uuid.UUID
+	ProjectID    uuid.UUID
+	Since        time.Time
+}
+
+type AlertRepository interface {
+	ListChannels(ctx context.Context, projectID uuid.UUID) ([]models.AlertChannel, error)
+	GetChannel(ctx context.Context, projectID, id uuid.UUID, out *models.AlertChannel) error
+	CreateChannel(ctx context.Context, ch *models.AlertChannel) error
+	UpdateChannel(ctx context.Context, ch *models.AlertChannel) error
+	// DeleteChannel deletes a channel and takes it off the project's rules.
+	DeleteChannel(ctx context.Context, projectID, id uuid.UUID) error
+
+	ListRules(ctx context.Context, projectID uuid.UUID) ([]models.AlertRule, error)
+	// ListEnabledRules returns the enabled rules with trigger, of one
+	// project or of all when projectID is uuid.Nil.
+	ListEnabledRules(ctx context.Context, projectID uuid.UUID, trigger string) ([]models.AlertRule, error)
+	GetRule(ctx context.Context, projectID, id uuid.UUID, out *models.AlertRule) error
+	CreateRule(ctx context.Context, rule *models.AlertRule) error
+	UpdateRule(ctx context.Context, rule *models.AlertRule) error
+	DeleteRule(ctx context.Context, projectID, id uuid.UUID) error
+
+	// ClaimDelivery stores d unless the channel already has its dedupe key,
+	// and tells whether it was stored.
+	ClaimDelivery(ctx context.Context, d *models.AlertDelivery) (bool, error)
+	UpdateDelivery(ctx context.Context, d *models.AlertDelivery) error
+	GetDelivery(ctx context.Context, id int64, out *models.AlertDelivery) error
+	// CountDeliveries counts the alerts a channel was sent, successfully or
+	// not, since a time.
+	CountDeliveries(ctx context.Context, channelID uuid.UUID, since time.Time) (int64, error)
+	// ListDeliveries returns a project's deliveries, newest first.
+	ListDeliveries(ctx context.Context, projectID uuid.UUID, limit int) ([]models.AlertDelivery, error)
+
+	// ListPendingApprovals returns the deployments of the projects that
+	// await approval.
+	ListPendingApprovals(ctx context.Context, projectIDs []uuid.UUID) ([]PendingApproval, error)
+}
+
+type alertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) AlertRepository {
+	return &alertRepository{db: db}
+}
+
+func (r *alertRepository) ListChannels(ctx context.Context, projectID uuid.UUID) ([]models.AlertChannel, error) {
+	var out []models.AlertChannel
+	if err := r.db.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list alert channels failed")
+	}
+	return out, nil
+}
+
+func (r *alertRepository) GetChannel(ctx context.Context, projectID, id uuid.UUID, out *models.AlertChannel) error {
+	if err := r.db.WithContext(ctx).First(out, "id = ? AND project_id = ?", id, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appErr.New(appErr.CodeNotFound, "alert channel not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get alert channel failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) CreateChannel(ctx context.Context, ch *models.AlertChannel) error {
+	if err := r.db.WithContext(ctx).Create(ch).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "create alert channel failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) UpdateChannel(ctx context.Context, ch *models.AlertChannel) error {
+	if err := r.db.WithContext(ctx).Save(ch).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "update alert channel failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) DeleteChannel(ctx context.Context, projectID, id uuid.UUID) error {
+	found := true
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models.AlertChannel{}, "id = ? AND project_id = ?", id, projectID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			found = false
+			return nil
+		}
+		return tx.Model(&models.AlertRule{}).
+			Where("project_id = ? AND channel_ids @> ?", projectID, datatypes.JSON(`["`+id.String()+`"]`)).
+			Update("channel_ids", gorm.Expr("channel_ids - ?", id.String())).Error
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "delete alert channel failed")
+	}
+	if !found {
+		return appErr.New(appErr.CodeNotFound, "alert channel not found")
+	}
+	return nil
+}
+
+func (r *alertRepository) ListRules(ctx context.Context, projectID uuid.UUID) ([]models.AlertRule, error) {
+	var out []models.AlertRule
+	if err := r.db.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list alert rules failed")
+	}
+	return out, nil
+}
+
+func (r *alertRepository) ListEnabledRules(ctx context.Context, projectID uuid.UUID, trigger string) ([]models.AlertRule, error) {
+	q := r.db.WithContext(ctx).Where("enabled AND trigger = ?", trigger)
+	if projectID != uuid.Nil {
+		q = q.Where("project_id = ?", projectID)
+	}
+	var out []models.AlertRule
+	if err := q.Order("created_at").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list alert rules failed")
+	}
+	return out, nil
+}
+
+func (r *alertRepository) GetRule(ctx context.Context, projectID, id uuid.UUID, out *models.AlertRule) error {
+	if err := r.db.WithContext(ctx).First(out, "id = ? AND project_id = ?", id, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appErr.New(appErr.CodeNotFound, "alert rule not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get alert rule failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) CreateRule(ctx context.Context, rule *models.AlertRule) error {
+	if err := r.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "create alert rule failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) UpdateRule(ctx context.Context, rule *models.AlertRule) error {
+	if err := r.db.WithContext(ctx).Save(rule).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "update alert rule failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) DeleteRule(ctx context.Context, projectID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&models.AlertRule{}, "id = ? AND project_id = ?", id, projectID)
+	if res.Error != nil {
+		return appErr.Wrap(res.Error, appErr.CodeInternal, "delete alert rule failed")
+	}
+	if res.RowsAffected == 0 {
+		return appErr.New(appErr.CodeNotFound, "alert rule not found")
+	}
+	return nil
+}
+
+func (r *alertRepository) ClaimDelivery(ctx context.Context, d *models.AlertDelivery) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "channel_id"}, {Name: "dedupe_key"}}, DoNothing: true}).
+		Create(d)
+	if res.Error != nil {
+		return false, appErr.Wrap(res.Error, appErr.CodeInternal, "record alert delivery failed")
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *alertRepository) UpdateDelivery(ctx context.Context, d *models.AlertDelivery) error {
+	err := r.db.WithContext(ctx).Model(d).Updates(map[string]interface{}{"status": d.Status, "error": d.Error, "attempts": d.Attempts}).Error
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "update alert delivery failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) GetDelivery(ctx context.Context, id int64, out *models.AlertDelivery) error {
+	if err := r.db.WithContext(ctx).First(out, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appErr.New(appErr.CodeNotFound, "alert delivery not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get alert delivery failed")
+	}
+	return nil
+}
+
+func (r *alertRepository) CountDeliveries(ctx context.Context, channelID uuid.UUID, since time.Time) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&models.AlertDelivery{}).
+		Where("channel_id = ? AND created_at >= ? AND status <> ?", channelID, since, models.AlertDeliveryRateLimited).
+		Count(&n).Error
+	if err != nil {
+		return 0, appErr.Wrap(err, appErr.CodeInternal, "count alert deliveries failed")
+	}
+	return n, nil
+}
+
+func (r *alertRepository) ListDeliveries(ctx context.Context, projectID uuid.UUID, limit int) ([]models.AlertDelivery, error) {
+	q := r.db.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at DESC, id DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var out []models.AlertDelivery
+	if err := q.Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list alert deliveries failed")
+	}
+	return out, nil
+}
+
+func (r *alertRepository) ListPendingApprovals(ctx context.Context, projectIDs []uuid.UUID) ([]PendingApproval, error) {
+	if len(projectIDs) == 0 {
+		return nil, nil
+	}
+	var out []PendingApproval
+	err := r.db.WithContext(ctx).Table("deployments AS d").
+		Select("d.id AS deployment_id, d.project_id, MAX(h.created_at) AS since").
+		Joins("JOIN deployment_status_history h ON h.deployment_id = d.id AND h.to_status = ?", models.DeploymentAwaitingApproval).
+		Where("d.status = ? AND d.project_id IN ?", models.DeploymentAwaitingApproval, projectIDs).
+		Group("d.id, d.project_id").
+		Scan(&out).Error
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list pending approvals failed")
+	}
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StatusChange is a deployment status change with the project facts the
+// anomaly detector groups by.
+type StatusChange struct {
+	DeploymentID uuid.UUID
+	ProjectID    uuid.UUID
+	Provider     string
+	Region       string
+	FromStatus   models.DeploymentStatus
+	ToStatus     models.DeploymentStatus
+	CreatedAt    time.Time
+}
+
+// AnomalyFilter selects anomalies; empty fields match all.
+type AnomalyFilter struct {
+	Kind  string
+	Since *time.Time
+	Limit int
+}
+
+type AnomalyRepository interface {
+	// ListStatusChanges returns the status changes since a time, of the
+	// given projects or of all when projectIDs is nil, ordered by
+	// deployment and time.
+	ListStatusChanges(ctx context.Context, since time.Time, projectIDs []uuid.UUID) ([]StatusChange, error)
+	// LastErrors returns the message of each deployment's last error log
+	// line; deployments without one are left out.
+	LastErrors(ctx context.Context, deploymentIDs []uuid.UUID) (map[uuid.UUID]string, error)
+	// Record stores a, unless an anomaly with its fingerprint exists, and
+	// tells whether it was stored.
+	Record(ctx context.Context, a *models.Anomaly) (bool, error)
+	// ListByProject returns a project's anomalies, newest first.
+	ListByProject(ctx context.Context, projectID uuid.UUID, filter AnomalyFilter) ([]models.Anomaly, error)
+}
+
+type anomalyRepository struct {
+	db *gorm.DB
+}
+
+func NewAnomalyRepository(db *gorm.DB) AnomalyRepository {
+	return &anomalyRepository{db: db}
+}
+
+func (r *anomalyRepository) ListStatusChanges(ctx context.Context, since time.Time, projectIDs []uuid.UUID) ([]StatusChange, error) {
+	if projectIDs != nil && len(projectIDs) == 0 {
+		return nil, nil
+	}
+	q := r.db.WithContext(ctx).Table("deployment_status_history AS h").
+		Select("h.deployment_id, d.project_id, p.cloud_provider AS provider, COALESCE(p.settings->>'region', '') AS region, h.from_status, h.to_status, h.created_at").
+		Joins("JOIN deployments d ON d.id = h.deployment_id").
+		Joins("JOIN projects p ON p.id = d.project_id").
+		Where("h.created_at >= ?", since)
+	if projectIDs != nil {
+		q = q.Where("d.project_id IN ?", projectIDs)
+	}
+	var out []StatusChange
+	if err := q.Order("h.deployment_id, h.created_at, h.id").Scan(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list status changes failed")
+	}
+	return out, nil
+}
+
+func (r *anomalyRepository) LastErrors(ctx context.Context, deploymentIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	out := map[uuid.UUID]string{}
+	if len(deploymentIDs) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		DeploymentID uuid.UUID
+		Message      string
+	}
+	err := r.db.WithContext(ctx).Raw(
+		`SELECT DISTINCT ON (deployment_id) deployment_id, message FROM deployment_logs
+		 WHERE deployment_id IN ? AND level = 'error' ORDER BY deployment_id, seq DESC`, deploymentIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list deployment errors failed")
+	}
+	for _, row := range rows {
+		out[row.DeploymentID] = row.Message
+	}
+	return out, nil
+}
+
+func (r *anomalyRepository) Record(ctx context.Context, a *models.Anomaly) (bool, error) {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "fingerprint"}}, DoNothing: true}).Create(a)
+	if res.Error != nil {
+		return false, appErr.Wrap(res.Error, appErr.CodeInternal, "record anomaly failed")
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *anomalyRepository) ListByProject(ctx context.Context, projectID uuid.UUID, filter AnomalyFilter) ([]models.Anomaly, error) {
+	q := r.db.WithContext(ctx).Where("project_id = ?", projectID)
+	if filter.Kind != "" {
+		q = q.Where("kind = ?", filter.Kind)
+	}
+	if filter.Since != nil {
+		q = q.Where("detected_at >= ?", *filter.Since)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	var out []models.Anomaly
+	if err := q.Order("detected_at DESC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list anomalies failed")
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ApprovalRepository interface {
+	// Create records a decision; a second decision by the same user on the
+	// same deployment is CodeAlreadyExists.
+	Create(ctx context.Context, a *models.DeploymentApproval) error
+	// ListByDeployment returns the decisions on a deployment, oldest first.
+	ListByDeployment(ctx context.Context, deploymentID uuid.UUID) ([]models.DeploymentApproval, error)
+}
+
+type approvalRepository struct {
+	db *gorm.DB
+}
+
+func NewApprovalRepository(db *gorm.DB) ApprovalRepository {
+	return &approvalRepository{db: db}
+}
+
+func (r *approvalRepository) Create(ctx context.Context, a *models.DeploymentApproval) error {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	if res.Error != nil {
+		return appErr.Wrap(res.Error, appErr.CodeInternal, "record approval failed")
+	}
+	if res.RowsAffected == 0 {
+		return appErr.New(appErr.CodeAlreadyExists, "user already decided on this deployment")
+	}
+	return nil
+}
+
+func (r *approvalRepository) ListByDeployment(ctx context.Context, deploymentID uuid.UUID) ([]models.DeploymentApproval, error) {
+	var out []models.DeploymentApproval
+	if err := r.db.WithContext(ctx).Where("deployment_id = ?", deploymentID).Order("created_at ASC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list approvals failed")
+	}
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type BudgetRepository interface {
+	CreateOverride(ctx context.Context, o *models.BudgetOverride) error
+	// ListOverrides returns a project's budget overrides, newest first.
+	ListOverrides(ctx context.Context, projectID uuid.UUID) ([]models.BudgetOverride, error)
+}
+
+type budgetRepository struct {
+	db *gorm.DB
+}
+
+func NewBudgetRepository(db *gorm.DB) BudgetRepository {
+	return &budgetRepository{db: db}
+}
+
+func (r *budgetRepository) CreateOverride(ctx context.Context, o *models.BudgetOverride) error {
+	if err := r.db.WithContext(ctx).Create(o).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "record budget override failed")
+	}
+	return nil
+}
+
+func (r *budgetRepository) ListOverrides(ctx context.Context, projectID uuid.UUID) ([]models.BudgetOverride, error) {
+	var out []models.BudgetOverride
+	if err := r.db.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at DESC, id DESC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list budget overrides failed")
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// CloudAccountRepository persists cloud accounts with their credentials
+// sealed by the keyring; callers always see plaintext credentials.
+type CloudAccountRepository interface {
+	BaseRepository[models.CloudAccount]
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.CloudAccount, error)
+}
+
+type cloudAccountRepository struct {
+	BaseRepository[models.CloudAccount]
+	db      *gorm.DB
+	keyring *utils.Keyring
+}
+
+func NewCloudAccountRepository(db *gorm.DB, keyring *utils.Keyring) CloudAccountRepository {
+	return &cloudAccountRepository{BaseRepository: NewBaseRepository[models.CloudAccount](db), db: db, keyring: keyring}
+}
+
+func (r *cloudAccountRepository) Create(ctx context.Context, obj *models.CloudAccount) error {
+	return r.sealed(obj, func() error { return r.BaseRepository.Create(ctx, obj) })
+}
+
+func (r *cloudAccountRepository) Update(ctx context.Context, obj *models.CloudAccount) error {
+	return r.sealed(obj, func() error { return r.BaseRepository.Update(ctx, obj) })
+}
+
+func (r *cloudAccountRepository) GetByID(ctx context.Context, id any, dest *models.CloudAccount) error {
+	if err := r.BaseRepository.GetByID(ctx, id, dest); err != nil {
+		return err
+	}
+	return r.open(dest)
+}
+
+func (r *cloudAccountRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.CloudAccount, error) {
+	var out []models.CloudAccount
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list cloud accounts failed")
+	}
+	for i := range out {
+		if err := r.open(&out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// sealed encrypts obj's credentials for the duration of write and restores
+// the plaintext afterwards so the caller's struct is left untouched.
+func (r *cloudAccountRepository) sealed(obj *models.CloudAccount, write func() error) error {
+	plain := obj.Credentials
+	ct, err := r.keyring.Seal(plain)
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "encrypt credentials failed")
+	}
+	obj.Credentials = ct
+	err = write()
+	obj.Credentials = plain
+	return err
+}
+
+func (r *cloudAccountRepository) open(obj *models.CloudAccount) error {
+	plain, err := r.keyring.Open(obj.Credentials)
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "decrypt credentials failed")
+	}
+	obj.Credentials = plain
+	return nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// LogQuery selects deployment log lines. Lines come in seq order starting
+// after AfterSeq; empty Levels and Search match every line.
+type LogQuery struct {
+	AfterSeq int64
+	Limit    int
+	Levels   []string
+	// Search is a case-insensitive substring of the message.
+	Search string
+}
+
+type DeploymentLogRepository interface {
+	// Append numbers entries with the deployment's next sequence numbers and
+	// stores them in one insert. Safe for concurrent writers.
+	Append(ctx context.Context, deploymentID uuid.UUID, entries []models.DeploymentLog) error
+	List(ctx context.Context, deploymentID uuid.UUID, q LogQuery) ([]models.DeploymentLog, error)
+}
+
+type deploymentLogRepository struct {
+	db *gorm.DB
+}
+
+func NewDeploymentLogRepository(db *gorm.DB) DeploymentLogRepository {
+	return &deploymentLogRepository{db: db}
+}
+
+func (r *deploymentLogRepository) Append(ctx context.Context, deploymentID uuid.UUID, entries []models.DeploymentLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// reserving the range on the deployment row serializes writers
+		var last int64
+		res := tx.Raw("UPDATE deployments SET log_seq = log_seq + ? WHERE id = ? RETURNING log_seq", len(entries), deploymentID).Scan(&last)
+		if res.Error != nil {
+			return appErr.Wrap(res.Error, appErr.CodeInternal, "reserve log sequence failed")
+		}
+		if res.RowsAffected == 0 {
+			return appErr.New(appErr.CodeNotFound, "deployment not found")
+		}
+
+		first := last - int64(len(entries)) + 1
+		for i := range entries {
+			entries[i].DeploymentID = deploymentID
+			entries[i].Seq = first + int64(i)
+		}
+		if err := tx.Create(&entries).Error; err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "append logs failed")
+		}
+		return nil
+	})
+}
+
+func (r *deploymentLogRepository) List(ctx context.Context, deploymentID uuid.UUID, q LogQuery) ([]models.DeploymentLog, error) {
+	db := r.db.WithContext(ctx).Where("deployment_id = ? AND seq > ?", deploymentID, q.AfterSeq)
+	if len(q.Levels) > 0 {
+		db = db.Where("level IN ?", q.Levels)
+	}
+	if q.Search != "" {
+		db = db.Where(`message ILIKE ? ESCAPE '\'`, "%"+escapeLike(q.Search)+"%")
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+	var out []models.DeploymentLog
+	if err := db.Order("seq ASC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list logs failed")
+	}
+	return out, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
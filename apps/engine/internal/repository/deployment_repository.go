@@ -2,18 +2,58 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/iac-studio/engine/internal/models"
 	appErr "github.com/iac-studio/engine/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DeploymentRepository interface {
 	BaseRepository[models.Deployment]
 	ListByProject(ctx context.Context, projectID uuid.UUID) ([]models.Deployment, error)
 	GetLatestByProject(ctx context.Context, projectID uuid.UUID, dest *models.Deployment) error
-	UpdateStatus(ctx context.Context, deploymentID uuid.UUID, status string) error
+	// UpdateStatus moves a deployment to status if models.DeploymentStatus
+	// allows the transition and records it, with the actor from the context,
+	// in the status history. An illegal transition is a CodeConflict error.
+	UpdateStatus(ctx context.Context, deploymentID uuid.UUID, status models.DeploymentStatus) error
+	// ListStatusHistory returns the deployment's status changes, oldest first.
+	ListStatusHistory(ctx context.Context, deploymentID uuid.UUID) ([]models.DeploymentStatusHistory, error)
+	// ListExpiring returns live deployments that expire at or before the
+	// given time. Deployments with a destroy in flight are left out: those
+	// queued for or running one, and those whose destroy failed, which is
+	// retried from the dead-letter queue.
+	ListExpiring(ctx context.Context, before time.Time) ([]models.Deployment, error)
+	// SetExpiry changes or clears (nil) the expiry and re-arms the warning.
+	SetExpiry(ctx context.Context, deploymentID uuid.UUID, expiresAt *time.Time) error
+	MarkExpiryWarned(ctx context.Context, deploymentID uuid.UUID, at time.Time) error
+	// SetCheckpoint records the last provisioning phase the worker completed.
+	SetCheckpoint(ctx context.Context, deploymentID uuid.UUID, checkpoint string) error
+	// ListQueue returns the project's deployments waiting for or running a
+	// provision, or waiting for a destroy, oldest first; the head of the list runs next. Plan-only
+	// deployments do not queue.
+	ListQueue(ctx context.Context, projectID uuid.UUID) ([]models.Deployment, error)
+	// SupersedeQueued marks every pending or awaiting-approval deployment of
+	// the project created before the given one, and not yet provisioned (no
+	// checkpoint and no state), as superseded and returns how many there were.
+	SupersedeQueued(ctx context.Context, projectID, deploymentID uuid.UUID) (int64, error)
+	// MarkApproved records when the deployment collected its approvals.
+	MarkApproved(ctx context.Context, deploymentID uuid.UUID, at time.Time) error
+}
+
+// queuedStatuses hold a place in the project queue. A deployment awaiting
+// approval keeps its place, so later ones cannot overtake it; a queued
+// destroy runs before deployments created after it.
+var queuedStatuses = []models.DeploymentStatus{
+	models.DeploymentPending,
+	models.DeploymentDestroyQueued,
+	models.DeploymentPlanning,
+	models.DeploymentAwaitingApproval,
+	models.DeploymentApproved,
+	models.DeploymentApplying,
 }
 
 type deploymentRepository struct {
@@ -43,10 +83,59 @@ func (r *deploymentRepository) GetLatestByProject(ctx context.Context, projectID
 	return nil
 }
 
-func (r *deploymentRepository) UpdateStatus(ctx context.Context, deploymentID uuid.UUID, status string) error {
-	res := r.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("status", status)
+func (r *deploymentRepository) UpdateStatus(ctx context.Context, deploymentID uuid.UUID, status models.DeploymentStatus) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cur models.Deployment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").First(&cur, "id = ?", deploymentID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return appErr.New(appErr.CodeNotFound, "deployment not found")
+			}
+			return appErr.Wrap(err, appErr.CodeInternal, "get deployment status failed")
+		}
+		if cur.Status == status {
+			return nil
+		}
+		if !cur.Status.CanTransitionTo(status) {
+			return appErr.New(appErr.CodeConflict, fmt.Sprintf("deployment cannot move from %s to %s", cur.Status, status))
+		}
+		if err := tx.Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("status", status).Error; err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "update deployment status failed")
+		}
+		h := models.DeploymentStatusHistory{DeploymentID: deploymentID, FromStatus: cur.Status, ToStatus: status, Actor: ActorFromContext(ctx)}
+		if err := tx.Create(&h).Error; err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "record deployment status failed")
+		}
+		return nil
+	})
+}
+
+func (r *deploymentRepository) ListStatusHistory(ctx context.Context, deploymentID uuid.UUID) ([]models.DeploymentStatusHistory, error) {
+	var out []models.DeploymentStatusHistory
+	if err := r.db.WithContext(ctx).Where("deployment_id = ?", deploymentID).Order("created_at ASC, id ASC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list deployment status history failed")
+	}
+	return out, nil
+}
+
+func (r *deploymentRepository) ListExpiring(ctx context.Context, before time.Time) ([]models.Deployment, error) {
+	var out []models.Deployment
+	err := r.db.WithContext(ctx).Omit("terraform_state", "outputs").
+		Where("expires_at IS NOT NULL AND expires_at <= ? AND status IN ?", before, []models.DeploymentStatus{models.DeploymentApplied, models.DeploymentFailed}).
+		Where("checkpoint IS NULL OR checkpoint <> ?", models.CheckpointDestroying).
+		Order("expires_at").Find(&out).Error
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list expiring deployments failed")
+	}
+	return out, nil
+}
+
+func (r *deploymentRepository) SetExpiry(ctx context.Context, deploymentID uuid.UUID, expiresAt *time.Time) error {
+	res := r.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Updates(map[string]interface{}{
+		"expires_at":       expiresAt,
+		"expiry_warned_at": nil,
+	})
 	if res.Error != nil {
-		return appErr.Wrap(res.Error, appErr.CodeInternal, "update deployment status failed")
+		return appErr.Wrap(res.Error, appErr.CodeInternal, "update deployment expiry failed")
 	}
 	if res.RowsAffected == 0 {
 		return appErr.New(appErr.CodeNotFound, "deployment not found")
@@ -54,4 +143,72 @@ func (r *deploymentRepository) UpdateStatus(ctx context.Context, deploymentID uu
 	return nil
 }
 
+func (r *deploymentRepository) MarkExpiryWarned(ctx context.Context, deploymentID uuid.UUID, at time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("expiry_warned_at", at).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "mark expiry warned failed")
+	}
+	return nil
+}
 
+func (r *deploymentRepository) SetCheckpoint(ctx context.Context, deploymentID uuid.UUID, checkpoint string) error {
+	if err := r.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("checkpoint", checkpoint).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "update deployment checkpoint failed")
+	}
+	return nil
+}
+
+func (r *deploymentRepository) ListQueue(ctx context.Context, projectID uuid.UUID) ([]models.Deployment, error) {
+	var out []models.Deployment
+	err := r.db.WithContext(ctx).Omit("terraform_state", "outputs", "plan").
+		Where("project_id = ? AND plan_only = false AND status IN ?", projectID, queuedStatuses).
+		Order("created_at ASC").Find(&out).Error
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list deployment queue failed")
+	}
+	return out, nil
+}
+
+func (r *deploymentRepository) SupersedeQueued(ctx context.Context, projectID, deploymentID uuid.UUID) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var queued []models.Deployment
+		err := tx.Model(&models.Deployment{}).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").
+			Where("project_id = ? AND id <> ? AND status IN ? AND plan_only = false", projectID, deploymentID, []models.DeploymentStatus{models.DeploymentPending, models.DeploymentAwaitingApproval}).
+			Where("checkpoint IS NULL OR checkpoint = ''").
+			Where("terraform_state IS NULL").
+			Where("created_at <= (?)", tx.Model(&models.Deployment{}).Select("created_at").Where("id = ?", deploymentID)).
+			Find(&queued).Error
+		if err != nil {
+			return err
+		}
+		if len(queued) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(queued))
+		actor := ActorFromContext(ctx)
+		history := make([]models.DeploymentStatusHistory, len(queued))
+		for i, d := range queued {
+			ids[i] = d.ID
+			history[i] = models.DeploymentStatusHistory{DeploymentID: d.ID, FromStatus: d.Status, ToStatus: models.DeploymentSuperseded, Actor: actor}
+		}
+		if err := tx.Model(&models.Deployment{}).Where("id IN ?", ids).Update("status", models.DeploymentSuperseded).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		n = int64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return 0, appErr.Wrap(err, appErr.CodeInternal, "supersede queued deployments failed")
+	}
+	return n, nil
+}
+
+func (r *deploymentRepository) MarkApproved(ctx context.Context, deploymentID uuid.UUID, at time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("approved_at", at).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "mark deployment approved failed")
+	}
+	return nil
+}
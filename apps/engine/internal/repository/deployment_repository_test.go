@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iac-studio/engine/internal/models"
+)
+
+func TestDeploymentRepository_ListExpiring(t *testing.T) {
+	db := testDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Deployment{}))
+	repo := NewDeploymentRepository(db)
+	ctx := context.Background()
+
+	// one project per test run, so rows left by other tests do not count
+	projectID := uuid.New()
+	expired := time.Now().Add(-time.Hour)
+	deployment := func(status models.DeploymentStatus, checkpoint string) uuid.UUID {
+		d := &models.Deployment{ID: uuid.New(), ProjectID: projectID, GraphID: uuid.New(), Status: status, Checkpoint: checkpoint, ExpiresAt: &expired}
+		require.NoError(t, db.Create(d).Error)
+		t.Cleanup(func() { db.Unscoped().Delete(&models.Deployment{}, "id = ?", d.ID) })
+		return d.ID
+	}
+	applied := deployment(models.DeploymentApplied, models.CheckpointApplied)
+	failedApply := deployment(models.DeploymentFailed, models.CheckpointApplying)
+	deployment(models.DeploymentDestroyQueued, models.CheckpointApplied)
+	deployment(models.DeploymentDestroying, models.CheckpointDestroying)
+	deployment(models.DeploymentFailed, models.CheckpointDestroying)
+	deployment(models.DeploymentPending, "")
+
+	items, err := repo.ListExpiring(ctx, time.Now())
+	require.NoError(t, err)
+	var got []uuid.UUID
+	for _, d := range items {
+		if d.ProjectID == projectID {
+			got = append(got, d.ID)
+		}
+	}
+	require.ElementsMatch(t, []uuid.UUID{applied, failedApply}, got)
+}
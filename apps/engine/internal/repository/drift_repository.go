@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// DriftCandidate is an applied deployment together with its project's drift
+// schedule, used to decide whether a check is due.
+type DriftCandidate struct {
+	DeploymentID   uuid.UUID
+	ProjectID      uuid.UUID
+	DriftSchedule  string
+	DriftCheckedAt *time.Time
+}
+
+type DriftRepository interface {
+	// Record stores report and mirrors its status on the deployment.
+	Record(ctx context.Context, report *models.DriftReport) error
+	ListByDeployment(ctx context.Context, deploymentID uuid.UUID, limit int) ([]models.DriftReport, error)
+	GetLatest(ctx context.Context, deploymentID uuid.UUID, dest *models.DriftReport) error
+	// ListCandidates returns every applied deployment of a non-archived project.
+	ListCandidates(ctx context.Context) ([]DriftCandidate, error)
+}
+
+type driftRepository struct {
+	db *gorm.DB
+}
+
+func NewDriftRepository(db *gorm.DB) DriftRepository {
+	return &driftRepository{db: db}
+}
+
+func (r *driftRepository) Record(ctx context.Context, report *models.DriftReport) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(report).Error; err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "create drift report failed")
+		}
+		res := tx.Model(&models.Deployment{}).Where("id = ?", report.DeploymentID).Updates(map[string]interface{}{
+			"drift_status":     report.Status,
+			"drift_checked_at": report.CreatedAt,
+		})
+		if res.Error != nil {
+			return appErr.Wrap(res.Error, appErr.CodeInternal, "update drift status failed")
+		}
+		if res.RowsAffected == 0 {
+			return appErr.New(appErr.CodeNotFound, "deployment not found")
+		}
+		return nil
+	})
+}
+
+func (r *driftRepository) ListByDeployment(ctx context.Context, deploymentID uuid.UUID, limit int) ([]models.DriftReport, error) {
+	var out []models.DriftReport
+	q := r.db.WithContext(ctx).Where("deployment_id = ?", deploymentID).Order("created_at DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list drift reports failed")
+	}
+	return out, nil
+}
+
+func (r *driftRepository) GetLatest(ctx context.Context, deploymentID uuid.UUID, dest *models.DriftReport) error {
+	if err := r.db.WithContext(ctx).Where("deployment_id = ?", deploymentID).Order("created_at DESC").First(dest).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return appErr.New(appErr.CodeNotFound, "no drift report found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get drift report failed")
+	}
+	return nil
+}
+
+func (r *driftRepository) ListCandidates(ctx context.Context) ([]DriftCandidate, error) {
+	var out []DriftCandidate
+	err := r.db.WithContext(ctx).Table("deployments AS d").
+		Select("d.id AS deployment_id, d.project_id, p.drift_schedule, d.drift_checked_at").
+		Joins("JOIN projects AS p ON p.id = d.project_id").
+		Where("d.status = ? AND d.deleted_at IS NULL AND p.deleted_at IS NULL AND p.archived = false", models.DeploymentApplied).
+		Scan(&out).Error
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list drift candidates failed")
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type PolicyRepository interface {
+	CreateRule(ctx context.Context, rule *models.PolicyRule) error
+	GetRule(ctx context.Context, id uuid.UUID, out *models.PolicyRule) error
+	UpdateRule(ctx context.Context, rule *models.PolicyRule) error
+	DeleteRule(ctx context.Context, id uuid.UUID) error
+	// ListRules returns ownerID's account-wide rules and, when projectID is
+	// set, that project's rules, oldest first.
+	ListRules(ctx context.Context, ownerID uuid.UUID, projectID *uuid.UUID) ([]models.PolicyRule, error)
+	// ReplaceFindings swaps a deployment's findings for those of a new
+	// evaluation and records its outcome on the deployment.
+	ReplaceFindings(ctx context.Context, deploymentID uuid.UUID, status string, findings []models.PolicyFinding) error
+	ListFindings(ctx context.Context, deploymentID uuid.UUID) ([]models.PolicyFinding, error)
+}
+
+type policyRepository struct {
+	db *gorm.DB
+}
+
+func NewPolicyRepository(db *gorm.DB) PolicyRepository {
+	return &policyRepository{db: db}
+}
+
+func (r *policyRepository) CreateRule(ctx context.Context, rule *models.PolicyRule) error {
+	if err := r.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "create policy rule failed")
+	}
+	return nil
+}
+
+func (r *policyRepository) GetRule(ctx context.Context, id uuid.UUID, out *models.PolicyRule) error {
+	if err := r.db.WithContext(ctx).First(out, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appErr.New(appErr.CodeNotFound, "policy rule not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get policy rule failed")
+	}
+	return nil
+}
+
+func (r *policyRepository) UpdateRule(ctx context.Context, rule *models.PolicyRule) error {
+	if err := r.db.WithContext(ctx).Save(rule).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "update policy rule failed")
+	}
+	return nil
+}
+
+func (r *policyRepository) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&models.PolicyRule{}, "id = ?", id)
+	if res.Error != nil {
+		return appErr.Wrap(res.Error, appErr.CodeInternal, "delete policy rule failed")
+	}
+	if res.RowsAffected == 0 {
+		return appErr.New(appErr.CodeNotFound, "policy rule not found")
+	}
+	return nil
+}
+
+func (r *policyRepository) ListRules(ctx context.Context, ownerID uuid.UUID, projectID *uuid.UUID) ([]models.PolicyRule, error) {
+	q := r.db.WithContext(ctx).Where("owner_id = ?", ownerID)
+	if projectID != nil {
+		q = q.Where("project_id IS NULL OR project_id = ?", *projectID)
+	} else {
+		q = q.Where("project_id IS NULL")
+	}
+	var out []models.PolicyRule
+	if err := q.Order("created_at ASC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list policy rules failed")
+	}
+	return out, nil
+}
+
+func (r *policyRepository) ReplaceFindings(ctx context.Context, deploymentID uuid.UUID, status string, findings []models.PolicyFinding) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("deployment_id = ?", deploymentID).Delete(&models.PolicyFinding{}).Error; err != nil {
+			return err
+		}
+		if len(findings) > 0 {
+			if err := tx.Create(&findings).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("policy_status", status).Error
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "record policy findings failed")
+	}
+	return nil
+}
+
+func (r *policyRepository) ListFindings(ctx context.Context, deploymentID uuid.UUID) ([]models.PolicyFinding, error) {
+	var out []models.PolicyFinding
+	if err := r.db.WithContext(ctx).Where("deployment_id = ?", deploymentID).Order("id ASC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list policy findings failed")
+	}
+	return out, nil
+}
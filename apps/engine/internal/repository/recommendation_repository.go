@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// RecommendationFilter selects recommendations; empty fields match all.
+type RecommendationFilter struct {
+	Source   string
+	Severity string
+	Status   string
+}
+
+type RecommendationRepository interface {
+	// SyncBySource reconciles a project's recommendations from source with
+	// recs by fingerprint. A known finding keeps its ID and status, except
+	// that an applied one that is found again reopens; open and snoozed
+	// findings that are gone are deleted, dismissed and applied ones stay
+	// as history.
+	SyncBySource(ctx context.Context, projectID uuid.UUID, source string, recs []models.Recommendation) error
+	// ListByProject returns a project's recommendations, newest first.
+	// Snoozes that ran out are reopened first.
+	ListByProject(ctx context.Context, projectID uuid.UUID, filter RecommendationFilter) ([]models.Recommendation, error)
+	Get(ctx context.Context, projectID, id uuid.UUID, out *models.Recommendation) error
+	Create(ctx context.Context, rec *models.Recommendation) error
+	Update(ctx context.Context, rec *models.Recommendation) error
+	Delete(ctx context.Context, projectID, id uuid.UUID) error
+}
+
+type recommendationRepository struct {
+	db *gorm.DB
+}
+
+func NewRecommendationRepository(db *gorm.DB) RecommendationRepository {
+	return &recommendationRepository{db: db}
+}
+
+func (r *recommendationRepository) SyncBySource(ctx context.Context, projectID uuid.UUID, source string, recs []models.Recommendation) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []models.Recommendation
+		if err := tx.Where("project_id = ? AND source = ?", projectID, source).Find(&existing).Error; err != nil {
+			return err
+		}
+		save, gone := syncRecommendations(existing, recs)
+		for i := range save {
+			write := tx.Save
+			if save[i].ID == uuid.Nil {
+				write = tx.Create
+			}
+			if err := write(&save[i]).Error; err != nil {
+				return err
+			}
+		}
+		if len(gone) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", gone).Delete(&models.Recommendation{}).Error
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "store recommendations failed")
+	}
+	return nil
+}
+
+// syncRecommendations matches recs to the existing recommendations by
+// fingerprint; see SyncBySource. It returns the recommendations to save,
+// new ones without an ID, and the IDs of those to delete.
+func syncRecommendations(existing, recs []models.Recommendation) ([]models.Recommendation, []uuid.UUID) {
+	known := make(map[string]models.Recommendation, len(existing))
+	for _, e := range existing {
+		if e.Fingerprint != "" {
+			known[e.Fingerprint] = e
+		}
+	}
+
+	save := make([]models.Recommendation, 0, len(recs))
+	found := make(map[string]bool, len(recs))
+	for _, rec := range recs {
+		e, ok := known[rec.Fingerprint]
+		if !ok || rec.Fingerprint == "" {
+			rec.ID = uuid.Nil
+			save = append(save, rec)
+			continue
+		}
+		found[rec.Fingerprint] = true
+		rec.ID, rec.CreatedAt = e.ID, e.CreatedAt
+		rec.Status, rec.SnoozedUntil, rec.AppliedVersion = e.Status, e.SnoozedUntil, e.AppliedVersion
+		if e.Status == models.RecommendationApplied {
+			rec.Status, rec.AppliedVersion = models.RecommendationOpen, nil
+		}
+		save = append(save, rec)
+	}
+
+	var gone []uuid.UUID
+	for _, e := range existing {
+		if e.Fingerprint != "" && found[e.Fingerprint] {
+			continue
+		}
+		if e.Status == models.RecommendationOpen || e.Status == models.RecommendationSnoozed {
+			gone = append(gone, e.ID)
+		}
+	}
+	return save, gone
+}
+
+func (r *recommendationRepository) ListByProject(ctx context.Context, projectID uuid.UUID, filter RecommendationFilter) ([]models.Recommendation, error) {
+	db := r.db.WithContext(ctx)
+	wake := db.Model(&models.Recommendation{}).
+		Where("project_id = ? AND status = ? AND snoozed_until <= ?", projectID, models.RecommendationSnoozed, time.Now()).
+		Updates(map[string]interface{}{"status": models.RecommendationOpen, "snoozed_until": nil})
+	if wake.Error != nil {
+		return nil, appErr.Wrap(wake.Error, appErr.CodeInternal, "reopen snoozed recommendations failed")
+	}
+
+	q := db.Where("project_id = ?", projectID)
+	if filter.Source != "" {
+		q = q.Where("source = ?", filter.Source)
+	}
+	if filter.Severity != "" {
+		q = q.Where("severity = ?", filter.Severity)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	var out []models.Recommendation
+	if err := q.Order("created_at DESC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list recommendations failed")
+	}
+	return out, nil
+}
+
+func (r *recommendationRepository) Get(ctx context.Context, projectID, id uuid.UUID, out *models.Recommendation) error {
+	if err := r.db.WithContext(ctx).First(out, "id = ? AND project_id = ?", id, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appErr.New(appErr.CodeNotFound, "recommendation not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get recommendation failed")
+	}
+	return nil
+}
+
+func (r *recommendationRepository) Create(ctx context.Context, rec *models.Recommendation) error {
+	if err := r.db.WithContext(ctx).Create(rec).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "create recommendation failed")
+	}
+	return nil
+}
+
+func (r *recommendationRepository) Update(ctx context.Context, rec *models.Recommendation) error {
+	if err := r.db.WithContext(ctx).Save(rec).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "update recommendation failed")
+	}
+	return nil
+}
+
+func (r *recommendationRepository) Delete(ctx context.Context, projectID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&models.Recommendation{}, "id = ? AND project_id = ?", id, projectID)
+	if res.Error != nil {
+		return appErr.Wrap(res.Error, appErr.CodeInternal, "delete recommendation failed")
+	}
+	if res.RowsAffected == 0 {
+		return appErr.New(appErr.CodeNotFound, "recommendation not found")
+	}
+	return nil
+}
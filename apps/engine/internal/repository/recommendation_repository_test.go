@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iac-studio/engine/internal/models"
+)
+
+func rec(fingerprint, status string) models.Recommendation {
+	return models.Recommendation{
+		ID:          uuid.New(),
+		Source:      models.RecommendationSourceSecurity,
+		Severity:    models.RecommendationSeverityHigh,
+		Status:      status,
+		Title:       "finding " + fingerprint,
+		Fingerprint: fingerprint,
+		CreatedAt:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSyncRecommendations(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	version := 3
+	open := rec("open", models.RecommendationOpen)
+	dismissed := rec("dismissed", models.RecommendationDismissed)
+	applied := rec("applied", models.RecommendationApplied)
+	applied.AppliedVersion = &version
+	snoozed := rec("snoozed", models.RecommendationSnoozed)
+	snoozed.SnoozedUntil = &until
+	goneOpen := rec("gone-open", models.RecommendationOpen)
+	goneSnoozed := rec("gone-snoozed", models.RecommendationSnoozed)
+	goneDismissed := rec("gone-dismissed", models.RecommendationDismissed)
+	goneApplied := rec("gone-applied", models.RecommendationApplied)
+	existing := []models.Recommendation{open, dismissed, applied, snoozed, goneOpen, goneSnoozed, goneDismissed, goneApplied}
+
+	found := func(fingerprint string) models.Recommendation {
+		r := rec(fingerprint, models.RecommendationOpen)
+		r.Title = "rerun " + fingerprint
+		r.CreatedAt = time.Time{}
+		return r
+	}
+	unfingerprinted := found("")
+	save, gone := syncRecommendations(existing, []models.Recommendation{
+		found("open"), found("dismissed"), found("applied"), found("snoozed"), found("new"), unfingerprinted,
+	})
+
+	require.Len(t, save, 6)
+	byFingerprint := map[string]models.Recommendation{}
+	for _, r := range save {
+		byFingerprint[r.Fingerprint] = r
+		require.Equal(t, "rerun "+r.Fingerprint, r.Title, "the finding's new content is saved")
+	}
+
+	// known findings keep their ID, age and status
+	for _, e := range []models.Recommendation{open, dismissed, snoozed} {
+		got := byFingerprint[e.Fingerprint]
+		require.Equal(t, e.ID, got.ID, e.Fingerprint)
+		require.Equal(t, e.CreatedAt, got.CreatedAt, e.Fingerprint)
+		require.Equal(t, e.Status, got.Status, e.Fingerprint)
+	}
+	require.Equal(t, &until, byFingerprint["snoozed"].SnoozedUntil)
+
+	// an applied finding that is found again reopens
+	require.Equal(t, applied.ID, byFingerprint["applied"].ID)
+	require.Equal(t, models.RecommendationOpen, byFingerprint["applied"].Status)
+	require.Nil(t, byFingerprint["applied"].AppliedVersion)
+
+	// new findings, and those without a fingerprint, are created
+	require.Equal(t, uuid.Nil, byFingerprint["new"].ID)
+	require.Equal(t, uuid.Nil, byFingerprint[""].ID)
+
+	// open and snoozed findings that are gone are resolved; the others stay
+	require.ElementsMatch(t, []uuid.UUID{goneOpen.ID, goneSnoozed.ID}, gone)
+}
+
+func TestRecommendationRepository(t *testing.T) {
+	db := testDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Recommendation{}))
+	repo := NewRecommendationRepository(db)
+	ctx := context.Background()
+	projectID := uuid.New()
+	t.Cleanup(func() { db.Where("project_id = ?", projectID).Delete(&models.Recommendation{}) })
+
+	t.Run("expired snoozes reopen when listed", func(t *testing.T) {
+		past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+		expired := rec("expired", models.RecommendationSnoozed)
+		expired.ProjectID, expired.SnoozedUntil = projectID, &past
+		asleep := rec("asleep", models.RecommendationSnoozed)
+		asleep.ProjectID, asleep.SnoozedUntil = projectID, &future
+		require.NoError(t, repo.Create(ctx, &expired))
+		require.NoError(t, repo.Create(ctx, &asleep))
+
+		out, err := repo.ListByProject(ctx, projectID, RecommendationFilter{Status: models.RecommendationSnoozed})
+		require.NoError(t, err)
+		require.Len(t, out, 1)
+		require.Equal(t, asleep.ID, out[0].ID)
+
+		var woken models.Recommendation
+		require.NoError(t, repo.Get(ctx, projectID, expired.ID, &woken))
+		require.Equal(t, models.RecommendationOpen, woken.Status)
+		require.Nil(t, woken.SnoozedUntil)
+	})
+
+	t.Run("sync upserts by fingerprint and resolves what is gone", func(t *testing.T) {
+		source := models.RecommendationSourceCost
+		first := []models.Recommendation{rec("keep", models.RecommendationOpen), rec("drop", models.RecommendationOpen)}
+		for i := range first {
+			first[i].ProjectID, first[i].Source = projectID, source
+		}
+		require.NoError(t, repo.SyncBySource(ctx, projectID, source, first))
+		out, err := repo.ListByProject(ctx, projectID, RecommendationFilter{Source: source})
+		require.NoError(t, err)
+		require.Len(t, out, 2)
+		ids := map[string]uuid.UUID{}
+		for _, r := range out {
+			ids[r.Fingerprint] = r.ID
+		}
+
+		keep := rec("keep", models.RecommendationOpen)
+		keep.ProjectID, keep.Source, keep.Title = projectID, source, "updated"
+		added := rec("added", models.RecommendationOpen)
+		added.ProjectID, added.Source = projectID, source
+		require.NoError(t, repo.SyncBySource(ctx, projectID, source, []models.Recommendation{keep, added}))
+
+		out, err = repo.ListByProject(ctx, projectID, RecommendationFilter{Source: source})
+		require.NoError(t, err)
+		require.Len(t, out, 2)
+		for _, r := range out {
+			require.NotEqual(t, "drop", r.Fingerprint)
+			if r.Fingerprint == "keep" {
+				require.Equal(t, ids["keep"], r.ID)
+				require.Equal(t, "updated", r.Title)
+			}
+		}
+	})
+}
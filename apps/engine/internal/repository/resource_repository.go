@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ResourceFilter narrows an inventory listing; empty fields match anything.
+type ResourceFilter struct {
+	Type   string
+	NodeID string
+}
+
+type ResourceRepository interface {
+	// Sync makes the live rows of a deployment match resources. Rows are
+	// matched by address: existing ones are updated in place, new ones
+	// created and rows whose resource is gone are soft-deleted.
+	Sync(ctx context.Context, deploymentID uuid.UUID, resources []models.Resource) error
+	ListByDeployment(ctx context.Context, deploymentID uuid.UUID) ([]models.Resource, error)
+	ListByProject(ctx context.Context, projectID uuid.UUID, filter ResourceFilter) ([]models.Resource, error)
+}
+
+type resourceRepository struct {
+	db *gorm.DB
+}
+
+func NewResourceRepository(db *gorm.DB) ResourceRepository {
+	return &resourceRepository{db: db}
+}
+
+func (r *resourceRepository) Sync(ctx context.Context, deploymentID uuid.UUID, resources []models.Resource) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []models.Resource
+		if err := tx.Where("deployment_id = ?", deploymentID).Find(&existing).Error; err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "list resources failed")
+		}
+		byAddress := make(map[string]models.Resource, len(existing))
+		for _, e := range existing {
+			byAddress[e.Address] = e
+		}
+
+		for i := range resources {
+			res := &resources[i]
+			res.DeploymentID = deploymentID
+			if e, ok := byAddress[res.Address]; ok {
+				delete(byAddress, res.Address)
+				res.ID = e.ID
+				res.CreatedAt = e.CreatedAt
+				err := tx.Model(&models.Resource{}).Where("id = ?", e.ID).Updates(map[string]interface{}{
+					"project_id":    res.ProjectID,
+					"resource_type": res.ResourceType,
+					"resource_name": res.ResourceName,
+					"cloud_id":      res.CloudID,
+					"node_id":       res.NodeID,
+					"properties":    res.Properties,
+				}).Error
+				if err != nil {
+					return appErr.Wrap(err, appErr.CodeInternal, "update resource failed")
+				}
+				continue
+			}
+			if err := tx.Create(res).Error; err != nil {
+				return appErr.Wrap(err, appErr.CodeInternal, "create resource failed")
+			}
+		}
+
+		// whatever is left no longer exists
+		if len(byAddress) > 0 {
+			ids := make([]uuid.UUID, 0, len(byAddress))
+			for _, e := range byAddress {
+				ids = append(ids, e.ID)
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&models.Resource{}).Error; err != nil {
+				return appErr.Wrap(err, appErr.CodeInternal, "delete resources failed")
+			}
+		}
+		return nil
+	})
+}
+
+func (r *resourceRepository) ListByDeployment(ctx context.Context, deploymentID uuid.UUID) ([]models.Resource, error) {
+	var out []models.Resource
+	if err := r.db.WithContext(ctx).Where("deployment_id = ?", deploymentID).Order("address ASC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list resources failed")
+	}
+	return out, nil
+}
+
+func (r *resourceRepository) ListByProject(ctx context.Context, projectID uuid.UUID, filter ResourceFilter) ([]models.Resource, error) {
+	q := r.db.WithContext(ctx).Where("project_id = ?", projectID)
+	if filter.Type != "" {
+		q = q.Where("resource_type = ?", filter.Type)
+	}
+	if filter.NodeID != "" {
+		q = q.Where("node_id = ?", filter.NodeID)
+	}
+	var out []models.Resource
+	if err := q.Order("deployment_id, address ASC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list resources failed")
+	}
+	return out, nil
+}
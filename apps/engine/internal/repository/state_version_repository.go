@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StateVersionRepository interface {
+	// Record stores state as a new version and makes it the deployment's
+	// current state. Writing the same bytes as the latest version is a no-op.
+	Record(ctx context.Context, deploymentID uuid.UUID, state []byte) (*models.StateVersion, error)
+	// Restore copies an earlier version into a new version and makes it current.
+	Restore(ctx context.Context, deploymentID uuid.UUID, serial int) (*models.StateVersion, error)
+	ListByDeployment(ctx context.Context, deploymentID uuid.UUID) ([]models.StateVersion, error)
+	// GetBySerial loads a version with its state decrypted.
+	GetBySerial(ctx context.Context, deploymentID uuid.UUID, serial int, dest *models.StateVersion) error
+	// GetCurrent returns the deployment's decrypted current state.
+	GetCurrent(ctx context.Context, deploymentID uuid.UUID) ([]byte, error)
+}
+
+// stateVersionRepository seals state with keyring before it is written to
+// either state_versions or deployments.terraform_state. A nil keyring stores
+// plaintext.
+type stateVersionRepository struct {
+	db      *gorm.DB
+	keyring *utils.Keyring
+}
+
+func NewStateVersionRepository(db *gorm.DB, keyring *utils.Keyring) StateVersionRepository {
+	return &stateVersionRepository{db: db, keyring: keyring}
+}
+
+func (r *stateVersionRepository) Record(ctx context.Context, deploymentID uuid.UUID, state []byte) (*models.StateVersion, error) {
+	var out *models.StateVersion
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		v, err := r.write(ctx, tx, deploymentID, state, nil)
+		out = v
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *stateVersionRepository) Restore(ctx context.Context, deploymentID uuid.UUID, serial int) (*models.StateVersion, error) {
+	var out *models.StateVersion
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var src models.StateVersion
+		if err := tx.Where("deployment_id = ? AND serial = ?", deploymentID, serial).First(&src).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return appErr.New(appErr.CodeNotFound, "state version not found")
+			}
+			return appErr.Wrap(err, appErr.CodeInternal, "get state version failed")
+		}
+		state, err := r.keyring.Open(src.State)
+		if err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "decrypt state version failed")
+		}
+		v, err := r.write(ctx, tx, deploymentID, state, &serial)
+		out = v
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// write appends a version inside tx. The deployment row is locked so that
+// concurrent writers get consecutive serials.
+func (r *stateVersionRepository) write(ctx context.Context, tx *gorm.DB, deploymentID uuid.UUID, state []byte, restoredFrom *int) (*models.StateVersion, error) {
+	if state == nil {
+		state = []byte("null")
+	}
+
+	var d models.Deployment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&d, "id = ?", deploymentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, appErr.New(appErr.CodeNotFound, "deployment not found")
+		}
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "lock deployment failed")
+	}
+
+	sum := utils.SumSHA256(state)
+	checksum := hex.EncodeToString(sum[:])
+
+	var latest models.StateVersion
+	hasLatest := true
+	if err := tx.Where("deployment_id = ?", deploymentID).Order("serial DESC").Omit("state").First(&latest).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "get latest state version failed")
+		}
+		hasLatest = false
+	}
+	if hasLatest && restoredFrom == nil && latest.Checksum == checksum {
+		return &latest, nil
+	}
+
+	sealed, err := r.keyring.Seal(state)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "encrypt state failed")
+	}
+
+	v := &models.StateVersion{
+		DeploymentID: deploymentID,
+		Serial:       1,
+		Lineage:      stateLineage(state),
+		Checksum:     checksum,
+		Size:         len(state),
+		Author:       ActorFromContext(ctx),
+		RestoredFrom: restoredFrom,
+		State:        datatypes.JSON(sealed),
+	}
+	if hasLatest {
+		v.Serial = latest.Serial + 1
+		if v.Lineage == "" {
+			v.Lineage = latest.Lineage
+		}
+	}
+	if v.Lineage == "" {
+		v.Lineage = uuid.NewString()
+	}
+
+	if err := tx.Create(v).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "create state version failed")
+	}
+	if err := tx.Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("terraform_state", datatypes.JSON(sealed)).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "update terraform state failed")
+	}
+	return v, nil
+}
+
+func (r *stateVersionRepository) ListByDeployment(ctx context.Context, deploymentID uuid.UUID) ([]models.StateVersion, error) {
+	var out []models.StateVersion
+	if err := r.db.WithContext(ctx).Omit("state").Where("deployment_id = ?", deploymentID).Order("serial DESC").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list state versions failed")
+	}
+	return out, nil
+}
+
+func (r *stateVersionRepository) GetBySerial(ctx context.Context, deploymentID uuid.UUID, serial int, dest *models.StateVersion) error {
+	if err := r.db.WithContext(ctx).Where("deployment_id = ? AND serial = ?", deploymentID, serial).First(dest).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return appErr.New(appErr.CodeNotFound, "state version not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get state version failed")
+	}
+	state, err := r.keyring.Open(dest.State)
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "decrypt state version failed")
+	}
+	dest.State = datatypes.JSON(state)
+	return nil
+}
+
+func (r *stateVersionRepository) GetCurrent(ctx context.Context, deploymentID uuid.UUID) ([]byte, error) {
+	var d models.Deployment
+	if err := r.db.WithContext(ctx).Select("id", "terraform_state").First(&d, "id = ?", deploymentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, appErr.New(appErr.CodeNotFound, "deployment not found")
+		}
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "get terraform state failed")
+	}
+	if len(d.TerraformState) == 0 {
+		return nil, nil
+	}
+	state, err := r.keyring.Open(d.TerraformState)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "decrypt terraform state failed")
+	}
+	return state, nil
+}
+
+// stateLineage returns the lineage of a raw Terraform state, if it has one.
+func stateLineage(state []byte) string {
+	var s struct {
+		Lineage string `json:"lineage"`
+	}
+	if err := json.Unmarshal(state, &s); err != nil {
+		return ""
+	}
+	return s.Lineage
+}
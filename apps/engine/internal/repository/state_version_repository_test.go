@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/utils"
+)
+
+func TestStateLineage(t *testing.T) {
+	require.Equal(t, "5f0c", stateLineage([]byte(`{"version":4,"serial":7,"lineage":"5f0c"}`)))
+	require.Empty(t, stateLineage([]byte(`{"version":4}`)))
+	require.Empty(t, stateLineage([]byte(`null`)))
+	require.Empty(t, stateLineage([]byte(`not json`)))
+}
+
+func TestStateVersionRepository(t *testing.T) {
+	db := testDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Deployment{}, &models.StateVersion{}))
+	keyring, err := utils.NewKeyring(map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)}, "k1")
+	require.NoError(t, err)
+	repo := NewStateVersionRepository(db, keyring)
+	ctx := WithActor(context.Background(), "user:test")
+
+	deployment := func() uuid.UUID {
+		d := &models.Deployment{ID: uuid.New(), ProjectID: uuid.New(), GraphID: uuid.New(), Status: models.DeploymentApplied}
+		require.NoError(t, db.Create(d).Error)
+		t.Cleanup(func() {
+			db.Where("deployment_id = ?", d.ID).Delete(&models.StateVersion{})
+			db.Delete(&models.Deployment{}, "id = ?", d.ID)
+		})
+		return d.ID
+	}
+	first := []byte(`{"version":4,"serial":1,"lineage":"lin-1","resources":[{"password":"hunter2"}]}`)
+	second := []byte(`{"version":4,"serial":2,"lineage":"lin-1","resources":[]}`)
+
+	t.Run("record appends versions and skips unchanged state", func(t *testing.T) {
+		id := deployment()
+		v1, err := repo.Record(ctx, id, first)
+		require.NoError(t, err)
+		require.Equal(t, 1, v1.Serial)
+		require.Equal(t, "lin-1", v1.Lineage)
+		require.Equal(t, "user:test", v1.Author)
+		require.Equal(t, len(first), v1.Size)
+
+		again, err := repo.Record(ctx, id, first)
+		require.NoError(t, err)
+		require.Equal(t, v1.ID, again.ID, "recording the same state is a no-op")
+
+		v2, err := repo.Record(ctx, id, second)
+		require.NoError(t, err)
+		require.Equal(t, 2, v2.Serial)
+
+		versions, err := repo.ListByDeployment(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		require.Equal(t, 2, versions[0].Serial)
+
+		current, err := repo.GetCurrent(ctx, id)
+		require.NoError(t, err)
+		require.JSONEq(t, string(second), string(current))
+
+		var stored models.StateVersion
+		require.NoError(t, db.Where("deployment_id = ? AND serial = 1", id).First(&stored).Error)
+		require.NotContains(t, string(stored.State), "hunter2", "state is sealed at rest")
+		var got models.StateVersion
+		require.NoError(t, repo.GetBySerial(ctx, id, 1, &got))
+		require.JSONEq(t, string(first), string(got.State))
+	})
+
+	t.Run("restore copies an older version into a new current one", func(t *testing.T) {
+		id := deployment()
+		_, err := repo.Record(ctx, id, first)
+		require.NoError(t, err)
+		_, err = repo.Record(ctx, id, second)
+		require.NoError(t, err)
+
+		v, err := repo.Restore(ctx, id, 1)
+		require.NoError(t, err)
+		require.Equal(t, 3, v.Serial)
+		require.NotNil(t, v.RestoredFrom)
+		require.Equal(t, 1, *v.RestoredFrom)
+
+		current, err := repo.GetCurrent(ctx, id)
+		require.NoError(t, err)
+		require.JSONEq(t, string(first), string(current))
+
+		_, err = repo.Restore(ctx, id, 9)
+		require.True(t, appErr.IsCode(err, appErr.CodeNotFound), "got %v", err)
+	})
+
+	t.Run("restore does not reach into another deployment", func(t *testing.T) {
+		a, b := deployment(), deployment()
+		_, err := repo.Record(ctx, a, first)
+		require.NoError(t, err)
+		_, err = repo.Record(ctx, a, second)
+		require.NoError(t, err)
+		_, err = repo.Record(ctx, b, second)
+		require.NoError(t, err)
+
+		// serial 2 exists only for a
+		_, err = repo.Restore(ctx, b, 2)
+		require.True(t, appErr.IsCode(err, appErr.CodeNotFound), "got %v", err)
+
+		versions, err := repo.ListByDeployment(ctx, b)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		current, err := repo.GetCurrent(ctx, b)
+		require.NoError(t, err)
+		require.JSONEq(t, string(second), string(current))
+	})
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// WebhookRepository persists webhooks with their signing secrets sealed by
+// the keyring; callers always see plaintext secrets.
+type WebhookRepository interface {
+	List(ctx context.Context, projectID uuid.UUID) ([]models.Webhook, error)
+	// ListSubscribed returns the enabled webhooks of a project that take
+	// events of type typ.
+	ListSubscribed(ctx context.Context, projectID uuid.UUID, typ string) ([]models.Webhook, error)
+	Get(ctx context.Context, projectID, id uuid.UUID, out *models.Webhook) error
+	Create(ctx context.Context, w *models.Webhook) error
+	Update(ctx context.Context, w *models.Webhook) error
+	Delete(ctx context.Context, projectID, id uuid.UUID) error
+
+	CreateDelivery(ctx context.Context, d *models.WebhookDelivery) error
+	UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error
+	// GetDelivery loads a delivery by ID alone, for the delivery task.
+	GetDelivery(ctx context.Context, id uuid.UUID, out *models.WebhookDelivery) error
+	// ListDeliveries returns a webhook's deliveries, newest first, without
+	// their payloads.
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db      *gorm.DB
+	keyring *utils.Keyring
+}
+
+func NewWebhookRepository(db *gorm.DB, keyring *utils.Keyring) WebhookRepository {
+	return &webhookRepository{db: db, keyring: keyring}
+}
+
+func (r *webhookRepository) List(ctx context.Context, projectID uuid.UUID) ([]models.Webhook, error) {
+	var out []models.Webhook
+	if err := r.db.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at").Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list webhooks failed")
+	}
+	return out, r.openAll(out)
+}
+
+func (r *webhookRepository) ListSubscribed(ctx context.Context, projectID uuid.UUID, typ string) ([]models.Webhook, error) {
+	var out []models.Webhook
+	err := r.db.WithContext(ctx).
+		Where("project_id = ? AND enabled AND events @> ?", projectID, datatypes.JSON(`["`+typ+`"]`)).
+		Order("created_at").Find(&out).Error
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list webhooks failed")
+	}
+	return out, r.openAll(out)
+}
+
+func (r *webhookRepository) Get(ctx context.Context, projectID, id uuid.UUID, out *models.Webhook) error {
+	if err := r.db.WithContext(ctx).First(out, "id = ? AND project_id = ?", id, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appErr.New(appErr.CodeNotFound, "webhook not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get webhook failed")
+	}
+	return r.open(out)
+}
+
+func (r *webhookRepository) Create(ctx context.Context, w *models.Webhook) error {
+	return r.sealed(w, func() error {
+		if err := r.db.WithContext(ctx).Create(w).Error; err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "create webhook failed")
+		}
+		return nil
+	})
+}
+
+func (r *webhookRepository) Update(ctx context.Context, w *models.Webhook) error {
+	return r.sealed(w, func() error {
+		if err := r.db.WithContext(ctx).Save(w).Error; err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "update webhook failed")
+		}
+		return nil
+	})
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, projectID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Delete(&models.Webhook{}, "id = ? AND project_id = ?", id, projectID)
+	if res.Error != nil {
+		return appErr.Wrap(res.Error, appErr.CodeInternal, "delete webhook failed")
+	}
+	if res.RowsAffected == 0 {
+		return appErr.New(appErr.CodeNotFound, "webhook not found")
+	}
+	return nil
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	if err := r.db.WithContext(ctx).Create(d).Error; err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "create webhook delivery failed")
+	}
+	return nil
+}
+
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	err := r.db.WithContext(ctx).Model(d).Updates(map[string]interface{}{
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"response_status": d.ResponseStatus,
+		"error":           d.Error,
+		"delivered_at":    d.DeliveredAt,
+	}).Error
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "update webhook delivery failed")
+	}
+	return nil
+}
+
+func (r *webhookRepository) GetDelivery(ctx context.Context, id uuid.UUID, out *models.WebhookDelivery) error {
+	if err := r.db.WithContext(ctx).First(out, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return appErr.New(appErr.CodeNotFound, "webhook delivery not found")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "get webhook delivery failed")
+	}
+	return nil
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	q := r.db.WithContext(ctx).Omit("payload").Where("webhook_id = ?", webhookID).Order("created_at DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var out []models.WebhookDelivery
+	if err := q.Find(&out).Error; err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "list webhook deliveries failed")
+	}
+	return out, nil
+}
+
+// sealed encrypts w's secret for the duration of write and restores the
+// plaintext afterwards so the caller's struct is left untouched.
+func (r *webhookRepository) sealed(w *models.Webhook, write func() error) error {
+	plain := w.Secret
+	ct, err := r.keyring.Seal([]byte(plain))
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "encrypt webhook secret failed")
+	}
+	w.Secret = string(ct)
+	err = write()
+	w.Secret = plain
+	return err
+}
+
+func (r *webhookRepository) open(w *models.Webhook) error {
+	plain, err := r.keyring.Open([]byte(w.Secret))
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInternal, "decrypt webhook secret failed")
+	}
+	w.Secret = string(plain)
+	return nil
+}
+
+func (r *webhookRepository) openAll(hooks []models.Webhook) error {
+	for i := range hooks {
+		if err := r.open(&hooks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,5 +1,397 @@
-//go:build ignore
-
 package services
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/ai/advisor"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// MaxGraphPromptLength bounds infrastructure descriptions.
+const MaxGraphPromptLength = 4000
+
+// Limits of what a deployment explanation sends to the model.
+const (
+	ExplainLogLines      = 200 // last log lines of a failure
+	ExplainLogLineLength = 500 // longer lines are cut
+	ExplainPlanChanges   = 200 // resource changes of a plan
+)
+
+// AIService runs the model-backed features. Without a configured model
+// every call but Status fails with CodeUnavailable.
+type AIService interface {
+	Status() AIStatus
+	// GenerateGraph proposes a graph for a plain-language description and
+	// saves it as a new graph version when input.Save is set.
+	GenerateGraph(ctx context.Context, projectID, userID uuid.UUID, input *GenerateGraphInput) (*GeneratedGraph, error)
+	// SaveGraph validates a proposed graph with the compiler and saves it
+	// as a new graph version.
+	SaveGraph(ctx context.Context, projectID, userID uuid.UUID, data *GraphData) (*models.ProjectGraph, error)
+	// ExplainDeployment explains a failed deployment, with patches to fix
+	// it, or else the deployment's plan.
+	ExplainDeployment(ctx context.Context, deploymentID, userID uuid.UUID) (*DeploymentExplanation, error)
+	// ApplyPatch applies patches to the current graph and saves the result
+	// as a new graph version.
+	ApplyPatch(ctx context.Context, projectID, userID uuid.UUID, input *ApplyPatchInput) (*models.ProjectGraph, error)
+}
+
+// AIStatus tells whether the AI features are available.
+type AIStatus struct {
+	Status   string `json:"status" enums:"ready,disabled"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// GenerateGraphInput describes the infrastructure to generate.
+type GenerateGraphInput struct {
+	Prompt string
+	Save   bool
+}
+
+// GeneratedGraph is a proposed graph. Version is set once it is saved.
+type GeneratedGraph struct {
+	Graph    GraphData `json:"graph"`
+	Summary  string    `json:"summary"`
+	Attempts int       `json:"attempts"`
+	// InputTokens and OutputTokens are the model usage of all attempts.
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	Version      int `json:"version,omitempty"`
+}
+
+// Explanation kinds.
+const (
+	ExplainPlan    = "plan"
+	ExplainFailure = "failure"
+)
+
+// DeploymentExplanation explains a deployment's plan or its failure.
+// Patches apply to GraphVersion, the graph the deployment ran.
+type DeploymentExplanation struct {
+	DeploymentID uuid.UUID            `json:"deployment_id"`
+	Kind         string               `json:"kind" enums:"plan,failure"`
+	GraphVersion int                  `json:"graph_version"`
+	Summary      string               `json:"summary"`
+	Cause        string               `json:"cause,omitempty"`
+	Risks        []string             `json:"risks,omitempty"`
+	Steps        []string             `json:"steps,omitempty"`
+	Patches      []compiler.NodePatch `json:"patches,omitempty"`
+	Attempts     int                  `json:"attempts"`
+	InputTokens  int                  `json:"input_tokens"`
+	OutputTokens int                  `json:"output_tokens"`
+}
+
+// ApplyPatchInput patches graph version GraphVersion, which must be the
+// current one so a patch never silently lands on a graph it was not made
+// for.
+type ApplyPatchInput struct {
+	GraphVersion int
+	Patches      []compiler.NodePatch
+}
+
+type aiService struct {
+	projectRepo repository.ProjectRepository
+	deployRepo  repository.DeploymentRepository
+	graphRepo   repository.GraphRepository
+	logRepo     repository.DeploymentLogRepository
+	projects    ProjectService
+	// advisor is nil when no model is configured
+	advisor  *advisor.Advisor
+	provider string
+	model    string
+}
+
+func NewAIService(projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, graphRepo repository.GraphRepository, logRepo repository.DeploymentLogRepository, projects ProjectService, adv *advisor.Advisor, provider, model string) AIService {
+	return &aiService{
+		projectRepo: projectRepo,
+		deployRepo:  deployRepo,
+		graphRepo:   graphRepo,
+		logRepo:     logRepo,
+		projects:    projects,
+		advisor:     adv,
+		provider:    provider,
+		model:       model,
+	}
+}
+
+var _ AIService = (*aiService)(nil)
+
+func (s *aiService) Status() AIStatus {
+	if s.advisor == nil {
+		return AIStatus{Status: "disabled"}
+	}
+	return AIStatus{Status: "ready", Provider: s.provider, Model: s.model}
+}
+
+func (s *aiService) GenerateGraph(ctx context.Context, projectID, userID uuid.UUID, input *GenerateGraphInput) (*GeneratedGraph, error) {
+	logger.L().Info("generate graph", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()))
+	if s.advisor == nil {
+		return nil, appErr.New(appErr.CodeUnavailable, "AI is not configured")
+	}
+	prompt := strings.TrimSpace(input.Prompt)
+	if prompt == "" || len(prompt) > MaxGraphPromptLength {
+		return nil, appErr.New(appErr.CodeInvalid, "prompt must be between 1 and 4000 characters")
+	}
+	if _, err := s.generatorProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+
+	gen, err := s.advisor.GenerateGraph(ctx, prompt)
+	if err != nil {
+		return nil, modelError(err, "the model did not produce a valid graph; try a more specific description")
+	}
+	out := &GeneratedGraph{
+		Graph:        graphDataFromCompiler(gen.Graph),
+		Summary:      gen.Summary,
+		Attempts:     gen.Attempts,
+		InputTokens:  gen.Usage.InputTokens,
+		OutputTokens: gen.Usage.OutputTokens,
+	}
+	logger.L().Info("graph generated", zap.String("project_id", projectID.String()), zap.Int("nodes", len(out.Graph.Nodes)), zap.Int("attempts", gen.Attempts), zap.Int("tokens", gen.Usage.Total()))
+
+	if input.Save {
+		g, err := s.projects.SaveGraph(ctx, projectID, userID, &out.Graph)
+		if err != nil {
+			return nil, err
+		}
+		out.Version = g.Version
+	}
+	return out, nil
+}
+
+func (s *aiService) SaveGraph(ctx context.Context, projectID, userID uuid.UUID, data *GraphData) (*models.ProjectGraph, error) {
+	if _, err := s.generatorProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	if errs := compiler.NewCompiler().Validate(compilerGraph(data)); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return nil, appErr.New(appErr.CodeInvalid, "invalid graph: "+strings.Join(msgs, "; ")).WithMeta("errors", msgs)
+	}
+	return s.projects.SaveGraph(ctx, projectID, userID, data)
+}
+
+func (s *aiService) ExplainDeployment(ctx context.Context, deploymentID, userID uuid.UUID) (*DeploymentExplanation, error) {
+	logger.L().Info("explain deployment", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	if s.advisor == nil {
+		return nil, appErr.New(appErr.CodeUnavailable, "AI is not configured")
+	}
+	var d models.Deployment
+	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(ctx, d.ProjectID, userID); err != nil {
+		return nil, err
+	}
+	var g models.ProjectGraph
+	if err := s.graphRepo.GetByID(ctx, d.GraphID, &g); err != nil {
+		return nil, err
+	}
+	data, err := decodeGraphData(&g)
+	if err != nil {
+		return nil, err
+	}
+	graph := compilerGraph(data)
+
+	out := &DeploymentExplanation{DeploymentID: d.ID, GraphVersion: g.Version}
+	var exp *advisor.Explanation
+	switch {
+	case d.Status == models.DeploymentFailed:
+		out.Kind = ExplainFailure
+		f, err := s.failure(ctx, &d, graph)
+		if err != nil {
+			return nil, err
+		}
+		exp, err = s.advisor.ExplainFailure(ctx, *f)
+		if err != nil {
+			return nil, modelError(err, "the model did not produce a usable explanation; try again")
+		}
+	case len(d.Plan) > 0:
+		out.Kind = ExplainPlan
+		plan, err := planForModel(d.Plan)
+		if err != nil {
+			return nil, err
+		}
+		exp, err = s.advisor.ExplainPlan(ctx, graph, plan)
+		if err != nil {
+			return nil, modelError(err, "the model did not produce a usable explanation; try again")
+		}
+	default:
+		return nil, appErr.New(appErr.CodeInvalid, "deployment has neither failed nor been planned")
+	}
+
+	out.Summary, out.Cause, out.Risks, out.Steps, out.Patches = exp.Summary, exp.Cause, exp.Risks, exp.Steps, exp.Patches
+	out.Attempts, out.InputTokens, out.OutputTokens = exp.Attempts, exp.Usage.InputTokens, exp.Usage.OutputTokens
+	logger.L().Info("deployment explained", zap.String("deployment_id", deploymentID.String()), zap.String("kind", out.Kind), zap.Int("patches", len(out.Patches)), zap.Int("tokens", exp.Usage.Total()))
+	return out, nil
+}
+
+func (s *aiService) ApplyPatch(ctx context.Context, projectID, userID uuid.UUID, input *ApplyPatchInput) (*models.ProjectGraph, error) {
+	logger.L().Info("apply graph patch", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()), zap.Int("patches", len(input.Patches)))
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	var g models.ProjectGraph
+	if err := s.graphRepo.GetCurrentByProject(ctx, projectID, &g); err != nil {
+		return nil, err
+	}
+	if g.Version != input.GraphVersion {
+		return nil, appErr.New(appErr.CodeConflict, "graph changed since the patch was proposed; review it against the current version").WithMeta("current_version", g.Version)
+	}
+	data, err := decodeGraphData(&g)
+	if err != nil {
+		return nil, err
+	}
+	if err := patchGraph(data, input.Patches); err != nil {
+		return nil, err
+	}
+	return s.projects.SaveGraph(ctx, projectID, userID, data)
+}
+
+// failure collects the last log lines of a failed deployment and the
+// phase that failed.
+func (s *aiService) failure(ctx context.Context, d *models.Deployment, graph compiler.Graph) (*advisor.Failure, error) {
+	after := d.LogSeq - ExplainLogLines
+	if after < 0 {
+		after = 0
+	}
+	logs, err := s.logRepo.List(ctx, d.ID, repository.LogQuery{AfterSeq: after, Limit: ExplainLogLines})
+	if err != nil {
+		return nil, err
+	}
+	f := &advisor.Failure{Graph: graph, Phase: "apply", Logs: make([]string, 0, len(logs))}
+	for _, l := range logs {
+		msg := l.Message
+		if len(msg) > ExplainLogLineLength {
+			msg = msg[:ExplainLogLineLength] + "..."
+		}
+		f.Logs = append(f.Logs, "["+l.Level+"] "+msg)
+		if l.Level == "error" && l.Phase != "" {
+			f.Phase = l.Phase
+		}
+	}
+	if len(f.Logs) == 0 {
+		return nil, appErr.New(appErr.CodeInvalid, "deployment has no logs to explain")
+	}
+	return f, nil
+}
+
+// planForModel trims a stored plan to what the model needs: resource
+// addresses, actions and the names of changed attributes. Attribute values
+// stay out; they may hold secrets.
+func planForModel(raw []byte) (json.RawMessage, error) {
+	var plan provisioner.Plan
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal plan failed")
+	}
+	plan.PlanOutput = ""
+	if len(plan.ResourceChanges) > ExplainPlanChanges {
+		plan.ResourceChanges = plan.ResourceChanges[:ExplainPlanChanges]
+	}
+	for i := range plan.ResourceChanges {
+		plan.ResourceChanges[i].Before = nil
+		plan.ResourceChanges[i].After = nil
+	}
+	out, err := json.Marshal(plan)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "marshal plan failed")
+	}
+	return out, nil
+}
+
+func (s *aiService) checkOwner(ctx context.Context, projectID, userID uuid.UUID) error {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return err
+	}
+	if p.UserID != userID {
+		return appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return nil
+}
+
+// generatorProject loads a project the user owns whose provider the
+// compiler supports.
+func (s *aiService) generatorProject(ctx context.Context, projectID, userID uuid.UUID) (*models.Project, error) {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	if p.CloudProvider != "aws" {
+		return nil, appErr.New(appErr.CodeInvalid, "graph generation supports aws projects only")
+	}
+	return &p, nil
+}
+
+// modelError maps advisor and model failures to application errors;
+// invalidMsg is the message when the model's answers stayed invalid.
+func modelError(err error, invalidMsg string) error {
+	var invalid *advisor.InvalidProposalError
+	if errors.As(err, &invalid) {
+		return appErr.Wrap(err, appErr.CodeInvalid, invalidMsg).WithMeta("errors", invalid.Errors)
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return appErr.Wrap(err, appErr.CodeUnavailable, "model request failed")
+}
+
+func decodeGraphData(g *models.ProjectGraph) (*GraphData, error) {
+	data := &GraphData{}
+	if len(g.Nodes) > 0 {
+		if err := json.Unmarshal(g.Nodes, &data.Nodes); err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal nodes failed")
+		}
+	}
+	if len(g.Edges) > 0 {
+		if err := json.Unmarshal(g.Edges, &data.Edges); err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal edges failed")
+		}
+	}
+	return data, nil
+}
+
+func compilerGraph(data *GraphData) compiler.Graph {
+	var g compiler.Graph
+	for _, n := range data.Nodes {
+		g.Nodes = append(g.Nodes, compiler.Node{ID: n.ID, Type: n.Type, Properties: n.Properties})
+	}
+	for _, e := range data.Edges {
+		g.Edges = append(g.Edges, compiler.Edge{ID: e.ID, From: e.From, To: e.To, Type: e.Type})
+	}
+	return g
+}
 
+// graphDataFromCompiler lays nodes out on a grid, four to a row, so a
+// generated graph opens readable in the editor.
+func graphDataFromCompiler(g compiler.Graph) GraphData {
+	const perRow, dx, dy = 4, 260, 180
+	out := GraphData{Nodes: make([]GraphNode, 0, len(g.Nodes)), Edges: make([]GraphEdge, 0, len(g.Edges))}
+	for i, n := range g.Nodes {
+		out.Nodes = append(out.Nodes, GraphNode{
+			ID:         n.ID,
+			Type:       n.Type,
+			Properties: n.Properties,
+			Position:   Position{X: float64(i%perRow) * dx, Y: float64(i/perRow) * dy},
+		})
+	}
+	for _, e := range g.Edges {
+		out.Edges = append(out.Edges, GraphEdge{ID: e.ID, From: e.From, To: e.To, Type: e.Type})
+	}
+	return out
+}
@@ -0,0 +1,678 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/ai/monitor"
+	"github.com/iac-studio/engine/internal/events"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/queue"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"github.com/iac-studio/engine/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// MaxAlertPendingMinutes caps how long an approval_pending rule waits.
+const MaxAlertPendingMinutes = 7 * 24 * 60
+
+// MaxAlertDeliveries caps a delivery listing.
+const MaxAlertDeliveries = 500
+
+// AlertService manages a project's alert channels and rules and sends the
+// alerts: events published to it are routed to the matching rules, and
+// pending approvals are checked periodically. A channel gets an alert once
+// per dedupe window and at most rateLimit alerts an hour. Alerts are sent by
+// the alert:deliver task, which retries failed sends with backoff.
+type AlertService interface {
+	ListChannels(ctx context.Context, projectID, userID uuid.UUID) ([]models.AlertChannel, error)
+	GetChannel(ctx context.Context, projectID, channelID, userID uuid.UUID) (*models.AlertChannel, error)
+	CreateChannel(ctx context.Context, projectID, userID uuid.UUID, input *AlertChannelInput) (*models.AlertChannel, error)
+	UpdateChannel(ctx context.Context, projectID, channelID, userID uuid.UUID, input *AlertChannelUpdate) (*models.AlertChannel, error)
+	DeleteChannel(ctx context.Context, projectID, channelID, userID uuid.UUID) error
+	// TestChannel sends a test notification to the channel, enabled or not,
+	// and returns the delivery.
+	TestChannel(ctx context.Context, projectID, channelID, userID uuid.UUID) (*models.AlertDelivery, error)
+
+	ListRules(ctx context.Context, projectID, userID uuid.UUID) ([]models.AlertRule, error)
+	GetRule(ctx context.Context, projectID, ruleID, userID uuid.UUID) (*models.AlertRule, error)
+	CreateRule(ctx context.Context, projectID, userID uuid.UUID, input *AlertRuleInput) (*models.AlertRule, error)
+	UpdateRule(ctx context.Context, projectID, ruleID, userID uuid.UUID, input *AlertRuleUpdate) (*models.AlertRule, error)
+	DeleteRule(ctx context.Context, projectID, ruleID, userID uuid.UUID) error
+
+	ListDeliveries(ctx context.Context, projectID, userID uuid.UUID, limit int) ([]models.AlertDelivery, error)
+
+	// Publish queues the alerts of the rules of the event's project that
+	// match it, which makes the service an events.Publisher.
+	Publish(ctx context.Context, e events.Event) error
+	// CheckPendingApprovals alerts on deployments that have awaited
+	// approval for longer than an approval_pending rule allows.
+	CheckPendingApprovals(ctx context.Context, now time.Time) error
+	// Deliver makes one attempt at a pending delivery. It returns an error
+	// when the attempt failed and another will follow; on the last attempt
+	// the delivery is marked failed instead.
+	Deliver(ctx context.Context, deliveryID int64, last bool) error
+}
+
+// AlertChannelInput creates a channel; it is enabled unless Enabled is
+// false.
+type AlertChannelInput struct {
+	Name    string                `json:"name" example:"ops slack"`
+	Type    string                `json:"type" example:"slack" enums:"webhook,slack,email"`
+	Config  monitor.ChannelConfig `json:"config"`
+	Enabled *bool                 `json:"enabled,omitempty"`
+}
+
+// AlertChannelUpdate changes the fields that are set. The type is fixed.
+type AlertChannelUpdate struct {
+	Name    *string                `json:"name,omitempty"`
+	Config  *monitor.ChannelConfig `json:"config,omitempty"`
+	Enabled *bool                  `json:"enabled,omitempty"`
+}
+
+// AlertRuleInput creates a rule; it is enabled unless Enabled is false.
+// AfterMinutes is required for approval_pending rules and not allowed for
+// others.
+type AlertRuleInput struct {
+	Name         string      `json:"name" example:"failed deployments"`
+	Trigger      string      `json:"trigger" example:"deployment_failed" enums:"deployment_failed,drift_detected,budget_exceeded,approval_pending,anomaly_detected"`
+	AfterMinutes int         `json:"after_minutes,omitempty" example:"60"`
+	ChannelIDs   []uuid.UUID `json:"channel_ids"`
+	Enabled      *bool       `json:"enabled,omitempty"`
+}
+
+// AlertRuleUpdate changes the fields that are set.
+type AlertRuleUpdate struct {
+	Name         *string      `json:"name,omitempty"`
+	Trigger      *string      `json:"trigger,omitempty" enums:"deployment_failed,drift_detected,budget_exceeded,approval_pending,anomaly_detected"`
+	AfterMinutes *int         `json:"after_minutes,omitempty"`
+	ChannelIDs   *[]uuid.UUID `json:"channel_ids,omitempty"`
+	Enabled      *bool        `json:"enabled,omitempty"`
+}
+
+var alertTriggers = map[string]bool{
+	monitor.TriggerDeploymentFailed: true,
+	monitor.TriggerDriftDetected:    true,
+	monitor.TriggerBudgetExceeded:   true,
+	monitor.TriggerApprovalPending:  true,
+	monitor.TriggerAnomalyDetected:  true,
+}
+
+// eventTriggers maps the events that can raise alerts to their trigger.
+var eventTriggers = map[string]string{
+	events.DeploymentFailed: monitor.TriggerDeploymentFailed,
+	events.DriftDetected:    monitor.TriggerDriftDetected,
+	events.BudgetExceeded:   monitor.TriggerBudgetExceeded,
+	events.AnomalyDetected:  monitor.TriggerAnomalyDetected,
+}
+
+type alertService struct {
+	projectRepo  repository.ProjectRepository
+	alertRepo    repository.AlertRepository
+	asynqClient  *asynq.Client
+	mailer       monitor.SMTPConfig
+	client       *http.Client
+	rateLimit    int
+	dedupeWindow time.Duration
+}
+
+func NewAlertService(projectRepo repository.ProjectRepository, alertRepo repository.AlertRepository, asynqClient *asynq.Client, mailer monitor.SMTPConfig, rateLimit int, dedupeWindow time.Duration) AlertService {
+	return &alertService{projectRepo: projectRepo, alertRepo: alertRepo, asynqClient: asynqClient, mailer: mailer, client: utils.NewSafeHTTPClient(10 * time.Second), rateLimit: rateLimit, dedupeWindow: dedupeWindow}
+}
+
+var (
+	_ AlertService     = (*alertService)(nil)
+	_ events.Publisher = (*alertService)(nil)
+)
+
+func (s *alertService) ListChannels(ctx context.Context, projectID, userID uuid.UUID) ([]models.AlertChannel, error) {
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	return s.alertRepo.ListChannels(ctx, projectID)
+}
+
+func (s *alertService) GetChannel(ctx context.Context, projectID, channelID, userID uuid.UUID) (*models.AlertChannel, error) {
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	var ch models.AlertChannel
+	if err := s.alertRepo.GetChannel(ctx, projectID, channelID, &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func (s *alertService) CreateChannel(ctx context.Context, projectID, userID uuid.UUID, input *AlertChannelInput) (*models.AlertChannel, error) {
+	logger.L().Info("create alert channel", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()), zap.String("type", input.Type))
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	if err := validateAlertName(input.Name); err != nil {
+		return nil, err
+	}
+	config, err := s.channelConfig(input.Type, input.Config)
+	if err != nil {
+		return nil, err
+	}
+	ch := &models.AlertChannel{ProjectID: projectID, Name: input.Name, Type: input.Type, Config: config, Enabled: input.Enabled == nil || *input.Enabled}
+	if err := s.alertRepo.CreateChannel(ctx, ch); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (s *alertService) UpdateChannel(ctx context.Context, projectID, channelID, userID uuid.UUID, input *AlertChannelUpdate) (*models.AlertChannel, error) {
+	logger.L().Info("update alert channel", zap.String("project_id", projectID.String()), zap.String("channel_id", channelID.String()), zap.String("user_id", userID.String()))
+	ch, err := s.GetChannel(ctx, projectID, channelID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		if err := validateAlertName(*input.Name); err != nil {
+			return nil, err
+		}
+		ch.Name = *input.Name
+	}
+	if input.Config != nil {
+		if ch.Config, err = s.channelConfig(ch.Type, *input.Config); err != nil {
+			return nil, err
+		}
+	}
+	if input.Enabled != nil {
+		ch.Enabled = *input.Enabled
+	}
+	if err := s.alertRepo.UpdateChannel(ctx, ch); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (s *alertService) DeleteChannel(ctx context.Context, projectID, channelID, userID uuid.UUID) error {
+	logger.L().Info("delete alert channel", zap.String("project_id", projectID.String()), zap.String("channel_id", channelID.String()), zap.String("user_id", userID.String()))
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return err
+	}
+	return s.alertRepo.DeleteChannel(ctx, projectID, channelID)
+}
+
+func (s *alertService) TestChannel(ctx context.Context, projectID, channelID, userID uuid.UUID) (*models.AlertDelivery, error) {
+	logger.L().Info("test alert channel", zap.String("project_id", projectID.String()), zap.String("channel_id", channelID.String()), zap.String("user_id", userID.String()))
+	p, err := s.ownedProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var ch models.AlertChannel
+	if err := s.alertRepo.GetChannel(ctx, projectID, channelID, &ch); err != nil {
+		return nil, err
+	}
+	a := monitor.Alert{
+		Trigger:     monitor.TriggerTest,
+		ProjectID:   projectID,
+		ProjectName: p.Name,
+		Subject:     uuid.NewString(),
+		Title:       fmt.Sprintf("[%s] Test notification", p.Name),
+		Text:        fmt.Sprintf("This is a test notification for the %q alert channel.", ch.Name),
+		OccurredAt:  time.Now().UTC(),
+	}
+	d, err := s.record(ctx, &ch, nil, a)
+	if err != nil || d == nil || d.Status != models.AlertDeliveryPending {
+		return d, err
+	}
+	// sent at once, without retries, so the caller sees the outcome
+	if err := s.attempt(ctx, &ch, d, a, true); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *alertService) ListRules(ctx context.Context, projectID, userID uuid.UUID) ([]models.AlertRule, error) {
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	return s.alertRepo.ListRules(ctx, projectID)
+}
+
+func (s *alertService) GetRule(ctx context.Context, projectID, ruleID, userID uuid.UUID) (*models.AlertRule, error) {
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	var rule models.AlertRule
+	if err := s.alertRepo.GetRule(ctx, projectID, ruleID, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *alertService) CreateRule(ctx context.Context, projectID, userID uuid.UUID, input *AlertRuleInput) (*models.AlertRule, error) {
+	logger.L().Info("create alert rule", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()), zap.String("trigger", input.Trigger))
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	if err := validateAlertName(input.Name); err != nil {
+		return nil, err
+	}
+	if err := validateAlertTrigger(input.Trigger, input.AfterMinutes); err != nil {
+		return nil, err
+	}
+	ids, err := s.ruleChannels(ctx, projectID, input.ChannelIDs)
+	if err != nil {
+		return nil, err
+	}
+	rule := &models.AlertRule{
+		ProjectID:    projectID,
+		Name:         input.Name,
+		Trigger:      input.Trigger,
+		AfterMinutes: input.AfterMinutes,
+		ChannelIDs:   ids,
+		Enabled:      input.Enabled == nil || *input.Enabled,
+	}
+	if err := s.alertRepo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *alertService) UpdateRule(ctx context.Context, projectID, ruleID, userID uuid.UUID, input *AlertRuleUpdate) (*models.AlertRule, error) {
+	logger.L().Info("update alert rule", zap.String("project_id", projectID.String()), zap.String("rule_id", ruleID.String()), zap.String("user_id", userID.String()))
+	rule, err := s.GetRule(ctx, projectID, ruleID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		if err := validateAlertName(*input.Name); err != nil {
+			return nil, err
+		}
+		rule.Name = *input.Name
+	}
+	if input.Trigger != nil {
+		rule.Trigger = *input.Trigger
+		if *input.Trigger != monitor.TriggerApprovalPending && input.AfterMinutes == nil {
+			rule.AfterMinutes = 0
+		}
+	}
+	if input.AfterMinutes != nil {
+		rule.AfterMinutes = *input.AfterMinutes
+	}
+	if err := validateAlertTrigger(rule.Trigger, rule.AfterMinutes); err != nil {
+		return nil, err
+	}
+	if input.ChannelIDs != nil {
+		if rule.ChannelIDs, err = s.ruleChannels(ctx, projectID, *input.ChannelIDs); err != nil {
+			return nil, err
+		}
+	}
+	if input.Enabled != nil {
+		rule.Enabled = *input.Enabled
+	}
+	if err := s.alertRepo.UpdateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *alertService) DeleteRule(ctx context.Context, projectID, ruleID, userID uuid.UUID) error {
+	logger.L().Info("delete alert rule", zap.String("project_id", projectID.String()), zap.String("rule_id", ruleID.String()), zap.String("user_id", userID.String()))
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return err
+	}
+	return s.alertRepo.DeleteRule(ctx, projectID, ruleID)
+}
+
+func (s *alertService) ListDeliveries(ctx context.Context, projectID, userID uuid.UUID, limit int) ([]models.AlertDelivery, error) {
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxAlertDeliveries {
+		limit = MaxAlertDeliveries
+	}
+	return s.alertRepo.ListDeliveries(ctx, projectID, limit)
+}
+
+func (s *alertService) Publish(ctx context.Context, e events.Event) error {
+	trigger, ok := eventTriggers[e.Type]
+	if !ok {
+		return nil
+	}
+	projectID, err := uuid.Parse(e.ProjectID)
+	if err != nil {
+		return nil
+	}
+	rules, err := s.alertRepo.ListEnabledRules(ctx, projectID, trigger)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return err
+	}
+	return s.dispatch(ctx, rules, alertFromEvent(e, trigger, &p))
+}
+
+func (s *alertService) CheckPendingApprovals(ctx context.Context, now time.Time) error {
+	rules, err := s.alertRepo.ListEnabledRules(ctx, uuid.Nil, monitor.TriggerApprovalPending)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	byProject := map[uuid.UUID][]models.AlertRule{}
+	var projectIDs []uuid.UUID
+	for _, r := range rules {
+		if _, ok := byProject[r.ProjectID]; !ok {
+			projectIDs = append(projectIDs, r.ProjectID)
+		}
+		byProject[r.ProjectID] = append(byProject[r.ProjectID], r)
+	}
+	pending, err := s.alertRepo.ListPendingApprovals(ctx, projectIDs)
+	if err != nil {
+		return err
+	}
+
+	projects := map[uuid.UUID]*models.Project{}
+	for _, pa := range pending {
+		waited := now.Sub(pa.Since)
+		var due []models.AlertRule
+		for _, r := range byProject[pa.ProjectID] {
+			if waited >= time.Duration(r.AfterMinutes)*time.Minute {
+				due = append(due, r)
+			}
+		}
+		if len(due) == 0 {
+			continue
+		}
+		p := projects[pa.ProjectID]
+		if p == nil {
+			p = &models.Project{}
+			if err := s.projectRepo.GetByID(ctx, pa.ProjectID, p); err != nil {
+				return err
+			}
+			projects[pa.ProjectID] = p
+		}
+		waited = waited.Truncate(time.Minute)
+		a := monitor.Alert{
+			Trigger:      monitor.TriggerApprovalPending,
+			ProjectID:    pa.ProjectID,
+			ProjectName:  p.Name,
+			DeploymentID: pa.DeploymentID.String(),
+			Subject:      pa.DeploymentID.String(),
+			Title:        fmt.Sprintf("[%s] Deployment awaiting approval", p.Name),
+			Text:         fmt.Sprintf("Deployment %s has awaited approval for %s.", pa.DeploymentID, waited),
+			Data:         map[string]interface{}{"pending_since": pa.Since, "pending_minutes": int(waited.Minutes())},
+			OccurredAt:   now.UTC(),
+		}
+		if err := s.dispatch(ctx, due, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch queues a for the enabled channels of rules, once per channel.
+func (s *alertService) dispatch(ctx context.Context, rules []models.AlertRule, a monitor.Alert) error {
+	sent := map[uuid.UUID]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		var ids []uuid.UUID
+		if err := json.Unmarshal(rule.ChannelIDs, &ids); err != nil {
+			logger.L().Warn("invalid alert rule channels", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+			continue
+		}
+		for _, id := range ids {
+			if sent[id] {
+				continue
+			}
+			sent[id] = true
+			var ch models.AlertChannel
+			if err := s.alertRepo.GetChannel(ctx, rule.ProjectID, id, &ch); err != nil {
+				if appErr.IsCode(err, appErr.CodeNotFound) {
+					continue
+				}
+				return err
+			}
+			if !ch.Enabled {
+				continue
+			}
+			d, err := s.record(ctx, &ch, &rule.ID, a)
+			if err != nil {
+				return err
+			}
+			if d != nil && d.Status == models.AlertDeliveryPending {
+				if err := s.queueDelivery(ctx, d); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *alertService) Deliver(ctx context.Context, deliveryID int64, last bool) error {
+	var d models.AlertDelivery
+	if err := s.alertRepo.GetDelivery(ctx, deliveryID, &d); err != nil {
+		return err
+	}
+	if d.Status != models.AlertDeliveryPending {
+		return nil
+	}
+	var ch models.AlertChannel
+	if err := s.alertRepo.GetChannel(ctx, d.ProjectID, d.ChannelID, &ch); err != nil {
+		return err
+	}
+	if !ch.Enabled {
+		d.Status, d.Error = models.AlertDeliveryFailed, "alert channel is disabled"
+		return s.alertRepo.UpdateDelivery(ctx, &d)
+	}
+	var a monitor.Alert
+	if err := json.Unmarshal(d.Payload, &a); err != nil {
+		d.Status, d.Error = models.AlertDeliveryFailed, "invalid alert payload"
+		return s.alertRepo.UpdateDelivery(ctx, &d)
+	}
+	return s.attempt(ctx, &ch, &d, a, last)
+}
+
+// record stores the delivery of a to ch unless the channel already got it
+// within the dedupe window, in which case it returns nil, or reached its
+// rate limit, which is recorded as a rate_limited delivery.
+func (s *alertService) record(ctx context.Context, ch *models.AlertChannel, ruleID *uuid.UUID, a monitor.Alert) (*models.AlertDelivery, error) {
+	n, err := s.alertRepo.CountDeliveries(ctx, ch.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return nil, fmt.Errorf("marshal alert: %w", err)
+	}
+	d := &models.AlertDelivery{
+		ProjectID: ch.ProjectID,
+		ChannelID: ch.ID,
+		RuleID:    ruleID,
+		Trigger:   a.Trigger,
+		DedupeKey: a.DedupeKey(s.dedupeWindow),
+		Title:     a.Title,
+		Status:    models.AlertDeliveryPending,
+		Payload:   datatypes.JSON(payload),
+	}
+	if int(n) >= s.rateLimit {
+		d.Status = models.AlertDeliveryRateLimited
+	}
+	claimed, err := s.alertRepo.ClaimDelivery(ctx, d)
+	if err != nil || !claimed {
+		return nil, err
+	}
+	if d.Status == models.AlertDeliveryRateLimited {
+		logger.L().Warn("alert rate limited", zap.String("channel_id", ch.ID.String()), zap.String("trigger", a.Trigger), zap.Int("limit", s.rateLimit))
+	}
+	return d, nil
+}
+
+// attempt sends a to ch and records the outcome on d. A failed send is
+// returned, to be retried, unless it was the last attempt or the channel
+// cannot be notified at all; either marks d failed.
+func (s *alertService) attempt(ctx context.Context, ch *models.AlertChannel, d *models.AlertDelivery, a monitor.Alert, last bool) error {
+	var notifier monitor.Notifier
+	var config monitor.ChannelConfig
+	err := json.Unmarshal(ch.Config, &config)
+	if err == nil {
+		notifier, err = monitor.NewNotifier(ch.Type, config, s.mailer, s.client)
+	}
+	if err != nil {
+		// a retry would fail the same way
+		last = true
+	} else {
+		err = notifier.Notify(ctx, a)
+	}
+	d.Attempts++
+	d.Error = ""
+	switch {
+	case err == nil:
+		d.Status = models.AlertDeliverySent
+	case last:
+		d.Status, d.Error = models.AlertDeliveryFailed, err.Error()
+	default:
+		d.Error = err.Error()
+	}
+	if err != nil {
+		logger.L().Warn("send alert failed", zap.String("channel_id", ch.ID.String()), zap.String("trigger", a.Trigger), zap.Int("attempt", d.Attempts), zap.Bool("last", last), zap.Error(err))
+	}
+	if err := s.alertRepo.UpdateDelivery(ctx, d); err != nil {
+		return err
+	}
+	if err != nil && !last {
+		return err
+	}
+	return nil
+}
+
+// queueDelivery enqueues the first attempt at d. A delivery that cannot be
+// enqueued is marked failed.
+func (s *alertService) queueDelivery(ctx context.Context, d *models.AlertDelivery) error {
+	if s.asynqClient == nil {
+		logger.L().Warn("asynq client not configured, skipping alert enqueue", zap.Int64("delivery_id", d.ID))
+		return nil
+	}
+	pb, _ := json.Marshal(map[string]int64{"delivery_id": d.ID})
+	task := asynq.NewTask(queue.TypeAlertDeliver, pb)
+	if _, err := s.asynqClient.EnqueueContext(ctx, task, queue.AlertTaskOptions(d.ID)...); err != nil {
+		logger.L().Error("enqueue alert delivery failed", zap.Error(err), zap.Int64("delivery_id", d.ID))
+		d.Status, d.Error = models.AlertDeliveryFailed, "enqueue failed: "+err.Error()
+		_ = s.alertRepo.UpdateDelivery(ctx, d)
+		return appErr.Wrap(err, appErr.CodeInternal, "enqueue alert delivery failed")
+	}
+	return nil
+}
+
+func (s *alertService) channelConfig(typ string, c monitor.ChannelConfig) (datatypes.JSON, error) {
+	if err := c.Validate(typ); err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInvalid, err.Error())
+	}
+	if typ == monitor.ChannelEmail && (s.mailer.Host == "" || s.mailer.From == "") {
+		return nil, appErr.New(appErr.CodeUnavailable, monitor.ErrSMTPDisabled.Error())
+	}
+	b, _ := json.Marshal(c)
+	return datatypes.JSON(b), nil
+}
+
+// ruleChannels checks the channels belong to the project and encodes them.
+func (s *alertService) ruleChannels(ctx context.Context, projectID uuid.UUID, ids []uuid.UUID) (datatypes.JSON, error) {
+	if len(ids) == 0 {
+		return nil, appErr.New(appErr.CodeInvalid, "channel_ids needs at least one channel")
+	}
+	seen := map[uuid.UUID]bool{}
+	var unique []uuid.UUID
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		var ch models.AlertChannel
+		if err := s.alertRepo.GetChannel(ctx, projectID, id, &ch); err != nil {
+			if appErr.IsCode(err, appErr.CodeNotFound) {
+				return nil, appErr.New(appErr.CodeInvalid, "unknown alert channel").WithMeta("channel_id", id)
+			}
+			return nil, err
+		}
+		unique = append(unique, id)
+	}
+	b, _ := json.Marshal(unique)
+	return datatypes.JSON(b), nil
+}
+
+func (s *alertService) ownedProject(ctx context.Context, projectID, userID uuid.UUID) (*models.Project, error) {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return &p, nil
+}
+
+func validateAlertName(name string) error {
+	if name == "" || len(name) > 255 {
+		return appErr.New(appErr.CodeInvalid, "name is required and at most 255 characters")
+	}
+	return nil
+}
+
+func validateAlertTrigger(trigger string, afterMinutes int) error {
+	if !alertTriggers[trigger] {
+		return appErr.New(appErr.CodeInvalid, "trigger must be deployment_failed, drift_detected, budget_exceeded, approval_pending or anomaly_detected")
+	}
+	if trigger == monitor.TriggerApprovalPending {
+		if afterMinutes < 1 || afterMinutes > MaxAlertPendingMinutes {
+			return appErr.New(appErr.CodeInvalid, fmt.Sprintf("approval_pending rules need after_minutes between 1 and %d", MaxAlertPendingMinutes))
+		}
+	} else if afterMinutes != 0 {
+		return appErr.New(appErr.CodeInvalid, "after_minutes only applies to approval_pending rules")
+	}
+	return nil
+}
+
+// alertFromEvent describes an event for the project's alert channels.
+func alertFromEvent(e events.Event, trigger string, p *models.Project) monitor.Alert {
+	a := monitor.Alert{
+		Trigger:      trigger,
+		ProjectID:    p.ID,
+		ProjectName:  p.Name,
+		DeploymentID: e.DeploymentID,
+		Subject:      e.DeploymentID,
+		Data:         e.Data,
+		OccurredAt:   e.OccurredAt,
+	}
+	if a.Subject == "" {
+		a.Subject = e.ProjectID
+	}
+	str := func(k string) string {
+		v, _ := e.Data[k].(string)
+		return v
+	}
+	switch trigger {
+	case monitor.TriggerDeploymentFailed:
+		a.Title = fmt.Sprintf("[%s] Deployment failed", p.Name)
+		a.Text = fmt.Sprintf("Deployment %s failed.", e.DeploymentID)
+		if msg := str("error"); msg != "" {
+			a.Text += "\n" + msg
+		}
+	case monitor.TriggerDriftDetected:
+		a.Title = fmt.Sprintf("[%s] Drift detected", p.Name)
+		a.Text = fmt.Sprintf("Deployment %s has drifted: %v resources differ from the graph.", e.DeploymentID, e.Data["resources"])
+	case monitor.TriggerBudgetExceeded:
+		a.Title = fmt.Sprintf("[%s] Budget exceeded", p.Name)
+		a.Text = str("message")
+	case monitor.TriggerAnomalyDetected:
+		a.Title = fmt.Sprintf("[%s] Anomaly detected: %s", p.Name, str("kind"))
+		a.Text = str("message")
+		if fp := str("fingerprint"); fp != "" {
+			a.Subject = fp
+		}
+	}
+	return a
+}
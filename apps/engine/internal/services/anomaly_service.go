@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/ai/monitor"
+	"github.com/iac-studio/engine/internal/events"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// AnomalyBaseline is how far back the deployment history reaches when the
+// detector computes project medians.
+const AnomalyBaseline = 30 * 24 * time.Hour
+
+// MaxAnomalies caps an anomaly listing.
+const MaxAnomalies = 500
+
+// AnomalyService finds anomalies in the deployment history, records them
+// and lists them per project.
+type AnomalyService interface {
+	// Scan checks the runs that ended at or after since, records the
+	// anomalies not seen before and publishes an event for each. It
+	// returns the new anomalies.
+	Scan(ctx context.Context, since, now time.Time) ([]models.Anomaly, error)
+	List(ctx context.Context, projectID, userID uuid.UUID, filter repository.AnomalyFilter) ([]models.Anomaly, error)
+}
+
+type anomalyService struct {
+	projectRepo repository.ProjectRepository
+	anomalyRepo repository.AnomalyRepository
+	detector    *monitor.Detector
+	publisher   events.Publisher
+}
+
+func NewAnomalyService(projectRepo repository.ProjectRepository, anomalyRepo repository.AnomalyRepository, detector *monitor.Detector, publisher events.Publisher) AnomalyService {
+	return &anomalyService{projectRepo: projectRepo, anomalyRepo: anomalyRepo, detector: detector, publisher: publisher}
+}
+
+var _ AnomalyService = (*anomalyService)(nil)
+
+var anomalyKinds = map[string]bool{monitor.KindSlowPhase: true, monitor.KindFailureBurst: true, monitor.KindRepeatedError: true}
+
+func (s *anomalyService) Scan(ctx context.Context, since, now time.Time) ([]models.Anomaly, error) {
+	// only projects with runs in the detector's window can have new
+	// anomalies; their history is loaded back to the baseline
+	from := now.Add(-s.detector.Window())
+	if since.Before(from) {
+		from = since
+	}
+	recent, err := s.anomalyRepo.ListStatusChanges(ctx, from, nil)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[uuid.UUID]bool{}
+	var projectIDs []uuid.UUID
+	for _, c := range recent {
+		if !seen[c.ProjectID] {
+			seen[c.ProjectID] = true
+			projectIDs = append(projectIDs, c.ProjectID)
+		}
+	}
+	if len(projectIDs) == 0 {
+		return nil, nil
+	}
+	changes, err := s.anomalyRepo.ListStatusChanges(ctx, now.Add(-AnomalyBaseline), projectIDs)
+	if err != nil {
+		return nil, err
+	}
+	runs := deploymentRuns(changes)
+
+	var failed []uuid.UUID
+	for _, r := range runs {
+		if r.Failed {
+			failed = append(failed, r.DeploymentID)
+		}
+	}
+	lastErrors, err := s.anomalyRepo.LastErrors(ctx, failed)
+	if err != nil {
+		return nil, err
+	}
+	for i := range runs {
+		if runs[i].Failed {
+			runs[i].Error = lastErrors[runs[i].DeploymentID]
+		}
+	}
+
+	var recorded []models.Anomaly
+	for _, a := range s.detector.Detect(runs, since, now) {
+		details, _ := json.Marshal(a.Details)
+		m := models.Anomaly{
+			ProjectID:   a.ProjectID,
+			Kind:        a.Kind,
+			Severity:    a.Severity,
+			Provider:    a.Provider,
+			Region:      a.Region,
+			Fingerprint: a.Fingerprint,
+			Message:     a.Message,
+			Details:     datatypes.JSON(details),
+			DetectedAt:  now,
+		}
+		if a.DeploymentID != uuid.Nil {
+			id := a.DeploymentID
+			m.DeploymentID = &id
+		}
+		created, err := s.anomalyRepo.Record(ctx, &m)
+		if err != nil {
+			return recorded, err
+		}
+		if !created {
+			continue
+		}
+		recorded = append(recorded, m)
+		logger.L().Warn("anomaly detected", zap.String("project_id", m.ProjectID.String()), zap.String("kind", m.Kind), zap.String("severity", m.Severity), zap.String("message", m.Message))
+		e := events.New(events.AnomalyDetected, a.ProjectID, a.DeploymentID, map[string]interface{}{
+			"anomaly_id":  m.ID.String(),
+			"kind":        m.Kind,
+			"severity":    m.Severity,
+			"message":     m.Message,
+			"fingerprint": m.Fingerprint,
+		})
+		if err := s.publisher.Publish(ctx, e); err != nil {
+			logger.L().Warn("publish anomaly failed", zap.String("anomaly_id", m.ID.String()), zap.Error(err))
+		}
+	}
+	return recorded, nil
+}
+
+func (s *anomalyService) List(ctx context.Context, projectID, userID uuid.UUID, filter repository.AnomalyFilter) ([]models.Anomaly, error) {
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	if filter.Kind != "" && !anomalyKinds[filter.Kind] {
+		return nil, appErr.New(appErr.CodeInvalid, "kind must be slow_phase, failure_burst or repeated_error")
+	}
+	if filter.Limit <= 0 || filter.Limit > MaxAnomalies {
+		filter.Limit = MaxAnomalies
+	}
+	return s.anomalyRepo.ListByProject(ctx, projectID, filter)
+}
+
+func (s *anomalyService) checkOwner(ctx context.Context, projectID, userID uuid.UUID) error {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return err
+	}
+	if p.UserID != userID {
+		return appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return nil
+}
+
+// deploymentRuns turns status changes, ordered by deployment and time, into
+// the stretches each deployment spent planning, applying or destroying.
+func deploymentRuns(changes []repository.StatusChange) []monitor.Run {
+	var runs []monitor.Run
+	for i := 1; i < len(changes); i++ {
+		prev, c := changes[i-1], changes[i]
+		if prev.DeploymentID != c.DeploymentID || prev.ToStatus != c.FromStatus {
+			continue
+		}
+		switch c.FromStatus {
+		case models.DeploymentPlanning, models.DeploymentApplying, models.DeploymentDestroying:
+		default:
+			continue
+		}
+		runs = append(runs, monitor.Run{
+			DeploymentID: c.DeploymentID,
+			ProjectID:    c.ProjectID,
+			Provider:     c.Provider,
+			Region:       c.Region,
+			Phase:        string(c.FromStatus),
+			StartedAt:    prev.CreatedAt,
+			EndedAt:      c.CreatedAt,
+			Failed:       c.ToStatus == models.DeploymentFailed,
+		})
+	}
+	return runs
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/queue"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// ApprovalRules is a project's four-eyes policy, stored in
+// models.Project.ApprovalRules. When enabled, the worker plans each
+// deployment and holds it at awaiting_approval until RequiredApprovals
+// approvers other than the author approve it.
+type ApprovalRules struct {
+	// RequiredApprovals is the number of approvals needed; zero turns
+	// approval off.
+	RequiredApprovals int `json:"required_approvals" example:"2"`
+	// Approvers are the users allowed to approve or reject.
+	Approvers []uuid.UUID `json:"approvers"`
+	// AutoApproveNoDeletes lets a deployment whose plan deletes nothing
+	// apply without approval.
+	AutoApproveNoDeletes bool `json:"auto_approve_no_deletes"`
+}
+
+// Approval limits.
+const (
+	MaxRequiredApprovals = 10
+	MaxApprovalComment   = 2000
+)
+
+// Enabled reports whether deployments need approval.
+func (r *ApprovalRules) Enabled() bool {
+	return r != nil && r.RequiredApprovals > 0
+}
+
+// AutoApproves reports whether plan may apply without approval.
+func (r *ApprovalRules) AutoApproves(plan *provisioner.Plan) bool {
+	return r.AutoApproveNoDeletes && plan != nil && plan.ResourceDels == 0
+}
+
+func (r *ApprovalRules) isApprover(userID uuid.UUID) bool {
+	for _, id := range r.Approvers {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseApprovalRules decodes and validates the approval rules of a project
+// owned by ownerID. Empty rules disable approval.
+func ParseApprovalRules(raw datatypes.JSON, ownerID uuid.UUID) (*ApprovalRules, error) {
+	rules := &ApprovalRules{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return rules, nil
+	}
+	if err := json.Unmarshal(raw, rules); err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInvalid, "invalid approval rules")
+	}
+	if rules.RequiredApprovals < 0 || rules.RequiredApprovals > MaxRequiredApprovals {
+		return nil, appErr.New(appErr.CodeInvalid, "required_approvals must be between 0 and "+strconv.Itoa(MaxRequiredApprovals))
+	}
+	seen := map[uuid.UUID]bool{}
+	others := 0
+	for _, id := range rules.Approvers {
+		if id == uuid.Nil || seen[id] {
+			return nil, appErr.New(appErr.CodeInvalid, "approvers must be distinct user ids")
+		}
+		seen[id] = true
+		if id != ownerID {
+			others++
+		}
+	}
+	// the author, who is the owner, never counts, so a project owner alone
+	// cannot approve
+	if others < rules.RequiredApprovals {
+		return nil, appErr.New(appErr.CodeInvalid, "approvers other than the project owner must number at least required_approvals")
+	}
+	return rules, nil
+}
+
+// ApprovalService collects approvals on deployments held at
+// awaiting_approval and enqueues the apply once there are enough.
+type ApprovalService interface {
+	GetApprovals(ctx context.Context, deploymentID, userID uuid.UUID) (*ApprovalStatus, error)
+	// Approve records userID's approval and, when it completes the
+	// required number, releases the deployment to apply.
+	Approve(ctx context.Context, deploymentID, userID uuid.UUID, comment string) (*ApprovalStatus, error)
+	// Reject records userID's rejection; one rejection ends the deployment.
+	Reject(ctx context.Context, deploymentID, userID uuid.UUID, comment string) (*ApprovalStatus, error)
+}
+
+// ApprovalStatus is where a deployment stands with its approvals.
+type ApprovalStatus struct {
+	DeploymentID uuid.UUID                   `json:"deployment_id"`
+	Status       models.DeploymentStatus     `json:"status"`
+	Required     int                         `json:"required"`
+	Approvals    int                         `json:"approvals"`
+	Decisions    []models.DeploymentApproval `json:"decisions"`
+}
+
+type approvalService struct {
+	projectRepo  repository.ProjectRepository
+	deployRepo   repository.DeploymentRepository
+	approvalRepo repository.ApprovalRepository
+	asynqClient  *asynq.Client
+}
+
+func NewApprovalService(projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, approvalRepo repository.ApprovalRepository, client *asynq.Client) ApprovalService {
+	return &approvalService{projectRepo: projectRepo, deployRepo: deployRepo, approvalRepo: approvalRepo, asynqClient: client}
+}
+
+var _ ApprovalService = (*approvalService)(nil)
+
+func (s *approvalService) GetApprovals(ctx context.Context, deploymentID, userID uuid.UUID) (*ApprovalStatus, error) {
+	logger.L().Info("get deployment approvals", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, p, rules, err := s.load(ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID != userID && !rules.isApprover(userID) {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user is neither project owner nor approver")
+	}
+	return s.status(ctx, d, p, rules)
+}
+
+func (s *approvalService) Approve(ctx context.Context, deploymentID, userID uuid.UUID, comment string) (*ApprovalStatus, error) {
+	logger.L().Info("approve deployment", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, p, rules, err := s.decide(ctx, deploymentID, userID, models.ApprovalApproved, comment)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.status(ctx, d, p, rules)
+	if err != nil {
+		return nil, err
+	}
+	if st.Approvals < st.Required {
+		return st, nil
+	}
+
+	// a concurrent rejection or cancel wins: the transition fails
+	ctx = repository.WithActor(ctx, "user:"+userID.String())
+	if err := s.deployRepo.UpdateStatus(ctx, d.ID, models.DeploymentApproved); err != nil {
+		return nil, err
+	}
+	if err := s.deployRepo.MarkApproved(ctx, d.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	st.Status = models.DeploymentApproved
+
+	pb, _ := json.Marshal(map[string]string{"deployment_id": d.ID.String()})
+	task := asynq.NewTask(queue.TypeProvision, pb)
+	if s.asynqClient == nil {
+		logger.L().Warn("asynq client not configured, skipping enqueue", zap.String("deployment_id", d.ID.String()))
+	} else if _, err := s.asynqClient.EnqueueContext(ctx, task, queue.DeploymentTaskOptions(queue.TypeProvision, d.ID)...); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		logger.L().Error("enqueue approved provision task failed", zap.Error(err), zap.String("deployment_id", d.ID.String()))
+		_ = s.deployRepo.UpdateStatus(ctx, d.ID, models.DeploymentFailed)
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "enqueue provision task failed")
+	}
+	logger.L().Info("deployment approved, apply enqueued", zap.String("deployment_id", d.ID.String()), zap.Int("approvals", st.Approvals))
+	return st, nil
+}
+
+func (s *approvalService) Reject(ctx context.Context, deploymentID, userID uuid.UUID, comment string) (*ApprovalStatus, error) {
+	logger.L().Info("reject deployment", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, p, rules, err := s.decide(ctx, deploymentID, userID, models.ApprovalRejected, comment)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.deployRepo.UpdateStatus(repository.WithActor(ctx, "user:"+userID.String()), d.ID, models.DeploymentRejected); err != nil {
+		return nil, err
+	}
+	d.Status = models.DeploymentRejected
+	logger.L().Info("deployment rejected", zap.String("deployment_id", d.ID.String()))
+	return s.status(ctx, d, p, rules)
+}
+
+// decide checks that userID may decide on the deployment and records the
+// decision.
+func (s *approvalService) decide(ctx context.Context, deploymentID, userID uuid.UUID, decision, comment string) (*models.Deployment, *models.Project, *ApprovalRules, error) {
+	if len(comment) > MaxApprovalComment {
+		return nil, nil, nil, appErr.New(appErr.CodeInvalid, "comment must be at most "+strconv.Itoa(MaxApprovalComment)+" characters")
+	}
+	d, p, rules, err := s.load(ctx, deploymentID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !rules.isApprover(userID) {
+		return nil, nil, nil, appErr.New(appErr.CodeUnauthorized, "user is not an approver of this project")
+	}
+	if userID == author(d, p) {
+		return nil, nil, nil, appErr.New(appErr.CodeForbidden, "the author cannot approve their own deployment")
+	}
+	if d.Status != models.DeploymentAwaitingApproval {
+		return nil, nil, nil, appErr.New(appErr.CodeConflict, "deployment is "+string(d.Status)+", not awaiting approval")
+	}
+	a := &models.DeploymentApproval{DeploymentID: d.ID, UserID: userID, Decision: decision, Comment: comment}
+	if err := s.approvalRepo.Create(ctx, a); err != nil {
+		return nil, nil, nil, err
+	}
+	return d, p, rules, nil
+}
+
+func (s *approvalService) load(ctx context.Context, deploymentID uuid.UUID) (*models.Deployment, *models.Project, *ApprovalRules, error) {
+	var d models.Deployment
+	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+		return nil, nil, nil, err
+	}
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
+		return nil, nil, nil, err
+	}
+	rules, err := ParseApprovalRules(p.ApprovalRules, p.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &d, &p, rules, nil
+}
+
+// status counts approvals from current approvers other than the author, so
+// removing someone from the list also withdraws their vote.
+func (s *approvalService) status(ctx context.Context, d *models.Deployment, p *models.Project, rules *ApprovalRules) (*ApprovalStatus, error) {
+	decisions, err := s.approvalRepo.ListByDeployment(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	st := &ApprovalStatus{DeploymentID: d.ID, Status: d.Status, Required: rules.RequiredApprovals, Decisions: decisions}
+	by := author(d, p)
+	for _, a := range decisions {
+		if a.Decision == models.ApprovalApproved && a.UserID != by && rules.isApprover(a.UserID) {
+			st.Approvals++
+		}
+	}
+	return st, nil
+}
+
+// author is who started d; deployments from before authors were recorded
+// count as the project owner's.
+func author(d *models.Deployment, p *models.Project) uuid.UUID {
+	if d.CreatedBy != nil {
+		return *d.CreatedBy
+	}
+	return p.UserID
+}
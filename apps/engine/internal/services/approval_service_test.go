@@ -0,0 +1,45 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	appErr "github.com/iac-studio/engine/pkg/errors"
+)
+
+func TestParseApprovalRules(t *testing.T) {
+	owner, alice, bob := uuid.New(), uuid.New(), uuid.New()
+	raw := func(rules ApprovalRules) datatypes.JSON {
+		b, err := json.Marshal(rules)
+		require.NoError(t, err)
+		return b
+	}
+
+	tests := []struct {
+		name  string
+		rules datatypes.JSON
+		valid bool
+	}{
+		{name: "empty", rules: nil, valid: true},
+		{name: "enough approvers", rules: raw(ApprovalRules{RequiredApprovals: 2, Approvers: []uuid.UUID{alice, bob}}), valid: true},
+		{name: "owner listed besides enough others", rules: raw(ApprovalRules{RequiredApprovals: 2, Approvers: []uuid.UUID{owner, alice, bob}}), valid: true},
+		{name: "owner counted towards the required approvals", rules: raw(ApprovalRules{RequiredApprovals: 2, Approvers: []uuid.UUID{owner, alice}})},
+		{name: "owner the only approver", rules: raw(ApprovalRules{RequiredApprovals: 1, Approvers: []uuid.UUID{owner}})},
+		{name: "duplicate approver", rules: raw(ApprovalRules{RequiredApprovals: 1, Approvers: []uuid.UUID{alice, alice}})},
+		{name: "too many required", rules: raw(ApprovalRules{RequiredApprovals: MaxRequiredApprovals + 1})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseApprovalRules(tt.rules, owner)
+			if tt.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, appErr.IsCode(err, appErr.CodeInvalid), "got %v", err)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/iac-studio/engine/internal/cloud"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"gorm.io/datatypes"
+)
+
+// BudgetEnforcement is what happens to a deployment over budget.
+type BudgetEnforcement string
+
+const (
+	// BudgetHard rejects deployments over the limit.
+	BudgetHard BudgetEnforcement = "hard"
+	// BudgetSoft rejects them unless the author overrides the budget; the
+	// override is audited.
+	BudgetSoft BudgetEnforcement = "soft"
+)
+
+// Budget is a project's monthly cost limit, stored in
+// models.Project.Budget. Deployments are checked against it using the
+// cost estimate of their graph.
+type Budget struct {
+	// MonthlyLimit is in the price catalog's currency; zero turns the
+	// budget off.
+	MonthlyLimit float64 `json:"monthly_limit" example:"500"`
+	// WarningPercent warns on deployments estimated at this share of the
+	// limit or more; zero never warns.
+	WarningPercent float64           `json:"warning_percent" example:"80"`
+	Enforcement    BudgetEnforcement `json:"enforcement" enums:"hard,soft"`
+}
+
+// Enabled reports whether the project has a budget.
+func (b *Budget) Enabled() bool {
+	return b != nil && b.MonthlyLimit > 0
+}
+
+// Exceeded reports whether est is over the limit.
+func (b *Budget) Exceeded(est *cloud.Estimate) bool {
+	return b.Enabled() && est.MonthlyCost > b.MonthlyLimit
+}
+
+// Warns reports whether est reached the warning threshold.
+func (b *Budget) Warns(est *cloud.Estimate) bool {
+	return b.Enabled() && b.WarningPercent > 0 && est.MonthlyCost >= b.MonthlyLimit*b.WarningPercent/100
+}
+
+// UncheckedError rejects a deployment that could not be priced, or only in
+// part (est lists the unpriced nodes), under a hard budget.
+func (b *Budget) UncheckedError(est *cloud.Estimate) error {
+	if est == nil {
+		return appErr.New(appErr.CodeBudgetExceeded, fmt.Sprintf("the graph could not be priced, so the %s budget of %.2f could not be checked; set override_budget to deploy anyway", b.Enforcement, b.MonthlyLimit)).
+			WithMeta("monthly_limit", b.MonthlyLimit).
+			WithMeta("enforcement", string(b.Enforcement)).
+			WithMeta("priced", false)
+	}
+	return appErr.New(appErr.CodeBudgetExceeded, fmt.Sprintf("%d node(s) of the graph could not be priced, so the %s budget of %.2f could not be checked; set override_budget to deploy anyway", len(est.Unpriced), b.Enforcement, b.MonthlyLimit)).
+		WithMeta("monthly_limit", b.MonthlyLimit).
+		WithMeta("estimated_cost", est.MonthlyCost).
+		WithMeta("enforcement", string(b.Enforcement)).
+		WithMeta("priced", false).
+		WithMeta("unpriced", est.Unpriced)
+}
+
+// ExceededError rejects a deployment estimated at est.
+func (b *Budget) ExceededError(est *cloud.Estimate) error {
+	msg := fmt.Sprintf("estimated monthly cost %.2f %s exceeds the %s budget of %.2f %s", est.MonthlyCost, est.Currency, b.Enforcement, b.MonthlyLimit, est.Currency)
+	if b.Enforcement == BudgetSoft {
+		msg += "; set override_budget to deploy anyway"
+	}
+	return appErr.New(appErr.CodeBudgetExceeded, msg).
+		WithMeta("monthly_limit", b.MonthlyLimit).
+		WithMeta("estimated_cost", est.MonthlyCost).
+		WithMeta("enforcement", string(b.Enforcement))
+}
+
+// ParseBudget decodes and validates a project's budget. An empty budget is
+// off.
+func ParseBudget(raw datatypes.JSON) (*Budget, error) {
+	b := &Budget{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return b, nil
+	}
+	if err := json.Unmarshal(raw, b); err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInvalid, "invalid budget")
+	}
+	if b.MonthlyLimit < 0 {
+		return nil, appErr.New(appErr.CodeInvalid, "monthly_limit must not be negative")
+	}
+	if b.WarningPercent < 0 || b.WarningPercent > 100 {
+		return nil, appErr.New(appErr.CodeInvalid, "warning_percent must be between 0 and 100")
+	}
+	switch b.Enforcement {
+	case BudgetHard, BudgetSoft:
+	case "":
+		b.Enforcement = BudgetHard
+	default:
+		return nil, appErr.New(appErr.CodeInvalid, "enforcement must be hard or soft")
+	}
+	return b, nil
+}
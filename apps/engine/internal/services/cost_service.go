@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/cloud/aws"
+	"github.com/iac-studio/engine/internal/cloud/azure"
+	"github.com/iac-studio/engine/internal/cloud/digitalocean"
+	"github.com/iac-studio/engine/internal/cloud/gcp"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// NewCostEstimator loads the price catalog from dir, or the built-in one
+// when dir is empty, and registers the pricers of every supported provider.
+func NewCostEstimator(dir string) (*cloud.Estimator, error) {
+	var catalog *cloud.Catalog
+	var err error
+	if dir == "" {
+		catalog, err = cloud.DefaultCatalog()
+	} else {
+		catalog, err = cloud.LoadCatalog(os.DirFS(dir))
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := cloud.NewEstimator(catalog)
+	e.RegisterPricer("aws", aws.Pricer{})
+	e.RegisterPricer("gcp", gcp.Pricer{})
+	e.RegisterPricer("azure", azure.Pricer{})
+	e.RegisterPricer("do", digitalocean.Pricer{})
+	return e, nil
+}
+
+// CostService estimates the monthly cost of project graphs from the
+// offline price catalog.
+type CostService interface {
+	// EstimateProject prices a saved graph version, the current one when
+	// version is 0.
+	EstimateProject(ctx context.Context, projectID, userID uuid.UUID, version int) (*cloud.Estimate, error)
+	// EstimateGraph prices a graph that has not been saved.
+	EstimateGraph(ctx context.Context, projectID, userID uuid.UUID, data *GraphData) (*cloud.Estimate, error)
+	// Estimate prices graph in the project's provider and region.
+	Estimate(p *models.Project, graph provisioner.Graph) (*cloud.Estimate, error)
+	// Diff compares the cost of two saved graph versions.
+	Diff(ctx context.Context, projectID, userID uuid.UUID, from, to int) (*VersionCostDiff, error)
+	// DiffPrevious compares est, the estimate of graph version g, with the
+	// version before it. It returns nil when g is the first version.
+	DiffPrevious(ctx context.Context, p *models.Project, g *models.ProjectGraph, est *cloud.Estimate) (*cloud.CostDiff, error)
+	Catalog() []cloud.CatalogInfo
+	// RecordBudgetOverride audits a deployment created over a soft budget.
+	RecordBudgetOverride(ctx context.Context, o *models.BudgetOverride) error
+	ListBudgetOverrides(ctx context.Context, projectID, userID uuid.UUID) ([]models.BudgetOverride, error)
+}
+
+// A cost jump is an increase of at least CostJumpPercent and
+// CostJumpMinimum a month; it is flagged on the deployment that makes it.
+const (
+	CostJumpPercent = 20
+	CostJumpMinimum = 10
+)
+
+// CostJump reports whether d raises the monthly cost enough to warn about.
+// A graph that was free before jumps once it costs CostJumpMinimum.
+func CostJump(d *cloud.CostDiff) bool {
+	if d == nil || d.Delta < CostJumpMinimum {
+		return false
+	}
+	return d.FromCost == 0 || d.DeltaPercent >= CostJumpPercent
+}
+
+// VersionCostDiff is the cost change from one graph version to another.
+type VersionCostDiff struct {
+	ProjectID   uuid.UUID `json:"project_id"`
+	FromVersion int       `json:"from_version"`
+	ToVersion   int       `json:"to_version"`
+	cloud.CostDiff
+}
+
+type costService struct {
+	projectRepo repository.ProjectRepository
+	graphRepo   repository.GraphRepository
+	budgetRepo  repository.BudgetRepository
+	estimator   *cloud.Estimator
+}
+
+func NewCostService(projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, budgetRepo repository.BudgetRepository, estimator *cloud.Estimator) CostService {
+	return &costService{projectRepo: projectRepo, graphRepo: graphRepo, budgetRepo: budgetRepo, estimator: estimator}
+}
+
+var _ CostService = (*costService)(nil)
+
+func (s *costService) EstimateProject(ctx context.Context, projectID, userID uuid.UUID, version int) (*cloud.Estimate, error) {
+	logger.L().Info("estimate project cost", zap.String("project_id", projectID.String()), zap.Int("version", version), zap.String("user_id", userID.String()))
+	p, err := s.ownedProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var g models.ProjectGraph
+	if version > 0 {
+		err = s.graphRepo.GetByVersion(ctx, projectID, version, &g)
+	} else {
+		err = s.graphRepo.GetCurrentByProject(ctx, projectID, &g)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.estimateVersion(p, &g)
+}
+
+func (s *costService) EstimateGraph(ctx context.Context, projectID, userID uuid.UUID, data *GraphData) (*cloud.Estimate, error) {
+	p, err := s.ownedProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var graph provisioner.Graph
+	for _, n := range data.Nodes {
+		graph.Nodes = append(graph.Nodes, provisioner.Node{ID: n.ID, Type: n.Type, Properties: n.Properties})
+	}
+	for _, e := range data.Edges {
+		graph.Edges = append(graph.Edges, provisioner.Edge{ID: e.ID, From: e.From, To: e.To, Type: e.Type})
+	}
+	return s.Estimate(p, graph)
+}
+
+func (s *costService) Estimate(p *models.Project, graph provisioner.Graph) (*cloud.Estimate, error) {
+	est, err := s.estimator.Estimate(p.CloudProvider, projectRegion(p), graph)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInvalid, err.Error())
+	}
+	return est, nil
+}
+
+func (s *costService) Diff(ctx context.Context, projectID, userID uuid.UUID, from, to int) (*VersionCostDiff, error) {
+	logger.L().Info("diff project cost", zap.String("project_id", projectID.String()), zap.Int("from", from), zap.Int("to", to), zap.String("user_id", userID.String()))
+	p, err := s.ownedProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var fromGraph, toGraph models.ProjectGraph
+	if err := s.graphRepo.GetByVersion(ctx, projectID, from, &fromGraph); err != nil {
+		return nil, err
+	}
+	if err := s.graphRepo.GetByVersion(ctx, projectID, to, &toGraph); err != nil {
+		return nil, err
+	}
+	fromEst, err := s.estimateVersion(p, &fromGraph)
+	if err != nil {
+		return nil, err
+	}
+	toEst, err := s.estimateVersion(p, &toGraph)
+	if err != nil {
+		return nil, err
+	}
+	return &VersionCostDiff{ProjectID: projectID, FromVersion: from, ToVersion: to, CostDiff: *cloud.Diff(fromEst, toEst)}, nil
+}
+
+func (s *costService) DiffPrevious(ctx context.Context, p *models.Project, g *models.ProjectGraph, est *cloud.Estimate) (*cloud.CostDiff, error) {
+	if g.Version <= 1 {
+		return nil, nil
+	}
+	var prev models.ProjectGraph
+	if err := s.graphRepo.GetByVersion(ctx, p.ID, g.Version-1, &prev); err != nil {
+		if appErr.IsCode(err, appErr.CodeNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	prevEst, err := s.estimateVersion(p, &prev)
+	if err != nil {
+		return nil, err
+	}
+	return cloud.Diff(prevEst, est), nil
+}
+
+func (s *costService) estimateVersion(p *models.Project, g *models.ProjectGraph) (*cloud.Estimate, error) {
+	graph, err := provisionerGraph(g)
+	if err != nil {
+		return nil, err
+	}
+	return s.Estimate(p, graph)
+}
+
+func (s *costService) Catalog() []cloud.CatalogInfo {
+	return s.estimator.Catalog().Info()
+}
+
+func (s *costService) RecordBudgetOverride(ctx context.Context, o *models.BudgetOverride) error {
+	return s.budgetRepo.CreateOverride(ctx, o)
+}
+
+func (s *costService) ListBudgetOverrides(ctx context.Context, projectID, userID uuid.UUID) ([]models.BudgetOverride, error) {
+	if _, err := s.ownedProject(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	return s.budgetRepo.ListOverrides(ctx, projectID)
+}
+
+func (s *costService) ownedProject(ctx context.Context, projectID, userID uuid.UUID) (*models.Project, error) {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return &p, nil
+}
+
+// projectRegion is the region set in the project settings, if any.
+func projectRegion(p *models.Project) string {
+	var settings map[string]interface{}
+	if len(p.Settings) > 0 {
+		_ = json.Unmarshal(p.Settings, &settings)
+	}
+	region, _ := settings["region"].(string)
+	return region
+}
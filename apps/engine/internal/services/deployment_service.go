@@ -3,11 +3,18 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/events"
 	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/queue"
 	"github.com/iac-studio/engine/internal/repository"
 	appErr "github.com/iac-studio/engine/pkg/errors"
 	"github.com/iac-studio/engine/pkg/logger"
@@ -22,45 +29,173 @@ type DeploymentService interface {
 	CreateDeployment(ctx context.Context, projectID, userID uuid.UUID, input *CreateDeploymentInput) (*models.Deployment, error)
 	GetDeployment(ctx context.Context, deploymentID, userID uuid.UUID) (*models.Deployment, error)
 	ListDeployments(ctx context.Context, projectID, userID uuid.UUID, filters *DeploymentFilters) ([]models.Deployment, error)
-	GetDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID) ([]DeploymentLog, error)
+	// GetDeploymentLogs returns one page of log lines matching q.
+	GetDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID, q repository.LogQuery) (*LogPage, error)
+	// ExportDeploymentLogs passes every log line matching q to fn, in
+	// pages, for downloads.
+	ExportDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID, q repository.LogQuery, fn func([]models.DeploymentLog) error) error
+
+	// CreatePlan starts a plan-only run of a graph version: what applying it
+	// would change, compared with the project's live deployment.
+	CreatePlan(ctx context.Context, projectID, userID uuid.UUID, input *PlanInput) (*models.Deployment, error)
+	// GetPlan returns the change set of a finished plan.
+	GetPlan(ctx context.Context, deploymentID, userID uuid.UUID) (*provisioner.Plan, error)
 
 	// Actions
-	DestroyDeployment(ctx context.Context, deploymentID, userID uuid.UUID) error
+	// DestroyDeployment destroys the whole deployment, or only nodeIDs and
+	// the nodes depending on them when any are given.
+	DestroyDeployment(ctx context.Context, deploymentID, userID uuid.UUID, nodeIDs []string) error
+	PreviewDestroy(ctx context.Context, deploymentID, userID uuid.UUID, nodeIDs []string) (*DestroyPreview, error)
+	// CancelDeployment withdraws a deployment that is still queued, or one
+	// stuck planning or applying whose task is gone. A stuck run that may
+	// have changed infrastructure is failed instead, so it can be destroyed.
 	CancelDeployment(ctx context.Context, deploymentID, userID uuid.UUID) error
+	// GetQueueStatus reports where a deployment stands in its project queue.
+	GetQueueStatus(ctx context.Context, deploymentID, userID uuid.UUID) (*QueueStatus, error)
+	// GetStatusHistory returns the deployment's status changes and how long
+	// it spent in each phase.
+	GetStatusHistory(ctx context.Context, deploymentID, userID uuid.UUID) (*StatusHistory, error)
+	// ExtendDeploymentTTL pushes back (or sets) when an ephemeral deployment
+	// is destroyed automatically.
+	ExtendDeploymentTTL(ctx context.Context, deploymentID, userID uuid.UUID, input *ExtendTTLInput) (*models.Deployment, error)
 
 	// Status updates (called by worker)
-	UpdateDeploymentStatus(ctx context.Context, deploymentID uuid.UUID, status string) error
+	// UpdateDeploymentStatus fails with CodeConflict when the transition is
+	// not allowed; see models.DeploymentStatus.
+	UpdateDeploymentStatus(ctx context.Context, deploymentID uuid.UUID, status models.DeploymentStatus) error
 	SaveDeploymentOutputs(ctx context.Context, deploymentID uuid.UUID, outputs map[string]interface{}) error
 	SaveTerraformState(ctx context.Context, deploymentID uuid.UUID, state []byte) error
+	SaveDeploymentPlan(ctx context.Context, deploymentID uuid.UUID, plan *provisioner.Plan) error
 	AppendLog(ctx context.Context, deploymentID uuid.UUID, log DeploymentLog) error
 }
 
 type CreateDeploymentInput struct {
 	GraphID uuid.UUID
+	// Environment is a free-form type such as "preview" used to pick the
+	// project's default TTL.
+	Environment string
+	// TTL overrides the project default; zero uses the default, if any.
+	TTL time.Duration
+	// Supersede cancels deployments of the project still waiting in the
+	// queue, so only the newest one runs.
+	Supersede bool
+	// OverrideBudget deploys over a soft project budget, or under a hard
+	// one when the graph could not be priced; the override is audited with
+	// OverrideReason.
+	OverrideBudget bool
+	OverrideReason string
+}
+
+// PlanInput selects what a plan-only run compares.
+type PlanInput struct {
+	// GraphVersion is the graph version to plan; zero plans the latest.
+	GraphVersion int
+	// BaseDeploymentID is the deployment whose state the plan starts from;
+	// nil picks the project's latest applied deployment.
+	BaseDeploymentID *uuid.UUID
+}
+
+// QueueStatus is where a deployment stands in its project's FIFO queue.
+// Position 1 is the deployment running or about to run; 0 means it has left
+// the queue (finished, failed, cancelled or superseded).
+type QueueStatus struct {
+	DeploymentID uuid.UUID               `json:"deployment_id"`
+	ProjectID    uuid.UUID               `json:"project_id"`
+	Status       models.DeploymentStatus `json:"status"`
+	Position     int                     `json:"position"`
+	Ahead        []uuid.UUID             `json:"ahead"`
+}
+
+// StatusHistory is a deployment's status timeline. Phases cover the time
+// from creation to now; the last one is still running unless the
+// deployment reached a terminal status.
+type StatusHistory struct {
+	DeploymentID uuid.UUID                        `json:"deployment_id"`
+	Status       models.DeploymentStatus          `json:"status"`
+	Transitions  []models.DeploymentStatusHistory `json:"transitions"`
+	Phases       []PhaseDuration                  `json:"phases"`
+	// Totals sums the seconds spent in each status over all phases, e.g. to
+	// compare apply times across deployments.
+	Totals map[models.DeploymentStatus]float64 `json:"totals"`
+}
+
+// PhaseDuration is one uninterrupted stretch in a status.
+type PhaseDuration struct {
+	Status    models.DeploymentStatus `json:"status"`
+	Actor     string                  `json:"actor,omitempty"`
+	StartedAt time.Time               `json:"started_at"`
+	EndedAt   *time.Time              `json:"ended_at,omitempty"`
+	Seconds   float64                 `json:"seconds"`
 }
 
+// ExtendTTLInput sets either a new absolute expiry or extends the current one.
+type ExtendTTLInput struct {
+	ExtendBy  time.Duration
+	ExpiresAt *time.Time
+}
+
+// MaxDeploymentTTL bounds how far in the future a deployment may expire.
+const MaxDeploymentTTL = 90 * 24 * time.Hour
+
 type DeploymentFilters struct {
 	Status   string
 	Page     int
 	PageSize int
 }
 
+// DestroyPreview lists what a destroy would remove, in graph terms and as
+// Terraform resource addresses. Resources include those a node's resource
+// takes down with it, which are not nodes of their own.
+type DestroyPreview struct {
+	Requested  []string `json:"requested"`
+	Dependents []string `json:"dependents"`
+	Nodes      []string `json:"nodes"`
+	Resources  []string `json:"resources"`
+	Full       bool     `json:"full"`
+}
+
 type DeploymentLog struct {
 	Timestamp time.Time              `json:"timestamp"`
 	Level     string                 `json:"level"`
+	Phase     string                 `json:"phase,omitempty"`
 	Message   string                 `json:"message"`
 	Data      map[string]interface{} `json:"data,omitempty"`
 }
 
+// Log page sizes.
+const (
+	DefaultLogPageSize = 200
+	MaxLogPageSize     = 1000
+)
+
+// LogPage is a page of deployment log lines. Pass NextSeq as the next
+// query's AfterSeq to continue.
+type LogPage struct {
+	Items   []models.DeploymentLog `json:"items"`
+	NextSeq int64                  `json:"next_seq"`
+	HasMore bool                   `json:"has_more"`
+}
+
 type deploymentService struct {
 	db          *gorm.DB
 	projectRepo repository.ProjectRepository
 	deployRepo  repository.DeploymentRepository
+	stateRepo   repository.StateVersionRepository
+	logRepo     repository.DeploymentLogRepository
+	// logs batches AppendLog writes; nil writes every line directly
+	logs        *LogBatcher
 	asynqClient *asynq.Client
+	// inspector finds runs whose task is gone; nil treats every run as live
+	inspector *asynq.Inspector
+	// costs estimates new deployments; nil skips the estimate
+	costs CostService
+	// publisher gets deployment failures and budget overruns; nil
+	// publishes nothing
+	publisher events.Publisher
 }
 
-func NewDeploymentService(db *gorm.DB, projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, client *asynq.Client) DeploymentService {
-	return &deploymentService{db: db, projectRepo: projectRepo, deployRepo: deployRepo, asynqClient: client}
+func NewDeploymentService(db *gorm.DB, projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, stateRepo repository.StateVersionRepository, logRepo repository.DeploymentLogRepository, logs *LogBatcher, client *asynq.Client, inspector *asynq.Inspector, costs CostService, publisher events.Publisher) DeploymentService {
+	return &deploymentService{db: db, projectRepo: projectRepo, deployRepo: deployRepo, stateRepo: stateRepo, logRepo: logRepo, logs: logs, asynqClient: client, inspector: inspector, costs: costs, publisher: publisher}
 }
 
 var _ DeploymentService = (*deploymentService)(nil)
@@ -97,44 +232,326 @@ func (s *deploymentService) CreateDeployment(ctx context.Context, projectID, use
 		}
 	}
 
-	// optional: prevent multiple active deployments for the same project
-	var latest models.Deployment
-	if err := s.deployRepo.GetLatestByProject(ctx, projectID, &latest); err == nil {
-		if latest.Status == "pending" || latest.Status == "planning" || latest.Status == "applying" {
-			return nil, appErr.New(appErr.CodeConflict, "another active deployment exists for project")
+	ttl := input.TTL
+	if ttl == 0 && input.Environment != "" {
+		defaults, err := ParseEnvironmentTTLs(p.EnvironmentTTLs)
+		if err != nil {
+			return nil, err
 		}
+		ttl = defaults[input.Environment]
+	}
+	if ttl < 0 || ttl > MaxDeploymentTTL {
+		return nil, appErr.New(appErr.CodeInvalid, "ttl must be between 0 and "+MaxDeploymentTTL.String())
 	}
 
 	d := &models.Deployment{
-		ProjectID: projectID,
-		GraphID:   graph.ID,
-		Status:    "pending",
+		ProjectID:   projectID,
+		GraphID:     graph.ID,
+		Status:      models.DeploymentPending,
+		Environment: input.Environment,
+		CreatedBy:   &userID,
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		d.ExpiresAt = &expiresAt
+	}
+	est := s.priceDeployment(ctx, &p, &graph, d)
+	override, err := s.checkBudget(&p, est, d, input)
+	if err != nil {
+		var ae *appErr.AppError
+		if errors.As(err, &ae) && ae.Code == appErr.CodeBudgetExceeded {
+			data := map[string]interface{}{"message": ae.Message, "overridden": false}
+			if est != nil {
+				data["currency"] = est.Currency
+			}
+			for k, v := range ae.Meta {
+				data[k] = v
+			}
+			s.publish(ctx, events.New(events.BudgetExceeded, projectID, uuid.Nil, data))
+		}
+		return nil, err
 	}
 
 	if err := s.deployRepo.Create(ctx, d); err != nil {
 		return nil, err
 	}
+	if override != nil {
+		override.DeploymentID = d.ID
+		if err := s.costs.RecordBudgetOverride(ctx, override); err != nil {
+			// an override that cannot be audited does not deploy
+			_ = s.deployRepo.UpdateStatus(ctx, d.ID, models.DeploymentFailed)
+			return nil, err
+		}
+		s.publish(ctx, events.New(events.BudgetExceeded, projectID, d.ID, map[string]interface{}{
+			"message":        fmt.Sprintf("deployed over the soft budget of %.2f %s at an estimated %.2f %s a month", override.MonthlyLimit, override.Currency, override.EstimatedCost, override.Currency),
+			"monthly_limit":  override.MonthlyLimit,
+			"estimated_cost": override.EstimatedCost,
+			"currency":       override.Currency,
+			"enforcement":    string(BudgetSoft),
+			"overridden":     true,
+		}))
+	}
 
 	// enqueue provision job
 	payload := map[string]string{"deployment_id": d.ID.String()}
 	pb, _ := json.Marshal(payload)
-	task := asynq.NewTask("deployment:provision", pb)
+	task := asynq.NewTask(queue.TypeProvision, pb)
 	if s.asynqClient == nil {
 		logger.L().Warn("asynq client not configured, skipping enqueue", zap.String("deployment_id", d.ID.String()))
 	} else {
-		if _, err := s.asynqClient.EnqueueContext(ctx, task); err != nil {
+		if _, err := s.asynqClient.EnqueueContext(ctx, task, queue.DeploymentTaskOptions(queue.TypeProvision, d.ID)...); err != nil {
 			// best-effort: mark deployment failed if enqueue fails
 			logger.L().Error("enqueue provision task failed", zap.Error(err), zap.String("deployment_id", d.ID.String()))
 			// try to update status
-			_ = s.deployRepo.UpdateStatus(ctx, d.ID, "failed")
+			_ = s.deployRepo.UpdateStatus(ctx, d.ID, models.DeploymentFailed)
 			return nil, appErr.Wrap(err, appErr.CodeInternal, "enqueue provision task failed")
 		}
 	}
 
-	logger.L().Info("deployment created and enqueued", zap.String("deployment_id", d.ID.String()), zap.String("project_id", projectID.String()))
+	s.publish(ctx, events.New(events.DeploymentCreated, projectID, d.ID, deploymentEventData(d)))
+
+	// deployments of a project run one at a time in creation order; the
+	// worker enforces it, here we only report where the new one stands
+	if input.Supersede {
+		n, err := s.deployRepo.SupersedeQueued(ctx, projectID, d.ID)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			logger.L().Info("queued deployments superseded", zap.String("project_id", projectID.String()), zap.Int64("count", n))
+		}
+	}
+	if qs, err := s.queueStatus(ctx, d); err == nil {
+		d.QueuePosition = qs.Position
+	}
+
+	logger.L().Info("deployment created and enqueued", zap.String("deployment_id", d.ID.String()), zap.String("project_id", projectID.String()), zap.Int("queue_position", d.QueuePosition))
+	return d, nil
+}
+
+// priceDeployment stores the estimated cost of the graph on d, returns
+// it, and warns when it costs much more than the version before it. A
+// graph that cannot be priced gets no estimate rather than failing the
+// deployment.
+func (s *deploymentService) priceDeployment(ctx context.Context, p *models.Project, g *models.ProjectGraph, d *models.Deployment) *cloud.Estimate {
+	if s.costs == nil {
+		return nil
+	}
+	graph, err := provisionerGraph(g)
+	if err != nil {
+		logger.L().Warn("cost estimate failed", zap.Error(err), zap.String("project_id", p.ID.String()), zap.Int("graph_version", g.Version))
+		return nil
+	}
+	est, err := s.costs.Estimate(p, graph)
+	if err != nil {
+		logger.L().Warn("cost estimate failed", zap.Error(err), zap.String("project_id", p.ID.String()), zap.Int("graph_version", g.Version))
+		return nil
+	}
+	b, _ := json.Marshal(est)
+	d.CostEstimate = datatypes.JSON(b)
+
+	diff, err := s.costs.DiffPrevious(ctx, p, g, est)
+	if err != nil {
+		logger.L().Warn("cost diff failed", zap.Error(err), zap.String("project_id", p.ID.String()), zap.Int("graph_version", g.Version))
+		return est
+	}
+	if CostJump(diff) {
+		addWarning(d, models.DeploymentWarning{
+			Code:    models.WarningCostJump,
+			Message: fmt.Sprintf("graph version %d raises the estimated monthly cost by %.2f %s (%.0f%%) to %.2f %s", g.Version, diff.Delta, diff.Currency, diff.DeltaPercent, diff.ToCost, diff.Currency),
+			Details: diff,
+		})
+		logger.L().Warn("deployment raises cost", zap.String("project_id", p.ID.String()), zap.Int("graph_version", g.Version), zap.Float64("delta", diff.Delta))
+	}
+	return est
+}
+
+// checkBudget holds d to the project budget. Over budget, a hard budget
+// rejects the deployment and a soft one does unless input overrides it; the
+// returned override is to be audited once d exists. A graph that could not
+// be priced, even in part, is rejected by a hard budget unless overridden,
+// and only warned about by a soft one. Plans, with a nil input, are only
+// warned.
+func (s *deploymentService) checkBudget(p *models.Project, est *cloud.Estimate, d *models.Deployment, input *CreateDeploymentInput) (*models.BudgetOverride, error) {
+	budget, err := ParseBudget(p.Budget)
+	if err != nil {
+		return nil, err
+	}
+	if !budget.Enabled() {
+		return nil, nil
+	}
+	// a partial estimate over the limit is over budget whatever the rest
+	// costs
+	if est == nil || (len(est.Unpriced) > 0 && !budget.Exceeded(est)) {
+		return s.checkUnpriced(p, budget, est, d, input)
+	}
+	if !budget.Exceeded(est) {
+		if budget.Warns(est) {
+			addWarning(d, models.DeploymentWarning{
+				Code:    models.WarningBudgetThreshold,
+				Message: fmt.Sprintf("estimated monthly cost %.2f %s is %.0f%% of the budget of %.2f %s", est.MonthlyCost, est.Currency, est.MonthlyCost/budget.MonthlyLimit*100, budget.MonthlyLimit, est.Currency),
+			})
+		}
+		return nil, nil
+	}
+	if input == nil {
+		addWarning(d, models.DeploymentWarning{Code: models.WarningBudgetExceeded, Message: budget.ExceededError(est).Error()})
+		return nil, nil
+	}
+	if budget.Enforcement == BudgetHard || !input.OverrideBudget {
+		logger.L().Info("deployment rejected over budget", zap.String("project_id", p.ID.String()), zap.Float64("estimated_cost", est.MonthlyCost), zap.Float64("monthly_limit", budget.MonthlyLimit))
+		return nil, budget.ExceededError(est)
+	}
+	addWarning(d, models.DeploymentWarning{
+		Code:    models.WarningBudgetOverridden,
+		Message: fmt.Sprintf("deployed over the soft budget of %.2f %s at an estimated %.2f %s a month", budget.MonthlyLimit, est.Currency, est.MonthlyCost, est.Currency),
+	})
+	logger.L().Warn("budget overridden", zap.String("project_id", p.ID.String()), zap.String("user_id", d.CreatedBy.String()), zap.Float64("estimated_cost", est.MonthlyCost), zap.Float64("monthly_limit", budget.MonthlyLimit))
+	return &models.BudgetOverride{
+		ProjectID:     p.ID,
+		UserID:        *d.CreatedBy,
+		MonthlyLimit:  budget.MonthlyLimit,
+		EstimatedCost: est.MonthlyCost,
+		Currency:      est.Currency,
+		Reason:        input.OverrideReason,
+	}, nil
+}
+
+// checkUnpriced is checkBudget for a graph that could not be priced (nil
+// est) or only in part.
+func (s *deploymentService) checkUnpriced(p *models.Project, budget *Budget, est *cloud.Estimate, d *models.Deployment, input *CreateDeploymentInput) (*models.BudgetOverride, error) {
+	what := "the graph could not be priced"
+	var unpriced interface{}
+	if est != nil {
+		what = fmt.Sprintf("%d node(s) of the graph could not be priced", len(est.Unpriced))
+		unpriced = est.Unpriced
+	}
+	if input == nil || budget.Enforcement != BudgetHard {
+		addWarning(d, models.DeploymentWarning{Code: models.WarningBudgetUnchecked, Message: what + ", so the budget was not checked", Details: unpriced})
+		return nil, nil
+	}
+	if !input.OverrideBudget {
+		logger.L().Info("unpriced deployment rejected by hard budget", zap.String("project_id", p.ID.String()), zap.Float64("monthly_limit", budget.MonthlyLimit))
+		return nil, budget.UncheckedError(est)
+	}
+	addWarning(d, models.DeploymentWarning{
+		Code:    models.WarningBudgetOverridden,
+		Message: fmt.Sprintf("deployed under the hard budget of %.2f a month although %s", budget.MonthlyLimit, what),
+	})
+	logger.L().Warn("budget overridden", zap.String("project_id", p.ID.String()), zap.String("user_id", d.CreatedBy.String()), zap.Float64("monthly_limit", budget.MonthlyLimit))
+	o := &models.BudgetOverride{
+		ProjectID:    p.ID,
+		UserID:       *d.CreatedBy,
+		MonthlyLimit: budget.MonthlyLimit,
+		Reason:       input.OverrideReason,
+	}
+	if est != nil {
+		o.EstimatedCost, o.Currency = est.MonthlyCost, est.Currency
+	}
+	return o, nil
+}
+
+// addWarning appends w to the warnings of d.
+func addWarning(d *models.Deployment, w models.DeploymentWarning) {
+	var warnings []models.DeploymentWarning
+	if len(d.Warnings) > 0 {
+		_ = json.Unmarshal(d.Warnings, &warnings)
+	}
+	b, _ := json.Marshal(append(warnings, w))
+	d.Warnings = datatypes.JSON(b)
+}
+
+func (s *deploymentService) CreatePlan(ctx context.Context, projectID, userID uuid.UUID, input *PlanInput) (*models.Deployment, error) {
+	logger.L().Info("create plan", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()), zap.Int("graph_version", input.GraphVersion))
+
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+
+	var graph models.ProjectGraph
+	q := s.db.WithContext(ctx).Where("project_id = ?", projectID)
+	if input.GraphVersion > 0 {
+		q = q.Where("version = ?", input.GraphVersion)
+	}
+	if err := q.Order("version DESC").First(&graph).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, appErr.New(appErr.CodeNotFound, "graph version not found")
+		}
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "get graph failed")
+	}
+
+	// compare with what runs now, so the plan shows what applying would change
+	var base models.Deployment
+	if input.BaseDeploymentID != nil {
+		if err := s.deployRepo.GetByID(ctx, *input.BaseDeploymentID, &base); err != nil {
+			return nil, err
+		}
+		if base.ProjectID != projectID {
+			return nil, appErr.New(appErr.CodeInvalid, "base deployment does not belong to project")
+		}
+	} else {
+		err := s.db.WithContext(ctx).Omit("terraform_state", "outputs", "plan").
+			Where("project_id = ? AND plan_only = false AND status = ?", projectID, models.DeploymentApplied).
+			Order("created_at DESC").First(&base).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "get live deployment failed")
+		}
+	}
+
+	d := &models.Deployment{
+		ProjectID: projectID,
+		GraphID:   graph.ID,
+		Status:    models.DeploymentPending,
+		PlanOnly:  true,
+		CreatedBy: &userID,
+	}
+	if base.ID != uuid.Nil {
+		d.BaseDeploymentID = &base.ID
+	}
+	est := s.priceDeployment(ctx, &p, &graph, d)
+	if _, err := s.checkBudget(&p, est, d, nil); err != nil {
+		return nil, err
+	}
+	if err := s.deployRepo.Create(ctx, d); err != nil {
+		return nil, err
+	}
+
+	pb, _ := json.Marshal(map[string]string{"deployment_id": d.ID.String()})
+	task := asynq.NewTask(queue.TypePlan, pb)
+	if s.asynqClient == nil {
+		logger.L().Warn("asynq client not configured, skipping plan enqueue", zap.String("deployment_id", d.ID.String()))
+	} else if _, err := s.asynqClient.EnqueueContext(ctx, task, queue.DeploymentTaskOptions(queue.TypePlan, d.ID)...); err != nil {
+		logger.L().Error("enqueue plan task failed", zap.Error(err), zap.String("deployment_id", d.ID.String()))
+		_ = s.deployRepo.UpdateStatus(ctx, d.ID, models.DeploymentFailed)
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "enqueue plan task failed")
+	}
+
+	s.publish(ctx, events.New(events.DeploymentCreated, projectID, d.ID, deploymentEventData(d)))
+
+	logger.L().Info("plan created and enqueued", zap.String("deployment_id", d.ID.String()), zap.String("project_id", projectID.String()), zap.Int("graph_version", graph.Version))
 	return d, nil
 }
 
+func (s *deploymentService) GetPlan(ctx context.Context, deploymentID, userID uuid.UUID) (*provisioner.Plan, error) {
+	logger.L().Info("get plan", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.GetDeployment(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.Plan) == 0 {
+		return nil, appErr.New(appErr.CodeNotFound, "deployment has no plan yet")
+	}
+	var plan provisioner.Plan
+	if err := json.Unmarshal(d.Plan, &plan); err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "decode plan failed")
+	}
+	return &plan, nil
+}
+
 func (s *deploymentService) GetDeployment(ctx context.Context, deploymentID, userID uuid.UUID) (*models.Deployment, error) {
 	logger.L().Info("get deployment", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
 	var d models.Deployment
@@ -163,93 +580,407 @@ func (s *deploymentService) ListDeployments(ctx context.Context, projectID, user
 	return s.deployRepo.ListByProject(ctx, projectID)
 }
 
-func (s *deploymentService) GetDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID) ([]DeploymentLog, error) {
+func (s *deploymentService) GetDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID, q repository.LogQuery) (*LogPage, error) {
 	logger.L().Info("get deployment logs", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
-	// validate ownership via deployment -> project -> user
-	var d models.Deployment
-	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+	if _, err := s.GetDeployment(ctx, deploymentID, userID); err != nil {
 		return nil, err
 	}
-	var p models.Project
-	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
-		return nil, err
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultLogPageSize
 	}
-	if p.UserID != userID {
-		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	if q.Limit > MaxLogPageSize {
+		q.Limit = MaxLogPageSize
+	}
+	// one extra line tells whether another page follows
+	limit := q.Limit
+	q.Limit++
+	items, err := s.logRepo.List(ctx, deploymentID, q)
+	if err != nil {
+		return nil, err
 	}
 
-	var out []DeploymentLog
-	if len(d.Outputs) == 0 {
-		return out, nil
+	page := &LogPage{Items: items, NextSeq: q.AfterSeq}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.HasMore = true
 	}
-	var raw map[string]any
-	if err := json.Unmarshal(d.Outputs, &raw); err != nil {
-		return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal outputs failed")
+	if n := len(page.Items); n > 0 {
+		page.NextSeq = page.Items[n-1].Seq
 	}
-	if logs, ok := raw["logs"]; ok {
-		b, _ := json.Marshal(logs)
-		_ = json.Unmarshal(b, &out) // ignore error, return what we can
+	return page, nil
+}
+
+func (s *deploymentService) ExportDeploymentLogs(ctx context.Context, deploymentID, userID uuid.UUID, q repository.LogQuery, fn func([]models.DeploymentLog) error) error {
+	logger.L().Info("export deployment logs", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	if _, err := s.GetDeployment(ctx, deploymentID, userID); err != nil {
+		return err
+	}
+
+	q.Limit = MaxLogPageSize
+	for {
+		items, err := s.logRepo.List(ctx, deploymentID, q)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+		if len(items) < q.Limit {
+			return nil
+		}
+		q.AfterSeq = items[len(items)-1].Seq
 	}
-	return out, nil
 }
 
-func (s *deploymentService) DestroyDeployment(ctx context.Context, deploymentID, userID uuid.UUID) error {
-	logger.L().Info("destroy deployment requested", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+func (s *deploymentService) DestroyDeployment(ctx context.Context, deploymentID, userID uuid.UUID, nodeIDs []string) error {
+	logger.L().Info("destroy deployment requested", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()), zap.Strings("node_ids", nodeIDs))
 	// ensure user owns the project
-	var d models.Deployment
-	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+	d, err := s.GetDeployment(ctx, deploymentID, userID)
+	if err != nil {
 		return err
 	}
-	var p models.Project
-	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
-		return err
+	if d.PlanOnly {
+		return appErr.New(appErr.CodeConflict, "plan-only deployments have nothing to destroy")
 	}
-	if p.UserID != userID {
-		return appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	switch d.Status {
+	case models.DeploymentPending, models.DeploymentPlanning, models.DeploymentAwaitingApproval, models.DeploymentApproved, models.DeploymentApplying, models.DeploymentDestroyQueued, models.DeploymentDestroying:
+		return appErr.New(appErr.CodeConflict, "deployment is "+string(d.Status))
+	case models.DeploymentDestroyed, models.DeploymentCancelled, models.DeploymentSuperseded, models.DeploymentRejected:
+		return appErr.New(appErr.CodeConflict, "deployment has nothing to destroy")
+	}
+	ctx = repository.WithActor(ctx, "user:"+userID.String())
+
+	// resolve dependents up front so the worker destroys a consistent set
+	payload := map[string]interface{}{"deployment_id": d.ID.String()}
+	if len(nodeIDs) > 0 {
+		preview, err := s.previewDestroy(ctx, d, nodeIDs)
+		if err != nil {
+			return err
+		}
+		payload["targets"] = preview.Nodes
 	}
 
 	// enqueue destroy job
-	payload := map[string]string{"deployment_id": d.ID.String()}
 	pb, _ := json.Marshal(payload)
-	task := asynq.NewTask("deployment:destroy", pb)
+	task := asynq.NewTask(queue.TypeDestroy, pb)
 	if s.asynqClient == nil {
 		logger.L().Warn("asynq client not configured, skipping destroy enqueue", zap.String("deployment_id", d.ID.String()))
 	} else {
-		if _, err := s.asynqClient.EnqueueContext(ctx, task); err != nil {
+		if _, err := s.asynqClient.EnqueueContext(ctx, task, queue.DeploymentTaskOptions(queue.TypeDestroy, d.ID)...); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				return appErr.New(appErr.CodeConflict, "a destroy task for this deployment is already queued or archived")
+			}
 			logger.L().Error("enqueue destroy task failed", zap.Error(err), zap.String("deployment_id", d.ID.String()))
 			return appErr.Wrap(err, appErr.CodeInternal, "enqueue destroy task failed")
 		}
 	}
-	// mark as pending -> worker will set appropriate status
-	_ = s.deployRepo.UpdateStatus(ctx, d.ID, "pending")
+	// a queued destroy is not a queued provision: it cannot be cancelled or
+	// superseded while the infrastructure is live
+	if err := s.deployRepo.UpdateStatus(ctx, d.ID, models.DeploymentDestroyQueued); err != nil {
+		logger.L().Warn("update status destroy_queued failed", zap.String("deployment_id", d.ID.String()), zap.Error(err))
+	}
 	return nil
 }
 
+func (s *deploymentService) PreviewDestroy(ctx context.Context, deploymentID, userID uuid.UUID, nodeIDs []string) (*DestroyPreview, error) {
+	logger.L().Info("preview destroy", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.GetDeployment(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.previewDestroy(ctx, d, nodeIDs)
+}
+
+// previewDestroy expands nodeIDs with their transitive dependents in the
+// deployed graph. No node IDs means every node is destroyed.
+func (s *deploymentService) previewDestroy(ctx context.Context, d *models.Deployment, nodeIDs []string) (*DestroyPreview, error) {
+	var g models.ProjectGraph
+	if err := s.db.WithContext(ctx).First(&g, "id = ?", d.GraphID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, appErr.New(appErr.CodeNotFound, "graph not found")
+		}
+		return nil, appErr.Wrap(err, appErr.CodeInternal, "get graph failed")
+	}
+	var graph compiler.Graph
+	if len(g.Nodes) > 0 {
+		if err := json.Unmarshal(g.Nodes, &graph.Nodes); err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal nodes failed")
+		}
+	}
+	if len(g.Edges) > 0 {
+		if err := json.Unmarshal(g.Edges, &graph.Edges); err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal edges failed")
+		}
+	}
+	return destroyPreview(graph, nodeIDs)
+}
+
+// destroyPreview computes the preview of destroying nodeIDs from graph.
+func destroyPreview(graph compiler.Graph, nodeIDs []string) (*DestroyPreview, error) {
+	preview := &DestroyPreview{Requested: nodeIDs, Dependents: []string{}, Full: len(nodeIDs) == 0}
+	selected := map[string]bool{}
+	if preview.Full {
+		for _, n := range graph.Nodes {
+			selected[n.ID] = true
+		}
+	} else {
+		dependents, err := compiler.Dependents(graph, nodeIDs)
+		if err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInvalid, err.Error())
+		}
+		preview.Dependents = dependents
+		for _, id := range append(append([]string{}, nodeIDs...), dependents...) {
+			selected[id] = true
+		}
+	}
+
+	// keep graph order so the preview reads like the canvas
+	for _, n := range graph.Nodes {
+		if selected[n.ID] {
+			preview.Nodes = append(preview.Nodes, n.ID)
+			preview.Resources = append(preview.Resources, compiler.ResourceAddresses(n)...)
+		}
+	}
+	return preview, nil
+}
+
 func (s *deploymentService) CancelDeployment(ctx context.Context, deploymentID, userID uuid.UUID) error {
 	logger.L().Info("cancel deployment", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
-	var d models.Deployment
-	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+	d, err := s.GetDeployment(ctx, deploymentID, userID)
+	if err != nil {
 		return err
 	}
-	var p models.Project
-	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
+
+	// only queued deployments can be cancelled; the worker skips them when
+	// their task comes up. Deployments with state (or, before checkpoints
+	// existed, queued for a destroy as pending) have live infrastructure.
+	provisioned := d.Checkpoint != "" || len(d.TerraformState) > 0
+	status := models.DeploymentCancelled
+	switch d.Status {
+	case models.DeploymentPending, models.DeploymentAwaitingApproval, models.DeploymentApproved:
+		if provisioned {
+			return appErr.New(appErr.CodeConflict, "deployment has already been provisioned")
+		}
+	case models.DeploymentPlanning, models.DeploymentApplying:
+		// a run whose task was deleted or lost would head the project
+		// queue for good
+		typ := queue.TypeProvision
+		if d.PlanOnly {
+			typ = queue.TypePlan
+		}
+		live, err := liveDeploymentTask(s.inspector, typ, d.ID)
+		if err != nil {
+			return err
+		}
+		if live {
+			return appErr.New(appErr.CodeConflict, "deployment is already running")
+		}
+		if provisioned {
+			status = models.DeploymentFailed
+		}
+	case models.DeploymentDestroyQueued:
+		return appErr.New(appErr.CodeConflict, "a destroy is queued; queued destroys cannot be cancelled")
+	default:
+		return appErr.New(appErr.CodeConflict, "deployment is not queued")
+	}
+	if err := s.deployRepo.UpdateStatus(repository.WithActor(ctx, "user:"+userID.String()), d.ID, status); err != nil {
 		return err
 	}
-	if p.UserID != userID {
-		return appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	logger.L().Info("deployment cancelled", zap.String("deployment_id", deploymentID.String()), zap.String("status", string(status)))
+	return nil
+}
+
+func (s *deploymentService) GetQueueStatus(ctx context.Context, deploymentID, userID uuid.UUID) (*QueueStatus, error) {
+	logger.L().Info("get deployment queue status", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.GetDeployment(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.queueStatus(ctx, d)
+}
+
+func (s *deploymentService) queueStatus(ctx context.Context, d *models.Deployment) (*QueueStatus, error) {
+	items, err := s.deployRepo.ListQueue(ctx, d.ProjectID)
+	if err != nil {
+		return nil, err
 	}
+	qs := &QueueStatus{DeploymentID: d.ID, ProjectID: d.ProjectID, Status: d.Status, Ahead: []uuid.UUID{}}
+	for i, item := range items {
+		if item.ID == d.ID {
+			qs.Position = i + 1
+			return qs, nil
+		}
+		qs.Ahead = append(qs.Ahead, item.ID)
+	}
+	// no longer queued
+	qs.Ahead = []uuid.UUID{}
+	return qs, nil
+}
 
-	// We don't have a dedicated cancel flow in worker yet; mark as failed and log.
-	if err := s.deployRepo.UpdateStatus(ctx, d.ID, "failed"); err != nil {
+func (s *deploymentService) GetStatusHistory(ctx context.Context, deploymentID, userID uuid.UUID) (*StatusHistory, error) {
+	logger.L().Info("get deployment status history", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.GetDeployment(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := s.deployRepo.ListStatusHistory(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	phases := PhaseDurations(d, transitions, time.Now())
+	totals := map[models.DeploymentStatus]float64{}
+	for _, p := range phases {
+		totals[p.Status] += p.Seconds
+	}
+	return &StatusHistory{DeploymentID: d.ID, Status: d.Status, Transitions: transitions, Phases: phases, Totals: totals}, nil
+}
+
+// PhaseDurations splits the time since d was created into phases at each
+// transition. Deployments created before the history existed show a single
+// phase in their current status.
+func PhaseDurations(d *models.Deployment, transitions []models.DeploymentStatusHistory, now time.Time) []PhaseDuration {
+	cur := PhaseDuration{Status: d.Status, StartedAt: d.CreatedAt}
+	if len(transitions) > 0 {
+		cur.Status = transitions[0].FromStatus
+	}
+	phases := make([]PhaseDuration, 0, len(transitions)+1)
+	for _, t := range transitions {
+		ended := t.CreatedAt
+		cur.EndedAt = &ended
+		cur.Seconds = ended.Sub(cur.StartedAt).Seconds()
+		phases = append(phases, cur)
+		cur = PhaseDuration{Status: t.ToStatus, Actor: t.Actor, StartedAt: t.CreatedAt}
+	}
+	// a terminal status has no duration worth reporting
+	if !cur.Status.Terminal() {
+		cur.Seconds = now.Sub(cur.StartedAt).Seconds()
+	}
+	return append(phases, cur)
+}
+
+func (s *deploymentService) ExtendDeploymentTTL(ctx context.Context, deploymentID, userID uuid.UUID, input *ExtendTTLInput) (*models.Deployment, error) {
+	logger.L().Info("extend deployment ttl", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.GetDeployment(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status == models.DeploymentDestroyQueued || d.Status == models.DeploymentDestroying || d.Status == models.DeploymentDestroyed {
+		return nil, appErr.New(appErr.CodeConflict, "deployment is "+string(d.Status))
+	}
+
+	now := time.Now()
+	var expiresAt time.Time
+	switch {
+	case input.ExpiresAt != nil:
+		expiresAt = *input.ExpiresAt
+	case input.ExtendBy > 0:
+		// extending a deployment without expiry starts from now
+		expiresAt = now.Add(input.ExtendBy)
+		if d.ExpiresAt != nil && d.ExpiresAt.After(now) {
+			expiresAt = d.ExpiresAt.Add(input.ExtendBy)
+		}
+	default:
+		return nil, appErr.New(appErr.CodeInvalid, "either extend_by or expires_at is required")
+	}
+	if !expiresAt.After(now) || expiresAt.Sub(now) > MaxDeploymentTTL {
+		return nil, appErr.New(appErr.CodeInvalid, "expiry must be in the future and within "+MaxDeploymentTTL.String())
+	}
+
+	if err := s.deployRepo.SetExpiry(ctx, d.ID, &expiresAt); err != nil {
+		return nil, err
+	}
+	d.ExpiresAt = &expiresAt
+	d.ExpiryWarnedAt = nil
+	logger.L().Info("deployment ttl extended", zap.String("deployment_id", deploymentID.String()), zap.Time("expires_at", expiresAt))
+	return d, nil
+}
+
+// ParseEnvironmentTTLs decodes a project's default TTLs per environment type.
+func ParseEnvironmentTTLs(raw datatypes.JSON) (map[string]time.Duration, error) {
+	out := map[string]time.Duration{}
+	if len(raw) == 0 {
+		return out, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInvalid, "environment ttls must map environment names to durations")
+	}
+	for env, v := range m {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInvalid, "invalid ttl for environment "+env)
+		}
+		if d <= 0 || d > MaxDeploymentTTL {
+			return nil, appErr.New(appErr.CodeInvalid, "ttl for environment "+env+" must be between 0 and "+MaxDeploymentTTL.String())
+		}
+		out[env] = d
+	}
+	return out, nil
+}
+
+func (s *deploymentService) UpdateDeploymentStatus(ctx context.Context, deploymentID uuid.UUID, status models.DeploymentStatus) error {
+	logger.L().Info("update deployment status", zap.String("deployment_id", deploymentID.String()), zap.String("status", string(status)))
+	if !status.Valid() {
+		return appErr.New(appErr.CodeInvalid, "unknown deployment status "+string(status))
+	}
+	// load the deployment first, so a retried task setting the same status
+	// again does not publish its event twice
+	typ, notify := statusEvents[status]
+	var d models.Deployment
+	if notify && s.publisher != nil {
+		if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+			logger.L().Warn("load deployment for event failed", zap.String("deployment_id", deploymentID.String()), zap.Error(err))
+			notify = false
+		} else if d.Status == status {
+			notify = false
+		}
+	}
+	if err := s.deployRepo.UpdateStatus(ctx, deploymentID, status); err != nil {
+		logger.L().Warn("update deployment status failed", zap.String("deployment_id", deploymentID.String()), zap.String("status", string(status)), zap.Error(err))
 		return err
 	}
-	logger.L().Info("deployment cancelled (marked failed)", zap.String("deployment_id", deploymentID.String()))
+	if notify && s.publisher != nil {
+		d.Status = status
+		s.publish(ctx, events.New(typ, d.ProjectID, d.ID, deploymentEventData(&d)))
+	}
 	return nil
 }
 
-func (s *deploymentService) UpdateDeploymentStatus(ctx context.Context, deploymentID uuid.UUID, status string) error {
-	logger.L().Info("update deployment status", zap.String("deployment_id", deploymentID.String()), zap.String("status", status))
-	return s.deployRepo.UpdateStatus(ctx, deploymentID, status)
+// statusEvents maps the deployment statuses that are published to their
+// event type. Only plan-only runs reach planned; full deployments publish
+// deployment.planned from SaveDeploymentPlan.
+var statusEvents = map[models.DeploymentStatus]string{
+	models.DeploymentPlanned:   events.DeploymentPlanned,
+	models.DeploymentApplied:   events.DeploymentApplied,
+	models.DeploymentFailed:    events.DeploymentFailed,
+	models.DeploymentDestroyed: events.DeploymentDestroyed,
+}
+
+// deploymentEventData is the data of a deployment lifecycle event.
+func deploymentEventData(d *models.Deployment) map[string]interface{} {
+	data := map[string]interface{}{
+		"status":    string(d.Status),
+		"graph_id":  d.GraphID.String(),
+		"plan_only": d.PlanOnly,
+	}
+	if d.Environment != "" {
+		data["environment"] = d.Environment
+	}
+	return data
+}
+
+// publish sends e to the publisher, if any; failures are only logged.
+func (s *deploymentService) publish(ctx context.Context, e events.Event) {
+	if s.publisher == nil {
+		return
+	}
+	if err := s.publisher.Publish(ctx, e); err != nil {
+		logger.L().Warn("publish event failed", zap.String("event_id", e.ID), zap.String("type", e.Type), zap.Error(err))
+	}
 }
 
 func (s *deploymentService) SaveDeploymentOutputs(ctx context.Context, deploymentID uuid.UUID, outputs map[string]interface{}) error {
@@ -268,55 +999,81 @@ func (s *deploymentService) SaveDeploymentOutputs(ctx context.Context, deploymen
 	return nil
 }
 
-func (s *deploymentService) SaveTerraformState(ctx context.Context, deploymentID uuid.UUID, state []byte) error {
-	logger.L().Info("save terraform state", zap.String("deployment_id", deploymentID.String()))
-	// store raw bytes as JSON (worker may compress/encode before calling)
-	js := datatypes.JSON(state)
-	res := s.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("terraform_state", js)
+func (s *deploymentService) SaveDeploymentPlan(ctx context.Context, deploymentID uuid.UUID, plan *provisioner.Plan) error {
+	logger.L().Info("save deployment plan", zap.String("deployment_id", deploymentID.String()), zap.Int("changes", plan.Changes))
+	// the raw plan repeats the configuration, including sensitive values
+	stored := *plan
+	stored.PlanOutput = ""
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInvalid, "marshal plan failed")
+	}
+	res := s.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("plan", datatypes.JSON(b))
 	if res.Error != nil {
-		return appErr.Wrap(res.Error, appErr.CodeInternal, "update terraform state failed")
+		return appErr.Wrap(res.Error, appErr.CodeInternal, "update plan failed")
 	}
 	if res.RowsAffected == 0 {
 		return appErr.New(appErr.CodeNotFound, "deployment not found")
 	}
+	s.publishPlanned(ctx, deploymentID, plan)
 	return nil
 }
 
-func (s *deploymentService) AppendLog(ctx context.Context, deploymentID uuid.UUID, logEntry DeploymentLog) error {
-	logger.L().Info("append deployment log", zap.String("deployment_id", deploymentID.String()))
+// publishPlanned publishes deployment.planned for a full deployment whose
+// preflight plan was stored. Plan-only runs publish it when they reach
+// planned; see statusEvents.
+func (s *deploymentService) publishPlanned(ctx context.Context, deploymentID uuid.UUID, plan *provisioner.Plan) {
+	if s.publisher == nil {
+		return
+	}
 	var d models.Deployment
 	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
-		return err
+		logger.L().Warn("load deployment for event failed", zap.String("deployment_id", deploymentID.String()), zap.Error(err))
+		return
 	}
-
-	// read existing outputs -> logs[]
-	var outputs map[string]any
-	if len(d.Outputs) > 0 {
-		if err := json.Unmarshal(d.Outputs, &outputs); err != nil {
-			outputs = map[string]any{}
-		}
-	} else {
-		outputs = map[string]any{}
+	if d.PlanOnly {
+		return
 	}
+	data := deploymentEventData(&d)
+	data["changes"] = plan.Changes
+	data["resource_adds"] = plan.ResourceAdds
+	data["resource_mods"] = plan.ResourceMods
+	data["resource_dels"] = plan.ResourceDels
+	s.publish(ctx, events.New(events.DeploymentPlanned, d.ProjectID, d.ID, data))
+}
 
-	var logs []DeploymentLog
-	if v, ok := outputs["logs"]; ok {
-		b, _ := json.Marshal(v)
-		_ = json.Unmarshal(b, &logs)
+func (s *deploymentService) SaveTerraformState(ctx context.Context, deploymentID uuid.UUID, state []byte) error {
+	logger.L().Info("save terraform state", zap.String("deployment_id", deploymentID.String()))
+	// every write becomes a new state version so a bad write can be rolled back
+	v, err := s.stateRepo.Record(ctx, deploymentID, state)
+	if err != nil {
+		return err
 	}
-	logs = append(logs, logEntry)
-	outputs["logs"] = logs
+	logger.L().Info("terraform state saved", zap.String("deployment_id", deploymentID.String()), zap.Int("serial", v.Serial), zap.String("author", v.Author))
+	return nil
+}
 
-	b, err := json.Marshal(outputs)
-	if err != nil {
-		return appErr.Wrap(err, appErr.CodeInternal, "marshal outputs failed")
+func (s *deploymentService) AppendLog(ctx context.Context, deploymentID uuid.UUID, logEntry DeploymentLog) error {
+	entry := models.DeploymentLog{
+		Timestamp: logEntry.Timestamp,
+		Level:     logEntry.Level,
+		Phase:     logEntry.Phase,
+		Message:   logEntry.Message,
 	}
-	res := s.db.WithContext(ctx).Model(&models.Deployment{}).Where("id = ?", deploymentID).Update("outputs", datatypes.JSON(b))
-	if res.Error != nil {
-		return appErr.Wrap(res.Error, appErr.CodeInternal, "append log failed")
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
 	}
-	if res.RowsAffected == 0 {
-		return appErr.New(appErr.CodeNotFound, "deployment not found")
+	if logEntry.Data != nil {
+		b, err := json.Marshal(logEntry.Data)
+		if err != nil {
+			return appErr.Wrap(err, appErr.CodeInvalid, "marshal log data failed")
+		}
+		entry.Data = datatypes.JSON(b)
 	}
-	return nil
+
+	if s.logs != nil {
+		s.logs.Add(deploymentID, entry)
+		return nil
+	}
+	return s.logRepo.Append(ctx, deploymentID, []models.DeploymentLog{entry})
 }
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	"github.com/iac-studio/engine/internal/cloud"
+	"github.com/iac-studio/engine/internal/events"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+)
+
+func TestCheckBudget(t *testing.T) {
+	hard := `{"monthly_limit":100,"warning_percent":80,"enforcement":"hard"}`
+	soft := `{"monthly_limit":100,"warning_percent":80,"enforcement":"soft"}`
+	estimate := func(cost float64) *cloud.Estimate {
+		return &cloud.Estimate{Currency: "USD", MonthlyCost: cost}
+	}
+	partly := func(cost float64) *cloud.Estimate {
+		return &cloud.Estimate{Currency: "USD", MonthlyCost: cost, Unpriced: []string{"queue"}}
+	}
+	deploy := &CreateDeploymentInput{}
+	override := &CreateDeploymentInput{OverrideBudget: true, OverrideReason: "launch traffic"}
+
+	tests := []struct {
+		name     string
+		budget   string
+		est      *cloud.Estimate
+		input    *CreateDeploymentInput
+		rejected bool
+		override bool
+		warning  string
+	}{
+		{name: "no budget", budget: "", est: estimate(1000), input: deploy},
+		{name: "under budget", budget: hard, est: estimate(50), input: deploy},
+		{name: "warn threshold", budget: hard, est: estimate(80), input: deploy, warning: models.WarningBudgetThreshold},
+		{name: "hard over budget", budget: hard, est: estimate(150), input: deploy, rejected: true},
+		{name: "hard over budget ignores override", budget: hard, est: estimate(150), input: override, rejected: true},
+		{name: "soft over budget", budget: soft, est: estimate(150), input: deploy, rejected: true},
+		{name: "soft over budget overridden", budget: soft, est: estimate(150), input: override, override: true, warning: models.WarningBudgetOverridden},
+		{name: "plan over budget warns", budget: hard, est: estimate(150), input: nil, warning: models.WarningBudgetExceeded},
+		{name: "hard unpriced", budget: hard, est: nil, input: deploy, rejected: true},
+		{name: "hard unpriced overridden", budget: hard, est: nil, input: override, override: true, warning: models.WarningBudgetOverridden},
+		{name: "soft unpriced warns", budget: soft, est: nil, input: deploy, warning: models.WarningBudgetUnchecked},
+		{name: "plan unpriced warns", budget: hard, est: nil, input: nil, warning: models.WarningBudgetUnchecked},
+		{name: "hard partly priced", budget: hard, est: partly(50), input: deploy, rejected: true},
+		{name: "hard partly priced overridden", budget: hard, est: partly(50), input: override, override: true, warning: models.WarningBudgetOverridden},
+		{name: "hard partly priced over budget ignores override", budget: hard, est: partly(150), input: override, rejected: true},
+		{name: "soft partly priced warns", budget: soft, est: partly(50), input: deploy, warning: models.WarningBudgetUnchecked},
+	}
+
+	s := &deploymentService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			p := &models.Project{ID: uuid.New(), Budget: datatypes.JSON(tt.budget)}
+			d := &models.Deployment{ProjectID: p.ID, CreatedBy: &userID}
+
+			o, err := s.checkBudget(p, tt.est, d, tt.input)
+			if tt.rejected {
+				require.True(t, appErr.IsCode(err, appErr.CodeBudgetExceeded), "got %v", err)
+				require.Nil(t, o)
+				return
+			}
+			require.NoError(t, err)
+			if tt.override {
+				require.NotNil(t, o)
+				require.Equal(t, p.ID, o.ProjectID)
+				require.Equal(t, userID, o.UserID)
+				require.Equal(t, 100.0, o.MonthlyLimit)
+				require.Equal(t, "launch traffic", o.Reason)
+			} else {
+				require.Nil(t, o)
+			}
+
+			var warnings []models.DeploymentWarning
+			if len(d.Warnings) > 0 {
+				require.NoError(t, json.Unmarshal(d.Warnings, &warnings))
+			}
+			if tt.warning == "" {
+				require.Empty(t, warnings)
+				return
+			}
+			require.Len(t, warnings, 1)
+			require.Equal(t, tt.warning, warnings[0].Code)
+		})
+	}
+}
+
+func TestDestroyPreview(t *testing.T) {
+	graph := compiler.Graph{
+		Nodes: []compiler.Node{
+			{ID: "sg", Type: "aws_security_group", Properties: map[string]interface{}{}},
+			{ID: "web", Type: "aws_instance", Properties: map[string]interface{}{"security_group": "sg"}},
+			{ID: "logs", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "logs", "versioning": true}},
+		},
+	}
+
+	preview, err := destroyPreview(graph, []string{"sg"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"web"}, preview.Dependents)
+	require.Equal(t, []string{"sg", "web"}, preview.Nodes)
+	require.Equal(t, []string{"aws_security_group.sg", "aws_instance.web"}, preview.Resources)
+
+	preview, err = destroyPreview(graph, []string{"logs"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"aws_s3_bucket.logs", "aws_s3_bucket_versioning.logs_versioning"}, preview.Resources, "the bucket's versioning goes with it")
+
+	preview, err = destroyPreview(graph, nil)
+	require.NoError(t, err)
+	require.True(t, preview.Full)
+	require.Len(t, preview.Resources, 4)
+
+	_, err = destroyPreview(graph, []string{"missing"})
+	require.True(t, appErr.IsCode(err, appErr.CodeInvalid), "got %v", err)
+}
+
+func TestDeploymentService_CancelDeployment(t *testing.T) {
+	ctx := context.Background()
+	state := datatypes.JSON(`{"version":4,"serial":3}`)
+
+	tests := []struct {
+		name      string
+		d         models.Deployment
+		cancelled bool
+	}{
+		{name: "queued", d: models.Deployment{Status: models.DeploymentPending}, cancelled: true},
+		{name: "awaiting approval", d: models.Deployment{Status: models.DeploymentAwaitingApproval}, cancelled: true},
+		{name: "retried after apply started", d: models.Deployment{Status: models.DeploymentPending, Checkpoint: models.CheckpointApplying}},
+		{name: "destroy queued before checkpoints", d: models.Deployment{Status: models.DeploymentPending, TerraformState: state}},
+		{name: "destroy queued", d: models.Deployment{Status: models.DeploymentDestroyQueued, TerraformState: state}},
+		{name: "failed destroy queued", d: models.Deployment{Status: models.DeploymentDestroyQueued}},
+		{name: "running", d: models.Deployment{Status: models.DeploymentApplying}},
+		{name: "applied", d: models.Deployment{Status: models.DeploymentApplied, TerraformState: state}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectRepo, deployRepo := new(mockProjectRepository), new(mockDeploymentRepository)
+			userID := uuid.New()
+			p := ownedProject(projectRepo, userID)
+			d := tt.d
+			d.ID, d.ProjectID = uuid.New(), p.ID
+			deployRepo.On("GetByID", mock.Anything, d.ID, mock.Anything).Return(nil, &d)
+			deployRepo.On("UpdateStatus", mock.Anything, d.ID, models.DeploymentCancelled).Return(nil)
+			svc := &deploymentService{projectRepo: projectRepo, deployRepo: deployRepo}
+
+			err := svc.CancelDeployment(ctx, d.ID, userID)
+			if tt.cancelled {
+				require.NoError(t, err)
+				deployRepo.AssertCalled(t, "UpdateStatus", mock.Anything, d.ID, models.DeploymentCancelled)
+				return
+			}
+			require.True(t, appErr.IsCode(err, appErr.CodeConflict), "got %v", err)
+			deployRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// recordingPublisher keeps every published event.
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e events.Event) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+func TestDeploymentService_PublishPlanned(t *testing.T) {
+	plan := &provisioner.Plan{Changes: 3, ResourceAdds: 2, ResourceDels: 1}
+
+	t.Run("full deployment", func(t *testing.T) {
+		deployRepo, pub := new(mockDeploymentRepository), &recordingPublisher{}
+		d := &models.Deployment{ID: uuid.New(), ProjectID: uuid.New(), Status: models.DeploymentPlanning}
+		deployRepo.On("GetByID", mock.Anything, d.ID, mock.Anything).Return(nil, d)
+		s := &deploymentService{deployRepo: deployRepo, publisher: pub}
+
+		s.publishPlanned(context.Background(), d.ID, plan)
+		require.Len(t, pub.events, 1)
+		e := pub.events[0]
+		require.Equal(t, events.DeploymentPlanned, e.Type)
+		require.Equal(t, d.ID.String(), e.DeploymentID)
+		require.Equal(t, 3, e.Data["changes"])
+		require.Equal(t, 1, e.Data["resource_dels"])
+		require.Equal(t, false, e.Data["plan_only"])
+	})
+
+	t.Run("plan-only runs publish on planned instead", func(t *testing.T) {
+		deployRepo, pub := new(mockDeploymentRepository), &recordingPublisher{}
+		d := &models.Deployment{ID: uuid.New(), ProjectID: uuid.New(), Status: models.DeploymentPlanning, PlanOnly: true}
+		deployRepo.On("GetByID", mock.Anything, d.ID, mock.Anything).Return(nil, d)
+		s := &deploymentService{deployRepo: deployRepo, publisher: pub}
+
+		s.publishPlanned(context.Background(), d.ID, plan)
+		require.Empty(t, pub.events)
+	})
+}
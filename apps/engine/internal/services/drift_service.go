@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/provisioner/terraform"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// DriftScheduleOff disables scheduled drift checks for a project.
+const DriftScheduleOff = "off"
+
+// MinDriftInterval bounds how often a deployment may be refreshed, since each
+// check runs terraform refresh against the cloud provider.
+const MinDriftInterval = 15 * time.Minute
+
+// DriftService exposes drift reports and on-demand drift checks.
+type DriftService interface {
+	ListDriftReports(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.DriftReport, error)
+	GetLatestDriftReport(ctx context.Context, deploymentID, userID uuid.UUID) (*models.DriftReport, error)
+	// RequestDriftCheck enqueues a drift check outside the project schedule.
+	RequestDriftCheck(ctx context.Context, deploymentID, userID uuid.UUID) error
+
+	// ListDriftChanges maps the latest drift report onto the deployed graph,
+	// one change per drifted node property.
+	ListDriftChanges(ctx context.Context, deploymentID, userID uuid.UUID) (*DriftChanges, error)
+	// ResolveDrift writes the accepted actual values into a new graph
+	// version. The deployed graph must still be the project's current one.
+	ResolveDrift(ctx context.Context, deploymentID, userID uuid.UUID, input *ResolveDriftInput) (*models.ProjectGraph, error)
+}
+
+// Drift resolution decisions.
+const (
+	DriftKeepGraph    = "keep_graph"
+	DriftAcceptActual = "accept_actual"
+)
+
+// DriftChange is one drifted node property. A resource deleted outside
+// Terraform is a single change without a property; accepting it removes the
+// node from the graph.
+type DriftChange struct {
+	ID          string      `json:"id"`
+	NodeID      string      `json:"node_id"`
+	Address     string      `json:"address"`
+	Action      string      `json:"action" enums:"updated,deleted"`
+	Property    string      `json:"property,omitempty"`
+	Attribute   string      `json:"attribute,omitempty"`
+	GraphValue  interface{} `json:"graph_value"`
+	ActualValue interface{} `json:"actual_value"`
+}
+
+type DriftChanges struct {
+	ReportID     uuid.UUID     `json:"report_id"`
+	GraphID      uuid.UUID     `json:"graph_id"`
+	GraphVersion int           `json:"graph_version"`
+	Changes      []DriftChange `json:"changes"`
+	// Unmapped lists drifted attributes ("address.attribute") that no node
+	// property controls; re-applying the graph is the only way to reset them.
+	Unmapped []string `json:"unmapped"`
+}
+
+type ResolveDriftInput struct {
+	// ReportID must be the latest report, so decisions are never applied
+	// to changes the user has not seen.
+	ReportID uuid.UUID
+	// Decisions maps change IDs to DriftKeepGraph or DriftAcceptActual.
+	// Changes without a decision keep the graph value.
+	Decisions map[string]string
+}
+
+type driftService struct {
+	projectRepo repository.ProjectRepository
+	deployRepo  repository.DeploymentRepository
+	graphRepo   repository.GraphRepository
+	driftRepo   repository.DriftRepository
+	projectSvc  ProjectService
+	asynqClient *asynq.Client
+}
+
+func NewDriftService(projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, graphRepo repository.GraphRepository, driftRepo repository.DriftRepository, projectSvc ProjectService, client *asynq.Client) DriftService {
+	return &driftService{projectRepo: projectRepo, deployRepo: deployRepo, graphRepo: graphRepo, driftRepo: driftRepo, projectSvc: projectSvc, asynqClient: client}
+}
+
+var _ DriftService = (*driftService)(nil)
+
+// DriftInterval returns how often a project is checked for drift: schedule is
+// the project's own setting and fallback the server default used when it is
+// empty. Zero means checks are disabled.
+func DriftInterval(schedule, fallback string) (time.Duration, error) {
+	if schedule == "" {
+		schedule = fallback
+	}
+	if schedule == "" || schedule == DriftScheduleOff {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(schedule)
+	if err != nil {
+		return 0, appErr.Wrap(err, appErr.CodeInvalid, `drift schedule must be a duration such as "6h" or "off"`)
+	}
+	if d < MinDriftInterval {
+		return 0, appErr.New(appErr.CodeInvalid, "drift schedule must be at least "+MinDriftInterval.String())
+	}
+	return d, nil
+}
+
+func (s *driftService) ListDriftReports(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.DriftReport, error) {
+	logger.L().Info("list drift reports", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	if _, err := s.authorize(ctx, deploymentID, userID); err != nil {
+		return nil, err
+	}
+	return s.driftRepo.ListByDeployment(ctx, deploymentID, 50)
+}
+
+func (s *driftService) GetLatestDriftReport(ctx context.Context, deploymentID, userID uuid.UUID) (*models.DriftReport, error) {
+	logger.L().Info("get latest drift report", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	if _, err := s.authorize(ctx, deploymentID, userID); err != nil {
+		return nil, err
+	}
+	var r models.DriftReport
+	if err := s.driftRepo.GetLatest(ctx, deploymentID, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *driftService) RequestDriftCheck(ctx context.Context, deploymentID, userID uuid.UUID) error {
+	logger.L().Info("drift check requested", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.authorize(ctx, deploymentID, userID)
+	if err != nil {
+		return err
+	}
+	if d.Status != models.DeploymentApplied {
+		return appErr.New(appErr.CodeConflict, "only applied deployments can be checked for drift")
+	}
+	if s.asynqClient == nil {
+		return appErr.New(appErr.CodeUnavailable, "task queue not configured")
+	}
+
+	pb, _ := json.Marshal(map[string]string{"deployment_id": d.ID.String()})
+	// one check at a time per deployment
+	if _, err := s.asynqClient.EnqueueContext(ctx, asynq.NewTask("drift:check", pb), asynq.Unique(MinDriftInterval)); err != nil {
+		if err == asynq.ErrDuplicateTask {
+			return appErr.New(appErr.CodeConflict, "a drift check is already queued")
+		}
+		return appErr.Wrap(err, appErr.CodeInternal, "enqueue drift check failed")
+	}
+	return nil
+}
+
+func (s *driftService) ListDriftChanges(ctx context.Context, deploymentID, userID uuid.UUID) (*DriftChanges, error) {
+	logger.L().Info("list drift changes", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.authorize(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.driftChanges(ctx, d)
+}
+
+func (s *driftService) ResolveDrift(ctx context.Context, deploymentID, userID uuid.UUID, input *ResolveDriftInput) (*models.ProjectGraph, error) {
+	logger.L().Info("resolve drift", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	d, err := s.authorize(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.driftChanges(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	if input.ReportID != changes.ReportID {
+		return nil, appErr.New(appErr.CodeConflict, "drift report is not the latest; reload the changes")
+	}
+	// accepted values are written into the current graph, so a version
+	// saved after the deployment would be reverted by building on the
+	// deployed one
+	var current models.ProjectGraph
+	if err := s.graphRepo.GetCurrentByProject(ctx, d.ProjectID, &current); err != nil {
+		return nil, err
+	}
+	if current.Version != changes.GraphVersion {
+		return nil, appErr.New(appErr.CodeConflict, "graph changed since the deployment; deploy the current version before accepting drift").WithMeta("current_version", current.Version).WithMeta("deployed_version", changes.GraphVersion)
+	}
+	data, err := decodeGraphData(&current)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]DriftChange, len(changes.Changes))
+	for _, c := range changes.Changes {
+		byID[c.ID] = c
+	}
+	removed := map[string]bool{}
+	accepted := 0
+	for id, decision := range input.Decisions {
+		c, ok := byID[id]
+		if !ok {
+			return nil, appErr.New(appErr.CodeInvalid, "unknown drift change "+id)
+		}
+		switch decision {
+		case DriftKeepGraph:
+			continue
+		case DriftAcceptActual:
+		default:
+			return nil, appErr.New(appErr.CodeInvalid, "decision must be keep_graph or accept_actual")
+		}
+		accepted++
+		if c.Action == terraform.DriftDeleted {
+			removed[c.NodeID] = true
+			continue
+		}
+		for i := range data.Nodes {
+			if data.Nodes[i].ID == c.NodeID {
+				if data.Nodes[i].Properties == nil {
+					data.Nodes[i].Properties = map[string]interface{}{}
+				}
+				data.Nodes[i].Properties[c.Property] = c.ActualValue
+			}
+		}
+	}
+	if accepted == 0 {
+		return nil, appErr.New(appErr.CodeInvalid, "no drift changes accepted")
+	}
+
+	if len(removed) > 0 {
+		nodes := data.Nodes[:0]
+		for _, n := range data.Nodes {
+			if !removed[n.ID] {
+				nodes = append(nodes, n)
+			}
+		}
+		edges := data.Edges[:0]
+		for _, e := range data.Edges {
+			if !removed[e.From] && !removed[e.To] {
+				edges = append(edges, e)
+			}
+		}
+		data.Nodes, data.Edges = nodes, edges
+	}
+
+	g, err := s.projectSvc.SaveGraph(ctx, d.ProjectID, userID, data)
+	if err != nil {
+		return nil, err
+	}
+	logger.L().Info("drift accepted into graph", zap.String("deployment_id", deploymentID.String()), zap.Int("accepted", accepted), zap.Int("version", g.Version))
+	return g, nil
+}
+
+// driftChanges maps the latest drift report of d onto the graph it deployed.
+func (s *driftService) driftChanges(ctx context.Context, d *models.Deployment) (*DriftChanges, error) {
+	var report models.DriftReport
+	if err := s.driftRepo.GetLatest(ctx, d.ID, &report); err != nil {
+		return nil, err
+	}
+	var drift []terraform.ResourceDrift
+	if len(report.Resources) > 0 {
+		if err := json.Unmarshal(report.Resources, &drift); err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal drift report failed")
+		}
+	}
+
+	var g models.ProjectGraph
+	if err := s.graphRepo.GetByID(ctx, d.GraphID, &g); err != nil {
+		return nil, err
+	}
+	data, err := decodeGraphData(&g)
+	if err != nil {
+		return nil, err
+	}
+
+	byAddress := make(map[string]GraphNode, len(data.Nodes))
+	for _, n := range data.Nodes {
+		byAddress[compiler.ResourceAddress(compiler.Node{ID: n.ID, Type: n.Type})] = n
+	}
+
+	out := &DriftChanges{ReportID: report.ID, GraphID: g.ID, GraphVersion: g.Version, Changes: []DriftChange{}, Unmapped: []string{}}
+	for _, r := range drift {
+		node, ok := byAddress[r.Address]
+		if !ok {
+			// resources the graph does not model directly, e.g. versioning
+			for _, a := range r.Attributes {
+				out.Unmapped = append(out.Unmapped, r.Address+"."+a.Path)
+			}
+			continue
+		}
+		if r.Action == terraform.DriftDeleted {
+			out.Changes = append(out.Changes, DriftChange{ID: node.ID, NodeID: node.ID, Address: r.Address, Action: r.Action, GraphValue: node.Properties})
+			continue
+		}
+
+		mapped := map[string]bool{}
+		for _, m := range compiler.AttributeMappings(node.Type) {
+			for _, a := range r.Attributes {
+				if a.Path != m.Root() {
+					continue
+				}
+				mapped[a.Path] = true
+				graphValue := node.Properties[m.Property]
+				actual := m.Value(a.Actual)
+				if reflect.DeepEqual(graphValue, actual) {
+					continue
+				}
+				out.Changes = append(out.Changes, DriftChange{
+					ID:          node.ID + "." + m.Property,
+					NodeID:      node.ID,
+					Address:     r.Address,
+					Action:      r.Action,
+					Property:    m.Property,
+					Attribute:   m.Attribute,
+					GraphValue:  graphValue,
+					ActualValue: actual,
+				})
+			}
+		}
+		for _, a := range r.Attributes {
+			if !mapped[a.Path] {
+				out.Unmapped = append(out.Unmapped, r.Address+"."+a.Path)
+			}
+		}
+	}
+	return out, nil
+}
+
+// authorize loads the deployment and checks that userID owns its project.
+func (s *driftService) authorize(ctx context.Context, deploymentID, userID uuid.UUID) (*models.Deployment, error) {
+	var d models.Deployment
+	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+		return nil, err
+	}
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return &d, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner/terraform"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+)
+
+type mockDriftRepository struct {
+	mock.Mock
+}
+
+func (m *mockDriftRepository) Record(ctx context.Context, report *models.DriftReport) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *mockDriftRepository) ListByDeployment(ctx context.Context, deploymentID uuid.UUID, limit int) ([]models.DriftReport, error) {
+	args := m.Called(ctx, deploymentID, limit)
+	if v := args.Get(0); v != nil {
+		return v.([]models.DriftReport), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDriftRepository) GetLatest(ctx context.Context, deploymentID uuid.UUID, dest *models.DriftReport) error {
+	args := m.Called(ctx, deploymentID, dest)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*dest = *args.Get(1).(*models.DriftReport)
+	}
+	return args.Error(0)
+}
+
+func (m *mockDriftRepository) ListCandidates(ctx context.Context) ([]repository.DriftCandidate, error) {
+	args := m.Called(ctx)
+	if v := args.Get(0); v != nil {
+		return v.([]repository.DriftCandidate), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// driftFixture is a deployed graph of an instance in a security group and a
+// bucket, with a drift report in which the instance changed, gained and lost
+// attributes and the bucket was deleted. The project's current graph is
+// version current; 3 is the deployed one.
+type driftFixture struct {
+	userID   uuid.UUID
+	d        *models.Deployment
+	report   *models.DriftReport
+	projects *mockProjectService
+	svc      DriftService
+}
+
+func newDriftFixture(t *testing.T, current int) *driftFixture {
+	t.Helper()
+	projectRepo, deployRepo, graphRepo, driftRepo, projects := new(mockProjectRepository), new(mockDeploymentRepository), new(mockGraphRepository), new(mockDriftRepository), new(mockProjectService)
+	userID := uuid.New()
+	p := ownedProject(projectRepo, userID)
+
+	nodes, err := json.Marshal([]GraphNode{
+		{ID: "app", Type: "aws_instance", Properties: map[string]interface{}{"ami": "ami-1", "instance_type": "t3.micro", "security_group": "web"}},
+		{ID: "web", Type: "aws_security_group", Properties: map[string]interface{}{"name": "web"}},
+		{ID: "logs", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "logs"}},
+	})
+	require.NoError(t, err)
+	edges, err := json.Marshal([]GraphEdge{{ID: "e1", From: "app", To: "web"}, {ID: "e2", From: "app", To: "logs"}})
+	require.NoError(t, err)
+	g := &models.ProjectGraph{ID: uuid.New(), ProjectID: p.ID, Version: 3, Nodes: datatypes.JSON(nodes), Edges: datatypes.JSON(edges)}
+	graphRepo.On("GetByID", mock.Anything, g.ID, mock.Anything).Return(nil, g)
+	if current == g.Version {
+		graphRepo.On("GetCurrentByProject", mock.Anything, p.ID, mock.Anything).Return(nil, g)
+	} else {
+		graphRepo.On("GetCurrentByProject", mock.Anything, p.ID, mock.Anything).Return(nil, &models.ProjectGraph{ID: uuid.New(), ProjectID: p.ID, Version: current, Nodes: g.Nodes, Edges: g.Edges})
+	}
+
+	d := &models.Deployment{ID: uuid.New(), ProjectID: p.ID, GraphID: g.ID, Status: models.DeploymentApplied}
+	deployRepo.On("GetByID", mock.Anything, d.ID, mock.Anything).Return(nil, d)
+
+	resources, err := json.Marshal([]terraform.ResourceDrift{
+		{Address: "aws_instance.app", Type: "aws_instance", Name: "app", Action: terraform.DriftUpdated, Attributes: []terraform.AttributeDrift{
+			{Path: "ami", Expected: "ami-1", Actual: nil},
+			{Path: "instance_type", Expected: "t3.micro", Actual: "t3.large"},
+			{Path: "monitoring", Expected: nil, Actual: true},
+			{Path: "tags", Expected: nil, Actual: map[string]interface{}{"Name": "app-server"}},
+		}},
+		{Address: "aws_s3_bucket.logs", Type: "aws_s3_bucket", Name: "logs", Action: terraform.DriftDeleted},
+	})
+	require.NoError(t, err)
+	report := &models.DriftReport{ID: uuid.New(), DeploymentID: d.ID, Status: models.DriftDrifted, ResourceCount: 2, Resources: datatypes.JSON(resources)}
+	driftRepo.On("GetLatest", mock.Anything, d.ID, mock.Anything).Return(nil, report)
+
+	return &driftFixture{userID: userID, d: d, report: report, projects: projects, svc: NewDriftService(projectRepo, deployRepo, graphRepo, driftRepo, projects, nil)}
+}
+
+func TestDriftService_ListDriftChanges(t *testing.T) {
+	f := newDriftFixture(t, 3)
+
+	changes, err := f.svc.ListDriftChanges(context.Background(), f.d.ID, f.userID)
+	require.NoError(t, err)
+	require.Equal(t, f.report.ID, changes.ReportID)
+	require.Equal(t, 3, changes.GraphVersion)
+
+	byID := map[string]DriftChange{}
+	for _, c := range changes.Changes {
+		byID[c.ID] = c
+	}
+	require.Len(t, byID, 4)
+
+	// changed
+	require.Equal(t, "t3.micro", byID["app.instance_type"].GraphValue)
+	require.Equal(t, "t3.large", byID["app.instance_type"].ActualValue)
+	// removed
+	require.Equal(t, "ami-1", byID["app.ami"].GraphValue)
+	require.Nil(t, byID["app.ami"].ActualValue)
+	// added, through a nested attribute
+	require.Nil(t, byID["app.name"].GraphValue)
+	require.Equal(t, "app-server", byID["app.name"].ActualValue)
+	require.Equal(t, "tags.Name", byID["app.name"].Attribute)
+	// deleted resource
+	require.Equal(t, terraform.DriftDeleted, byID["logs"].Action)
+
+	require.Equal(t, []string{"aws_instance.app.monitoring"}, changes.Unmapped)
+}
+
+func TestDriftService_ResolveDrift(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("accepted values become a new graph version", func(t *testing.T) {
+		f := newDriftFixture(t, 3)
+		var saved *GraphData
+		f.projects.On("SaveGraph", mock.Anything, f.d.ProjectID, f.userID, mock.Anything).
+			Run(func(args mock.Arguments) { saved = args.Get(3).(*GraphData) }).
+			Return(&models.ProjectGraph{ProjectID: f.d.ProjectID, Version: 4}, nil)
+
+		g, err := f.svc.ResolveDrift(ctx, f.d.ID, f.userID, &ResolveDriftInput{ReportID: f.report.ID, Decisions: map[string]string{
+			"app.instance_type": DriftAcceptActual,
+			"app.name":          DriftAcceptActual,
+			"app.ami":           DriftKeepGraph,
+			"logs":              DriftAcceptActual,
+		}})
+		require.NoError(t, err)
+		require.Equal(t, 4, g.Version)
+
+		require.Len(t, saved.Nodes, 2, "the deleted bucket is removed")
+		app := saved.Nodes[0]
+		require.Equal(t, "app", app.ID)
+		require.Equal(t, "t3.large", app.Properties["instance_type"])
+		require.Equal(t, "app-server", app.Properties["name"])
+		require.Equal(t, "ami-1", app.Properties["ami"], "kept values are unchanged")
+		require.Equal(t, []GraphEdge{{ID: "e1", From: "app", To: "web"}}, saved.Edges)
+	})
+
+	t.Run("accepting a removed attribute clears it; undecided changes keep the graph", func(t *testing.T) {
+		f := newDriftFixture(t, 3)
+		var saved *GraphData
+		f.projects.On("SaveGraph", mock.Anything, f.d.ProjectID, f.userID, mock.Anything).
+			Run(func(args mock.Arguments) { saved = args.Get(3).(*GraphData) }).
+			Return(&models.ProjectGraph{ProjectID: f.d.ProjectID, Version: 4}, nil)
+
+		_, err := f.svc.ResolveDrift(ctx, f.d.ID, f.userID, &ResolveDriftInput{ReportID: f.report.ID, Decisions: map[string]string{"app.ami": DriftAcceptActual}})
+		require.NoError(t, err)
+		require.Len(t, saved.Nodes, 3)
+		require.Nil(t, saved.Nodes[0].Properties["ami"])
+		require.Equal(t, "t3.micro", saved.Nodes[0].Properties["instance_type"])
+	})
+
+	invalid := map[string]*ResolveDriftInput{
+		"nothing accepted": {Decisions: map[string]string{"app.ami": DriftKeepGraph}},
+		"unknown change":   {Decisions: map[string]string{"app.user_data": DriftAcceptActual}},
+		"unknown decision": {Decisions: map[string]string{"app.ami": "merge"}},
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			f := newDriftFixture(t, 3)
+			input.ReportID = f.report.ID
+
+			_, err := f.svc.ResolveDrift(ctx, f.d.ID, f.userID, input)
+			require.True(t, appErr.IsCode(err, appErr.CodeInvalid), "got %v", err)
+			f.projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("graph saved since the deployment", func(t *testing.T) {
+		f := newDriftFixture(t, 4)
+
+		_, err := f.svc.ResolveDrift(ctx, f.d.ID, f.userID, &ResolveDriftInput{ReportID: f.report.ID, Decisions: map[string]string{"app.instance_type": DriftAcceptActual}})
+		require.True(t, appErr.IsCode(err, appErr.CodeConflict), "got %v", err)
+		f.projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("stale report", func(t *testing.T) {
+		f := newDriftFixture(t, 3)
+
+		_, err := f.svc.ResolveDrift(ctx, f.d.ID, f.userID, &ResolveDriftInput{ReportID: uuid.New(), Decisions: map[string]string{"app.ami": DriftAcceptActual}})
+		require.True(t, appErr.IsCode(err, appErr.CodeConflict), "got %v", err)
+		f.projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+)
+
+// patchGraph applies patches to data in place. The patched graph must not
+// gain validation problems; ones it already had are not the patch's fault.
+// On error data is unchanged.
+func patchGraph(data *GraphData, patches []compiler.NodePatch) error {
+	if len(patches) == 0 {
+		return appErr.New(appErr.CodeInvalid, "patches are required")
+	}
+	before := compilerGraph(data)
+	after, err := compiler.ApplyPatches(before, patches)
+	if err != nil {
+		return appErr.New(appErr.CodeInvalid, err.Error())
+	}
+	c := compiler.NewCompiler()
+	had := map[string]bool{}
+	for _, e := range c.Validate(before) {
+		had[e.Error()] = true
+	}
+	var msgs []string
+	for _, e := range c.Validate(after) {
+		if !had[e.Error()] {
+			msgs = append(msgs, e.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return appErr.New(appErr.CodeInvalid, "patch makes the graph invalid: "+strings.Join(msgs, "; ")).WithMeta("errors", msgs)
+	}
+
+	for _, p := range patches {
+		for i := range data.Nodes {
+			if data.Nodes[i].ID == p.NodeID {
+				data.Nodes[i].Properties = p.Apply(data.Nodes[i].Properties)
+			}
+		}
+	}
+	return nil
+}
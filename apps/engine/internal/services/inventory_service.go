@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// InventoryService keeps the resource inventory in step with Terraform state
+// and serves it without exposing raw state.
+type InventoryService interface {
+	ListProjectResources(ctx context.Context, projectID, userID uuid.UUID, filter repository.ResourceFilter) ([]models.Resource, error)
+	ListDeploymentResources(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.Resource, error)
+
+	// SyncResources replaces the inventory of d with resources (called by
+	// the worker after apply, destroy and drift refresh).
+	SyncResources(ctx context.Context, d *models.Deployment, resources []provisioner.Resource) error
+}
+
+type inventoryService struct {
+	projectRepo  repository.ProjectRepository
+	deployRepo   repository.DeploymentRepository
+	resourceRepo repository.ResourceRepository
+}
+
+func NewInventoryService(projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, resourceRepo repository.ResourceRepository) InventoryService {
+	return &inventoryService{projectRepo: projectRepo, deployRepo: deployRepo, resourceRepo: resourceRepo}
+}
+
+var _ InventoryService = (*inventoryService)(nil)
+
+func (s *inventoryService) ListProjectResources(ctx context.Context, projectID, userID uuid.UUID, filter repository.ResourceFilter) ([]models.Resource, error) {
+	logger.L().Info("list project resources", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()))
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return s.resourceRepo.ListByProject(ctx, projectID, filter)
+}
+
+func (s *inventoryService) ListDeploymentResources(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.Resource, error) {
+	logger.L().Info("list deployment resources", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	var d models.Deployment
+	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+		return nil, err
+	}
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return s.resourceRepo.ListByDeployment(ctx, deploymentID)
+}
+
+func (s *inventoryService) SyncResources(ctx context.Context, d *models.Deployment, resources []provisioner.Resource) error {
+	rows := make([]models.Resource, 0, len(resources))
+	for _, r := range resources {
+		props, err := json.Marshal(r.Attributes)
+		if err != nil {
+			return appErr.Wrap(err, appErr.CodeInternal, "marshal resource attributes failed")
+		}
+		rows = append(rows, models.Resource{
+			DeploymentID: d.ID,
+			ProjectID:    d.ProjectID,
+			ResourceType: r.Type,
+			ResourceName: r.Name,
+			Address:      r.Address,
+			CloudID:      r.CloudID,
+			NodeID:       r.NodeID,
+			Properties:   datatypes.JSON(props),
+		})
+	}
+	if err := s.resourceRepo.Sync(ctx, d.ID, rows); err != nil {
+		return err
+	}
+	logger.L().Info("resource inventory synced", zap.String("deployment_id", d.ID.String()), zap.Int("resources", len(rows)))
+	return nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// LogBatcher buffers deployment log lines and writes them in batches, every
+// interval or as soon as maxBatch lines are pending. Close flushes what is
+// left.
+type LogBatcher struct {
+	repo     repository.DeploymentLogRepository
+	maxBatch int
+
+	// flushMu keeps batches of a deployment in order across flushes
+	flushMu sync.Mutex
+	mu      sync.Mutex
+	pending map[uuid.UUID][]models.DeploymentLog
+	size    int
+
+	flushCh chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewLogBatcher(repo repository.DeploymentLogRepository, interval time.Duration, maxBatch int) *LogBatcher {
+	b := &LogBatcher{
+		repo:     repo,
+		maxBatch: maxBatch,
+		pending:  map[uuid.UUID][]models.DeploymentLog{},
+		flushCh:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// Add queues a log line for deploymentID.
+func (b *LogBatcher) Add(deploymentID uuid.UUID, entry models.DeploymentLog) {
+	b.mu.Lock()
+	b.pending[deploymentID] = append(b.pending[deploymentID], entry)
+	b.size++
+	full := b.size >= b.maxBatch
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes every pending line now. Lines of a deployment that fail to
+// write are dropped so a missing deployment cannot grow the buffer forever.
+func (b *LogBatcher) Flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = map[uuid.UUID][]models.DeploymentLog{}
+	b.size = 0
+	b.mu.Unlock()
+
+	var firstErr error
+	for id, entries := range pending {
+		if err := b.repo.Append(ctx, id, entries); err != nil {
+			logger.L().Warn("write deployment logs failed", zap.String("deployment_id", id.String()), zap.Int("lines", len(entries)), zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Close stops the background flush and writes the remaining lines.
+func (b *LogBatcher) Close() error {
+	close(b.stop)
+	<-b.done
+	return b.Flush(context.Background())
+}
+
+func (b *LogBatcher) run(interval time.Duration) {
+	defer close(b.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-t.C:
+		case <-b.flushCh:
+		}
+		_ = b.Flush(context.Background())
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/repository"
+)
+
+type logBatch struct {
+	deploymentID uuid.UUID
+	entries      []models.DeploymentLog
+}
+
+// mockDeploymentLogRepository sends every Append to batches.
+type mockDeploymentLogRepository struct {
+	mock.Mock
+	batches chan logBatch
+}
+
+func newMockDeploymentLogRepository() *mockDeploymentLogRepository {
+	return &mockDeploymentLogRepository{batches: make(chan logBatch, 100)}
+}
+
+func (m *mockDeploymentLogRepository) Append(ctx context.Context, deploymentID uuid.UUID, entries []models.DeploymentLog) error {
+	args := m.Called(ctx, deploymentID, entries)
+	m.batches <- logBatch{deploymentID: deploymentID, entries: entries}
+	return args.Error(0)
+}
+
+func (m *mockDeploymentLogRepository) List(ctx context.Context, deploymentID uuid.UUID, q repository.LogQuery) ([]models.DeploymentLog, error) {
+	args := m.Called(ctx, deploymentID, q)
+	if v := args.Get(0); v != nil {
+		return v.([]models.DeploymentLog), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func logLines(n int) []models.DeploymentLog {
+	out := make([]models.DeploymentLog, n)
+	for i := range out {
+		out[i] = models.DeploymentLog{Level: "info", Message: fmt.Sprintf("line %d", i)}
+	}
+	return out
+}
+
+func waitBatch(t *testing.T, repo *mockDeploymentLogRepository, within time.Duration) logBatch {
+	t.Helper()
+	select {
+	case b := <-repo.batches:
+		return b
+	case <-time.After(within):
+		t.Fatal("no batch written")
+		return logBatch{}
+	}
+}
+
+func TestLogBatcher_FlushesBySize(t *testing.T) {
+	repo := newMockDeploymentLogRepository()
+	repo.On("Append", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	b := NewLogBatcher(repo, time.Hour, 3)
+	defer b.Close()
+
+	id := uuid.New()
+	lines := logLines(3)
+	for _, l := range lines[:2] {
+		b.Add(id, l)
+	}
+	select {
+	case <-repo.batches:
+		t.Fatal("flushed before the batch was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.Add(id, lines[2])
+	batch := waitBatch(t, repo, time.Second)
+	require.Equal(t, id, batch.deploymentID)
+	require.Equal(t, lines, batch.entries, "lines keep their order")
+}
+
+func TestLogBatcher_FlushesByInterval(t *testing.T) {
+	repo := newMockDeploymentLogRepository()
+	repo.On("Append", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	b := NewLogBatcher(repo, 20*time.Millisecond, 100)
+	defer b.Close()
+
+	id := uuid.New()
+	lines := logLines(2)
+	for _, l := range lines {
+		b.Add(id, l)
+	}
+	batch := waitBatch(t, repo, time.Second)
+	require.Equal(t, id, batch.deploymentID)
+	require.Equal(t, lines, batch.entries)
+}
+
+func TestLogBatcher_CloseFlushesPending(t *testing.T) {
+	repo := newMockDeploymentLogRepository()
+	repo.On("Append", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	b := NewLogBatcher(repo, time.Hour, 100)
+
+	a, c := uuid.New(), uuid.New()
+	b.Add(a, logLines(1)[0])
+	b.Add(c, logLines(2)[0])
+	b.Add(c, logLines(2)[1])
+	require.NoError(t, b.Close())
+
+	got := map[uuid.UUID][]models.DeploymentLog{}
+	for range 2 {
+		batch := waitBatch(t, repo, time.Second)
+		got[batch.deploymentID] = batch.entries
+	}
+	require.Equal(t, logLines(1), got[a])
+	require.Equal(t, logLines(2), got[c])
+	repo.AssertNumberOfCalls(t, "Append", 2)
+}
+
+func TestLogBatcher_DropsFailedBatch(t *testing.T) {
+	repo := newMockDeploymentLogRepository()
+	missing, ok := uuid.New(), uuid.New()
+	repo.On("Append", mock.Anything, missing, mock.Anything).Return(errors.New("deployment not found"))
+	repo.On("Append", mock.Anything, ok, mock.Anything).Return(nil)
+	b := NewLogBatcher(repo, time.Hour, 100)
+	defer b.Close()
+
+	b.Add(missing, logLines(1)[0])
+	b.Add(ok, logLines(1)[0])
+	require.Error(t, b.Flush(context.Background()))
+
+	// the failed lines are not retried
+	require.NoError(t, b.Flush(context.Background()))
+	repo.AssertNumberOfCalls(t, "Append", 2)
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/policy"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// PolicyService manages custom policy rules and evaluates a project's rules
+// against deployments. Rules without a project apply to all projects of
+// their owner.
+type PolicyService interface {
+	// ListBuiltinRules returns the built-in rules as configured for a project.
+	ListBuiltinRules(ctx context.Context, projectID, userID uuid.UUID) ([]policy.Rule, error)
+	// ListRules returns userID's account-wide rules and, with a project,
+	// that project's rules.
+	ListRules(ctx context.Context, userID uuid.UUID, projectID *uuid.UUID) ([]models.PolicyRule, error)
+	CreateRule(ctx context.Context, userID uuid.UUID, input *PolicyRuleInput) (*models.PolicyRule, error)
+	UpdateRule(ctx context.Context, ruleID, userID uuid.UUID, input *PolicyRuleInput) (*models.PolicyRule, error)
+	DeleteRule(ctx context.Context, ruleID, userID uuid.UUID) error
+	ListFindings(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.PolicyFinding, error)
+	// Evaluate runs the deployment's project rules against its graph and,
+	// when there is one, its plan, and records the findings on it.
+	Evaluate(ctx context.Context, d *models.Deployment, graph provisioner.Graph, plan *provisioner.Plan) (*policy.Result, error)
+}
+
+// PolicyRuleInput creates or replaces a custom rule. ProjectID is fixed at
+// creation; nil makes the rule account-wide.
+type PolicyRuleInput struct {
+	ProjectID   *uuid.UUID      `json:"project_id,omitempty"`
+	Name        string          `json:"name" example:"RDS must be encrypted"`
+	Description string          `json:"description,omitempty"`
+	Enforcement string          `json:"enforcement" example:"mandatory" enums:"advisory,mandatory,off"`
+	Definition  json.RawMessage `json:"definition" swaggertype:"object"`
+}
+
+type policyService struct {
+	projectRepo repository.ProjectRepository
+	deployRepo  repository.DeploymentRepository
+	policyRepo  repository.PolicyRepository
+}
+
+func NewPolicyService(projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, policyRepo repository.PolicyRepository) PolicyService {
+	return &policyService{projectRepo: projectRepo, deployRepo: deployRepo, policyRepo: policyRepo}
+}
+
+var _ PolicyService = (*policyService)(nil)
+
+func (s *policyService) ListBuiltinRules(ctx context.Context, projectID, userID uuid.UUID) ([]policy.Rule, error) {
+	p, err := s.ownedProject(ctx, projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := policy.ParseSettings(p.PolicySettings)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInvalid, "invalid policy settings")
+	}
+	return policy.Builtins(settings), nil
+}
+
+func (s *policyService) ListRules(ctx context.Context, userID uuid.UUID, projectID *uuid.UUID) ([]models.PolicyRule, error) {
+	if projectID != nil {
+		if _, err := s.ownedProject(ctx, *projectID, userID); err != nil {
+			return nil, err
+		}
+	}
+	return s.policyRepo.ListRules(ctx, userID, projectID)
+}
+
+func (s *policyService) CreateRule(ctx context.Context, userID uuid.UUID, input *PolicyRuleInput) (*models.PolicyRule, error) {
+	logger.L().Info("create policy rule", zap.String("user_id", userID.String()), zap.String("name", input.Name))
+	if input.ProjectID != nil {
+		if _, err := s.ownedProject(ctx, *input.ProjectID, userID); err != nil {
+			return nil, err
+		}
+	}
+	rule := &models.PolicyRule{OwnerID: userID, ProjectID: input.ProjectID}
+	if err := applyRuleInput(rule, input); err != nil {
+		return nil, err
+	}
+	if err := s.policyRepo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *policyService) UpdateRule(ctx context.Context, ruleID, userID uuid.UUID, input *PolicyRuleInput) (*models.PolicyRule, error) {
+	logger.L().Info("update policy rule", zap.String("rule_id", ruleID.String()), zap.String("user_id", userID.String()))
+	rule, err := s.ownedRule(ctx, ruleID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyRuleInput(rule, input); err != nil {
+		return nil, err
+	}
+	if err := s.policyRepo.UpdateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *policyService) DeleteRule(ctx context.Context, ruleID, userID uuid.UUID) error {
+	logger.L().Info("delete policy rule", zap.String("rule_id", ruleID.String()), zap.String("user_id", userID.String()))
+	if _, err := s.ownedRule(ctx, ruleID, userID); err != nil {
+		return err
+	}
+	return s.policyRepo.DeleteRule(ctx, ruleID)
+}
+
+func (s *policyService) ListFindings(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.PolicyFinding, error) {
+	var d models.Deployment
+	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+		return nil, err
+	}
+	if _, err := s.ownedProject(ctx, d.ProjectID, userID); err != nil {
+		return nil, err
+	}
+	return s.policyRepo.ListFindings(ctx, deploymentID)
+}
+
+func (s *policyService) Evaluate(ctx context.Context, d *models.Deployment, graph provisioner.Graph, plan *provisioner.Plan) (*policy.Result, error) {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
+		return nil, err
+	}
+	settings, err := policy.ParseSettings(p.PolicySettings)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.CodeInvalid, "invalid policy settings")
+	}
+	custom, err := s.policyRepo.ListRules(ctx, p.UserID, &p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := policy.Builtins(settings)
+	for _, c := range custom {
+		def, err := policy.ParseDefinition(c.Definition)
+		if err != nil {
+			// rules are validated on write; skip rather than block on one
+			logger.L().Warn("skipping invalid policy rule", zap.String("rule_id", c.ID.String()), zap.Error(err))
+			continue
+		}
+		rules = append(rules, policy.Rule{ID: c.ID.String(), Name: c.Name, Description: c.Description, Enforcement: policy.Enforcement(c.Enforcement), Definition: def})
+	}
+
+	res := policy.Evaluate(rules, &policy.Input{Graph: graph, Plan: plan, Settings: settings})
+	status := models.PolicyPassed
+	switch {
+	case res.Blocked():
+		status = models.PolicyBlocked
+	case len(res.Findings) > 0:
+		status = models.PolicyWarned
+	}
+	findings := make([]models.PolicyFinding, 0, len(res.Findings))
+	for _, f := range res.Findings {
+		findings = append(findings, models.PolicyFinding{
+			DeploymentID: d.ID,
+			RuleID:       f.RuleID,
+			RuleName:     f.RuleName,
+			Enforcement:  string(f.Enforcement),
+			Source:       f.Source,
+			NodeID:       f.NodeID,
+			Address:      f.Address,
+			Message:      f.Message,
+		})
+	}
+	if err := s.policyRepo.ReplaceFindings(ctx, d.ID, status, findings); err != nil {
+		return nil, err
+	}
+	d.PolicyStatus = status
+	logger.L().Info("policy evaluated", zap.String("deployment_id", d.ID.String()), zap.String("status", status), zap.Int("mandatory", res.Mandatory), zap.Int("advisory", res.Advisory))
+	return res, nil
+}
+
+func (s *policyService) ownedProject(ctx context.Context, projectID, userID uuid.UUID) (*models.Project, error) {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return &p, nil
+}
+
+func (s *policyService) ownedRule(ctx context.Context, ruleID, userID uuid.UUID) (*models.PolicyRule, error) {
+	var rule models.PolicyRule
+	if err := s.policyRepo.GetRule(ctx, ruleID, &rule); err != nil {
+		return nil, err
+	}
+	if rule.OwnerID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own policy rule")
+	}
+	return &rule, nil
+}
+
+// applyRuleInput validates input and copies it onto rule.
+func applyRuleInput(rule *models.PolicyRule, input *PolicyRuleInput) error {
+	if input.Name == "" || len(input.Name) > 128 {
+		return appErr.New(appErr.CodeInvalid, "name is required and at most 128 characters")
+	}
+	if !policy.Enforcement(input.Enforcement).Valid() {
+		return appErr.New(appErr.CodeInvalid, "enforcement must be advisory, mandatory or off")
+	}
+	def, err := policy.ParseDefinition(input.Definition)
+	if err != nil {
+		return appErr.Wrap(err, appErr.CodeInvalid, err.Error())
+	}
+	b, _ := json.Marshal(def)
+	rule.Name = input.Name
+	rule.Description = input.Description
+	rule.Enforcement = input.Enforcement
+	rule.Definition = datatypes.JSON(b)
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/events"
 	"github.com/iac-studio/engine/internal/models"
 	"github.com/iac-studio/engine/internal/repository"
 	appErr "github.com/iac-studio/engine/pkg/errors"
@@ -77,10 +78,13 @@ type Position struct {
 type projectService struct {
 	db          *gorm.DB
 	projectRepo repository.ProjectRepository
+	publisher   events.Publisher
 }
 
-func NewProjectService(db *gorm.DB, projectRepo repository.ProjectRepository) ProjectService {
-	return &projectService{db: db, projectRepo: projectRepo}
+// NewProjectService builds the project service; saved graphs are published
+// to publisher, which may be nil.
+func NewProjectService(db *gorm.DB, projectRepo repository.ProjectRepository, publisher events.Publisher) ProjectService {
+	return &projectService{db: db, projectRepo: projectRepo, publisher: publisher}
 }
 
 // Ensure interfaces are satisfied at compile time
@@ -244,6 +248,17 @@ func (s *projectService) SaveGraph(ctx context.Context, projectID, userID uuid.U
 	}
 
 	logger.L().Info("graph saved", zap.String("project_id", projectID.String()), zap.Int("version", nextVersion), zap.String("user_id", userID.String()))
+	if s.publisher != nil {
+		e := events.New(events.GraphSaved, projectID, uuid.Nil, map[string]interface{}{
+			"graph_id": g.ID.String(),
+			"version":  g.Version,
+			"nodes":    len(graphData.Nodes),
+			"edges":    len(graphData.Edges),
+		})
+		if err := s.publisher.Publish(ctx, e); err != nil {
+			logger.L().Warn("publish event failed", zap.String("event_id", e.ID), zap.String("type", e.Type), zap.Error(err))
+		}
+	}
 	return g, nil
 }
 
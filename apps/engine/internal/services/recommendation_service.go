@@ -0,0 +1,294 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// RecommendationService manages the lifecycle of project recommendations,
+// whether found by an analyzer or added by a user or the AI, and applies
+// their patches to the graph.
+type RecommendationService interface {
+	List(ctx context.Context, projectID, userID uuid.UUID, filter repository.RecommendationFilter) ([]models.Recommendation, error)
+	Get(ctx context.Context, projectID, recID, userID uuid.UUID) (*models.Recommendation, error)
+	Create(ctx context.Context, projectID, userID uuid.UUID, input *RecommendationInput) (*models.Recommendation, error)
+	Update(ctx context.Context, projectID, recID, userID uuid.UUID, input *RecommendationUpdate) (*models.Recommendation, error)
+	Delete(ctx context.Context, projectID, recID, userID uuid.UUID) error
+	// Apply saves the current graph with the recommendation's patch as a
+	// new graph version and marks the recommendation applied. It refuses
+	// a graph that changed since the patch was made, unless the change is
+	// the patch itself, as when marking it applied failed before.
+	Apply(ctx context.Context, projectID, recID, userID uuid.UUID) (*models.Recommendation, error)
+}
+
+// RecommendationInput creates a recommendation. NodeIDs default to the
+// nodes the patch touches, and GraphVersion to the current graph's.
+type RecommendationInput struct {
+	Source      string               `json:"source" example:"ai" enums:"security,cost,performance,ai"`
+	Severity    string               `json:"severity" example:"medium" enums:"low,medium,high,critical"`
+	Title       string               `json:"title" example:"Enable bucket versioning"`
+	Description string               `json:"description,omitempty"`
+	NodeIDs     []string             `json:"node_ids,omitempty"`
+	Patch       []compiler.NodePatch `json:"patch,omitempty"`
+	Details     json.RawMessage      `json:"details,omitempty" swaggertype:"object"`
+	// GraphVersion is the graph version the patch was made against.
+	GraphVersion int `json:"graph_version,omitempty" example:"3"`
+}
+
+// RecommendationUpdate changes the fields that are set. Status moves a
+// recommendation between open, dismissed and snoozed; snoozing needs
+// SnoozedUntil. Only Apply marks one applied.
+type RecommendationUpdate struct {
+	Status       *string    `json:"status,omitempty" enums:"open,dismissed,snoozed"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	Severity     *string    `json:"severity,omitempty" enums:"low,medium,high,critical"`
+	Title        *string    `json:"title,omitempty"`
+	Description  *string    `json:"description,omitempty"`
+}
+
+var (
+	recommendationSources    = map[string]bool{models.RecommendationSourceSecurity: true, models.RecommendationSourceCost: true, models.RecommendationSourcePerformance: true, models.RecommendationSourceAI: true}
+	recommendationSeverities = map[string]bool{models.RecommendationSeverityLow: true, models.RecommendationSeverityMedium: true, models.RecommendationSeverityHigh: true, models.RecommendationSeverityCritical: true}
+)
+
+type recommendationService struct {
+	projectRepo repository.ProjectRepository
+	graphRepo   repository.GraphRepository
+	recRepo     repository.RecommendationRepository
+	projects    ProjectService
+}
+
+func NewRecommendationService(projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, recRepo repository.RecommendationRepository, projects ProjectService) RecommendationService {
+	return &recommendationService{projectRepo: projectRepo, graphRepo: graphRepo, recRepo: recRepo, projects: projects}
+}
+
+var _ RecommendationService = (*recommendationService)(nil)
+
+func (s *recommendationService) List(ctx context.Context, projectID, userID uuid.UUID, filter repository.RecommendationFilter) ([]models.Recommendation, error) {
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	return s.recRepo.ListByProject(ctx, projectID, filter)
+}
+
+func (s *recommendationService) Get(ctx context.Context, projectID, recID, userID uuid.UUID) (*models.Recommendation, error) {
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	var rec models.Recommendation
+	if err := s.recRepo.Get(ctx, projectID, recID, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *recommendationService) Create(ctx context.Context, projectID, userID uuid.UUID, input *RecommendationInput) (*models.Recommendation, error) {
+	logger.L().Info("create recommendation", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()), zap.String("source", input.Source))
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	if !recommendationSources[input.Source] {
+		return nil, appErr.New(appErr.CodeInvalid, "source must be security, cost, performance or ai")
+	}
+	if !recommendationSeverities[input.Severity] {
+		return nil, appErr.New(appErr.CodeInvalid, "severity must be low, medium, high or critical")
+	}
+	if input.Title == "" {
+		return nil, appErr.New(appErr.CodeInvalid, "title is required")
+	}
+	for i, p := range input.Patch {
+		if p.NodeID == "" || (len(p.Set) == 0 && len(p.Unset) == 0) {
+			return nil, appErr.New(appErr.CodeInvalid, "patch entries need a node_id and properties to set or unset").WithMeta("index", i)
+		}
+	}
+
+	nodeIDs := input.NodeIDs
+	if len(nodeIDs) == 0 {
+		seen := map[string]bool{}
+		for _, p := range input.Patch {
+			if !seen[p.NodeID] {
+				seen[p.NodeID] = true
+				nodeIDs = append(nodeIDs, p.NodeID)
+			}
+		}
+	}
+	if nodeIDs == nil {
+		nodeIDs = []string{}
+	}
+	ids, _ := json.Marshal(nodeIDs)
+	rec := &models.Recommendation{
+		ProjectID:    projectID,
+		Source:       input.Source,
+		Severity:     input.Severity,
+		Status:       models.RecommendationOpen,
+		Title:        input.Title,
+		Description:  input.Description,
+		NodeIDs:      datatypes.JSON(ids),
+		GraphVersion: input.GraphVersion,
+	}
+	if len(input.Patch) > 0 {
+		patch, _ := json.Marshal(input.Patch)
+		rec.Patch = datatypes.JSON(patch)
+		if rec.GraphVersion == 0 {
+			var g models.ProjectGraph
+			err := s.graphRepo.GetCurrentByProject(ctx, projectID, &g)
+			if err != nil && !appErr.IsCode(err, appErr.CodeNotFound) {
+				return nil, err
+			}
+			rec.GraphVersion = g.Version
+		}
+	}
+	if len(input.Details) > 0 {
+		if !json.Valid(input.Details) {
+			return nil, appErr.New(appErr.CodeInvalid, "details must be JSON")
+		}
+		rec.Details = datatypes.JSON(input.Details)
+	}
+	if err := s.recRepo.Create(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *recommendationService) Update(ctx context.Context, projectID, recID, userID uuid.UUID, input *RecommendationUpdate) (*models.Recommendation, error) {
+	logger.L().Info("update recommendation", zap.String("project_id", projectID.String()), zap.String("recommendation_id", recID.String()), zap.String("user_id", userID.String()))
+	rec, err := s.Get(ctx, projectID, recID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Severity != nil {
+		if !recommendationSeverities[*input.Severity] {
+			return nil, appErr.New(appErr.CodeInvalid, "severity must be low, medium, high or critical")
+		}
+		rec.Severity = *input.Severity
+	}
+	if input.Title != nil {
+		if *input.Title == "" {
+			return nil, appErr.New(appErr.CodeInvalid, "title is required")
+		}
+		rec.Title = *input.Title
+	}
+	if input.Description != nil {
+		rec.Description = *input.Description
+	}
+	if input.Status != nil {
+		switch *input.Status {
+		case models.RecommendationOpen, models.RecommendationDismissed:
+			if input.SnoozedUntil != nil {
+				return nil, appErr.New(appErr.CodeInvalid, "snoozed_until needs status snoozed")
+			}
+			rec.SnoozedUntil = nil
+		case models.RecommendationSnoozed:
+			if input.SnoozedUntil == nil || !input.SnoozedUntil.After(time.Now()) {
+				return nil, appErr.New(appErr.CodeInvalid, "snoozing needs a snoozed_until in the future")
+			}
+			rec.SnoozedUntil = input.SnoozedUntil
+		case models.RecommendationApplied:
+			return nil, appErr.New(appErr.CodeInvalid, "recommendations are marked applied by applying them")
+		default:
+			return nil, appErr.New(appErr.CodeInvalid, "status must be open, dismissed or snoozed")
+		}
+		if rec.Status == models.RecommendationApplied {
+			rec.AppliedVersion = nil
+		}
+		rec.Status = *input.Status
+	} else if input.SnoozedUntil != nil {
+		return nil, appErr.New(appErr.CodeInvalid, "snoozed_until needs status snoozed")
+	}
+	if err := s.recRepo.Update(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *recommendationService) Delete(ctx context.Context, projectID, recID, userID uuid.UUID) error {
+	logger.L().Info("delete recommendation", zap.String("project_id", projectID.String()), zap.String("recommendation_id", recID.String()), zap.String("user_id", userID.String()))
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return err
+	}
+	return s.recRepo.Delete(ctx, projectID, recID)
+}
+
+func (s *recommendationService) Apply(ctx context.Context, projectID, recID, userID uuid.UUID) (*models.Recommendation, error) {
+	logger.L().Info("apply recommendation", zap.String("project_id", projectID.String()), zap.String("recommendation_id", recID.String()), zap.String("user_id", userID.String()))
+	rec, err := s.Get(ctx, projectID, recID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status == models.RecommendationApplied {
+		return nil, appErr.New(appErr.CodeConflict, "recommendation is already applied").WithMeta("applied_version", rec.AppliedVersion)
+	}
+	var patches []compiler.NodePatch
+	if len(rec.Patch) > 0 {
+		if err := json.Unmarshal(rec.Patch, &patches); err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeInternal, "unmarshal patch failed")
+		}
+	}
+	if len(patches) == 0 {
+		return nil, appErr.New(appErr.CodeInvalid, "recommendation has no patch to apply; change the graph by hand")
+	}
+
+	var g models.ProjectGraph
+	if err := s.graphRepo.GetCurrentByProject(ctx, projectID, &g); err != nil {
+		return nil, err
+	}
+	data, err := decodeGraphData(&g)
+	if err != nil {
+		return nil, err
+	}
+	before, _ := json.Marshal(data.Nodes)
+	if err := patchGraph(data, patches); err != nil {
+		return nil, err
+	}
+	after, _ := json.Marshal(data.Nodes)
+
+	version := g.Version
+	switch {
+	case bytes.Equal(before, after):
+		// the graph already has the patch, typically saved by an earlier
+		// Apply that failed to mark the recommendation
+		logger.L().Info("recommendation patch already in graph", zap.String("recommendation_id", recID.String()), zap.Int("version", g.Version))
+	case rec.GraphVersion != 0 && rec.GraphVersion != g.Version:
+		return nil, appErr.New(appErr.CodeConflict, "graph changed since the recommendation was made; rerun the analysis or review it against the current version").
+			WithMeta("current_version", g.Version).
+			WithMeta("recommendation_version", rec.GraphVersion)
+	default:
+		saved, err := s.projects.SaveGraph(ctx, projectID, userID, data)
+		if err != nil {
+			return nil, err
+		}
+		version = saved.Version
+	}
+
+	rec.Status = models.RecommendationApplied
+	rec.AppliedVersion = &version
+	rec.SnoozedUntil = nil
+	if err := s.recRepo.Update(ctx, rec); err != nil {
+		logger.L().Error("mark recommendation applied failed", zap.String("recommendation_id", recID.String()), zap.Int("version", version), zap.Error(err))
+		return nil, err
+	}
+	logger.L().Info("recommendation applied", zap.String("recommendation_id", recID.String()), zap.Int("version", version))
+	return rec, nil
+}
+
+func (s *recommendationService) checkOwner(ctx context.Context, projectID, userID uuid.UUID) error {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return err
+	}
+	if p.UserID != userID {
+		return appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+)
+
+type mockRecommendationRepository struct {
+	mock.Mock
+}
+
+func (m *mockRecommendationRepository) SyncBySource(ctx context.Context, projectID uuid.UUID, source string, recs []models.Recommendation) error {
+	args := m.Called(ctx, projectID, source, recs)
+	return args.Error(0)
+}
+
+func (m *mockRecommendationRepository) ListByProject(ctx context.Context, projectID uuid.UUID, filter repository.RecommendationFilter) ([]models.Recommendation, error) {
+	args := m.Called(ctx, projectID, filter)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Recommendation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRecommendationRepository) Get(ctx context.Context, projectID, id uuid.UUID, out *models.Recommendation) error {
+	args := m.Called(ctx, projectID, id, out)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*out = *args.Get(1).(*models.Recommendation)
+	}
+	return args.Error(0)
+}
+
+func (m *mockRecommendationRepository) Create(ctx context.Context, rec *models.Recommendation) error {
+	args := m.Called(ctx, rec)
+	return args.Error(0)
+}
+
+func (m *mockRecommendationRepository) Update(ctx context.Context, rec *models.Recommendation) error {
+	args := m.Called(ctx, rec)
+	return args.Error(0)
+}
+
+func (m *mockRecommendationRepository) Delete(ctx context.Context, projectID, id uuid.UUID) error {
+	args := m.Called(ctx, projectID, id)
+	return args.Error(0)
+}
+
+func newRecommendationTest() (*mockProjectRepository, *mockGraphRepository, *mockRecommendationRepository, *mockProjectService, RecommendationService) {
+	projectRepo := new(mockProjectRepository)
+	graphRepo := new(mockGraphRepository)
+	recRepo := new(mockRecommendationRepository)
+	projects := new(mockProjectService)
+	return projectRepo, graphRepo, recRepo, projects, NewRecommendationService(projectRepo, graphRepo, recRepo, projects)
+}
+
+// bucketGraph is a current graph with one S3 bucket without versioning.
+func bucketGraph(t *testing.T, projectID uuid.UUID) *models.ProjectGraph {
+	t.Helper()
+	nodes, err := json.Marshal([]GraphNode{{ID: "logs", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "logs"}}})
+	require.NoError(t, err)
+	return &models.ProjectGraph{ProjectID: projectID, Version: 3, Nodes: datatypes.JSON(nodes), Edges: datatypes.JSON(`[]`), IsCurrent: true}
+}
+
+func TestRecommendationService_List(t *testing.T) {
+	ctx := context.Background()
+	projectRepo, _, recRepo, _, svc := newRecommendationTest()
+	userID := uuid.New()
+	p := ownedProject(projectRepo, userID)
+	filter := repository.RecommendationFilter{Source: models.RecommendationSourceSecurity, Status: models.RecommendationOpen}
+	recs := []models.Recommendation{{ID: uuid.New(), ProjectID: p.ID, Source: models.RecommendationSourceSecurity, Status: models.RecommendationOpen}}
+	recRepo.On("ListByProject", mock.Anything, p.ID, filter).Return(recs, nil)
+
+	out, err := svc.List(ctx, p.ID, userID, filter)
+	require.NoError(t, err)
+	require.Equal(t, recs, out)
+
+	_, err = svc.List(ctx, p.ID, uuid.New(), filter)
+	require.True(t, appErr.IsCode(err, appErr.CodeUnauthorized), "got %v", err)
+	recRepo.AssertNumberOfCalls(t, "ListByProject", 1)
+}
+
+func TestRecommendationService_Update(t *testing.T) {
+	ctx := context.Background()
+	status := func(s string) *string { return &s }
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	t.Run("dismiss clears the snooze", func(t *testing.T) {
+		projectRepo, _, recRepo, _, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationSnoozed, SnoozedUntil: &future}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+		recRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+		out, err := svc.Update(ctx, p.ID, rec.ID, userID, &RecommendationUpdate{Status: status(models.RecommendationDismissed)})
+		require.NoError(t, err)
+		require.Equal(t, models.RecommendationDismissed, out.Status)
+		require.Nil(t, out.SnoozedUntil)
+		recRepo.AssertCalled(t, "Update", mock.Anything, out)
+	})
+
+	t.Run("snooze until a future time", func(t *testing.T) {
+		projectRepo, _, recRepo, _, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationOpen}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+		recRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+		out, err := svc.Update(ctx, p.ID, rec.ID, userID, &RecommendationUpdate{Status: status(models.RecommendationSnoozed), SnoozedUntil: &future})
+		require.NoError(t, err)
+		require.Equal(t, models.RecommendationSnoozed, out.Status)
+		require.Equal(t, &future, out.SnoozedUntil)
+	})
+
+	invalid := map[string]*RecommendationUpdate{
+		"snooze without a time":    {Status: status(models.RecommendationSnoozed)},
+		"snooze into the past":     {Status: status(models.RecommendationSnoozed), SnoozedUntil: &past},
+		"snooze time when opening": {Status: status(models.RecommendationOpen), SnoozedUntil: &future},
+		"snooze time alone":        {SnoozedUntil: &future},
+		"mark applied":             {Status: status(models.RecommendationApplied)},
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			projectRepo, _, recRepo, _, svc := newRecommendationTest()
+			userID := uuid.New()
+			p := ownedProject(projectRepo, userID)
+			rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationOpen}
+			recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+
+			_, err := svc.Update(ctx, p.ID, rec.ID, userID, input)
+			require.True(t, appErr.IsCode(err, appErr.CodeInvalid), "got %v", err)
+			recRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRecommendationService_Apply(t *testing.T) {
+	ctx := context.Background()
+	patch, err := json.Marshal([]compiler.NodePatch{{NodeID: "logs", Set: map[string]interface{}{"versioning": true}}})
+	require.NoError(t, err)
+
+	t.Run("saves the patched graph and marks it applied", func(t *testing.T) {
+		projectRepo, graphRepo, recRepo, projects, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationOpen, Patch: datatypes.JSON(patch), GraphVersion: 3}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+		recRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+		graphRepo.On("GetCurrentByProject", mock.Anything, p.ID, mock.Anything).Return(nil, bucketGraph(t, p.ID))
+		versioned := mock.MatchedBy(func(data *GraphData) bool {
+			return len(data.Nodes) == 1 && data.Nodes[0].Properties["versioning"] == true && data.Nodes[0].Properties["bucket_name"] == "logs"
+		})
+		projects.On("SaveGraph", mock.Anything, p.ID, userID, versioned).Return(&models.ProjectGraph{ProjectID: p.ID, Version: 4}, nil)
+
+		out, err := svc.Apply(ctx, p.ID, rec.ID, userID)
+		require.NoError(t, err)
+		require.Equal(t, models.RecommendationApplied, out.Status)
+		require.NotNil(t, out.AppliedVersion)
+		require.Equal(t, 4, *out.AppliedVersion)
+		projects.AssertExpectations(t)
+		recRepo.AssertCalled(t, "Update", mock.Anything, out)
+	})
+
+	t.Run("graph changed since the recommendation was made", func(t *testing.T) {
+		projectRepo, graphRepo, recRepo, projects, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationOpen, Patch: datatypes.JSON(patch), GraphVersion: 2}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+		graphRepo.On("GetCurrentByProject", mock.Anything, p.ID, mock.Anything).Return(nil, bucketGraph(t, p.ID))
+
+		_, err := svc.Apply(ctx, p.ID, rec.ID, userID)
+		require.True(t, appErr.IsCode(err, appErr.CodeConflict), "got %v", err)
+		projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		recRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("retry after the patched graph was saved", func(t *testing.T) {
+		projectRepo, graphRepo, recRepo, projects, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationOpen, Patch: datatypes.JSON(patch), GraphVersion: 3}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+		recRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+		// version 4 is version 3 with the patch, saved by the failed attempt
+		saved := bucketGraph(t, p.ID)
+		saved.Version = 4
+		nodes, err := json.Marshal([]GraphNode{{ID: "logs", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "logs", "versioning": true}}})
+		require.NoError(t, err)
+		saved.Nodes = datatypes.JSON(nodes)
+		graphRepo.On("GetCurrentByProject", mock.Anything, p.ID, mock.Anything).Return(nil, saved)
+
+		out, err := svc.Apply(ctx, p.ID, rec.ID, userID)
+		require.NoError(t, err)
+		require.Equal(t, models.RecommendationApplied, out.Status)
+		require.NotNil(t, out.AppliedVersion)
+		require.Equal(t, 4, *out.AppliedVersion)
+		projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("already applied", func(t *testing.T) {
+		projectRepo, _, recRepo, projects, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		version := 2
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationApplied, AppliedVersion: &version, Patch: datatypes.JSON(patch)}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+
+		_, err := svc.Apply(ctx, p.ID, rec.ID, userID)
+		require.True(t, appErr.IsCode(err, appErr.CodeConflict), "got %v", err)
+		projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no patch", func(t *testing.T) {
+		projectRepo, _, recRepo, projects, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationOpen}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+
+		_, err := svc.Apply(ctx, p.ID, rec.ID, userID)
+		require.True(t, appErr.IsCode(err, appErr.CodeInvalid), "got %v", err)
+		projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("patch that breaks the graph", func(t *testing.T) {
+		projectRepo, graphRepo, recRepo, projects, svc := newRecommendationTest()
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		breaking, _ := json.Marshal([]compiler.NodePatch{{NodeID: "logs", Unset: []string{"bucket_name"}}})
+		rec := &models.Recommendation{ID: uuid.New(), ProjectID: p.ID, Status: models.RecommendationOpen, Patch: datatypes.JSON(breaking)}
+		recRepo.On("Get", mock.Anything, p.ID, rec.ID, mock.Anything).Return(nil, rec)
+		graphRepo.On("GetCurrentByProject", mock.Anything, p.ID, mock.Anything).Return(nil, bucketGraph(t, p.ID))
+
+		_, err := svc.Apply(ctx, p.ID, rec.ID, userID)
+		require.True(t, appErr.IsCode(err, appErr.CodeInvalid), "got %v", err)
+		projects.AssertNotCalled(t, "SaveGraph", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		recRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSecurityService_AnalyzeSyncsFingerprintedFindings(t *testing.T) {
+	ctx := context.Background()
+	projectRepo, graphRepo, recRepo := new(mockProjectRepository), new(mockGraphRepository), new(mockRecommendationRepository)
+	userID := uuid.New()
+	p := ownedProject(projectRepo, userID)
+	nodes, err := json.Marshal([]GraphNode{
+		{ID: "site", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "site", "acl": "public-read"}},
+		{ID: "assets", Type: "aws_s3_bucket", Properties: map[string]interface{}{"bucket_name": "assets", "block_public_access": false}},
+	})
+	require.NoError(t, err)
+	g := &models.ProjectGraph{ProjectID: p.ID, Version: 5, Nodes: datatypes.JSON(nodes), Edges: datatypes.JSON(`[]`), IsCurrent: true}
+	graphRepo.On("GetCurrentByProject", mock.Anything, p.ID, mock.Anything).Return(nil, g)
+	var synced []models.Recommendation
+	recRepo.On("SyncBySource", mock.Anything, p.ID, models.RecommendationSourceSecurity, mock.Anything).
+		Run(func(args mock.Arguments) { synced = args.Get(3).([]models.Recommendation) }).
+		Return(nil)
+
+	report, err := NewSecurityService(projectRepo, graphRepo, recRepo).Analyze(ctx, p.ID, userID, 0)
+	require.NoError(t, err)
+	require.Len(t, report.Findings, 2)
+	require.Len(t, synced, 2)
+	seen := map[string]bool{}
+	for _, rec := range synced {
+		require.Equal(t, p.ID, rec.ProjectID)
+		require.Equal(t, models.RecommendationOpen, rec.Status)
+		require.NotEmpty(t, rec.Fingerprint)
+		require.False(t, seen[rec.Fingerprint], "fingerprint %s repeated", rec.Fingerprint)
+		seen[rec.Fingerprint] = true
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/ai/optimizer"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/provisioner"
+	"github.com/iac-studio/engine/internal/provisioner/compiler"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// SecurityService runs the security analyzer over project graphs and keeps
+// its findings as the project's security recommendations; see
+// RecommendationService.
+type SecurityService interface {
+	// Analyze checks a graph version, the current one when version is 0,
+	// and replaces the project's security recommendations with the findings.
+	Analyze(ctx context.Context, projectID, userID uuid.UUID, version int) (*SecurityReport, error)
+}
+
+// SecurityReport is the outcome of one analysis.
+type SecurityReport struct {
+	ProjectID    uuid.UUID                   `json:"project_id"`
+	GraphVersion int                         `json:"graph_version"`
+	Findings     []optimizer.SecurityFinding `json:"findings"`
+	Counts       map[optimizer.Severity]int  `json:"counts"`
+}
+
+// securityDetails is what a security recommendation stores in details.
+type securityDetails struct {
+	GraphVersion int `json:"graph_version"`
+	optimizer.SecurityFinding
+}
+
+type securityService struct {
+	projectRepo repository.ProjectRepository
+	graphRepo   repository.GraphRepository
+	recRepo     repository.RecommendationRepository
+}
+
+func NewSecurityService(projectRepo repository.ProjectRepository, graphRepo repository.GraphRepository, recRepo repository.RecommendationRepository) SecurityService {
+	return &securityService{projectRepo: projectRepo, graphRepo: graphRepo, recRepo: recRepo}
+}
+
+var _ SecurityService = (*securityService)(nil)
+
+func (s *securityService) Analyze(ctx context.Context, projectID, userID uuid.UUID, version int) (*SecurityReport, error) {
+	logger.L().Info("analyze project security", zap.String("project_id", projectID.String()), zap.Int("version", version), zap.String("user_id", userID.String()))
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	var g models.ProjectGraph
+	var err error
+	if version > 0 {
+		err = s.graphRepo.GetByVersion(ctx, projectID, version, &g)
+	} else {
+		err = s.graphRepo.GetCurrentByProject(ctx, projectID, &g)
+	}
+	if err != nil {
+		return nil, err
+	}
+	graph, err := provisionerGraph(&g)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := optimizer.AnalyzeSecurity(graph)
+	report := &SecurityReport{ProjectID: projectID, GraphVersion: g.Version, Findings: findings, Counts: map[optimizer.Severity]int{}}
+	recs := make([]models.Recommendation, 0, len(findings))
+	for _, f := range findings {
+		report.Counts[f.Severity]++
+		details, _ := json.Marshal(securityDetails{GraphVersion: g.Version, SecurityFinding: f})
+		nodeIDs, _ := json.Marshal([]string{f.NodeID})
+		rec := models.Recommendation{
+			ProjectID:    projectID,
+			Source:       models.RecommendationSourceSecurity,
+			Severity:     string(f.Severity),
+			Status:       models.RecommendationOpen,
+			Title:        f.Title,
+			Description:  f.Rationale,
+			NodeIDs:      datatypes.JSON(nodeIDs),
+			Fingerprint:  f.Fingerprint(),
+			Details:      datatypes.JSON(details),
+			GraphVersion: g.Version,
+		}
+		if len(f.Patch) > 0 {
+			patch, _ := json.Marshal([]compiler.NodePatch{{NodeID: f.NodeID, Set: f.Patch, Reason: f.Rationale}})
+			rec.Patch = datatypes.JSON(patch)
+		}
+		recs = append(recs, rec)
+	}
+	if err := s.recRepo.SyncBySource(ctx, projectID, models.RecommendationSourceSecurity, recs); err != nil {
+		return nil, err
+	}
+	logger.L().Info("security analysis completed", zap.String("project_id", projectID.String()), zap.Int("graph_version", g.Version), zap.Int("findings", len(findings)))
+	return report, nil
+}
+
+func (s *securityService) checkOwner(ctx context.Context, projectID, userID uuid.UUID) error {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return err
+	}
+	if p.UserID != userID {
+		return appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return nil
+}
+
+// provisionerGraph decodes a stored graph version into the provisioner's
+// graph.
+func provisionerGraph(g *models.ProjectGraph) (provisioner.Graph, error) {
+	var out provisioner.Graph
+	if len(g.Nodes) > 0 {
+		if err := json.Unmarshal(g.Nodes, &out.Nodes); err != nil {
+			return out, appErr.Wrap(err, appErr.CodeInternal, "unmarshal nodes failed")
+		}
+	}
+	if len(g.Edges) > 0 {
+		if err := json.Unmarshal(g.Edges, &out.Edges); err != nil {
+			return out, appErr.Wrap(err, appErr.CodeInternal, "unmarshal edges failed")
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/repository"
+	"github.com/iac-studio/engine/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	// Initialize logger for tests (required by services)
+	_, err := logger.Init("info", "json")
+	if err != nil {
+		panic("failed to init logger: " + err.Error())
+	}
+	os.Exit(m.Run())
+}
+
+// Mock implementations shared by the service tests
+
+type mockProjectRepository struct {
+	mock.Mock
+}
+
+func (m *mockProjectRepository) Create(ctx context.Context, obj *models.Project) error {
+	args := m.Called(ctx, obj)
+	return args.Error(0)
+}
+
+func (m *mockProjectRepository) GetByID(ctx context.Context, id any, dest *models.Project) error {
+	args := m.Called(ctx, id, dest)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		src := args.Get(1).(*models.Project)
+		*dest = *src
+	}
+	return args.Error(0)
+}
+
+func (m *mockProjectRepository) Update(ctx context.Context, obj *models.Project) error {
+	args := m.Called(ctx, obj)
+	return args.Error(0)
+}
+
+func (m *mockProjectRepository) Delete(ctx context.Context, id any) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockProjectRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Project, error) {
+	args := m.Called(ctx, userID)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Project), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockProjectRepository) Archive(ctx context.Context, projectID uuid.UUID) error {
+	args := m.Called(ctx, projectID)
+	return args.Error(0)
+}
+
+// mockDeploymentRepository serves deployments by ID and records status
+// changes; the other methods are not called.
+type mockDeploymentRepository struct {
+	repository.DeploymentRepository
+	mock.Mock
+}
+
+func (m *mockDeploymentRepository) GetByID(ctx context.Context, id any, dest *models.Deployment) error {
+	args := m.Called(ctx, id, dest)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*dest = *args.Get(1).(*models.Deployment)
+	}
+	return args.Error(0)
+}
+
+func (m *mockDeploymentRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.DeploymentStatus) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+type mockGraphRepository struct {
+	mock.Mock
+}
+
+func (m *mockGraphRepository) Create(ctx context.Context, obj *models.ProjectGraph) error {
+	args := m.Called(ctx, obj)
+	return args.Error(0)
+}
+
+func (m *mockGraphRepository) GetByID(ctx context.Context, id any, dest *models.ProjectGraph) error {
+	args := m.Called(ctx, id, dest)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*dest = *args.Get(1).(*models.ProjectGraph)
+	}
+	return args.Error(0)
+}
+
+func (m *mockGraphRepository) Update(ctx context.Context, obj *models.ProjectGraph) error {
+	args := m.Called(ctx, obj)
+	return args.Error(0)
+}
+
+func (m *mockGraphRepository) Delete(ctx context.Context, id any) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockGraphRepository) GetCurrentByProject(ctx context.Context, projectID uuid.UUID, dest *models.ProjectGraph) error {
+	args := m.Called(ctx, projectID, dest)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*dest = *args.Get(1).(*models.ProjectGraph)
+	}
+	return args.Error(0)
+}
+
+func (m *mockGraphRepository) GetByVersion(ctx context.Context, projectID uuid.UUID, version int, dest *models.ProjectGraph) error {
+	args := m.Called(ctx, projectID, version, dest)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*dest = *args.Get(1).(*models.ProjectGraph)
+	}
+	return args.Error(0)
+}
+
+func (m *mockGraphRepository) ListByProject(ctx context.Context, projectID uuid.UUID) ([]models.ProjectGraph, error) {
+	args := m.Called(ctx, projectID)
+	if v := args.Get(0); v != nil {
+		return v.([]models.ProjectGraph), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockGraphRepository) SetCurrent(ctx context.Context, projectID uuid.UUID, version int) error {
+	args := m.Called(ctx, projectID, version)
+	return args.Error(0)
+}
+
+// mockProjectService mocks the graph saving services delegate to; the
+// other methods are not called.
+type mockProjectService struct {
+	ProjectService
+	mock.Mock
+}
+
+func (m *mockProjectService) SaveGraph(ctx context.Context, projectID, userID uuid.UUID, graphData *GraphData) (*models.ProjectGraph, error) {
+	args := m.Called(ctx, projectID, userID, graphData)
+	if v := args.Get(0); v != nil {
+		return v.(*models.ProjectGraph), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// ownedProject returns a project of userID that projectRepo serves.
+func ownedProject(projectRepo *mockProjectRepository, userID uuid.UUID) *models.Project {
+	p := &models.Project{ID: uuid.New(), UserID: userID, Name: "demo"}
+	projectRepo.On("GetByID", mock.Anything, p.ID, mock.Anything).Return(nil, p)
+	return p
+}
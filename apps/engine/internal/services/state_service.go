@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// StateService exposes the Terraform state history of a deployment.
+type StateService interface {
+	ListStateVersions(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.StateVersion, error)
+	GetStateVersion(ctx context.Context, deploymentID, userID uuid.UUID, serial int) (*models.StateVersion, error)
+	// PromoteStateVersion makes an earlier version the deployment's current state.
+	PromoteStateVersion(ctx context.Context, deploymentID, userID uuid.UUID, serial int) (*models.StateVersion, error)
+}
+
+type stateService struct {
+	projectRepo repository.ProjectRepository
+	deployRepo  repository.DeploymentRepository
+	stateRepo   repository.StateVersionRepository
+}
+
+func NewStateService(projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, stateRepo repository.StateVersionRepository) StateService {
+	return &stateService{projectRepo: projectRepo, deployRepo: deployRepo, stateRepo: stateRepo}
+}
+
+var _ StateService = (*stateService)(nil)
+
+func (s *stateService) ListStateVersions(ctx context.Context, deploymentID, userID uuid.UUID) ([]models.StateVersion, error) {
+	logger.L().Info("list state versions", zap.String("deployment_id", deploymentID.String()), zap.String("user_id", userID.String()))
+	if _, err := s.authorize(ctx, deploymentID, userID); err != nil {
+		return nil, err
+	}
+	return s.stateRepo.ListByDeployment(ctx, deploymentID)
+}
+
+func (s *stateService) GetStateVersion(ctx context.Context, deploymentID, userID uuid.UUID, serial int) (*models.StateVersion, error) {
+	logger.L().Info("get state version", zap.String("deployment_id", deploymentID.String()), zap.Int("serial", serial), zap.String("user_id", userID.String()))
+	if _, err := s.authorize(ctx, deploymentID, userID); err != nil {
+		return nil, err
+	}
+	var v models.StateVersion
+	if err := s.stateRepo.GetBySerial(ctx, deploymentID, serial, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (s *stateService) PromoteStateVersion(ctx context.Context, deploymentID, userID uuid.UUID, serial int) (*models.StateVersion, error) {
+	logger.L().Info("promote state version", zap.String("deployment_id", deploymentID.String()), zap.Int("serial", serial), zap.String("user_id", userID.String()))
+	d, err := s.authorize(ctx, deploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	// swapping state under a running terraform process would corrupt it
+	switch d.Status {
+	case models.DeploymentPending, models.DeploymentPlanning, models.DeploymentAwaitingApproval, models.DeploymentApproved, models.DeploymentApplying, models.DeploymentDestroyQueued, models.DeploymentDestroying:
+		return nil, appErr.New(appErr.CodeConflict, "cannot promote state while deployment is "+string(d.Status))
+	}
+
+	v, err := s.stateRepo.Restore(repository.WithActor(ctx, "user:"+userID.String()), deploymentID, serial)
+	if err != nil {
+		return nil, err
+	}
+	logger.L().Info("state version promoted", zap.String("deployment_id", deploymentID.String()), zap.Int("from_serial", serial), zap.Int("serial", v.Serial))
+	return v, nil
+}
+
+// authorize loads the deployment and checks that userID owns its project.
+func (s *stateService) authorize(ctx context.Context, deploymentID, userID uuid.UUID) (*models.Deployment, error) {
+	var d models.Deployment
+	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+		return nil, err
+	}
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return &d, nil
+}
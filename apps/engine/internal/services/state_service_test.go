@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+)
+
+type mockStateVersionRepository struct {
+	mock.Mock
+}
+
+func (m *mockStateVersionRepository) Record(ctx context.Context, deploymentID uuid.UUID, state []byte) (*models.StateVersion, error) {
+	args := m.Called(ctx, deploymentID, state)
+	if v := args.Get(0); v != nil {
+		return v.(*models.StateVersion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockStateVersionRepository) Restore(ctx context.Context, deploymentID uuid.UUID, serial int) (*models.StateVersion, error) {
+	args := m.Called(ctx, deploymentID, serial)
+	if v := args.Get(0); v != nil {
+		return v.(*models.StateVersion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockStateVersionRepository) ListByDeployment(ctx context.Context, deploymentID uuid.UUID) ([]models.StateVersion, error) {
+	args := m.Called(ctx, deploymentID)
+	if v := args.Get(0); v != nil {
+		return v.([]models.StateVersion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockStateVersionRepository) GetBySerial(ctx context.Context, deploymentID uuid.UUID, serial int, dest *models.StateVersion) error {
+	args := m.Called(ctx, deploymentID, serial, dest)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*dest = *args.Get(1).(*models.StateVersion)
+	}
+	return args.Error(0)
+}
+
+func (m *mockStateVersionRepository) GetCurrent(ctx context.Context, deploymentID uuid.UUID) ([]byte, error) {
+	args := m.Called(ctx, deploymentID)
+	if v := args.Get(0); v != nil {
+		return v.([]byte), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestStateService_PromoteStateVersion(t *testing.T) {
+	ctx := context.Background()
+	setup := func(status models.DeploymentStatus) (*mockStateVersionRepository, StateService, *models.Deployment, uuid.UUID) {
+		projectRepo, deployRepo, stateRepo := new(mockProjectRepository), new(mockDeploymentRepository), new(mockStateVersionRepository)
+		userID := uuid.New()
+		p := ownedProject(projectRepo, userID)
+		d := &models.Deployment{ID: uuid.New(), ProjectID: p.ID, Status: status}
+		deployRepo.On("GetByID", mock.Anything, d.ID, mock.Anything).Return(nil, d)
+		return stateRepo, NewStateService(projectRepo, deployRepo, stateRepo), d, userID
+	}
+
+	t.Run("restores an older version as the user", func(t *testing.T) {
+		stateRepo, svc, d, userID := setup(models.DeploymentApplied)
+		from := 2
+		restored := &models.StateVersion{DeploymentID: d.ID, Serial: 5, RestoredFrom: &from}
+		asUser := mock.MatchedBy(func(ctx context.Context) bool {
+			return repository.ActorFromContext(ctx) == "user:"+userID.String()
+		})
+		stateRepo.On("Restore", asUser, d.ID, 2).Return(restored, nil)
+
+		v, err := svc.PromoteStateVersion(ctx, d.ID, userID, 2)
+		require.NoError(t, err)
+		require.Equal(t, restored, v)
+		stateRepo.AssertExpectations(t)
+	})
+
+	t.Run("version of another deployment", func(t *testing.T) {
+		stateRepo, svc, d, userID := setup(models.DeploymentApplied)
+		stateRepo.On("Restore", mock.Anything, d.ID, 7).Return(nil, appErr.New(appErr.CodeNotFound, "state version not found"))
+
+		_, err := svc.PromoteStateVersion(ctx, d.ID, userID, 7)
+		require.True(t, appErr.IsCode(err, appErr.CodeNotFound), "got %v", err)
+	})
+
+	t.Run("other user's deployment", func(t *testing.T) {
+		stateRepo, svc, d, _ := setup(models.DeploymentApplied)
+
+		_, err := svc.PromoteStateVersion(ctx, d.ID, uuid.New(), 1)
+		require.True(t, appErr.IsCode(err, appErr.CodeUnauthorized), "got %v", err)
+		stateRepo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	for _, status := range []models.DeploymentStatus{models.DeploymentPending, models.DeploymentPlanning, models.DeploymentApplying, models.DeploymentDestroying} {
+		t.Run("while "+string(status), func(t *testing.T) {
+			stateRepo, svc, d, userID := setup(status)
+
+			_, err := svc.PromoteStateVersion(ctx, d.ID, userID, 1)
+			require.True(t, appErr.IsCode(err, appErr.CodeConflict), "got %v", err)
+			stateRepo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
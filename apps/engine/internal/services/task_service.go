@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/queue"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// archivedPageSize is how many archived tasks are fetched from redis per call.
+const archivedPageSize = 100
+
+// TaskService exposes the dead-letter queue: deployment tasks that failed
+// permanently or ran out of retries and were archived by the worker.
+type TaskService interface {
+	ListArchivedTasks(ctx context.Context, userID uuid.UUID) ([]ArchivedTask, error)
+	// RetryArchivedTask moves an archived task back to the pending queue.
+	RetryArchivedTask(ctx context.Context, taskID string, userID uuid.UUID) error
+	// DeleteArchivedTask drops an archived task and fails its deployment,
+	// which nothing will run any more.
+	DeleteArchivedTask(ctx context.Context, taskID string, userID uuid.UUID) error
+}
+
+// ArchivedTask is a dead-lettered deployment task.
+type ArchivedTask struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type" example:"deployment:provision"`
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	ProjectID    uuid.UUID `json:"project_id"`
+	Targets      []string  `json:"targets,omitempty"`
+	LastError    string    `json:"last_error"`
+	Retried      int       `json:"retried"`
+	MaxRetry     int       `json:"max_retry"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+}
+
+// archivedPayload mirrors the deployment task payload (tasks.ProvisionPayload).
+type archivedPayload struct {
+	DeploymentID string   `json:"deployment_id"`
+	Targets      []string `json:"targets"`
+}
+
+type taskService struct {
+	projectRepo repository.ProjectRepository
+	deployRepo  repository.DeploymentRepository
+	inspector   *asynq.Inspector
+}
+
+func NewTaskService(projectRepo repository.ProjectRepository, deployRepo repository.DeploymentRepository, inspector *asynq.Inspector) TaskService {
+	return &taskService{projectRepo: projectRepo, deployRepo: deployRepo, inspector: inspector}
+}
+
+var _ TaskService = (*taskService)(nil)
+
+func (s *taskService) ListArchivedTasks(ctx context.Context, userID uuid.UUID) ([]ArchivedTask, error) {
+	logger.L().Info("list archived tasks", zap.String("user_id", userID.String()))
+	if s.inspector == nil {
+		return nil, appErr.New(appErr.CodeUnavailable, "task queue not configured")
+	}
+
+	owned := map[uuid.UUID]*models.Deployment{}
+	denied := map[uuid.UUID]bool{}
+	out := []ArchivedTask{}
+	for page := 1; ; page++ {
+		infos, err := s.inspector.ListArchivedTasks(queue.DefaultQueue, asynq.PageSize(archivedPageSize), asynq.Page(page))
+		if err != nil {
+			return nil, appErr.Wrap(err, appErr.CodeUnavailable, "list archived tasks failed")
+		}
+		for _, info := range infos {
+			at, ok := toArchivedTask(info)
+			if !ok || denied[at.DeploymentID] {
+				continue
+			}
+			d, found := owned[at.DeploymentID]
+			if !found {
+				if d, err = s.authorize(ctx, at.DeploymentID, userID); err != nil {
+					denied[at.DeploymentID] = true
+					continue
+				}
+				owned[at.DeploymentID] = d
+			}
+			at.ProjectID = d.ProjectID
+			out = append(out, at)
+		}
+		if len(infos) < archivedPageSize {
+			return out, nil
+		}
+	}
+}
+
+func (s *taskService) RetryArchivedTask(ctx context.Context, taskID string, userID uuid.UUID) error {
+	logger.L().Info("retry archived task", zap.String("task_id", taskID), zap.String("user_id", userID.String()))
+	at, err := s.archived(ctx, taskID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.inspector.RunTask(queue.DefaultQueue, taskID); err != nil {
+		return appErr.Wrap(err, appErr.CodeUnavailable, "retry archived task failed")
+	}
+	// the worker resumes from the deployment's checkpoint
+	status := models.DeploymentPending
+	if at.Type == queue.TypeDestroy {
+		status = models.DeploymentDestroyQueued
+	}
+	if err := s.deployRepo.UpdateStatus(repository.WithActor(ctx, "user:"+userID.String()), at.DeploymentID, status); err != nil {
+		logger.L().Warn("update status failed", zap.String("status", string(status)), zap.Error(err))
+	}
+	return nil
+}
+
+func (s *taskService) DeleteArchivedTask(ctx context.Context, taskID string, userID uuid.UUID) error {
+	logger.L().Info("delete archived task", zap.String("task_id", taskID), zap.String("user_id", userID.String()))
+	at, err := s.archived(ctx, taskID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.inspector.DeleteTask(queue.DefaultQueue, taskID); err != nil {
+		return appErr.Wrap(err, appErr.CodeUnavailable, "delete archived task failed")
+	}
+	// nothing will run the deployment any more; failing it takes it out of
+	// the project queue
+	if err := s.deployRepo.UpdateStatus(repository.WithActor(ctx, "user:"+userID.String()), at.DeploymentID, models.DeploymentFailed); err != nil {
+		logger.L().Warn("fail deployment failed", zap.String("deployment_id", at.DeploymentID.String()), zap.Error(err))
+	}
+	return nil
+}
+
+// archived loads an archived deployment task the user owns.
+func (s *taskService) archived(ctx context.Context, taskID string, userID uuid.UUID) (*ArchivedTask, error) {
+	if s.inspector == nil {
+		return nil, appErr.New(appErr.CodeUnavailable, "task queue not configured")
+	}
+	info, err := s.inspector.GetTaskInfo(queue.DefaultQueue, taskID)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, appErr.New(appErr.CodeNotFound, "task not found")
+		}
+		return nil, appErr.Wrap(err, appErr.CodeUnavailable, "get task failed")
+	}
+	at, ok := toArchivedTask(info)
+	if !ok || info.State != asynq.TaskStateArchived {
+		return nil, appErr.New(appErr.CodeNotFound, "task not found")
+	}
+	d, err := s.authorize(ctx, at.DeploymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	at.ProjectID = d.ProjectID
+	return &at, nil
+}
+
+// liveDeploymentTask reports whether the deployment's task of type typ is
+// still queued, running or waiting for a retry. Without an inspector every
+// task counts as live.
+func liveDeploymentTask(inspector *asynq.Inspector, typ string, deploymentID uuid.UUID) (bool, error) {
+	if inspector == nil {
+		return true, nil
+	}
+	info, err := inspector.GetTaskInfo(queue.DefaultQueue, queue.DeploymentTaskID(typ, deploymentID))
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return false, nil
+		}
+		return false, appErr.Wrap(err, appErr.CodeUnavailable, "get task failed")
+	}
+	return info.State != asynq.TaskStateArchived && info.State != asynq.TaskStateCompleted, nil
+}
+
+// toArchivedTask decodes a deployment task; other task types are skipped.
+func toArchivedTask(info *asynq.TaskInfo) (ArchivedTask, bool) {
+	switch info.Type {
+	case queue.TypeProvision, queue.TypeDestroy, queue.TypePlan:
+	default:
+		return ArchivedTask{}, false
+	}
+	var p archivedPayload
+	if err := json.Unmarshal(info.Payload, &p); err != nil {
+		return ArchivedTask{}, false
+	}
+	id, err := uuid.Parse(p.DeploymentID)
+	if err != nil {
+		return ArchivedTask{}, false
+	}
+	return ArchivedTask{
+		ID:           info.ID,
+		Type:         info.Type,
+		DeploymentID: id,
+		Targets:      p.Targets,
+		LastError:    info.LastErr,
+		Retried:      info.Retried,
+		MaxRetry:     info.MaxRetry,
+		LastFailedAt: info.LastFailedAt,
+	}, true
+}
+
+func (s *taskService) authorize(ctx context.Context, deploymentID, userID uuid.UUID) (*models.Deployment, error) {
+	var d models.Deployment
+	if err := s.deployRepo.GetByID(ctx, deploymentID, &d); err != nil {
+		return nil, err
+	}
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, d.ProjectID, &p); err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return &d, nil
+}
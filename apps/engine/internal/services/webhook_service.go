@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/iac-studio/engine/internal/events"
+	"github.com/iac-studio/engine/internal/models"
+	"github.com/iac-studio/engine/internal/queue"
+	"github.com/iac-studio/engine/internal/repository"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+	"github.com/iac-studio/engine/pkg/logger"
+	"github.com/iac-studio/engine/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// MaxWebhookDeliveries caps a delivery listing.
+const MaxWebhookDeliveries = 500
+
+// WebhookService manages a project's outbound webhooks. Events published to
+// it are recorded as one delivery per subscribed webhook and sent by the
+// webhook:deliver task, which retries failed requests with backoff.
+type WebhookService interface {
+	List(ctx context.Context, projectID, userID uuid.UUID) ([]models.Webhook, error)
+	Get(ctx context.Context, projectID, webhookID, userID uuid.UUID) (*models.Webhook, error)
+	// Create registers a webhook and returns it with its signing secret,
+	// which is not shown again.
+	Create(ctx context.Context, projectID, userID uuid.UUID, input *WebhookInput) (*CreatedWebhook, error)
+	Update(ctx context.Context, projectID, webhookID, userID uuid.UUID, input *WebhookUpdate) (*models.Webhook, error)
+	Delete(ctx context.Context, projectID, webhookID, userID uuid.UUID) error
+
+	ListDeliveries(ctx context.Context, projectID, webhookID, userID uuid.UUID, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, projectID, webhookID, deliveryID, userID uuid.UUID) (*models.WebhookDelivery, error)
+	// Redeliver sends a delivery's payload again as a new delivery with the
+	// same event ID.
+	Redeliver(ctx context.Context, projectID, webhookID, deliveryID, userID uuid.UUID) (*models.WebhookDelivery, error)
+
+	// Publish queues a delivery of the event to every enabled webhook of
+	// its project subscribed to it, which makes the service an
+	// events.Publisher.
+	Publish(ctx context.Context, e events.Event) error
+	// Deliver makes one attempt at a pending delivery. It returns an error
+	// when the attempt failed and another will follow; on the last attempt
+	// the delivery is marked failed instead.
+	Deliver(ctx context.Context, deliveryID uuid.UUID, last bool) error
+}
+
+// WebhookInput registers a webhook; it is enabled unless Enabled is false.
+type WebhookInput struct {
+	URL         string   `json:"url" example:"https://ci.example.com/hooks/iac"`
+	Description string   `json:"description,omitempty"`
+	Events      []string `json:"events" example:"deployment.applied,deployment.failed"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+}
+
+// WebhookUpdate changes the fields that are set. The secret is fixed.
+type WebhookUpdate struct {
+	URL         *string   `json:"url,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Events      *[]string `json:"events,omitempty"`
+	Enabled     *bool     `json:"enabled,omitempty"`
+}
+
+// CreatedWebhook is a new webhook with the secret its deliveries are signed
+// with; see events.Verify.
+type CreatedWebhook struct {
+	models.Webhook
+	Secret string `json:"secret" example:"whsec_3f2a..."`
+}
+
+type webhookService struct {
+	projectRepo repository.ProjectRepository
+	webhookRepo repository.WebhookRepository
+	asynqClient *asynq.Client
+	client      *http.Client
+}
+
+func NewWebhookService(projectRepo repository.ProjectRepository, webhookRepo repository.WebhookRepository, asynqClient *asynq.Client) WebhookService {
+	return &webhookService{projectRepo: projectRepo, webhookRepo: webhookRepo, asynqClient: asynqClient, client: utils.NewSafeHTTPClient(10 * time.Second)}
+}
+
+var (
+	_ WebhookService   = (*webhookService)(nil)
+	_ events.Publisher = (*webhookService)(nil)
+)
+
+func (s *webhookService) List(ctx context.Context, projectID, userID uuid.UUID) ([]models.Webhook, error) {
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.List(ctx, projectID)
+}
+
+func (s *webhookService) Get(ctx context.Context, projectID, webhookID, userID uuid.UUID) (*models.Webhook, error) {
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	var w models.Webhook
+	if err := s.webhookRepo.Get(ctx, projectID, webhookID, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (s *webhookService) Create(ctx context.Context, projectID, userID uuid.UUID, input *WebhookInput) (*CreatedWebhook, error) {
+	logger.L().Info("create webhook", zap.String("project_id", projectID.String()), zap.String("user_id", userID.String()))
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL(input.URL); err != nil {
+		return nil, err
+	}
+	filter, err := webhookEvents(input.Events)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	w := &models.Webhook{
+		ProjectID:   projectID,
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      filter,
+		Secret:      secret,
+		Enabled:     input.Enabled == nil || *input.Enabled,
+	}
+	if err := s.webhookRepo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+	return &CreatedWebhook{Webhook: *w, Secret: secret}, nil
+}
+
+func (s *webhookService) Update(ctx context.Context, projectID, webhookID, userID uuid.UUID, input *WebhookUpdate) (*models.Webhook, error) {
+	logger.L().Info("update webhook", zap.String("project_id", projectID.String()), zap.String("webhook_id", webhookID.String()), zap.String("user_id", userID.String()))
+	w, err := s.Get(ctx, projectID, webhookID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.URL != nil {
+		if err := validateWebhookURL(*input.URL); err != nil {
+			return nil, err
+		}
+		w.URL = *input.URL
+	}
+	if input.Description != nil {
+		w.Description = *input.Description
+	}
+	if input.Events != nil {
+		if w.Events, err = webhookEvents(*input.Events); err != nil {
+			return nil, err
+		}
+	}
+	if input.Enabled != nil {
+		w.Enabled = *input.Enabled
+	}
+	if err := s.webhookRepo.Update(ctx, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *webhookService) Delete(ctx context.Context, projectID, webhookID, userID uuid.UUID) error {
+	logger.L().Info("delete webhook", zap.String("project_id", projectID.String()), zap.String("webhook_id", webhookID.String()), zap.String("user_id", userID.String()))
+	if err := s.checkOwner(ctx, projectID, userID); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(ctx, projectID, webhookID)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, projectID, webhookID, userID uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, projectID, webhookID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxWebhookDeliveries {
+		limit = MaxWebhookDeliveries
+	}
+	return s.webhookRepo.ListDeliveries(ctx, webhookID, limit)
+}
+
+func (s *webhookService) GetDelivery(ctx context.Context, projectID, webhookID, deliveryID, userID uuid.UUID) (*models.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, projectID, webhookID, userID); err != nil {
+		return nil, err
+	}
+	var d models.WebhookDelivery
+	if err := s.webhookRepo.GetDelivery(ctx, deliveryID, &d); err != nil {
+		return nil, err
+	}
+	if d.WebhookID != webhookID {
+		return nil, appErr.New(appErr.CodeNotFound, "webhook delivery not found")
+	}
+	return &d, nil
+}
+
+func (s *webhookService) Redeliver(ctx context.Context, projectID, webhookID, deliveryID, userID uuid.UUID) (*models.WebhookDelivery, error) {
+	logger.L().Info("redeliver webhook", zap.String("project_id", projectID.String()), zap.String("webhook_id", webhookID.String()), zap.String("delivery_id", deliveryID.String()), zap.String("user_id", userID.String()))
+	w, err := s.Get(ctx, projectID, webhookID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !w.Enabled {
+		return nil, appErr.New(appErr.CodeConflict, "webhook is disabled")
+	}
+	prev, err := s.GetDelivery(ctx, projectID, webhookID, deliveryID, userID)
+	if err != nil {
+		return nil, err
+	}
+	d := &models.WebhookDelivery{
+		WebhookID:    w.ID,
+		ProjectID:    projectID,
+		EventID:      prev.EventID,
+		EventType:    prev.EventType,
+		Payload:      prev.Payload,
+		Status:       models.WebhookDeliveryPending,
+		RedeliveryOf: &prev.ID,
+	}
+	if err := s.queueDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *webhookService) Publish(ctx context.Context, e events.Event) error {
+	projectID, err := uuid.Parse(e.ProjectID)
+	if err != nil {
+		return nil
+	}
+	hooks, err := s.webhookRepo.ListSubscribed(ctx, projectID, e.Type)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	var errs []error
+	for _, w := range hooks {
+		d := &models.WebhookDelivery{
+			WebhookID: w.ID,
+			ProjectID: projectID,
+			EventID:   e.ID,
+			EventType: e.Type,
+			Payload:   datatypes.JSON(payload),
+			Status:    models.WebhookDeliveryPending,
+		}
+		if err := s.queueDelivery(ctx, d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *webhookService) Deliver(ctx context.Context, deliveryID uuid.UUID, last bool) error {
+	var d models.WebhookDelivery
+	if err := s.webhookRepo.GetDelivery(ctx, deliveryID, &d); err != nil {
+		return err
+	}
+	if d.Status != models.WebhookDeliveryPending {
+		return nil
+	}
+	var w models.Webhook
+	if err := s.webhookRepo.Get(ctx, d.ProjectID, d.WebhookID, &w); err != nil {
+		return err
+	}
+	if !w.Enabled {
+		d.Status = models.WebhookDeliveryFailed
+		d.Error = "webhook is disabled"
+		return s.webhookRepo.UpdateDelivery(ctx, &d)
+	}
+
+	status, sendErr := s.send(ctx, &w, &d)
+	d.Attempts++
+	d.ResponseStatus = status
+	d.Error = ""
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		d.Status = models.WebhookDeliverySucceeded
+		d.DeliveredAt = &now
+	case last:
+		d.Status = models.WebhookDeliveryFailed
+		d.Error = sendErr.Error()
+	default:
+		d.Error = sendErr.Error()
+	}
+	if err := s.webhookRepo.UpdateDelivery(ctx, &d); err != nil {
+		return err
+	}
+	if sendErr != nil && !last {
+		return sendErr
+	}
+	return nil
+}
+
+// send POSTs the delivery's payload, signed now, and returns the response
+// status. Any status outside 2xx is an error.
+func (s *webhookService) send(ctx context.Context, w *models.Webhook, d *models.WebhookDelivery) (int, error) {
+	e := events.Event{ID: d.EventID, Type: d.EventType}
+	req, err := events.NewSignedRequest(ctx, w.URL, w.Secret, d.ID.String(), e, d.Payload, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// queueDelivery stores d and enqueues its first attempt. A delivery that
+// cannot be enqueued is marked failed.
+func (s *webhookService) queueDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	if err := s.webhookRepo.CreateDelivery(ctx, d); err != nil {
+		return err
+	}
+	if s.asynqClient == nil {
+		logger.L().Warn("asynq client not configured, skipping webhook enqueue", zap.String("delivery_id", d.ID.String()))
+		return nil
+	}
+	pb, _ := json.Marshal(map[string]string{"delivery_id": d.ID.String()})
+	task := asynq.NewTask(queue.TypeWebhookDeliver, pb)
+	if _, err := s.asynqClient.EnqueueContext(ctx, task, queue.WebhookTaskOptions(d.ID)...); err != nil {
+		logger.L().Error("enqueue webhook delivery failed", zap.Error(err), zap.String("delivery_id", d.ID.String()))
+		d.Status = models.WebhookDeliveryFailed
+		d.Error = "enqueue failed: " + err.Error()
+		_ = s.webhookRepo.UpdateDelivery(ctx, d)
+		return appErr.Wrap(err, appErr.CodeInternal, "enqueue webhook delivery failed")
+	}
+	return nil
+}
+
+func (s *webhookService) checkOwner(ctx context.Context, projectID, userID uuid.UUID) error {
+	var p models.Project
+	if err := s.projectRepo.GetByID(ctx, projectID, &p); err != nil {
+		return err
+	}
+	if p.UserID != userID {
+		return appErr.New(appErr.CodeUnauthorized, "user does not own project")
+	}
+	return nil
+}
+
+// validateWebhookURL rejects URLs that are not http(s) or name an internal
+// host; names resolving to internal addresses are refused when dialing.
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return appErr.New(appErr.CodeInvalid, "url must be an absolute http or https URL")
+	}
+	if err := utils.CheckPublicHost(u.Hostname()); err != nil {
+		return appErr.New(appErr.CodeInvalid, "url must not point to a loopback, private or link-local address")
+	}
+	return nil
+}
+
+// webhookEvents validates an event filter and returns it without
+// duplicates.
+func webhookEvents(types []string) (datatypes.JSON, error) {
+	if len(types) == 0 {
+		return nil, appErr.New(appErr.CodeInvalid, "events must name at least one event type")
+	}
+	seen := make(map[string]bool, len(types))
+	unique := make([]string, 0, len(types))
+	for _, t := range types {
+		if !events.KnownType(t) {
+			return nil, appErr.New(appErr.CodeInvalid, "unknown event type "+t).WithMeta("allowed", events.Types)
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		unique = append(unique, t)
+	}
+	b, _ := json.Marshal(unique)
+	return datatypes.JSON(b), nil
+}
+
+// newWebhookSecret returns a random signing secret.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", appErr.Wrap(err, appErr.CodeInternal, "generate webhook secret failed")
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	"github.com/iac-studio/engine/internal/events"
+	"github.com/iac-studio/engine/internal/models"
+	appErr "github.com/iac-studio/engine/pkg/errors"
+)
+
+type mockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *mockWebhookRepository) List(ctx context.Context, projectID uuid.UUID) ([]models.Webhook, error) {
+	args := m.Called(ctx, projectID)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Webhook), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockWebhookRepository) ListSubscribed(ctx context.Context, projectID uuid.UUID, typ string) ([]models.Webhook, error) {
+	args := m.Called(ctx, projectID, typ)
+	if v := args.Get(0); v != nil {
+		return v.([]models.Webhook), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockWebhookRepository) Get(ctx context.Context, projectID, id uuid.UUID, out *models.Webhook) error {
+	args := m.Called(ctx, projectID, id, out)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*out = *args.Get(1).(*models.Webhook)
+	}
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepository) Create(ctx context.Context, w *models.Webhook) error {
+	args := m.Called(ctx, w)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepository) Update(ctx context.Context, w *models.Webhook) error {
+	args := m.Called(ctx, w)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepository) Delete(ctx context.Context, projectID, id uuid.UUID) error {
+	args := m.Called(ctx, projectID, id)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepository) CreateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	args := m.Called(ctx, d)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepository) UpdateDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	args := m.Called(ctx, d)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID, out *models.WebhookDelivery) error {
+	args := m.Called(ctx, id, out)
+	if args.Error(0) == nil && args.Get(1) != nil {
+		*out = *args.Get(1).(*models.WebhookDelivery)
+	}
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, limit)
+	if v := args.Get(0); v != nil {
+		return v.([]models.WebhookDelivery), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// assignID gives a created delivery its ID, as the database would.
+func assignID(args mock.Arguments) {
+	args.Get(1).(*models.WebhookDelivery).ID = uuid.New()
+}
+
+func TestWebhookService_Publish(t *testing.T) {
+	projectID := uuid.New()
+	e := events.New(events.DeploymentApplied, projectID, uuid.New(), nil)
+
+	t.Run("queues a delivery per subscribed webhook", func(t *testing.T) {
+		repo := &mockWebhookRepository{}
+		svc := NewWebhookService(&mockProjectRepository{}, repo, nil)
+		hooks := []models.Webhook{{ID: uuid.New(), ProjectID: projectID}, {ID: uuid.New(), ProjectID: projectID}}
+		repo.On("ListSubscribed", mock.Anything, projectID, events.DeploymentApplied).Return(hooks, nil)
+		repo.On("CreateDelivery", mock.Anything, mock.Anything).Run(assignID).Return(nil)
+
+		require.NoError(t, svc.Publish(context.Background(), e))
+		repo.AssertNumberOfCalls(t, "CreateDelivery", 2)
+		for i, call := range repo.Calls[1:] {
+			d := call.Arguments.Get(1).(*models.WebhookDelivery)
+			require.Equal(t, hooks[i].ID, d.WebhookID)
+			require.Equal(t, e.ID, d.EventID)
+			require.Equal(t, events.DeploymentApplied, d.EventType)
+			require.Equal(t, models.WebhookDeliveryPending, d.Status)
+			require.JSONEq(t, `"`+e.ID+`"`, string(mustJSONField(t, d.Payload, "id")))
+		}
+	})
+
+	t.Run("events nobody subscribed to queue nothing", func(t *testing.T) {
+		repo := &mockWebhookRepository{}
+		svc := NewWebhookService(&mockProjectRepository{}, repo, nil)
+		repo.On("ListSubscribed", mock.Anything, projectID, events.GraphSaved).Return([]models.Webhook{}, nil)
+
+		require.NoError(t, svc.Publish(context.Background(), events.New(events.GraphSaved, projectID, uuid.Nil, nil)))
+		repo.AssertNotCalled(t, "CreateDelivery", mock.Anything, mock.Anything)
+	})
+
+	t.Run("events without a project are ignored", func(t *testing.T) {
+		repo := &mockWebhookRepository{}
+		svc := NewWebhookService(&mockProjectRepository{}, repo, nil)
+
+		require.NoError(t, svc.Publish(context.Background(), events.Event{ID: uuid.NewString(), Type: events.DeploymentApplied}))
+		repo.AssertNotCalled(t, "ListSubscribed", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWebhookService_Deliver(t *testing.T) {
+	projectID := uuid.New()
+	var status int
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	hook := &models.Webhook{ID: uuid.New(), ProjectID: projectID, URL: srv.URL, Secret: "whsec_test", Enabled: true}
+	pending := func() *models.WebhookDelivery {
+		return &models.WebhookDelivery{
+			ID:        uuid.New(),
+			WebhookID: hook.ID,
+			ProjectID: projectID,
+			EventID:   "evt-1",
+			EventType: events.DeploymentFailed,
+			Payload:   datatypes.JSON(`{"id":"evt-1"}`),
+			Status:    models.WebhookDeliveryPending,
+			Attempts:  2,
+		}
+	}
+	newService := func(d *models.WebhookDelivery, w *models.Webhook) (*webhookService, *mockWebhookRepository) {
+		repo := &mockWebhookRepository{}
+		repo.On("GetDelivery", mock.Anything, d.ID, mock.Anything).Return(nil, d)
+		repo.On("Get", mock.Anything, projectID, w.ID, mock.Anything).Return(nil, w)
+		repo.On("UpdateDelivery", mock.Anything, mock.Anything).Return(nil)
+		svc := NewWebhookService(&mockProjectRepository{}, repo, nil).(*webhookService)
+		// the test server listens on loopback, which the default client refuses
+		svc.client = srv.Client()
+		return svc, repo
+	}
+	updated := func(repo *mockWebhookRepository) *models.WebhookDelivery {
+		call := repo.Calls[len(repo.Calls)-1]
+		require.Equal(t, "UpdateDelivery", call.Method)
+		return call.Arguments.Get(1).(*models.WebhookDelivery)
+	}
+
+	t.Run("success is signed and recorded", func(t *testing.T) {
+		status = http.StatusNoContent
+		d := pending()
+		svc, repo := newService(d, hook)
+
+		require.NoError(t, svc.Deliver(context.Background(), d.ID, false))
+		out := updated(repo)
+		require.Equal(t, models.WebhookDeliverySucceeded, out.Status)
+		require.Equal(t, 3, out.Attempts)
+		require.Equal(t, http.StatusNoContent, out.ResponseStatus)
+		require.NotNil(t, out.DeliveredAt)
+		require.Equal(t, d.ID.String(), got.Header.Get(events.HeaderDelivery))
+		require.Equal(t, "evt-1", got.Header.Get(events.HeaderIdempotency))
+		require.True(t, events.Verify("whsec_test", got.Header.Get(events.HeaderTimestamp), got.Header.Get(events.HeaderSignature), d.Payload, time.Minute, time.Now()))
+	})
+
+	t.Run("failed attempt stays pending for a retry", func(t *testing.T) {
+		status = http.StatusBadGateway
+		d := pending()
+		svc, repo := newService(d, hook)
+
+		require.EqualError(t, svc.Deliver(context.Background(), d.ID, false), "webhook returned status 502")
+		out := updated(repo)
+		require.Equal(t, models.WebhookDeliveryPending, out.Status)
+		require.Equal(t, 3, out.Attempts)
+		require.Equal(t, "webhook returned status 502", out.Error)
+	})
+
+	t.Run("failed last attempt marks the delivery failed", func(t *testing.T) {
+		status = http.StatusBadGateway
+		d := pending()
+		svc, repo := newService(d, hook)
+
+		require.NoError(t, svc.Deliver(context.Background(), d.ID, true))
+		out := updated(repo)
+		require.Equal(t, models.WebhookDeliveryFailed, out.Status)
+		require.Equal(t, 3, out.Attempts)
+		require.Equal(t, http.StatusBadGateway, out.ResponseStatus)
+		require.Equal(t, "webhook returned status 502", out.Error)
+	})
+
+	t.Run("disabled webhook fails the delivery unsent", func(t *testing.T) {
+		got = nil
+		d := pending()
+		disabled := *hook
+		disabled.Enabled = false
+		svc, repo := newService(d, &disabled)
+
+		require.NoError(t, svc.Deliver(context.Background(), d.ID, false))
+		out := updated(repo)
+		require.Equal(t, models.WebhookDeliveryFailed, out.Status)
+		require.Equal(t, "webhook is disabled", out.Error)
+		require.Equal(t, 2, out.Attempts)
+		require.Nil(t, got)
+	})
+
+	t.Run("finished deliveries are not sent again", func(t *testing.T) {
+		got = nil
+		d := pending()
+		d.Status = models.WebhookDeliverySucceeded
+		svc, repo := newService(d, hook)
+
+		require.NoError(t, svc.Deliver(context.Background(), d.ID, false))
+		repo.AssertNotCalled(t, "UpdateDelivery", mock.Anything, mock.Anything)
+		require.Nil(t, got)
+	})
+}
+
+func TestWebhookService_Redeliver(t *testing.T) {
+	userID := uuid.New()
+	projectRepo := &mockProjectRepository{}
+	p := ownedProject(projectRepo, userID)
+	hook := &models.Webhook{ID: uuid.New(), ProjectID: p.ID, Enabled: true}
+	prev := &models.WebhookDelivery{
+		ID:        uuid.New(),
+		WebhookID: hook.ID,
+		ProjectID: p.ID,
+		EventID:   "evt-1",
+		EventType: events.DeploymentApplied,
+		Payload:   datatypes.JSON(`{"id":"evt-1"}`),
+		Status:    models.WebhookDeliveryFailed,
+		Attempts:  9,
+		Error:     "webhook returned status 500",
+	}
+
+	t.Run("new delivery of the same event", func(t *testing.T) {
+		repo := &mockWebhookRepository{}
+		repo.On("Get", mock.Anything, p.ID, hook.ID, mock.Anything).Return(nil, hook)
+		repo.On("GetDelivery", mock.Anything, prev.ID, mock.Anything).Return(nil, prev)
+		repo.On("CreateDelivery", mock.Anything, mock.Anything).Run(assignID).Return(nil)
+		svc := NewWebhookService(projectRepo, repo, nil)
+
+		d, err := svc.Redeliver(context.Background(), p.ID, hook.ID, prev.ID, userID)
+		require.NoError(t, err)
+		require.NotEqual(t, prev.ID, d.ID)
+		require.NotEqual(t, uuid.Nil, d.ID)
+		require.Equal(t, prev.EventID, d.EventID)
+		require.Equal(t, prev.EventType, d.EventType)
+		require.Equal(t, prev.Payload, d.Payload)
+		require.Equal(t, models.WebhookDeliveryPending, d.Status)
+		require.Zero(t, d.Attempts)
+		require.Empty(t, d.Error)
+		require.Equal(t, prev.ID, *d.RedeliveryOf)
+	})
+
+	t.Run("disabled webhook is refused", func(t *testing.T) {
+		disabled := *hook
+		disabled.Enabled = false
+		repo := &mockWebhookRepository{}
+		repo.On("Get", mock.Anything, p.ID, hook.ID, mock.Anything).Return(nil, &disabled)
+		svc := NewWebhookService(projectRepo, repo, nil)
+
+		_, err := svc.Redeliver(context.Background(), p.ID, hook.ID, prev.ID, userID)
+		require.True(t, appErr.IsCode(err, appErr.CodeConflict))
+		repo.AssertNotCalled(t, "CreateDelivery", mock.Anything, mock.Anything)
+	})
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	require.NoError(t, validateWebhookURL("https://ci.example.com/hooks/iac"))
+	for _, raw := range []string{"ftp://ci.example.com/x", "/hooks", "http://localhost:9000/x", "http://127.0.0.1/x", "http://192.168.0.10/x", "http://169.254.169.254/latest/meta-data"} {
+		require.True(t, appErr.IsCode(validateWebhookURL(raw), appErr.CodeInvalid), raw)
+	}
+}
+
+func mustJSONField(t *testing.T, raw datatypes.JSON, key string) json.RawMessage {
+	t.Helper()
+	var m map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(raw, &m))
+	return m[key]
+}
@@ -0,0 +1,15 @@
+-- state_versions keeps every Terraform state written for a deployment so a
+-- bad write can be rolled back instead of overwriting deployments.terraform_state.
+CREATE TABLE IF NOT EXISTS state_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    serial INT NOT NULL,
+    lineage VARCHAR(64) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    size INT NOT NULL,
+    author VARCHAR(128) NOT NULL,
+    restored_from INT,
+    state JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_state_versions_deployment_serial ON state_versions(deployment_id, serial);
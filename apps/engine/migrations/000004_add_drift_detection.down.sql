@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS drift_reports;
+ALTER TABLE projects DROP COLUMN IF EXISTS drift_schedule;
+ALTER TABLE deployments DROP COLUMN IF EXISTS drift_checked_at;
+ALTER TABLE deployments DROP COLUMN IF EXISTS drift_status;
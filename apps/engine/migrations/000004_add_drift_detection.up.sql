@@ -0,0 +1,16 @@
+-- Drift detection: every scheduled check of an applied deployment writes a
+-- drift report, and the latest result is mirrored on the deployment.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS drift_status VARCHAR(16) NOT NULL DEFAULT 'unknown';
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS drift_checked_at TIMESTAMPTZ;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS drift_schedule VARCHAR(32);
+
+CREATE TABLE IF NOT EXISTS drift_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL,
+    resource_count INT NOT NULL DEFAULT 0,
+    resources JSONB,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_drift_reports_deployment_created ON drift_reports(deployment_id, created_at DESC);
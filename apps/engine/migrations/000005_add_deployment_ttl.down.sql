@@ -0,0 +1,6 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS environment_ttls;
+DROP INDEX IF EXISTS idx_deployments_expires_at;
+DROP INDEX IF EXISTS idx_deployments_environment;
+ALTER TABLE deployments DROP COLUMN IF EXISTS expiry_warned_at;
+ALTER TABLE deployments DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE deployments DROP COLUMN IF EXISTS environment;
@@ -0,0 +1,9 @@
+-- Ephemeral environments: deployments may expire and are destroyed by the
+-- worker once expires_at passes. Projects carry default TTLs per environment.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS environment VARCHAR(32);
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS expiry_warned_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_deployments_environment ON deployments(environment);
+CREATE INDEX IF NOT EXISTS idx_deployments_expires_at ON deployments(expires_at) WHERE expires_at IS NOT NULL;
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS environment_ttls JSONB;
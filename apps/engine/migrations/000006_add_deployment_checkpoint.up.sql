@@ -0,0 +1,3 @@
+-- Retry-safe provisioning: the worker records the last completed phase so a
+-- retried task resumes instead of re-running a finished apply or destroy.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS checkpoint VARCHAR(16);
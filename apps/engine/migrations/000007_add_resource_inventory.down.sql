@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_resources_deployment_address;
+DROP INDEX IF EXISTS idx_resources_cloud_id;
+DROP INDEX IF EXISTS idx_resources_project;
+ALTER TABLE resources DROP COLUMN IF EXISTS node_id;
+ALTER TABLE resources DROP COLUMN IF EXISTS cloud_id;
+ALTER TABLE resources DROP COLUMN IF EXISTS address;
+ALTER TABLE resources DROP COLUMN IF EXISTS project_id;
@@ -0,0 +1,9 @@
+-- Resource inventory: rows are synced from Terraform state after apply,
+-- destroy and drift refresh, one per managed resource address.
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS project_id UUID REFERENCES projects(id) ON DELETE CASCADE;
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS address VARCHAR(256);
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS cloud_id VARCHAR(256);
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS node_id VARCHAR(128);
+CREATE INDEX IF NOT EXISTS idx_resources_project ON resources(project_id);
+CREATE INDEX IF NOT EXISTS idx_resources_cloud_id ON resources(cloud_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_resources_deployment_address ON resources(deployment_id, address) WHERE deleted_at IS NULL;
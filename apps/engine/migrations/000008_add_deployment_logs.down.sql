@@ -0,0 +1,2 @@
+ALTER TABLE deployments DROP COLUMN IF EXISTS log_seq;
+DROP TABLE IF EXISTS deployment_logs;
@@ -0,0 +1,29 @@
+-- Deployment logs move out of deployments.outputs into their own table,
+-- one row per line numbered by a per-deployment sequence.
+CREATE TABLE IF NOT EXISTS deployment_logs (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    ts TIMESTAMPTZ NOT NULL,
+    level VARCHAR(16) NOT NULL,
+    phase VARCHAR(32),
+    message TEXT NOT NULL,
+    data JSONB
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deployment_logs_deployment_seq ON deployment_logs(deployment_id, seq);
+
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS log_seq BIGINT NOT NULL DEFAULT 0;
+
+-- carry over logs stored in outputs.logs
+INSERT INTO deployment_logs (deployment_id, seq, ts, level, message, data)
+SELECT d.id, l.ord, COALESCE((l.entry->>'timestamp')::timestamptz, d.created_at),
+       COALESCE(l.entry->>'level', 'info'), COALESCE(l.entry->>'message', ''), l.entry->'data'
+FROM deployments d, jsonb_array_elements(d.outputs->'logs') WITH ORDINALITY AS l(entry, ord)
+WHERE jsonb_typeof(d.outputs->'logs') = 'array'
+ON CONFLICT DO NOTHING;
+
+UPDATE deployments d SET log_seq = m.seq
+FROM (SELECT deployment_id, MAX(seq) AS seq FROM deployment_logs GROUP BY deployment_id) m
+WHERE m.deployment_id = d.id;
+
+UPDATE deployments SET outputs = outputs - 'logs' WHERE outputs->'logs' IS NOT NULL;
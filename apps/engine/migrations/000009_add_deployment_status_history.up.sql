@@ -0,0 +1,15 @@
+-- Every deployment status change, with who caused it; phase durations are
+-- derived from consecutive rows.
+CREATE TABLE IF NOT EXISTS deployment_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    from_status VARCHAR(32) NOT NULL,
+    to_status VARCHAR(32) NOT NULL,
+    actor VARCHAR(128) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_deployment_status_history_deployment_id ON deployment_status_history(deployment_id);
+CREATE INDEX IF NOT EXISTS idx_deployment_status_history_created_at ON deployment_status_history(created_at);
+
+-- "completed" was allowed by the old validator but never written by the worker
+UPDATE deployments SET status = 'applied' WHERE status = 'completed';
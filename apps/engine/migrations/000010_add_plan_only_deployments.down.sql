@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_deployments_plan_only;
+ALTER TABLE deployments DROP COLUMN IF EXISTS plan;
+ALTER TABLE deployments DROP COLUMN IF EXISTS base_deployment_id;
+ALTER TABLE deployments DROP COLUMN IF EXISTS plan_only;
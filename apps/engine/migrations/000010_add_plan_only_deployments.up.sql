@@ -0,0 +1,5 @@
+-- Plan-only deployments stop after terraform plan and keep the change set.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS plan_only BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS base_deployment_id UUID REFERENCES deployments(id) ON DELETE SET NULL;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS plan JSONB;
+CREATE INDEX IF NOT EXISTS idx_deployments_plan_only ON deployments(plan_only);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS deployment_approvals;
+ALTER TABLE deployments DROP COLUMN IF EXISTS approved_at;
+ALTER TABLE deployments DROP COLUMN IF EXISTS created_by;
+ALTER TABLE projects DROP COLUMN IF EXISTS approval_rules;
@@ -0,0 +1,15 @@
+-- Four-eyes control: deployments of projects with approval rules wait at
+-- awaiting_approval until enough approvers other than the author approve.
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS approval_rules JSONB;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS created_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS approved_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS deployment_approvals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    decision VARCHAR(16) NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deployment_approvals_deployment_user ON deployment_approvals(deployment_id, user_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS policy_findings;
+DROP TABLE IF EXISTS policy_rules;
+ALTER TABLE deployments DROP COLUMN IF EXISTS policy_status;
+ALTER TABLE projects DROP COLUMN IF EXISTS policy_settings;
@@ -0,0 +1,34 @@
+-- Policy as code: custom rules per project or account, built-in rule
+-- settings per project, and the findings of each deployment's evaluation.
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS policy_settings JSONB;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS policy_status VARCHAR(16);
+
+CREATE TABLE IF NOT EXISTS policy_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    project_id UUID REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    description TEXT,
+    enforcement VARCHAR(16) NOT NULL,
+    definition JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_policy_rules_owner_id ON policy_rules(owner_id);
+CREATE INDEX IF NOT EXISTS idx_policy_rules_project_id ON policy_rules(project_id);
+CREATE INDEX IF NOT EXISTS idx_policy_rules_deleted_at ON policy_rules(deleted_at);
+
+CREATE TABLE IF NOT EXISTS policy_findings (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    rule_id VARCHAR(64) NOT NULL,
+    rule_name VARCHAR(128) NOT NULL,
+    enforcement VARCHAR(16) NOT NULL,
+    source VARCHAR(16) NOT NULL,
+    node_id VARCHAR(255),
+    address VARCHAR(512),
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_policy_findings_deployment_id ON policy_findings(deployment_id);
@@ -0,0 +1,2 @@
+-- Estimated monthly cost of the graph a deployment was created from.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS cost_estimate JSONB;
@@ -0,0 +1,3 @@
+-- Non-blocking warnings raised when a deployment is created, such as a
+-- large cost increase over the previous graph version.
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS warnings JSONB;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS budget_overrides;
+ALTER TABLE projects DROP COLUMN IF EXISTS budget;
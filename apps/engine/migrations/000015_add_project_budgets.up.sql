@@ -0,0 +1,17 @@
+-- Project budgets and the audit trail of deployments created over a soft
+-- budget.
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS budget JSONB;
+
+CREATE TABLE IF NOT EXISTS budget_overrides (
+    id BIGSERIAL PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    monthly_limit DOUBLE PRECISION NOT NULL,
+    estimated_cost DOUBLE PRECISION NOT NULL,
+    currency VARCHAR(8) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_budget_overrides_project_id ON budget_overrides(project_id);
+CREATE INDEX IF NOT EXISTS idx_budget_overrides_deployment_id ON budget_overrides(deployment_id);
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_recommendations_status;
+DROP INDEX IF EXISTS idx_recommendations_source;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS applied_version;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS snoozed_until;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS fingerprint;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS patch;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS node_ids;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS description;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS status;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS severity;
+ALTER TABLE recommendations DROP COLUMN IF EXISTS source;
@@ -0,0 +1,30 @@
+-- Recommendation lifecycle: typed source, severity and status, affected
+-- nodes and an applicable graph patch. Rows of the security analyzer are
+-- backfilled from their details.
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS source VARCHAR(16);
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS severity VARCHAR(16);
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'open';
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS node_ids JSONB;
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS patch JSONB;
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(255);
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMPTZ;
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS applied_version INTEGER;
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE recommendations SET
+    source = COALESCE(details->>'source', 'security'),
+    severity = COALESCE(details->>'severity', 'medium'),
+    description = details->>'rationale',
+    fingerprint = details->>'fingerprint',
+    node_ids = CASE WHEN details->>'node_id' IS NOT NULL THEN jsonb_build_array(details->>'node_id') ELSE '[]'::jsonb END,
+    patch = CASE WHEN jsonb_typeof(details->'patch') = 'object' AND details->>'node_id' IS NOT NULL
+        THEN jsonb_build_array(jsonb_build_object('node_id', details->>'node_id', 'set', details->'patch', 'reason', details->>'rationale'))
+        END,
+    updated_at = created_at
+WHERE source IS NULL;
+
+ALTER TABLE recommendations ALTER COLUMN source SET NOT NULL;
+ALTER TABLE recommendations ALTER COLUMN severity SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_recommendations_source ON recommendations(source);
+CREATE INDEX IF NOT EXISTS idx_recommendations_status ON recommendations(status);
@@ -0,0 +1,20 @@
+-- Anomalies found in the deployment history: slow phases, failure bursts
+-- on a provider/region and repeated errors.
+CREATE TABLE IF NOT EXISTS anomalies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    deployment_id UUID REFERENCES deployments(id) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL,
+    severity VARCHAR(16) NOT NULL,
+    provider VARCHAR(32),
+    region VARCHAR(64),
+    fingerprint VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    details JSONB,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_anomalies_fingerprint ON anomalies(fingerprint);
+CREATE INDEX IF NOT EXISTS idx_anomalies_project_id ON anomalies(project_id);
+CREATE INDEX IF NOT EXISTS idx_anomalies_deployment_id ON anomalies(deployment_id);
+CREATE INDEX IF NOT EXISTS idx_anomalies_kind ON anomalies(kind);
+CREATE INDEX IF NOT EXISTS idx_anomalies_detected_at ON anomalies(detected_at);
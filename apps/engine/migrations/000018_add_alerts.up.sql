@@ -0,0 +1,43 @@
+-- Project alerting: channels alerts are sent to, rules routing triggers to
+-- channels, and the delivery log used for dedupe and rate limits.
+CREATE TABLE IF NOT EXISTS alert_channels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    config JSONB NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_alert_channels_project_id ON alert_channels(project_id);
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    trigger VARCHAR(32) NOT NULL,
+    after_minutes INTEGER NOT NULL DEFAULT 0,
+    channel_ids JSONB NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_project_id ON alert_rules(project_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_trigger ON alert_rules(trigger);
+
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    channel_id UUID NOT NULL REFERENCES alert_channels(id) ON DELETE CASCADE,
+    rule_id UUID REFERENCES alert_rules(id) ON DELETE SET NULL,
+    trigger VARCHAR(32) NOT NULL,
+    dedupe_key VARCHAR(255) NOT NULL,
+    title TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_deliveries_channel_key ON alert_deliveries(channel_id, dedupe_key);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_project_id ON alert_deliveries(project_id);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_created_at ON alert_deliveries(created_at);
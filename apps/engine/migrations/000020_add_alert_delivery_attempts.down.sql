@@ -0,0 +1,2 @@
+ALTER TABLE alert_deliveries DROP COLUMN IF EXISTS payload;
+ALTER TABLE alert_deliveries DROP COLUMN IF EXISTS attempts;
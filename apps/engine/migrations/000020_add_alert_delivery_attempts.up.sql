@@ -0,0 +1,4 @@
+-- Alert deliveries are sent by a queued task and retried: the alert is kept
+-- with the delivery and its attempts counted.
+ALTER TABLE alert_deliveries ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alert_deliveries ADD COLUMN IF NOT EXISTS payload JSONB;
@@ -0,0 +1,3 @@
+-- Fails while any secret is still sealed: sealed secrets are longer than
+-- 128 characters.
+ALTER TABLE webhooks ALTER COLUMN secret TYPE VARCHAR(128);
@@ -0,0 +1,3 @@
+-- Webhook signing secrets are stored sealed by the encryption keyring; the
+-- envelope does not fit the old column. cmd/migrate seals existing rows.
+ALTER TABLE webhooks ALTER COLUMN secret TYPE TEXT;
@@ -0,0 +1 @@
+ALTER TABLE recommendations DROP COLUMN IF EXISTS graph_version;
@@ -0,0 +1,3 @@
+-- Recommendations remember the graph version their patch was made against,
+-- so applying one refuses a graph that changed since.
+ALTER TABLE recommendations ADD COLUMN IF NOT EXISTS graph_version INTEGER NOT NULL DEFAULT 0;
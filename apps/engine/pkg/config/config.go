@@ -34,6 +34,56 @@ type Config struct {
 	// per-deployment working directory (e.g. terraform execution). If empty
 	// the system temp dir will be used.
 	WorkingDir string `mapstructure:"WORKING_DIR"`
+
+	// Master keys for encrypting Terraform state and cloud credentials at
+	// rest, given inline as "id=base64key,..." and/or in a file with one
+	// "id=base64key" per line. New data is sealed with EncryptionPrimaryKey
+	// (the first key listed when empty). Encryption is off when no key is set.
+	EncryptionKeys       string `mapstructure:"ENCRYPTION_KEYS"`
+	EncryptionKeysFile   string `mapstructure:"ENCRYPTION_KEYS_FILE"`
+	EncryptionPrimaryKey string `mapstructure:"ENCRYPTION_PRIMARY_KEY"`
+
+	// DriftScanInterval is how often the worker looks for deployments due a
+	// drift check. DriftDefaultSchedule applies to projects without their own
+	// schedule: a duration such as "24h", or "off".
+	DriftScanInterval    time.Duration `mapstructure:"DRIFT_SCAN_INTERVAL"`
+	DriftDefaultSchedule string        `mapstructure:"DRIFT_DEFAULT_SCHEDULE"`
+
+	// TTLWarningLead is how long before an ephemeral deployment expires the
+	// deployment.expiring event is published. EventWebhookURL, when set,
+	// receives every event as a JSON POST.
+	TTLWarningLead  time.Duration `mapstructure:"TTL_WARNING_LEAD"`
+	EventWebhookURL string        `mapstructure:"EVENT_WEBHOOK_URL" validate:"omitempty,url"`
+
+	// AnomalyScanInterval is how often the worker checks the deployment
+	// history for anomalies; 0 disables the scan.
+	AnomalyScanInterval time.Duration `mapstructure:"ANOMALY_SCAN_INTERVAL"`
+
+	// Alerting: a channel gets an alert once per AlertDedupeWindow and at
+	// most AlertRateLimit alerts an hour. Email channels are sent through
+	// the SMTP server, which is off when SMTPHost is empty.
+	AlertDedupeWindow time.Duration `mapstructure:"ALERT_DEDUPE_WINDOW"`
+	AlertRateLimit    int           `mapstructure:"ALERT_RATE_LIMIT" validate:"gte=1,lte=10000"`
+	SMTPHost          string        `mapstructure:"SMTP_HOST"`
+	SMTPPort          int           `mapstructure:"SMTP_PORT" validate:"gte=1,lte=65535"`
+	SMTPUsername      string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword      string        `mapstructure:"SMTP_PASSWORD"`
+	SMTPFrom          string        `mapstructure:"SMTP_FROM" validate:"omitempty,email"`
+
+	// PriceCatalogDir holds the JSON and CSV price files cost estimates
+	// are computed from. The catalog built into the binary is used when
+	// empty.
+	PriceCatalogDir string `mapstructure:"PRICE_CATALOG_DIR"`
+
+	// LLM settings for the AI features, which are off when LLMProvider is
+	// empty. LLMBaseURL points the openai provider at an OpenAI-compatible
+	// server, e.g. a self-hosted model.
+	LLMProvider   string        `mapstructure:"LLM_PROVIDER" validate:"omitempty,oneof=anthropic openai"`
+	LLMAPIKey     string        `mapstructure:"LLM_API_KEY"`
+	LLMBaseURL    string        `mapstructure:"LLM_BASE_URL" validate:"omitempty,url"`
+	LLMModel      string        `mapstructure:"LLM_MODEL"`
+	LLMTimeout    time.Duration `mapstructure:"LLM_TIMEOUT"`
+	LLMMaxRetries int           `mapstructure:"LLM_MAX_RETRIES" validate:"gte=0,lte=10"`
 }
 
 var (
@@ -67,6 +117,15 @@ func Load() (*Config, error) {
 	v.SetDefault("ASYNQ_CONCURRENCY", 10)
 	v.SetDefault("GOMAXPROCS", 0)
 	v.SetDefault("WORKING_DIR", "")
+	v.SetDefault("DRIFT_SCAN_INTERVAL", "5m")
+	v.SetDefault("DRIFT_DEFAULT_SCHEDULE", "24h")
+	v.SetDefault("TTL_WARNING_LEAD", "1h")
+	v.SetDefault("ANOMALY_SCAN_INTERVAL", "10m")
+	v.SetDefault("ALERT_DEDUPE_WINDOW", "1h")
+	v.SetDefault("ALERT_RATE_LIMIT", 20)
+	v.SetDefault("SMTP_PORT", 587)
+	v.SetDefault("LLM_TIMEOUT", "2m")
+	v.SetDefault("LLM_MAX_RETRIES", 2)
 
 	// Optional config file
 	_ = v.ReadInConfig()
@@ -84,6 +143,28 @@ func Load() (*Config, error) {
 		"ASYNQ_CONCURRENCY",
 		"GOMAXPROCS",
 		"WORKING_DIR",
+		"ENCRYPTION_KEYS",
+		"ENCRYPTION_KEYS_FILE",
+		"ENCRYPTION_PRIMARY_KEY",
+		"DRIFT_SCAN_INTERVAL",
+		"DRIFT_DEFAULT_SCHEDULE",
+		"TTL_WARNING_LEAD",
+		"EVENT_WEBHOOK_URL",
+		"ANOMALY_SCAN_INTERVAL",
+		"ALERT_DEDUPE_WINDOW",
+		"ALERT_RATE_LIMIT",
+		"SMTP_HOST",
+		"SMTP_PORT",
+		"SMTP_USERNAME",
+		"SMTP_PASSWORD",
+		"SMTP_FROM",
+		"PRICE_CATALOG_DIR",
+		"LLM_PROVIDER",
+		"LLM_API_KEY",
+		"LLM_BASE_URL",
+		"LLM_MODEL",
+		"LLM_TIMEOUT",
+		"LLM_MAX_RETRIES",
 	}
 	for _, key := range keys {
 		_ = v.BindEnv(key)
@@ -102,6 +183,41 @@ func Load() (*Config, error) {
 		}
 		c.ShutdownTimeout = d
 	}
+	if s := v.GetString("DRIFT_SCAN_INTERVAL"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DRIFT_SCAN_INTERVAL: %w", err)
+		}
+		c.DriftScanInterval = d
+	}
+	if s := v.GetString("TTL_WARNING_LEAD"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL_WARNING_LEAD: %w", err)
+		}
+		c.TTLWarningLead = d
+	}
+	if s := v.GetString("ANOMALY_SCAN_INTERVAL"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ANOMALY_SCAN_INTERVAL: %w", err)
+		}
+		c.AnomalyScanInterval = d
+	}
+	if s := v.GetString("ALERT_DEDUPE_WINDOW"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALERT_DEDUPE_WINDOW: %w", err)
+		}
+		c.AlertDedupeWindow = d
+	}
+	if s := v.GetString("LLM_TIMEOUT"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LLM_TIMEOUT: %w", err)
+		}
+		c.LLMTimeout = d
+	}
 
 	if err := validate.Struct(&c); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
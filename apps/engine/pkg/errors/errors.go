@@ -19,6 +19,8 @@ const (
 	CodeUnavailable   Code = "unavailable"
 	CodeDeadline      Code = "deadline_exceeded"
 	CodeAlreadyExists Code = "already_exists"
+	// CodeBudgetExceeded rejects work whose estimated cost is over budget.
+	CodeBudgetExceeded Code = "budget_exceeded"
 )
 
 // AppError is a structured error type that carries a code, message, and optional metadata.
@@ -1,5 +1,247 @@
-//go:build ignore
-
 package utils
 
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvelopeAlgorithm identifies the scheme used by Seal.
+const EnvelopeAlgorithm = "aes-256-gcm"
+
+var (
+	ErrNoKeyring  = errors.New("encryption keyring not configured")
+	ErrUnknownKey = errors.New("unknown encryption key id")
+)
+
+// Envelope is the serialized form of sealed data. Each value gets its own
+// random data key, which is stored wrapped by the master key named in KeyID.
+type Envelope struct {
+	Algorithm  string `json:"alg"`
+	KeyID      string `json:"kid"`
+	WrappedKey string `json:"dek"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ct"`
+}
+
+// Keyring holds the master keys used to wrap data keys. New values are
+// always sealed with the primary key; any known key can open a value.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring builds a keyring from 32-byte master keys indexed by key ID.
+func NewKeyring(keys map[string][]byte, primary string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring needs at least one key")
+	}
+	for id, k := range keys {
+		if id == "" {
+			return nil, errors.New("empty encryption key id")
+		}
+		if len(k) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, got %d", id, len(k))
+		}
+	}
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("%w: primary %q", ErrUnknownKey, primary)
+	}
+	return &Keyring{primary: primary, keys: keys}, nil
+}
+
+// LoadKeyring builds a keyring from an inline spec ("id=base64key,...") and/or
+// a key file with one "id=base64key" per line. The primary defaults to the
+// first key listed. It returns nil without error when no key is configured.
+func LoadKeyring(spec, file, primary string) (*Keyring, error) {
+	var entries []string
+	for _, e := range strings.Split(spec, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			entries = append(entries, e)
+		}
+	}
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("open key file: %w", err)
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		id, enc, ok := strings.Cut(e, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid key entry %q: expected id=base64key", id)
+		}
+		id = strings.TrimSpace(id)
+		k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc))
+		if err != nil {
+			return nil, fmt.Errorf("decode key %q: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("duplicate encryption key id %q", id)
+		}
+		keys[id] = k
+		if primary == "" {
+			primary = id
+		}
+	}
+	return NewKeyring(keys, primary)
+}
+
+// PrimaryKeyID returns the ID of the key used for new values.
+func (k *Keyring) PrimaryKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// Seal encrypts plaintext under a fresh data key and returns the JSON
+// envelope. A nil keyring returns plaintext unchanged so encryption can be
+// left disabled in development.
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	wrapped, err := gcmSeal(k.keys[k.primary], dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	nonce, ct, err := gcmSealParts(dek, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	return json.Marshal(Envelope{
+		Algorithm:  EnvelopeAlgorithm,
+		KeyID:      k.primary,
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(ct),
+	})
+}
+
+// Open decrypts a value produced by Seal. Data that is not an envelope is
+// returned as-is, which lets rows written before encryption be read.
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	env, ok := ParseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+	master, ok := k.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, env.KeyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode data key: %w", err)
+	}
+	dek, err := gcmOpen(master, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("decode nonce: %w", err)
+	}
+	ct, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decode ciphertext: %w", err)
+	}
+	pt, err := gcmOpen(dek, append(nonce, ct...))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return pt, nil
+}
+
+// NeedsRotation reports whether data is plaintext or sealed with a key other
+// than the primary one.
+func (k *Keyring) NeedsRotation(data []byte) bool {
+	if k == nil {
+		return false
+	}
+	env, ok := ParseEnvelope(data)
+	return !ok || env.KeyID != k.primary
+}
+
+// ParseEnvelope decodes data as an Envelope, reporting false if it is not one.
+func ParseEnvelope(data []byte) (*Envelope, bool) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, false
+	}
+	if env.Algorithm != EnvelopeAlgorithm || env.KeyID == "" || env.Ciphertext == "" {
+		return nil, false
+	}
+	return &env, true
+}
+
+// gcmSeal encrypts with a random nonce prepended to the ciphertext.
+func gcmSeal(key, plaintext []byte) ([]byte, error) {
+	nonce, ct, err := gcmSealParts(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ct...), nil
+}
+
+func gcmSealParts(key, plaintext []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
 
+func gcmOpen(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	kr, err := NewKeyring(map[string][]byte{"k1": testKey(t)}, "k1")
+	if err != nil {
+		t.Fatalf("new keyring: %v", err)
+	}
+
+	plain := []byte(`{"version":4,"resources":[{"password":"hunter2"}]}`)
+	sealed, err := kr.Seal(plain)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("hunter2")) {
+		t.Fatal("sealed data contains plaintext")
+	}
+	env, ok := ParseEnvelope(sealed)
+	if !ok || env.KeyID != "k1" {
+		t.Fatalf("expected envelope with kid k1, got %+v", env)
+	}
+
+	got, err := kr.Open(sealed)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("round trip mismatch: %s", got)
+	}
+
+	// plaintext written before encryption was enabled passes through
+	got, err = kr.Open(plain)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("expected plaintext passthrough, got %s, %v", got, err)
+	}
+}
+
+func TestKeyringOpenTampered(t *testing.T) {
+	kr, _ := NewKeyring(map[string][]byte{"k1": testKey(t)}, "k1")
+	sealed, _ := kr.Seal([]byte("secret"))
+	env, _ := ParseEnvelope(sealed)
+
+	ct, _ := base64.StdEncoding.DecodeString(env.Ciphertext)
+	ct[0] ^= 0xff
+	env.Ciphertext = base64.StdEncoding.EncodeToString(ct)
+	tampered := []byte(`{"alg":"` + env.Algorithm + `","kid":"` + env.KeyID + `","dek":"` + env.WrappedKey + `","nonce":"` + env.Nonce + `","ct":"` + env.Ciphertext + `"}`)
+
+	if _, err := kr.Open(tampered); err == nil {
+		t.Fatal("expected tampered ciphertext to fail authentication")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+	oldRing, _ := NewKeyring(map[string][]byte{"old": oldKey}, "old")
+	sealed, _ := oldRing.Seal([]byte("secret"))
+
+	ring, err := NewKeyring(map[string][]byte{"old": oldKey, "new": newKey}, "new")
+	if err != nil {
+		t.Fatalf("new keyring: %v", err)
+	}
+	if !ring.NeedsRotation(sealed) {
+		t.Fatal("value sealed with old key should need rotation")
+	}
+	if !ring.NeedsRotation([]byte("plaintext")) {
+		t.Fatal("plaintext should need rotation")
+	}
+
+	plain, err := ring.Open(sealed)
+	if err != nil {
+		t.Fatalf("open with old key: %v", err)
+	}
+	resealed, _ := ring.Seal(plain)
+	if ring.NeedsRotation(resealed) {
+		t.Fatal("resealed value should use the primary key")
+	}
+
+	newOnly, _ := NewKeyring(map[string][]byte{"new": newKey}, "new")
+	if _, err := newOnly.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	kr, err := LoadKeyring("", "", "")
+	if err != nil || kr != nil {
+		t.Fatalf("expected nil keyring when unconfigured, got %v, %v", kr, err)
+	}
+
+	k1 := base64.StdEncoding.EncodeToString(testKey(t))
+	k2 := base64.StdEncoding.EncodeToString(testKey(t))
+	file := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(file, []byte("# rotated 2026-10\nk2="+k2+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	kr, err = LoadKeyring("k1="+k1, file, "")
+	if err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	if kr.PrimaryKeyID() != "k1" {
+		t.Fatalf("expected first key to be primary, got %s", kr.PrimaryKeyID())
+	}
+
+	kr, err = LoadKeyring("k1="+k1, file, "k2")
+	if err != nil || kr.PrimaryKeyID() != "k2" {
+		t.Fatalf("expected k2 primary, got %v, %v", kr, err)
+	}
+
+	if _, err := LoadKeyring("k1=c2hvcnQ=", "", ""); err == nil {
+		t.Fatal("expected short key to be rejected")
+	}
+}
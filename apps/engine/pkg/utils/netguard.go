@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for outbound requests to loopback, private,
+// link-local and other internal addresses.
+var ErrBlockedAddress = errors.New("destination address is not allowed")
+
+// blockedPrefixes are the ranges outside the ones netip classifies that user
+// supplied URLs must not reach: shared (carrier-grade NAT) and benchmarking
+// space, and IPv4-mapped and NAT64 forms of IPv4 addresses.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// IsPublicIP reports whether ip is a global unicast address outside the
+// private, loopback and link-local ranges; 169.254.169.254, the cloud
+// metadata endpoint, is link-local.
+func IsPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPublicHost rejects hosts that name an internal address without a
+// lookup: localhost and IP literals that are not public. Names that resolve
+// to internal addresses are caught when dialing; see SafeDialContext.
+func CheckPublicHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrBlockedAddress
+	}
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil && !IsPublicIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// SafeDialContext dials like d but refuses connections to addresses that
+// are not public. The check runs on the address actually connected to,
+// after the lookup, so a name cannot be rebound to an internal address
+// between validation and the request.
+func SafeDialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	guarded := *d
+	guarded.Control = func(network, address string, _ syscall.RawConn) error {
+		ap, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+		}
+		if !IsPublicIP(ap.Addr()) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, ap.Addr())
+		}
+		return nil
+	}
+	return guarded.DialContext
+}
+
+// NewSafeHTTPClient returns a client for user supplied URLs: it only
+// connects to public addresses, ignores proxy settings, which would make
+// the proxy's address the one checked, and gives up after timeout.
+func NewSafeHTTPClient(timeout time.Duration) *http.Client {
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           SafeDialContext(&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// every hop is dialed through the guard; cap them like the default
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:10.0.0.1":  false,
+		"64:ff9b::a00:1":   false,
+		"224.0.0.1":        false,
+		"255.255.255.255":  false,
+	}
+	for addr, want := range cases {
+		if got := IsPublicIP(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	for _, host := range []string{"localhost", "LOCALHOST.", "api.localhost", "127.0.0.1", "[::1]", "169.254.169.254", "10.0.0.8"} {
+		if err := CheckPublicHost(host); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("CheckPublicHost(%q) = %v, want ErrBlockedAddress", host, err)
+		}
+	}
+	for _, host := range []string{"hooks.example.com", "8.8.8.8", "[2606:4700::1111]"} {
+		if err := CheckPublicHost(host); err != nil {
+			t.Errorf("CheckPublicHost(%q) = %v", host, err)
+		}
+	}
+}
+
+func TestSafeHTTPClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	_, err := NewSafeHTTPClient(time.Second).Do(req)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("request to %s: got %v, want ErrBlockedAddress", srv.URL, err)
+	}
+}